@@ -1,19 +1,96 @@
 package www
 
 import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"strings"
 )
 
 const (
 	authHeader = "Authorization"
+
+	SchemeBasic  = "Basic"
+	SchemeBearer = "Bearer"
 )
 
+// Authenticator Verify the credentials in the Authorization header of a
+// request. Basic credentials are checked against Users, and Bearer tokens
+// that look like a JWT are checked by JWT, otherwise they are looked up in
+// Tokens. Leave any of them nil to turn that kind of credential off.
+type Authenticator struct {
+	// Realm Sent to the client in the WWW-Authenticate challenge.
+	Realm  string
+	Users  UserStore
+	Tokens TokenStore
+	JWT    *JWTVerifier
+}
+
+// AuthError Returned when a request could not be authenticated, it tells
+// Challenge what went wrong.
+type AuthError struct {
+	// Scheme The scheme the client tried, or empty when no credentials were
+	// sent.
+	Scheme string
+	msg    string
+}
+
+func (e *AuthError) Error() string {
+	return e.msg
+}
+
+// Identity Who made a request, made available to handlers through the
+// request context, see IdentityFromContext.
+type Identity struct {
+	// Subject The username, account ID or JWT "sub" claim.
+	Subject string
+	// Scheme That was used to authenticate, Basic or Bearer.
+	Scheme string
+	Scopes []string
+	// Claims Of the JWT, when one was used.
+	Claims map[string]any
+}
+
+// StaticUsers A UserStore backed by a map of usernames to users, handy for a
+// few admin accounts loaded from the environment.
+type StaticUsers map[string]*User
+
+// TokenStore Looks up a bearer token that is not a JWT, like an API key.
+// Return a nil Identity when the token is unknown.
+type TokenStore interface {
+	LookupToken(token string) (*Identity, error)
+}
+
+// User A set of credentials for Basic authentication.
+type User struct {
+	Username string
+	// PasswordHash Made by HashPassword or HashPasswordBcrypt, never the
+	// plain password.
+	PasswordHash string
+	Scopes       []string
+}
+
+// UserStore Looks up users for Basic authentication. Return a nil User when
+// the username is unknown.
+type UserStore interface {
+	LookupUser(username string) (*User, error)
+}
+
+type identityKey struct{}
+
 // Authenticate Challenge user access.
 //
 //	Expected format = "Basic " + base64("username:password")
 //	In Go  for example example:
 //	  auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
+//
+// Deprecated: This compares the header to a single shared secret in the
+// environment. Use an Authenticator, which verifies hashed passwords per user
+// and bearer tokens.
 func Authenticate(headers map[string]string) error {
 	headerVal := GetHeader(headers, authHeader)
 	if headerVal == "" {
@@ -25,9 +102,158 @@ func Authenticate(headers map[string]string) error {
 		return fmt.Errorf("%v", Stderr.AuthCodeNotSet)
 	}
 
-	if headerVal != envVal {
+	if subtle.ConstantTimeCompare([]byte(headerVal), []byte(envVal)) != 1 {
 		return fmt.Errorf("%v", Stderr.AuthCodeInvalid)
 	}
 
 	return nil
 }
+
+// IdentityFromContext Retrieve the identity that authenticated the request.
+func IdentityFromContext(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(*Identity)
+	return id, ok && id != nil
+}
+
+// WithIdentity Return a copy of ctx that carries the identity.
+func WithIdentity(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// Authenticate Verify the credentials sent with a request. Failures are
+// returned as an *AuthError, to pass on to Challenge.
+func (a *Authenticator) Authenticate(r *http.Request) (*Identity, error) {
+	header := r.Header.Get(authHeader)
+	if header == "" {
+		return nil, &AuthError{msg: Stderr.AuthHeaderMissing}
+	}
+
+	scheme, credentials, _ := strings.Cut(header, " ")
+	credentials = strings.TrimSpace(credentials)
+
+	switch {
+	case strings.EqualFold(scheme, SchemeBasic) && a.Users != nil:
+		return a.basic(credentials)
+	case strings.EqualFold(scheme, SchemeBearer) && (a.Tokens != nil || a.JWT != nil):
+		return a.bearer(credentials)
+	}
+
+	return nil, &AuthError{msg: fmt.Sprintf(Stderr.AuthSchemeUnsupported, scheme)}
+}
+
+// Challenge Respond with a 401 Unauthorized and a WWW-Authenticate header
+// for each scheme that is turned on.
+//
+//	See https://www.rfc-editor.org/rfc/rfc7617 and
+//	https://www.rfc-editor.org/rfc/rfc6750#section-3
+func (a *Authenticator) Challenge(w http.ResponseWriter, err error) {
+	var ae *AuthError
+	errors.As(err, &ae)
+
+	if a.Users != nil {
+		w.Header().Add("WWW-Authenticate", fmt.Sprintf(`Basic realm=%q, charset="UTF-8"`, a.Realm))
+	}
+
+	if a.Tokens != nil || a.JWT != nil {
+		challenge := fmt.Sprintf(`Bearer realm=%q`, a.Realm)
+		// Only say why when the client sent a token, see RFC 6750 section 3.1.
+		if ae != nil && ae.Scheme == SchemeBearer {
+			challenge += fmt.Sprintf(`, error="invalid_token", error_description=%q`, ae.msg)
+		}
+		w.Header().Add("WWW-Authenticate", challenge)
+	}
+
+	code := http.StatusUnauthorized
+	w.Header().Set("Content-Type", ContentTypeHtml)
+	w.WriteHeader(code)
+	writeBody(w, code, http.StatusText(code))
+}
+
+// basic Verify a username and password.
+func (a *Authenticator) basic(credentials string) (*Identity, error) {
+	fail := &AuthError{Scheme: SchemeBasic, msg: Stderr.CredentialsInvalid}
+
+	decoded, e1 := base64.StdEncoding.DecodeString(credentials)
+	if e1 != nil {
+		return nil, fail
+	}
+
+	username, password, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return nil, fail
+	}
+
+	user, e2 := a.Users.LookupUser(username)
+	if e2 != nil {
+		return nil, e2
+	}
+
+	if user == nil {
+		// Take as long as a wrong password would.
+		_, _ = VerifyPassword(dummyHash(), password)
+		return nil, fail
+	}
+
+	match, e3 := VerifyPassword(user.PasswordHash, password)
+	if e3 != nil {
+		return nil, e3
+	}
+
+	if !match {
+		return nil, fail
+	}
+
+	return &Identity{
+		Subject: user.Username,
+		Scheme:  SchemeBasic,
+		Scopes:  user.Scopes,
+	}, nil
+}
+
+// bearer Verify a JWT or look up an opaque token.
+func (a *Authenticator) bearer(token string) (*Identity, error) {
+	if token == "" {
+		return nil, &AuthError{Scheme: SchemeBearer, msg: Stderr.TokenInvalid}
+	}
+
+	if a.JWT != nil && strings.Count(token, ".") == 2 {
+		id, e1 := a.JWT.Verify(token)
+		if e1 != nil {
+			return nil, &AuthError{Scheme: SchemeBearer, msg: e1.Error()}
+		}
+		return id, nil
+	}
+
+	if a.Tokens == nil {
+		return nil, &AuthError{Scheme: SchemeBearer, msg: Stderr.TokenInvalid}
+	}
+
+	id, e2 := a.Tokens.LookupToken(token)
+	if e2 != nil {
+		return nil, e2
+	}
+
+	if id == nil {
+		return nil, &AuthError{Scheme: SchemeBearer, msg: Stderr.TokenInvalid}
+	}
+
+	id.Scheme = SchemeBearer
+
+	return id, nil
+}
+
+// HasScope Report whether the identity was granted a scope.
+func (id *Identity) HasScope(scope string) bool {
+	for _, s := range id.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LookupUser Return the user or nil when there is no such user.
+func (su StaticUsers) LookupUser(username string) (*User, error) {
+	return su[username], nil
+}
@@ -1,8 +1,13 @@
 package www
 
 import (
+	"context"
 	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -39,3 +44,121 @@ func TestAuthentication(tRunner *testing.T) {
 		})
 	}
 }
+
+type tokenStore map[string]*Identity
+
+func (ts tokenStore) LookupToken(token string) (*Identity, error) {
+	return ts[token], nil
+}
+
+func TestAuthenticator_Authenticate(t *testing.T) {
+	hash, _ := HashPassword("0123")
+	auth := &Authenticator{
+		Realm:  "test",
+		Users:  StaticUsers{"abcd": {Username: "abcd", PasswordHash: hash, Scopes: []string{"admin"}}},
+		Tokens: tokenStore{"key-1": {Subject: "account-1"}},
+	}
+	basic := func(creds string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(creds))
+	}
+
+	tests := []struct {
+		name        string
+		header      string
+		wantSubject string
+		wantScheme  string
+	}{
+		{"basic", basic("abcd:0123"), "abcd", SchemeBasic},
+		{"basic-lowercase-scheme", "basic " + base64.StdEncoding.EncodeToString([]byte("abcd:0123")), "abcd", SchemeBasic},
+		{"basic-wrong-password", basic("abcd:1234"), "", ""},
+		{"basic-unknown-user", basic("wxyz:0123"), "", ""},
+		{"basic-no-colon", basic("abcd"), "", ""},
+		{"basic-not-base64", "Basic %%%", "", ""},
+		{"bearer", "Bearer key-1", "account-1", SchemeBearer},
+		{"bearer-unknown", "Bearer key-2", "", ""},
+		{"unsupported-scheme", "Digest abc", "", ""},
+		{"missing", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				r.Header.Set(authHeader, tt.header)
+			}
+
+			got, err := auth.Authenticate(r)
+
+			if tt.wantSubject == "" {
+				var ae *AuthError
+				if !errors.As(err, &ae) {
+					t.Errorf("Authenticate() error = %v, want an AuthError", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("Authenticate() error = %v", err)
+				return
+			}
+
+			if got.Subject != tt.wantSubject || got.Scheme != tt.wantScheme {
+				t.Errorf("Authenticate() = %v %v, want %v %v", got.Scheme, got.Subject, tt.wantScheme, tt.wantSubject)
+			}
+		})
+	}
+}
+
+func TestAuthenticator_Challenge(t *testing.T) {
+	auth := &Authenticator{
+		Realm:  "test",
+		Users:  StaticUsers{},
+		Tokens: tokenStore{},
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want []string
+	}{
+		{
+			"no-credentials",
+			&AuthError{msg: Stderr.AuthHeaderMissing},
+			[]string{`Basic realm="test", charset="UTF-8"`, `Bearer realm="test"`},
+		},
+		{
+			"invalid-token",
+			&AuthError{Scheme: SchemeBearer, msg: Stderr.TokenInvalid},
+			[]string{`Basic realm="test", charset="UTF-8"`, `Bearer realm="test", error="invalid_token", error_description="invalid bearer token"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			auth.Challenge(w, tt.err)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("Challenge() status = %v, want %v", w.Code, http.StatusUnauthorized)
+				return
+			}
+
+			if got := w.Header().Values("WWW-Authenticate"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Challenge() WWW-Authenticate = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIdentityFromContext(t *testing.T) {
+	want := &Identity{Subject: "abcd"}
+
+	if _, ok := IdentityFromContext(context.Background()); ok {
+		t.Errorf("IdentityFromContext() found an identity in an empty context")
+		return
+	}
+
+	got, ok := IdentityFromContext(WithIdentity(context.Background(), want))
+	if !ok || got != want {
+		t.Errorf("IdentityFromContext() = %v, want %v", got, want)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/kohirens/stdlib/logger"
+	"github.com/kohirens/www"
 )
 
 type Handler struct {
@@ -70,6 +71,7 @@ func NewRequest(l *Input) (*http.Request, error) {
 		return nil, fmt.Errorf(stderr.NewRequest, e2)
 	}
 	r.Header = headers
+	r.RemoteAddr = l.RequestContext.HTTP.SourceIp
 
 	if method == "POST" || method == "PUT" {
 		b := l.Body
@@ -100,6 +102,31 @@ func NewResponse() *Output {
 	}
 }
 
+// Preflight Answer a CORS preflight request according to the policy. Returns
+// nil when the event is not a preflight request, so that it can be processed
+// as usual. Call this before PreliminaryChecks, which answers every OPTIONS
+// request with only an Allow header.
+func Preflight(event *Input, policy *www.CORS) *Output {
+	if strings.ToUpper(event.RequestContext.HTTP.Method) != http.MethodOptions {
+		return nil
+	}
+
+	r, e1 := NewRequest(event)
+	if e1 != nil {
+		Log.Errf("%v", e1.Error())
+		return nil
+	}
+
+	w := NewResponse()
+	if !policy.Preflight(w, r) {
+		return nil
+	}
+
+	PrepareResponse(w)
+
+	return w
+}
+
 func PreliminaryChecks(event *Input) *Output {
 	method := event.RequestContext.HTTP.Method
 	httpAllowedMethods, ok := os.LookupEnv(envHttpMethods)
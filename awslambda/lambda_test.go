@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"testing"
+
+	"github.com/kohirens/www"
 )
 
 const (
@@ -303,3 +305,50 @@ func TestGetCookie(runner *testing.T) {
 		})
 	}
 }
+
+func TestPreflight(t *testing.T) {
+	policy := &www.CORS{
+		AllowedOrigins: []string{"https://*.example.com"},
+		AllowedMethods: []string{"GET", "POST"},
+	}
+	tests := []struct {
+		name    string
+		method  string
+		origin  string
+		want    int
+		wantNil bool
+	}{
+		{"not-options", "GET", "https://app.example.com", 0, true},
+		{"allowed", "OPTIONS", "https://app.example.com", 204, false},
+		{"origin-not-allowed", "OPTIONS", "https://example.org", 403, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := &Input{
+				RawPath: "/api/meals",
+				Headers: map[string]string{
+					"Origin":                        tt.origin,
+					"Access-Control-Request-Method": "POST",
+				},
+				RequestContext: &Context{
+					HTTP: &Http{Method: tt.method, Path: "/api/meals"},
+				},
+			}
+
+			got := Preflight(event, policy)
+			if (got == nil) != tt.wantNil {
+				t.Errorf("Preflight() = %v, wantNil %v", got, tt.wantNil)
+				return
+			}
+
+			if got != nil && got.StatusCode != tt.want {
+				t.Errorf("Preflight() status = %v, want %v", got.StatusCode, tt.want)
+				return
+			}
+
+			if tt.want == 204 && got.Headers["Access-Control-Allow-Origin"] != tt.origin {
+				t.Errorf("Preflight() headers = %v, want origin %v", got.Headers, tt.origin)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 
 	"github.com/kohirens/sso"
+	"github.com/kohirens/www"
 	"github.com/kohirens/www/awslambda"
 	"github.com/kohirens/www/gpg"
 	"github.com/kohirens/www/session"
@@ -33,6 +34,21 @@ func (a *Api) AddService(key string, service interface{}) {
 	a.serviceManager.Add(key, service)
 }
 
+var _ CORSApp = (*Api)(nil)
+
+// AllowCORS Opt a route in to responding to cross-origin requests. Preflight
+// requests for the route are answered before the session is loaded, and the
+// Access-Control-* headers are added to the routes' responses. It fails when
+// the RouteManager is not a CORSRouter, or the policy is unsafe.
+func (a *Api) AllowCORS(endpoint string, policy *www.CORS) error {
+	cr, ok := a.router.(CORSRouter)
+	if !ok {
+		return fmt.Errorf("%v", stderr.NoCORSRouter)
+	}
+
+	return cr.AllowCORS(endpoint, policy)
+}
+
 // AuthManager Return the authentication manager.
 func (a *Api) AuthManager() AuthManager {
 	return a.authManager
@@ -116,6 +132,10 @@ func (a *Api) ServiceManager() ServiceManager {
 }
 
 // Session Get the session manager.
+//
+// Deprecated: A Manager at KeySessionManager is shared by all requests, so
+// concurrent requests overwrite each other's session. Register a
+// session.SessionStore at KeySessionStore and call SessionManager instead.
 func (a *Api) Session() (*session.Manager, error) {
 	x, e1 := a.serviceManager.Get(KeySessionManager)
 	if e1 != nil {
@@ -139,6 +159,15 @@ func (a *Api) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	rawPath := r.URL.Path
 	Log.Infof("request %v %v", r.Method, rawPath)
 
+	if policy := a.cors(rawPath); policy != nil {
+		if policy.Preflight(w, r) {
+			return
+		}
+		policy.SetHeaders(w, r)
+	}
+
+	r = a.withSession(r)
+
 	if e := a.RestoreSessionData(w, r); e != nil {
 		Log.Errf("%v", e.Error())
 		w.WriteHeader(http.StatusInternalServerError)
@@ -151,8 +180,9 @@ func (a *Api) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Add common variables to the template manager.
-	a.tmplManager.AppendVars(Variables{
+	// Add common variables to a copy of the template manager for the
+	// request, see Templates.
+	r = withTemplates(r, a.tmplManager, Variables{
 		"HTTP_Method": r.Method,
 		"URL_Path":    rawPath,
 		"URL_Query":   r.URL.RawQuery,
@@ -205,6 +235,13 @@ func (a *Api) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 func (a *Api) ServeLambda(event *awslambda.Input) (*awslambda.Output, error) {
 	Log.Infof("handler started")
 
+	policy := a.cors(event.RawPath)
+	if policy != nil {
+		if res := awslambda.Preflight(event, policy); res != nil {
+			return res, nil
+		}
+	}
+
 	if errRes := awslambda.PreliminaryChecks(event); errRes != nil {
 		return errRes, nil
 	}
@@ -220,6 +257,12 @@ func (a *Api) ServeLambda(event *awslambda.Input) (*awslambda.Output, error) {
 		return w, nil
 	}
 
+	if policy != nil {
+		policy.SetHeaders(w, r)
+	}
+
+	r = a.withSession(r)
+
 	Log.Infof("request %v %v", method, rawPath)
 	if e := a.RestoreSessionData(w, r); e != nil {
 		Log.Errf("%v", e.Error())
@@ -247,7 +290,7 @@ func (a *Api) ServeLambda(event *awslambda.Input) (*awslambda.Output, error) {
 }
 
 func (a *Api) RestoreSessionData(w http.ResponseWriter, r *http.Request) error {
-	sm, e1 := a.Session()
+	sm, e1 := SessionManager(r, a)
 	if e1 != nil {
 		return e1
 	}
@@ -259,6 +302,9 @@ func (a *Api) RestoreSessionData(w http.ResponseWriter, r *http.Request) error {
 		}
 	}
 
+	// Update the cookie when the session policy extended the session.
+	sm.RefreshCookie(w)
+
 	// TODO pull from the cookie which provider the client chose.
 	gp, e2 := a.authManager.Get(KeyGoogleProvider)
 	if e2 != nil {
@@ -282,7 +328,7 @@ func (a *Api) RestoreSessionData(w http.ResponseWriter, r *http.Request) error {
 }
 
 func (a *Api) SaveSessionData(w http.ResponseWriter, r *http.Request) error {
-	sm, e1 := a.Session()
+	sm, e1 := SessionManager(r, a)
 	if e1 != nil {
 		return e1
 	}
@@ -307,6 +353,9 @@ func (a *Api) SaveSessionData(w http.ResponseWriter, r *http.Request) error {
 		return e
 	}
 
+	// Send the session cookies when the session is kept in cookies.
+	sm.RefreshCookie(w)
+
 	return nil
 }
 
@@ -320,3 +369,14 @@ type appKey struct {
 	PrivateKey string `json:"private_key"`
 	PassPhrase string `json:"pass_phrase"`
 }
+
+// cors The CORS policy of an endpoint, nil when it has none or the
+// RouteManager is not a CORSRouter.
+func (a *Api) cors(endpoint string) *www.CORS {
+	cr, ok := a.router.(CORSRouter)
+	if !ok {
+		return nil
+	}
+
+	return cr.CORS(endpoint)
+}
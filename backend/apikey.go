@@ -0,0 +1,392 @@
+package backend
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kohirens/www"
+	"github.com/kohirens/www/storage"
+)
+
+// APIKey A key that a machine client, such as a partner's server, uses to
+// call the API on behalf of an account. Only a hash of the secret is kept,
+// the full token is shown once when the key is issued or rotated.
+type APIKey struct {
+	AccountID string    `json:"account_id"`
+	Created   time.Time `json:"created"`
+	// Expires When the key stops working, zero means never.
+	Expires time.Time `json:"expires"`
+	// Hash SHA-256 of the secret, hex encoded.
+	Hash string `json:"hash,omitempty"`
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// PreviousHash Of the secret before the last rotation, it keeps working
+	// until PreviousExpires so that clients can switch over.
+	PreviousHash    string    `json:"previous_hash,omitempty"`
+	PreviousExpires time.Time `json:"previous_expires"`
+	Revoked         time.Time `json:"revoked"`
+	Rotated         time.Time `json:"rotated"`
+	Scopes          []string  `json:"scopes"`
+}
+
+// APIKeyManager Issues and verifies API keys tied to a backend.Account.
+type APIKeyManager interface {
+	// Issue A new key for an account, returns the key and the token to give
+	// to the client.
+	Issue(accountID, name string, scopes []string, ttl time.Duration) (*APIKey, string, error)
+	// List The keys of an account.
+	List(accountID string) ([]*APIKey, error)
+	Lookup(id string) (*APIKey, error)
+	// Revoke A key, it stops working immediately.
+	Revoke(id string) error
+	// Rotate Replace the secret of a key, the old secret keeps working for
+	// grace.
+	Rotate(id string, grace time.Duration) (*APIKey, string, error)
+	// Verify A token and return its key when it is valid.
+	Verify(token string) (*APIKey, error)
+}
+
+// APIKeyExec An implementation of APIKeyManager that keeps keys in storage.
+// It also implements www.TokenStore, so it can be used as the Tokens of a
+// www.Authenticator.
+type APIKeyExec struct {
+	accounts AccountManager
+	// mutex Serializes updates to keys and the per-account index, so that a
+	// Rotate cannot undo a Revoke.
+	mutex sync.Mutex
+	store storage.Storage
+}
+
+const (
+	// APIKeyPrefix Starts every token, so that leaked keys are easy to spot
+	// by secret scanners.
+	APIKeyPrefix = "ak_"
+
+	PrefixAPIKeys = "apikeys"
+)
+
+var (
+	_ APIKeyManager  = (*APIKeyExec)(nil)
+	_ www.TokenStore = (*APIKeyExec)(nil)
+
+	// apiKeyNow Allows tests to control the clock.
+	apiKeyNow = time.Now
+)
+
+// NewAPIKeyExec Initialize an API key manager. Keys can only be issued to
+// accounts that accounts can look up.
+func NewAPIKeyExec(store storage.Storage, accounts AccountManager) *APIKeyExec {
+	return &APIKeyExec{
+		accounts: accounts,
+		store:    store,
+	}
+}
+
+// Active Report whether the key can be used.
+func (k *APIKey) Active(now time.Time) bool {
+	if !k.Revoked.IsZero() {
+		return false
+	}
+
+	return k.Expires.IsZero() || now.Before(k.Expires)
+}
+
+// Issue A new key for an account.
+func (km *APIKeyExec) Issue(accountID, name string, scopes []string, ttl time.Duration) (*APIKey, string, error) {
+	if _, e := km.accounts.Lookup(accountID); e != nil {
+		return nil, "", e
+	}
+
+	id, e1 := uuid.NewV7()
+	if e1 != nil {
+		return nil, "", fmt.Errorf(stderr.UUID, e1.Error())
+	}
+
+	secret, hash, e2 := newAPIKeySecret()
+	if e2 != nil {
+		return nil, "", e2
+	}
+
+	now := apiKeyNow().UTC()
+	key := &APIKey{
+		AccountID: accountID,
+		Created:   now,
+		Hash:      hash,
+		ID:        id.String(),
+		Name:      name,
+		Scopes:    scopes,
+	}
+
+	if ttl > 0 {
+		key.Expires = now.Add(ttl)
+	}
+
+	if e := km.save(key); e != nil {
+		return nil, "", e
+	}
+
+	if e := km.index(accountID, key.ID); e != nil {
+		return nil, "", e
+	}
+
+	return key, apiKeyToken(key.ID, secret), nil
+}
+
+// IndexLocation Where the IDs of the keys for an account are kept in storage.
+func (km *APIKeyExec) IndexLocation(accountID string) string {
+	return fmt.Sprintf(PrefixAPIKeys+"/accounts/%v.json", accountID)
+}
+
+// List The keys of an account, including revoked and expired keys.
+func (km *APIKeyExec) List(accountID string) ([]*APIKey, error) {
+	// The ID becomes part of a path, so only accept IDs of existing accounts.
+	if _, e := km.accounts.Lookup(accountID); e != nil {
+		return nil, e
+	}
+
+	ids, e1 := km.loadIndex(accountID)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	keys := make([]*APIKey, 0, len(ids))
+	for _, id := range ids {
+		key, e2 := km.Lookup(id)
+		if e2 != nil {
+			return nil, e2
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// Location Where a key is kept in storage.
+func (km *APIKeyExec) Location(id string) string {
+	return fmt.Sprintf(PrefixAPIKeys+"/%v.json", id)
+}
+
+// Lookup A key by its ID.
+func (km *APIKeyExec) Lookup(id string) (*APIKey, error) {
+	// The ID becomes part of a path, so only accept IDs that Issue makes.
+	if _, e := uuid.Parse(id); e != nil {
+		return nil, &APIKeyNotFoundError{id}
+	}
+
+	location := km.Location(id)
+	if !km.store.Exist(location) {
+		return nil, &APIKeyNotFoundError{id}
+	}
+
+	data, e1 := km.store.Load(location)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	key := &APIKey{}
+	if e := json.Unmarshal(data, key); e != nil {
+		return nil, fmt.Errorf(stderr.DecodeJSON, e.Error())
+	}
+
+	return key, nil
+}
+
+// LookupToken Resolve a bearer token to the account and scopes of its key.
+// Unknown, expired and revoked keys return a nil identity.
+func (km *APIKeyExec) LookupToken(token string) (*www.Identity, error) {
+	key, e1 := km.Verify(token)
+	if e1 != nil {
+		var ie *APIKeyInvalidError
+		if errors.As(e1, &ie) {
+			return nil, nil
+		}
+		return nil, e1
+	}
+
+	return &www.Identity{
+		Subject: key.AccountID,
+		Scopes:  key.Scopes,
+		Claims:  map[string]any{"key_id": key.ID},
+	}, nil
+}
+
+// Revoke A key.
+func (km *APIKeyExec) Revoke(id string) error {
+	km.mutex.Lock()
+	defer km.mutex.Unlock()
+
+	key, e1 := km.Lookup(id)
+	if e1 != nil {
+		return e1
+	}
+
+	if !key.Revoked.IsZero() {
+		return nil
+	}
+
+	key.Revoked = apiKeyNow().UTC()
+
+	return km.save(key)
+}
+
+// Rotate Replace the secret of a key.
+func (km *APIKeyExec) Rotate(id string, grace time.Duration) (*APIKey, string, error) {
+	km.mutex.Lock()
+	defer km.mutex.Unlock()
+
+	key, e1 := km.Lookup(id)
+	if e1 != nil {
+		return nil, "", e1
+	}
+
+	now := apiKeyNow().UTC()
+	if !key.Active(now) {
+		return nil, "", &APIKeyInvalidError{id}
+	}
+
+	secret, hash, e2 := newAPIKeySecret()
+	if e2 != nil {
+		return nil, "", e2
+	}
+
+	key.PreviousHash = ""
+	key.PreviousExpires = time.Time{}
+	if grace > 0 {
+		key.PreviousHash = key.Hash
+		key.PreviousExpires = now.Add(grace)
+	}
+	key.Hash = hash
+	key.Rotated = now
+
+	if e := km.save(key); e != nil {
+		return nil, "", e
+	}
+
+	return key, apiKeyToken(key.ID, secret), nil
+}
+
+// Verify A token and return its key. Tokens that are malformed, unknown,
+// expired or revoked return an *APIKeyInvalidError.
+func (km *APIKeyExec) Verify(token string) (*APIKey, error) {
+	id, secret, ok := parseAPIKeyToken(token)
+	if !ok {
+		return nil, &APIKeyInvalidError{}
+	}
+
+	key, e1 := km.Lookup(id)
+	if e1 != nil {
+		var nf *APIKeyNotFoundError
+		if errors.As(e1, &nf) {
+			return nil, &APIKeyInvalidError{id}
+		}
+		return nil, e1
+	}
+
+	now := apiKeyNow()
+	if !key.Active(now) {
+		return nil, &APIKeyInvalidError{id}
+	}
+
+	sum := sha256.Sum256([]byte(secret))
+	hash := hex.EncodeToString(sum[:])
+
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(key.Hash)) == 1 {
+		return key, nil
+	}
+
+	if key.PreviousHash != "" && now.Before(key.PreviousExpires) &&
+		subtle.ConstantTimeCompare([]byte(hash), []byte(key.PreviousHash)) == 1 {
+		return key, nil
+	}
+
+	return nil, &APIKeyInvalidError{id}
+}
+
+// index Add a key ID to the index of an account.
+func (km *APIKeyExec) index(accountID, id string) error {
+	km.mutex.Lock()
+	defer km.mutex.Unlock()
+
+	ids, e1 := km.loadIndex(accountID)
+	if e1 != nil {
+		return e1
+	}
+
+	data, e2 := json.Marshal(append(ids, id))
+	if e2 != nil {
+		return fmt.Errorf(stderr.EncodeJSON, e2.Error())
+	}
+
+	return km.store.Save(km.IndexLocation(accountID), data)
+}
+
+func (km *APIKeyExec) loadIndex(accountID string) ([]string, error) {
+	location := km.IndexLocation(accountID)
+	if !km.store.Exist(location) {
+		return []string{}, nil
+	}
+
+	data, e1 := km.store.Load(location)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	var ids []string
+	if e := json.Unmarshal(data, &ids); e != nil {
+		return nil, fmt.Errorf(stderr.DecodeJSON, e.Error())
+	}
+
+	return ids, nil
+}
+
+func (km *APIKeyExec) save(key *APIKey) error {
+	data, e1 := json.Marshal(key)
+	if e1 != nil {
+		return fmt.Errorf(stderr.EncodeJSON, e1.Error())
+	}
+
+	return km.store.Save(km.Location(key.ID), data)
+}
+
+func apiKeyToken(id, secret string) string {
+	return APIKeyPrefix + id + "_" + secret
+}
+
+// newAPIKeySecret Generate a secret and its hash. The secret has 256 bits of
+// entropy, so a plain SHA-256, without a salt or a slow hash, is enough to
+// keep it safe at rest.
+func newAPIKeySecret() (string, string, error) {
+	b := make([]byte, 32)
+	if _, e := rand.Read(b); e != nil {
+		return "", "", fmt.Errorf(stderr.RandomBytes, e.Error())
+	}
+
+	secret := hex.EncodeToString(b)
+	sum := sha256.Sum256([]byte(secret))
+
+	return secret, hex.EncodeToString(sum[:]), nil
+}
+
+// parseAPIKeyToken Split a token into the key ID and secret.
+func parseAPIKeyToken(token string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(token, APIKeyPrefix)
+	if !ok {
+		return "", "", false
+	}
+
+	id, secret, ok := strings.Cut(rest, "_")
+	if !ok || id == "" || secret == "" {
+		return "", "", false
+	}
+
+	return id, secret, true
+}
@@ -0,0 +1,205 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kohirens/www"
+)
+
+// apiKeyRequest The JSON body of the API key admin routes.
+type apiKeyRequest struct {
+	AccountID string   `json:"account_id"`
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	// Grace Seconds that the old secret keeps working after a rotation.
+	Grace int64 `json:"grace"`
+	// TTL Seconds until the key expires, zero for never.
+	TTL int64 `json:"ttl"`
+}
+
+// apiKeyResponse The token is only ever sent in the response to an issue or
+// rotate request.
+type apiKeyResponse struct {
+	Key   *APIKey `json:"key"`
+	Token string  `json:"token,omitempty"`
+}
+
+// APIKeyAuth Wrap a route so that it only runs for requests with a valid API
+// key in the "Authorization: Bearer" header. The route can get the account
+// ID and scopes of the key with www.IdentityFromContext(r.Context()).
+//
+//	NOTE: ServeHTTP redirects clients that are not logged in to the LoginPage,
+//	so add the endpoints for machine clients to PublicPages.
+func APIKeyAuth(keys www.TokenStore, next Route) Route {
+	return Authenticated(&www.Authenticator{Realm: "api", Tokens: keys}, next)
+}
+
+// IssueAPIKey Admin route to issue an API key. It expects a JSON body with
+// "account_id", "name", "scopes" and optionally "ttl" in seconds. For example:
+//
+//	admin := &www.Authenticator{Realm: "admin", Users: adminUsers}
+//	app.AddService(backend.KeyAPIKeyManager, backend.NewAPIKeyExec(store, am))
+//	app.AddRoute("/api/admin/keys/issue", backend.Authenticated(admin, backend.IssueAPIKey))
+func IssueAPIKey(w http.ResponseWriter, r *http.Request, a App) error {
+	km, req, ok, e1 := apiKeyAdmin(w, r, a)
+	if !ok {
+		return e1
+	}
+
+	key, token, e2 := km.Issue(req.AccountID, req.Name, req.Scopes, time.Duration(req.TTL)*time.Second)
+	if e2 != nil {
+		var nf *AccountNotFoundError
+		if errors.As(e2, &nf) {
+			return respondError(w, http.StatusNotFound, e2)
+		}
+		return e2
+	}
+
+	return respondJSON(w, http.StatusCreated, &apiKeyResponse{key.redact(), token})
+}
+
+// ListAPIKeys Admin route to list the API keys of the account in the
+// "account_id" query parameter.
+func ListAPIKeys(w http.ResponseWriter, r *http.Request, a App) error {
+	if r.Method != http.MethodGet {
+		www.Respond405(w, http.MethodGet)
+		return nil
+	}
+
+	km, e1 := apiKeyManager(a)
+	if e1 != nil {
+		return e1
+	}
+
+	keys, e2 := km.List(r.URL.Query().Get("account_id"))
+	if e2 != nil {
+		var nf *AccountNotFoundError
+		if errors.As(e2, &nf) {
+			return respondError(w, http.StatusNotFound, e2)
+		}
+		return e2
+	}
+
+	res := make([]*apiKeyResponse, len(keys))
+	for i, key := range keys {
+		res[i] = &apiKeyResponse{Key: key.redact()}
+	}
+
+	return respondJSON(w, http.StatusOK, res)
+}
+
+// RevokeAPIKey Admin route to revoke the API key with the "id" in the JSON
+// body.
+func RevokeAPIKey(w http.ResponseWriter, r *http.Request, a App) error {
+	km, req, ok, e1 := apiKeyAdmin(w, r, a)
+	if !ok {
+		return e1
+	}
+
+	if e := km.Revoke(req.ID); e != nil {
+		var nf *APIKeyNotFoundError
+		if errors.As(e, &nf) {
+			return respondError(w, http.StatusNotFound, e)
+		}
+		return e
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+// RotateAPIKey Admin route to replace the secret of the API key with the
+// "id" in the JSON body. The old secret keeps working for "grace" seconds.
+func RotateAPIKey(w http.ResponseWriter, r *http.Request, a App) error {
+	km, req, ok, e1 := apiKeyAdmin(w, r, a)
+	if !ok {
+		return e1
+	}
+
+	key, token, e2 := km.Rotate(req.ID, time.Duration(req.Grace)*time.Second)
+	if e2 != nil {
+		var nf *APIKeyNotFoundError
+		var ie *APIKeyInvalidError
+		switch {
+		case errors.As(e2, &nf):
+			return respondError(w, http.StatusNotFound, e2)
+		case errors.As(e2, &ie):
+			return respondError(w, http.StatusConflict, e2)
+		}
+		return e2
+	}
+
+	return respondJSON(w, http.StatusOK, &apiKeyResponse{key.redact(), token})
+}
+
+// apiKeyAdmin Get the API key manager and decode the JSON body of an admin
+// route. When ok is false the route must return the error, which is nil when
+// a response has already been written.
+func apiKeyAdmin(w http.ResponseWriter, r *http.Request, a App) (APIKeyManager, *apiKeyRequest, bool, error) {
+	if r.Method != http.MethodPost {
+		www.Respond405(w, http.MethodPost)
+		return nil, nil, false, nil
+	}
+
+	km, e1 := apiKeyManager(a)
+	if e1 != nil {
+		return nil, nil, false, e1
+	}
+
+	req := &apiKeyRequest{}
+	if e := json.NewDecoder(r.Body).Decode(req); e != nil {
+		return nil, nil, false, respondError(w, http.StatusBadRequest, fmt.Errorf(stderr.DecodeJSON, e.Error()))
+	}
+
+	return km, req, true, nil
+}
+
+func apiKeyManager(a App) (APIKeyManager, error) {
+	x, e1 := a.Service(KeyAPIKeyManager)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	km, ok := x.(APIKeyManager)
+	if !ok {
+		return nil, fmt.Errorf(stderr.ServiceTypeMatch, KeyAPIKeyManager)
+	}
+
+	return km, nil
+}
+
+// redact Return a copy of the key without the hashes of its secrets.
+func (k *APIKey) redact() *APIKey {
+	c := *k
+	c.Hash = ""
+	c.PreviousHash = ""
+
+	return &c
+}
+
+func respondError(w http.ResponseWriter, code int, err error) error {
+	return respondJSON(w, code, map[string]string{"status": "error", "message": err.Error()})
+}
+
+// respondJSON Send content as JSON with the status code.
+func respondJSON(w http.ResponseWriter, code int, content any) error {
+	body, e1 := json.Marshal(content)
+	if e1 != nil {
+		return fmt.Errorf(stderr.EncodeJSON, e1.Error())
+	}
+
+	w.Header().Set("Content-Type", www.ContentTypeJson)
+	w.WriteHeader(code)
+
+	if _, e := w.Write(body); e != nil {
+		return fmt.Errorf(stderr.WriteResponse, e.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,334 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kohirens/www"
+	"github.com/kohirens/www/storage"
+)
+
+// newAPIKeyExec Keep keys in tmp and accounts in the fixtures, where account
+// 1234 exists.
+func newAPIKeyExec() *APIKeyExec {
+	fixtures, _ := storage.NewLocalStorage(fixtureDir)
+	tmp, _ := storage.NewLocalStorage(tmpDir)
+	// LocalStorage makes the directories as keys are saved.
+	_ = os.RemoveAll(tmpDir + "/" + PrefixAPIKeys)
+
+	return NewAPIKeyExec(tmp, NewAccountExec(fixtures))
+}
+
+func setAPIKeyClock(t *testing.T, start time.Time) func(d time.Duration) {
+	current := start
+	apiKeyNow = func() time.Time { return current }
+	t.Cleanup(func() { apiKeyNow = time.Now })
+
+	return func(d time.Duration) { current = current.Add(d) }
+}
+
+func TestAPIKeyExec_Verify(t *testing.T) {
+	advance := setAPIKeyClock(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	km := newAPIKeyExec()
+
+	key, token, e1 := km.Issue("1234", "partner", []string{"meals:read"}, time.Hour)
+	if e1 != nil {
+		t.Fatalf("Issue() error = %v", e1)
+	}
+
+	// Change the last hex digit of the secret, whatever it is.
+	last := "0"
+	if strings.HasSuffix(token, "0") {
+		last = "1"
+	}
+	wrongSecret := token[:len(token)-1] + last
+
+	_, revokedToken, _ := km.Issue("1234", "revoked", nil, 0)
+	revoked, _ := km.Verify(revokedToken)
+	_ = km.Revoke(revoked.ID)
+
+	tests := []struct {
+		name    string
+		token   string
+		wait    time.Duration
+		wantErr bool
+	}{
+		{"valid", token, 0, false},
+		{"wrong-secret", wrongSecret, 0, true},
+		{"no-prefix", token[len(APIKeyPrefix):], 0, true},
+		{"unknown-id", APIKeyPrefix + "00000000-0000-0000-0000-000000000000_abc", 0, true},
+		{"path-in-id", APIKeyPrefix + "../accounts/1234_abc", 0, true},
+		{"revoked", revokedToken, 0, true},
+		{"expired", token, time.Hour, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			advance(tt.wait)
+
+			got, err := km.Verify(tt.token)
+
+			var ie *APIKeyInvalidError
+			if tt.wantErr {
+				if !errors.As(err, &ie) {
+					t.Errorf("Verify() error = %v, want an APIKeyInvalidError", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("Verify() error = %v", err)
+				return
+			}
+
+			if got.ID != key.ID || got.AccountID != "1234" {
+				t.Errorf("Verify() = %v, want key %v of account 1234", got.ID, key.ID)
+			}
+		})
+	}
+}
+
+func TestAPIKeyExec_Rotate(t *testing.T) {
+	advance := setAPIKeyClock(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	km := newAPIKeyExec()
+
+	key, oldToken, _ := km.Issue("1234", "partner", nil, 0)
+
+	_, newToken, e1 := km.Rotate(key.ID, time.Minute)
+	if e1 != nil {
+		t.Fatalf("Rotate() error = %v", e1)
+	}
+
+	if _, e := km.Verify(newToken); e != nil {
+		t.Errorf("Verify() new token error = %v", e)
+	}
+
+	if _, e := km.Verify(oldToken); e != nil {
+		t.Errorf("Verify() old token in grace period error = %v", e)
+	}
+
+	advance(time.Minute)
+
+	if _, e := km.Verify(oldToken); e == nil {
+		t.Errorf("Verify() old token after grace period, want an error")
+	}
+
+	if _, e := km.Verify(newToken); e != nil {
+		t.Errorf("Verify() new token after grace period error = %v", e)
+	}
+}
+
+// pausingStorage Pauses the next load of a name, until resumed.
+type pausingStorage struct {
+	storage.Storage
+	mutex   sync.Mutex
+	name    string
+	loading chan struct{}
+	resume  chan struct{}
+}
+
+func (s *pausingStorage) Load(name string) ([]byte, error) {
+	s.mutex.Lock()
+	pause := name == s.name
+	if pause {
+		s.name = ""
+	}
+	s.mutex.Unlock()
+
+	data, e := s.Storage.Load(name)
+	if pause {
+		s.loading <- struct{}{}
+		<-s.resume
+	}
+
+	return data, e
+}
+
+func TestAPIKeyExec_RevokeWhileRotating(t *testing.T) {
+	fixtures, _ := storage.NewLocalStorage(fixtureDir)
+	store := &pausingStorage{
+		Storage: storage.NewMemoryStorage(0, 0),
+		loading: make(chan struct{}),
+		resume:  make(chan struct{}),
+	}
+	km := NewAPIKeyExec(store, NewAccountExec(fixtures))
+
+	key, _, _ := km.Issue("1234", "partner", nil, 0)
+
+	store.mutex.Lock()
+	store.name = km.Location(key.ID)
+	store.mutex.Unlock()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if _, _, e := km.Rotate(key.ID, 0); e != nil {
+			t.Errorf("Rotate() error = %v", e)
+		}
+	}()
+
+	// Revoke while Rotate holds the key it loaded.
+	<-store.loading
+	go func() {
+		defer wg.Done()
+		if e := km.Revoke(key.ID); e != nil {
+			t.Errorf("Revoke() error = %v", e)
+		}
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(store.resume)
+	wg.Wait()
+
+	got, e1 := km.Lookup(key.ID)
+	if e1 != nil {
+		t.Fatalf("Lookup() error = %v", e1)
+	}
+	if got.Revoked.IsZero() {
+		t.Errorf("Rotate() undid the Revoke() of %v", key.ID)
+	}
+}
+
+func TestAPIKeyExec_List(t *testing.T) {
+	km := newAPIKeyExec()
+
+	if _, _, e := km.Issue("5678", "no-account", nil, 0); e == nil {
+		t.Errorf("Issue() to a missing account, want an error")
+	}
+
+	first, _, _ := km.Issue("1234", "first", nil, 0)
+	second, _, _ := km.Issue("1234", "second", nil, 0)
+
+	got, e1 := km.List("1234")
+	if e1 != nil {
+		t.Fatalf("List() error = %v", e1)
+	}
+
+	if len(got) != 2 || got[0].ID != first.ID || got[1].ID != second.ID {
+		t.Errorf("List() = %v, want keys %v and %v", got, first.ID, second.ID)
+	}
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	km := newAPIKeyExec()
+	_, token, _ := km.Issue("1234", "partner", []string{"meals:read"}, 0)
+
+	route := APIKeyAuth(km, RequireScope("meals:read", func(w http.ResponseWriter, r *http.Request, a App) error {
+		id, _ := www.IdentityFromContext(r.Context())
+		_, _ = w.Write([]byte(id.Subject))
+		return nil
+	}))
+
+	tests := []struct {
+		name     string
+		header   string
+		wantCode int
+		wantBody string
+	}{
+		{"valid", "Bearer " + token, http.StatusOK, "1234"},
+		{"invalid", "Bearer " + APIKeyPrefix + "nope", http.StatusUnauthorized, ""},
+		{"missing", "", http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/v1/meals", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+
+			if e := route(w, r, nil); e != nil {
+				t.Errorf("APIKeyAuth() error = %v", e)
+				return
+			}
+
+			if w.Code != tt.wantCode {
+				t.Errorf("APIKeyAuth() status = %v, want %v", w.Code, tt.wantCode)
+				return
+			}
+
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("APIKeyAuth() body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAPIKeyRoutes(t *testing.T) {
+	km := newAPIKeyExec()
+	store, _ := storage.NewLocalStorage(tmpDir)
+	app := NewWithDefaults("test", store)
+	app.AddService(KeyAPIKeyManager, km)
+
+	call := func(route Route, method string, body any) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(body)
+		r := httptest.NewRequest(method, "/api/admin/keys", bytes.NewReader(b))
+		w := httptest.NewRecorder()
+		if e := route(w, r, app); e != nil {
+			t.Fatalf("route error = %v", e)
+		}
+		return w
+	}
+
+	w1 := call(IssueAPIKey, "POST", map[string]any{"account_id": "1234", "name": "partner", "scopes": []string{"meals:read"}})
+	if w1.Code != http.StatusCreated {
+		t.Fatalf("IssueAPIKey() status = %v, want %v", w1.Code, http.StatusCreated)
+	}
+
+	issued := &apiKeyResponse{}
+	_ = json.Unmarshal(w1.Body.Bytes(), issued)
+	if issued.Token == "" || issued.Key.Hash != "" {
+		t.Errorf("IssueAPIKey() = %s, want a token and no hash", w1.Body.String())
+	}
+
+	tests := []struct {
+		name     string
+		route    Route
+		method   string
+		body     any
+		wantCode int
+	}{
+		{"issue-wrong-method", IssueAPIKey, "GET", nil, http.StatusMethodNotAllowed},
+		{"issue-missing-account", IssueAPIKey, "POST", map[string]any{"account_id": "5678"}, http.StatusNotFound},
+		{"rotate", RotateAPIKey, "POST", map[string]any{"id": issued.Key.ID}, http.StatusOK},
+		{"revoke", RevokeAPIKey, "POST", map[string]any{"id": issued.Key.ID}, http.StatusNoContent},
+		{"rotate-revoked", RotateAPIKey, "POST", map[string]any{"id": issued.Key.ID}, http.StatusConflict},
+		{"revoke-missing", RevokeAPIKey, "POST", map[string]any{"id": "nope"}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := call(tt.route, tt.method, tt.body); w.Code != tt.wantCode {
+				t.Errorf("status = %v, want %v: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+		})
+	}
+
+	listTests := []struct {
+		name     string
+		method   string
+		query    string
+		wantCode int
+	}{
+		{"list", "GET", "account_id=1234", http.StatusOK},
+		{"list-wrong-method", "POST", "account_id=1234", http.StatusMethodNotAllowed},
+		{"list-missing-account", "GET", "account_id=5678", http.StatusNotFound},
+		{"list-traversal", "GET", "account_id=../../accounts/1234", http.StatusNotFound},
+		{"list-no-account", "GET", "", http.StatusNotFound},
+	}
+	for _, tt := range listTests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/api/admin/keys?"+tt.query, nil)
+			w := httptest.NewRecorder()
+			if e := ListAPIKeys(w, r, app); e != nil || w.Code != tt.wantCode {
+				t.Errorf("ListAPIKeys() = %v %v, want %v", w.Code, e, tt.wantCode)
+			}
+		})
+	}
+}
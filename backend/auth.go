@@ -1,8 +1,12 @@
 package backend
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
+
 	"github.com/kohirens/sso"
+	"github.com/kohirens/www"
 )
 
 // AuthManager handles storing and retrieval of OIDC providers when an endpoint
@@ -38,3 +42,47 @@ func (ap *Authorizer) Get(name string) (sso.OIDCProvider, error) {
 	}
 	return p, nil
 }
+
+// Authenticated Wrap a route so that it is only called for requests with
+// valid credentials; other requests get a 401 challenge. The route can get the
+// identity of the client with www.IdentityFromContext(r.Context()). For
+// example:
+//
+//	auth := &www.Authenticator{
+//		Realm: "admin",
+//		Users: www.StaticUsers{"admin": {Username: "admin", PasswordHash: hash}},
+//	}
+//	app.AddRoute("/api/admin", backend.Authenticated(auth, adminRoute))
+func Authenticated(auth *www.Authenticator, next Route) Route {
+	return func(w http.ResponseWriter, r *http.Request, a App) error {
+		id, e1 := auth.Authenticate(r)
+		if e1 != nil {
+			var ae *www.AuthError
+			if !errors.As(e1, &ae) {
+				// The user or token store failed, not the client.
+				return e1
+			}
+
+			Log.Dbugf(stdout.Unauthorized, r.URL.Path, e1.Error())
+			auth.Challenge(w, e1)
+			return nil
+		}
+
+		return next(w, r.WithContext(www.WithIdentity(r.Context(), id)), a)
+	}
+}
+
+// RequireScope Wrap a route so that it is only called when the identity of
+// the request, see Authenticated, was granted the scope; otherwise the client
+// gets a 403 Forbidden.
+func RequireScope(scope string, next Route) Route {
+	return func(w http.ResponseWriter, r *http.Request, a App) error {
+		id, ok := www.IdentityFromContext(r.Context())
+		if !ok || !id.HasScope(scope) {
+			www.RespondWithStatus(w, http.StatusForbidden, nil, www.ContentTypeHtml)
+			return nil
+		}
+
+		return next(w, r, a)
+	}
+}
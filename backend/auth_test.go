@@ -0,0 +1,57 @@
+package backend
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kohirens/www"
+)
+
+func TestAuthenticated(t *testing.T) {
+	hash, _ := www.HashPassword("0123")
+	auth := &www.Authenticator{
+		Realm: "test",
+		Users: www.StaticUsers{"abcd": {Username: "abcd", PasswordHash: hash, Scopes: []string{"admin"}}},
+	}
+	route := Authenticated(auth, RequireScope("admin", func(w http.ResponseWriter, r *http.Request, a App) error {
+		id, _ := www.IdentityFromContext(r.Context())
+		_, _ = w.Write([]byte(id.Subject))
+		return nil
+	}))
+
+	tests := []struct {
+		name     string
+		creds    string
+		wantCode int
+		wantBody string
+	}{
+		{"authenticated", "abcd:0123", http.StatusOK, "abcd"},
+		{"wrong-password", "abcd:1234", http.StatusUnauthorized, ""},
+		{"no-credentials", "", http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/admin", nil)
+			if tt.creds != "" {
+				r.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(tt.creds)))
+			}
+			w := httptest.NewRecorder()
+
+			if e := route(w, r, nil); e != nil {
+				t.Errorf("Authenticated() error = %v", e)
+				return
+			}
+
+			if w.Code != tt.wantCode {
+				t.Errorf("Authenticated() status = %v, want %v", w.Code, tt.wantCode)
+				return
+			}
+
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("Authenticated() body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/kohirens/stdlib/logger"
+	"github.com/kohirens/www"
 	"github.com/kohirens/www/awslambda"
 	"github.com/kohirens/www/storage"
 )
@@ -24,9 +25,19 @@ type App interface {
 	TmplManager() TemplateManager
 }
 
+// CORSApp An optional extension of App for routes that respond to
+// cross-origin requests. Api implements it, when its RouteManager is a
+// CORSRouter.
+type CORSApp interface {
+	AllowCORS(endpoint string, policy *www.CORS) error
+}
+
 const (
+	KeyAPIKeyManager  = "akm"
 	KeyGoogleProvider = "gp"
+	KeySessionIndex   = "si"
 	KeySessionManager = "sm"
+	KeySessionStore   = "ss"
 
 	// MetaRefresh HTML template to redirect the client.
 	MetaRefresh = `<!DOCTYPE html>
@@ -10,6 +10,23 @@ func (e *AccountNotFoundError) Error() string {
 	return fmt.Sprintf(stderr.AccountNotFound, e.id)
 }
 
+// APIKeyInvalidError Thrown when a token does not match an active API key.
+type APIKeyInvalidError struct {
+	id string
+}
+
+func (e *APIKeyInvalidError) Error() string {
+	return fmt.Sprintf(stderr.APIKeyInvalid, e.id)
+}
+
+type APIKeyNotFoundError struct {
+	id string
+}
+
+func (e *APIKeyNotFoundError) Error() string {
+	return fmt.Sprintf(stderr.APIKeyNotFound, e.id)
+}
+
 type ProviderNotFound struct {
 	name string
 }
@@ -45,6 +62,15 @@ func NewReferralError(contentType, msg, loc string, code int, log bool) *Referra
 	}
 }
 
+// SessionNotFoundError Thrown when an account has no session with the ID.
+type SessionNotFoundError struct {
+	id string
+}
+
+func (e *SessionNotFoundError) Error() string {
+	return fmt.Sprintf(stderr.SessionNotFound, e.id)
+}
+
 type ServiceNotFoundError struct {
 	name string
 }
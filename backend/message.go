@@ -3,20 +3,33 @@ package backend
 var stderr = struct {
 	AbsPath,
 	AccountNotFound,
+	APIKeyInvalid,
+	APIKeyNotFound,
 	AuthProviderLookup,
 	BuildLoginRequest,
+	ContentTypeMatch,
 	DecodeJSON,
+	EncodeJSON,
+	FieldRequired,
 	FileNotFound,
 	FileOpen,
 	FileWrite,
 	LoginRequest,
 	MakeDir,
 	MaxLen,
+	NoCORSRouter,
 	NoRoutes,
+	NotSignedIn,
+	NoURLSigner,
+	PresignForbidden,
+	PresignMethod,
 	ProviderNotFound,
+	RandomBytes,
+	ReadBody,
 	RenderFiles,
 	SeeOther,
 	ServiceNotFound,
+	SessionNotFound,
 	ServicePointer,
 	ServiceTypeMatch,
 	SignOut,
@@ -24,25 +37,40 @@ var stderr = struct {
 	TemplateLoad,
 	TemplateParse,
 	UnmarshalJSON,
+	UploadTooLarge,
+	UploadTooSmall,
 	UUID,
 	WriteResponse string
 }{
 	AbsPath:            "could not get absolute path for %v: %v",
 	AccountNotFound:    "account %v not found",
+	APIKeyInvalid:      "API key %v is invalid, expired or revoked",
+	APIKeyNotFound:     "API key %v not found",
 	AuthProviderLookup: "cannot retrieve authentication provider: %v",
 	BuildLoginRequest:  "failed to build a login request: %v",
+	ContentTypeMatch:   "content type %q does not match the signed content type %q",
 	DecodeJSON:         "failed to decode JSON: %v",
+	EncodeJSON:         "failed to encode JSON: %v",
+	FieldRequired:      "field %v is required",
 	FileNotFound:       "%q not found: %v",
 	FileOpen:           "could not open file %v",
 	FileWrite:          "could not write file %v",
 	LoginRequest:       "could not login: %v",
 	MakeDir:            "could not make dir: %v",
 	MaxLen:             "field %v exceeds max length of %v",
+	NoCORSRouter:       "the route manager cannot allow CORS, it must implement backend.CORSRouter",
 	NoRoutes:           "no routes registered",
+	NotSignedIn:        "the session is not signed in to an account",
+	NoURLSigner:        "the storage has no URL signer",
+	PresignForbidden:   "the account may not presign %v %v",
+	PresignMethod:      "cannot presign method %q",
 	ProviderNotFound:   "authentication provider %v was not found",
+	RandomBytes:        "cannot read random bytes: %v",
+	ReadBody:           "cannot read the request body: %v",
 	RenderFiles:        "render files %v",
 	SeeOther:           "see other %v",
 	ServiceNotFound:    "service %q was not found",
+	SessionNotFound:    "session %v not found",
 	ServicePointer:     "service parameter must be a pointer",
 	ServiceTypeMatch:   "service found at %q; type did not match any in the declared type constraint",
 	SignOut:            "signing out failed: %v",
@@ -50,6 +78,8 @@ var stderr = struct {
 	TemplateLoad:       "could not load template: %v",
 	TemplateParse:      "cannot parse template: %v",
 	UnmarshalJSON:      "json unmarshall error %v %v, offset: %v",
+	UploadTooLarge:     "the upload is larger than %v bytes",
+	UploadTooSmall:     "the upload is smaller than %v bytes",
 	UUID:               "cannot generate UUID: %v",
 	WriteResponse:      "cannot write response: %v",
 }
@@ -67,6 +97,7 @@ var stdout = struct {
 	SaveStorage,
 	SkipLogin,
 	TemplateLoad,
+	Unauthorized,
 	UriPath string
 }{
 	CurrentVersion:  "%v, %v",
@@ -81,5 +112,6 @@ var stdout = struct {
 	SaveStorage:     "save storage %v",
 	SkipLogin:       "skip login for %v",
 	TemplateLoad:    "loaded template: %v",
+	Unauthorized:    "unauthorized request to %v: %v",
 	UriPath:         "raw path is %v",
 }
@@ -0,0 +1,261 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kohirens/www"
+	"github.com/kohirens/www/storage"
+)
+
+// PresignPolicy Decides which URLs PresignURL signs.
+type PresignPolicy struct {
+	// Authorize Whether the account may make the request, the name of which
+	// is normalized by storage.NormalizeKey. When nil, an account may only
+	// sign URLs to names under its ID, such as "<account ID>/avatar.png".
+	Authorize func(r *http.Request, accountID string, req *PresignRequest) bool
+	// Expires How long the URLs are valid, DefaultPresignExpires when zero.
+	Expires time.Duration
+	// MaxSize The largest upload in bytes, zero for no limit.
+	MaxSize int64
+}
+
+// PresignRequest The JSON body of PresignURL.
+type PresignRequest struct {
+	// ContentType The Content-Type of an upload, empty for any.
+	ContentType string `json:"content_type"`
+	// Method GET to download, PUT or POST to upload. POST needs storage that
+	// can presign a form, such as storage.BucketStorage.
+	Method string `json:"method"`
+	Name   string `json:"name"`
+	// Size Of the upload in bytes, a PUT is limited to exactly this size. It
+	// is required for a PUT when the policy has a MaxSize.
+	Size int64 `json:"size"`
+}
+
+// postPresigner Storage that can presign a form to upload with a POST.
+type postPresigner interface {
+	PresignPost(ctx context.Context, name string, expires time.Duration, opts *storage.PresignOptions) (*storage.PresignedRequest, error)
+}
+
+// DefaultPresignExpires See PresignPolicy.Expires.
+const DefaultPresignExpires = 15 * time.Minute
+
+// PresignURL Make a route that signs a URL to data in store, so that the
+// browser downloads or uploads it directly, instead of through this server.
+// The session of the request must be signed in to an account, see
+// TrackSignIn, which the policy authorizes. It responds with a
+// storage.PresignedRequest as JSON. For example:
+//
+//	app.AddRoute("/api/files/presign", backend.PresignURL(bucket, &backend.PresignPolicy{
+//		MaxSize: 10 << 20,
+//	}))
+//
+// A browser then uploads to the URL with the method and headers of the
+// response, or posts a form of the fields with the file last for a POST.
+func PresignURL(store storage.PresignStorage, policy *PresignPolicy) Route {
+	return func(w http.ResponseWriter, r *http.Request, a App) error {
+		if r.Method != http.MethodPost {
+			www.Respond405(w, http.MethodPost)
+			return nil
+		}
+
+		sm, e1 := SessionManager(r, a)
+		if e1 != nil {
+			return e1
+		}
+
+		accountID := string(sm.Get(SessionKeyAccountID))
+		if accountID == "" {
+			return respondError(w, http.StatusUnauthorized, fmt.Errorf("%v", stderr.NotSignedIn))
+		}
+
+		req := &PresignRequest{}
+		if e := json.NewDecoder(r.Body).Decode(req); e != nil {
+			return respondError(w, http.StatusBadRequest, fmt.Errorf(stderr.DecodeJSON, e.Error()))
+		}
+
+		if req.Name == "" {
+			return respondError(w, http.StatusBadRequest, fmt.Errorf(stderr.FieldRequired, "name"))
+		}
+
+		name, e2 := storage.NormalizeKey(req.Name)
+		if e2 != nil {
+			return respondError(w, http.StatusBadRequest, e2)
+		}
+		req.Name = name
+
+		if !policy.authorize(r, accountID, req) {
+			return respondError(w, http.StatusForbidden, fmt.Errorf(stderr.PresignForbidden, req.Method, req.Name))
+		}
+
+		if policy.MaxSize > 0 && req.Size > policy.MaxSize {
+			return respondError(w, http.StatusRequestEntityTooLarge, fmt.Errorf(stderr.UploadTooLarge, policy.MaxSize))
+		}
+
+		expires := policy.Expires
+		if expires <= 0 {
+			expires = DefaultPresignExpires
+		}
+
+		opts := &storage.PresignOptions{ContentType: req.ContentType, MaxSize: policy.MaxSize}
+		if req.Size > 0 {
+			opts.MaxSize = req.Size
+		}
+
+		var signed *storage.PresignedRequest
+		var e3 error
+
+		switch req.Method {
+		case http.MethodGet:
+			signed, e3 = store.PresignGet(r.Context(), req.Name, expires)
+		case http.MethodPut:
+			if policy.MaxSize > 0 && req.Size == 0 {
+				return respondError(w, http.StatusBadRequest, fmt.Errorf(stderr.FieldRequired, "size"))
+			}
+			if req.Size > 0 {
+				opts.MinSize = req.Size
+			}
+			signed, e3 = store.PresignPut(r.Context(), req.Name, expires, opts)
+		case http.MethodPost:
+			pp, ok := store.(postPresigner)
+			if !ok {
+				return respondError(w, http.StatusBadRequest, fmt.Errorf(stderr.PresignMethod, req.Method))
+			}
+			signed, e3 = pp.PresignPost(r.Context(), req.Name, expires, opts)
+		default:
+			return respondError(w, http.StatusBadRequest, fmt.Errorf(stderr.PresignMethod, req.Method))
+		}
+
+		if e3 != nil {
+			return e3
+		}
+
+		return respondJSON(w, http.StatusOK, signed)
+	}
+}
+
+// ServeSignedURL Make a route that serves the URLs that the Signer of store
+// signed, to download a file with a GET, or upload one with a PUT. The
+// signature authorizes the request, so add the route to PublicPages. Files
+// are sent as attachments, and an upload is saved as
+// "application/octet-stream" unless its content type was signed. For
+// example:
+//
+//	store.Signer = &storage.URLSigner{BaseURL: "https://example.com/api/files", Key: key}
+//	app.AddRoute("/api/files", backend.ServeSignedURL(store))
+//	app.AddRoute("/api/files/presign", backend.PresignURL(store, policy))
+//	backend.PublicPages = append(backend.PublicPages, "/api/files")
+//
+//	NOTE: An upload is held in memory until it is saved, limit its size with
+//	PresignPolicy.MaxSize.
+func ServeSignedURL(store *storage.LocalStorage) Route {
+	return func(w http.ResponseWriter, r *http.Request, a App) error {
+		if r.Method != http.MethodGet && r.Method != http.MethodPut {
+			www.Respond405(w, "GET, PUT")
+			return nil
+		}
+
+		if store.Signer == nil {
+			return fmt.Errorf("%v", stderr.NoURLSigner)
+		}
+
+		u, e1 := store.Signer.Verify(r)
+		if e1 != nil {
+			return respondError(w, http.StatusForbidden, e1)
+		}
+
+		if r.Method == http.MethodGet {
+			return serveSignedFile(w, r, store, u)
+		}
+
+		contentType := r.Header.Get("Content-Type")
+		if u.ContentType != "" && contentType != u.ContentType {
+			return respondError(w, http.StatusForbidden, fmt.Errorf(stderr.ContentTypeMatch, contentType, u.ContentType))
+		}
+
+		body := io.Reader(r.Body)
+		if u.MaxSize > 0 {
+			body = io.LimitReader(r.Body, u.MaxSize+1)
+		}
+
+		data, e2 := io.ReadAll(body)
+		if e2 != nil {
+			return respondError(w, http.StatusBadRequest, fmt.Errorf(stderr.ReadBody, e2.Error()))
+		}
+
+		if u.MaxSize > 0 && int64(len(data)) > u.MaxSize {
+			return respondError(w, http.StatusRequestEntityTooLarge, fmt.Errorf(stderr.UploadTooLarge, u.MaxSize))
+		}
+
+		if int64(len(data)) < u.MinSize {
+			return respondError(w, http.StatusBadRequest, fmt.Errorf(stderr.UploadTooSmall, u.MinSize))
+		}
+
+		// Only keep a content type that was signed, one the uploader chose
+		// could make the browser run the file as a page of this site.
+		if u.ContentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		if e := store.SaveWithOptions(r.Context(), u.Name, data, &storage.SaveOptions{ContentType: contentType}); e != nil {
+			return e
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+		return nil
+	}
+}
+
+// authorize See PresignPolicy.Authorize.
+func (p *PresignPolicy) authorize(r *http.Request, accountID string, req *PresignRequest) bool {
+	if p.Authorize != nil {
+		return p.Authorize(r, accountID, req)
+	}
+
+	return strings.HasPrefix(req.Name, accountID+"/")
+}
+
+func serveSignedFile(w http.ResponseWriter, r *http.Request, store *storage.LocalStorage, u *storage.SignedURL) error {
+	body, info, e1 := store.Open(r.Context(), u.Name)
+	if e1 != nil {
+		if errors.Is(e1, fs.ErrNotExist) {
+			return respondError(w, http.StatusNotFound, fmt.Errorf(stderr.FileNotFound, u.Name, fs.ErrNotExist))
+		}
+		return e1
+	}
+	defer func() { _ = body.Close() }()
+
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	// Files are uploaded by users, so they are always downloaded, never shown
+	// as a page of this site.
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(u.Name)}))
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if info.CacheControl != "" {
+		w.Header().Set("Cache-Control", info.CacheControl)
+	}
+	w.WriteHeader(http.StatusOK)
+
+	if _, e := io.Copy(w, body); e != nil {
+		return fmt.Errorf(stderr.WriteResponse, e.Error())
+	}
+
+	return nil
+}
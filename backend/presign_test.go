@@ -0,0 +1,134 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kohirens/www/session"
+	"github.com/kohirens/www/storage"
+)
+
+func TestPresignURL(t *testing.T) {
+	app, _, sessions := newSessionIndexApp(t)
+	current := signIn(t, app, sessions, "a1")
+
+	anonymous := httptest.NewRequest("GET", "/", nil)
+	anonymous = anonymous.WithContext(session.WithManager(anonymous.Context(), sessions.New()))
+
+	store := &storage.LocalStorage{
+		Signer:  &storage.URLSigner{BaseURL: "https://example.com/api/files", Key: []byte("key")},
+		WorkDir: t.TempDir(),
+	}
+
+	presign := PresignURL(store, &PresignPolicy{MaxSize: 10})
+	serve := ServeSignedURL(store)
+
+	call := func(route Route, r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		if e := route(w, r, app); e != nil {
+			t.Fatalf("route error = %v", e)
+		}
+		return w
+	}
+
+	sign := func(req *PresignRequest, r *http.Request) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(req)
+		return call(presign, httptest.NewRequest("POST", "/api/files/presign", bytes.NewReader(b)).WithContext(r.Context()))
+	}
+
+	tests := []struct {
+		name     string
+		req      *PresignRequest
+		r        *http.Request
+		wantCode int
+	}{
+		{"not-signed-in", &PresignRequest{Method: "GET", Name: "a1/a.txt"}, anonymous, http.StatusUnauthorized},
+		{"other-account", &PresignRequest{Method: "GET", Name: "a2/a.txt"}, current, http.StatusForbidden},
+		{"traversal", &PresignRequest{Method: "GET", Name: "a1/../a2/a.txt"}, current, http.StatusBadRequest},
+		{"normalized", &PresignRequest{Method: "GET", Name: "/a1//a.txt"}, current, http.StatusOK},
+		{"too-large", &PresignRequest{Method: "PUT", Name: "a1/a.txt", Size: 11}, current, http.StatusRequestEntityTooLarge},
+		{"put-without-size", &PresignRequest{Method: "PUT", Name: "a1/a.txt"}, current, http.StatusBadRequest},
+		{"post-to-local", &PresignRequest{Method: "POST", Name: "a1/a.txt", Size: 5}, current, http.StatusBadRequest},
+		{"get", &PresignRequest{Method: "GET", Name: "a1/a.txt"}, current, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := sign(tt.req, tt.r); w.Code != tt.wantCode {
+				t.Errorf("PresignURL() status = %v, want %v: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+		})
+	}
+
+	w1 := sign(&PresignRequest{ContentType: "text/plain", Method: "PUT", Name: "a1/a.txt", Size: 5}, current)
+	put := &storage.PresignedRequest{}
+	if e := json.Unmarshal(w1.Body.Bytes(), put); e != nil || w1.Code != http.StatusOK {
+		t.Fatalf("PresignURL() = %v %s, want a presigned PUT", w1.Code, w1.Body.String())
+	}
+
+	wrongType := httptest.NewRequest(put.Method, put.URL, bytes.NewReader([]byte("hello")))
+	wrongType.Header.Set("Content-Type", "text/html")
+	if w := call(serve, wrongType); w.Code != http.StatusForbidden {
+		t.Errorf("ServeSignedURL() of another content type status = %v, want 403", w.Code)
+	}
+
+	tooLarge := httptest.NewRequest(put.Method, put.URL, bytes.NewReader([]byte("hello world")))
+	tooLarge.Header.Set("Content-Type", "text/plain")
+	if w := call(serve, tooLarge); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("ServeSignedURL() of a larger upload status = %v, want 413", w.Code)
+	}
+
+	upload := httptest.NewRequest(put.Method, put.URL, bytes.NewReader([]byte("hello")))
+	upload.Header.Set("Content-Type", "text/plain")
+	if w := call(serve, upload); w.Code != http.StatusNoContent {
+		t.Fatalf("ServeSignedURL() upload status = %v, want 204: %s", w.Code, w.Body.String())
+	}
+
+	if w := call(serve, httptest.NewRequest(http.MethodGet, put.URL, nil)); w.Code != http.StatusForbidden {
+		t.Errorf("ServeSignedURL() GET with a PUT URL status = %v, want 403", w.Code)
+	}
+
+	w2 := sign(&PresignRequest{Method: "GET", Name: "a1/a.txt"}, current)
+	get := &storage.PresignedRequest{}
+	_ = json.Unmarshal(w2.Body.Bytes(), get)
+
+	w3 := call(serve, httptest.NewRequest(get.Method, get.URL, nil))
+	body, _ := io.ReadAll(w3.Body)
+	if w3.Code != http.StatusOK || string(body) != "hello" || w3.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("ServeSignedURL() download = %v %q %v, want hello as text/plain", w3.Code, body, w3.Header().Get("Content-Type"))
+	}
+
+	if got := w3.Header().Get("Content-Disposition"); got != `attachment; filename=a.txt` {
+		t.Errorf("ServeSignedURL() download Content-Disposition = %q, want an attachment", got)
+	}
+
+	// An upload without a signed content type cannot choose one.
+	w4 := sign(&PresignRequest{Method: "PUT", Name: "a1/page.html", Size: 6}, current)
+	put = &storage.PresignedRequest{}
+	_ = json.Unmarshal(w4.Body.Bytes(), put)
+
+	html := httptest.NewRequest(put.Method, put.URL, bytes.NewReader([]byte("<html>")))
+	html.Header.Set("Content-Type", "text/html")
+	if w := call(serve, html); w.Code != http.StatusNoContent {
+		t.Fatalf("ServeSignedURL() upload status = %v, want 204: %s", w.Code, w.Body.String())
+	}
+
+	w5 := sign(&PresignRequest{Method: "GET", Name: "a1/page.html"}, current)
+	get = &storage.PresignedRequest{}
+	_ = json.Unmarshal(w5.Body.Bytes(), get)
+
+	w6 := call(serve, httptest.NewRequest(get.Method, get.URL, nil))
+	wantHeaders := map[string]string{
+		"Content-Disposition":    "attachment; filename=page.html",
+		"Content-Type":           "application/octet-stream",
+		"X-Content-Type-Options": "nosniff",
+	}
+	for name, want := range wantHeaders {
+		if got := w6.Header().Get(name); got != want {
+			t.Errorf("ServeSignedURL() download of an HTML upload %v = %q, want %q", name, got, want)
+		}
+	}
+}
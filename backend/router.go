@@ -3,6 +3,8 @@ package backend
 import (
 	"net/http"
 	"path/filepath"
+
+	"github.com/kohirens/www"
 )
 
 // Route Is a function similar to http.HandleFunc, but returns an error.
@@ -17,6 +19,9 @@ type Router struct {
 	routes          map[string]Route
 	notFoundHandler Route
 	h               http.HandlerFunc
+	// policies A map of endpoints to the CORS policy they opted in to. Routes
+	// not in this map do not respond to cross-origin requests.
+	policies map[string]*www.CORS
 }
 
 type RouteManager interface {
@@ -25,9 +30,21 @@ type RouteManager interface {
 	NotFound(f Route)
 }
 
+// CORSRouter An optional extension of RouteManager for routes that respond
+// to cross-origin requests. Router implements it.
+type CORSRouter interface {
+	// AllowCORS Opt a route in to responding to cross-origin requests.
+	AllowCORS(route string, policy *www.CORS) error
+	// CORS Find the CORS policy for an endpoint, nil when it has none.
+	CORS(endpoint string) *www.CORS
+}
+
+var _ CORSRouter = (*Router)(nil)
+
 func NewRouteManager() RouteManager {
 	return &Router{
-		routes: make(map[string]Route),
+		routes:   make(map[string]Route),
+		policies: make(map[string]*www.CORS),
 		notFoundHandler: func(w http.ResponseWriter, r *http.Request, _ App) error {
 			w.WriteHeader(http.StatusNotFound)
 			_, _ = w.Write([]byte("Not Found"))
@@ -41,6 +58,33 @@ func (router *Router) Add(route string, fn Route) {
 	router.routes[route] = fn
 }
 
+// AllowCORS Opt a route in to responding to cross-origin requests according
+// to the policy. The route uses the same patterns as Add. An unsafe policy,
+// see www.CORS.Validate, is rejected.
+func (router *Router) AllowCORS(route string, policy *www.CORS) error {
+	if e := policy.Validate(); e != nil {
+		return e
+	}
+
+	if router.policies == nil {
+		router.policies = make(map[string]*www.CORS)
+	}
+	router.policies[route] = policy
+
+	return nil
+}
+
+// CORS Find the CORS policy for an endpoint, using the same lookup as Find.
+// Return nil when the endpoint has not opted in.
+func (router *Router) CORS(endpoint string) *www.CORS {
+	policy, ok := router.policies[endpoint]
+	if !ok {
+		policy = router.policies["*"+filepath.Ext(endpoint)]
+	}
+
+	return policy
+}
+
 // NotFound Return a 404 response when an endpoint does not map to a handler
 // function.
 func (router *Router) NotFound(f Route) {
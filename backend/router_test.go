@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"net/http"
 	"testing"
+
+	"github.com/kohirens/www"
 )
 
 func TestRouter_Route(t *testing.T) {
@@ -40,3 +42,42 @@ func TestRouter_Route(t *testing.T) {
 		})
 	}
 }
+
+func TestRouter_CORS(t *testing.T) {
+	policy := &www.CORS{AllowedOrigins: []string{"https://app.example.com"}}
+	tests := []struct {
+		name     string
+		route    string
+		endpoint string
+		want     *www.CORS
+	}{
+		{"exact", "/api/meals", "/api/meals", policy},
+		{"wildcard", "*.json", "/api/meals.json", policy},
+		{"not-opted-in", "/api/meals", "/api/plans", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := NewRouteManager().(CORSRouter)
+			if e := router.AllowCORS(tt.route, policy); e != nil {
+				t.Fatalf("AllowCORS() error = %v", e)
+			}
+
+			if got := router.CORS(tt.endpoint); got != tt.want {
+				t.Errorf("CORS() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouter_AllowCORS_Unsafe(t *testing.T) {
+	router := NewRouteManager().(CORSRouter)
+	policy := &www.CORS{AllowedOrigins: []string{"*"}, AllowCredentials: true}
+
+	if e := router.AllowCORS("/api/meals", policy); e == nil {
+		t.Errorf("AllowCORS() of a credentialed wildcard did not fail")
+	}
+
+	if got := router.CORS("/api/meals"); got != nil {
+		t.Errorf("CORS() = %v, want nil", got)
+	}
+}
@@ -0,0 +1,51 @@
+package backend
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/kohirens/www/session"
+)
+
+// SessionManager The session of a request. This is the Manager that ServeHTTP
+// or ServeLambda made for the request from the session.SessionStore service
+// at KeySessionStore. When there is no SessionStore, it falls back to the
+// Manager service at KeySessionManager, which all requests share.
+func SessionManager(r *http.Request, a App) (*session.Manager, error) {
+	if sm, ok := session.FromRequest(r); ok {
+		return sm, nil
+	}
+
+	x, e1 := a.Service(KeySessionManager)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	sm, ok := x.(*session.Manager)
+	if !ok {
+		return nil, fmt.Errorf(stderr.ServiceTypeMatch, KeySessionManager)
+	}
+
+	return sm, nil
+}
+
+// withSession Attach a new Manager to the request when a SessionStore is
+// registered, so that concurrent requests do not share a session.
+func (a *Api) withSession(r *http.Request) *http.Request {
+	if _, ok := session.FromRequest(r); ok {
+		return r
+	}
+
+	x, e1 := a.serviceManager.Get(KeySessionStore)
+	if e1 != nil {
+		return r
+	}
+
+	store, ok := x.(*session.SessionStore)
+	if !ok {
+		Log.Warnf(stderr.ServiceTypeMatch, KeySessionStore)
+		return r
+	}
+
+	return r.WithContext(session.WithManager(r.Context(), store.New()))
+}
@@ -0,0 +1,113 @@
+package backend
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kohirens/www/session"
+	"github.com/kohirens/www/storage"
+)
+
+// mockProvider An authentication provider with no state to save.
+type mockProvider struct{}
+
+func (m *mockProvider) AuthLink(loginHint string) (string, error) { return "", nil }
+func (m *mockProvider) Name() string                              { return "mock" }
+func (m *mockProvider) Application() string                       { return "" }
+func (m *mockProvider) ClientEmail() string                       { return "" }
+func (m *mockProvider) ClientID() string                          { return "" }
+func (m *mockProvider) SignOut() error                            { return nil }
+
+func TestSessionManager(t *testing.T) {
+	store, _ := storage.NewLocalStorage(tmpDir)
+	shared := session.NewManager(session.NewMemoryStorage(0), "", time.Minute)
+	perRequest := session.NewManager(session.NewMemoryStorage(0), "", time.Minute)
+
+	tests := []struct {
+		name     string
+		services map[string]any
+		request  *http.Request
+		want     *session.Manager
+		wantErr  bool
+	}{
+		{"none", nil, nil, nil, true},
+		{"wrong-type", map[string]any{KeySessionManager: "sm"}, nil, nil, true},
+		{"shared", map[string]any{KeySessionManager: shared}, nil, shared, false},
+		{
+			"per-request",
+			map[string]any{KeySessionManager: shared},
+			httptest.NewRequest("GET", "/", nil).WithContext(session.WithManager(t.Context(), perRequest)),
+			perRequest,
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := NewWithDefaults("test", store)
+			for k, v := range tt.services {
+				app.AddService(k, v)
+			}
+
+			got, e1 := SessionManager(tt.request, app)
+			if (e1 != nil) != tt.wantErr {
+				t.Fatalf("SessionManager() error = %v, wantErr %v", e1, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("SessionManager() = %p, want %p", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApi_ServeHTTP_ConcurrentSessions(t *testing.T) {
+	store, _ := storage.NewLocalStorage(tmpDir)
+	app := NewWithDefaults("test", store)
+	app.AuthManager().Add(KeyGoogleProvider, &mockProvider{})
+	app.AddService(KeySessionStore, session.NewSessionStore(session.NewMemoryStorage(0), "", time.Minute))
+
+	app.AddRoute("/", func(w http.ResponseWriter, r *http.Request, a App) error {
+		sm, e1 := SessionManager(r, a)
+		if e1 != nil {
+			return e1
+		}
+
+		want := r.URL.Query().Get("n")
+		sm.Set("n", []byte(want))
+		time.Sleep(time.Millisecond)
+
+		if got := string(sm.Get("n")); got != want {
+			t.Errorf("Get() = %v, want %v", got, want)
+		}
+
+		w.Header().Set(session.IDKey, sm.ID().String())
+		return nil
+	})
+
+	ids := make(map[string]bool)
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest("GET", fmt.Sprintf("/?n=%v", i), nil))
+
+			mutex.Lock()
+			ids[w.Header().Get(session.IDKey)] = true
+			mutex.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	if len(ids) != 20 {
+		t.Errorf("ServeHTTP() made %v sessions for 20 requests, want 20", len(ids))
+	}
+}
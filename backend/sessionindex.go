@@ -0,0 +1,302 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kohirens/www/session"
+	"github.com/kohirens/www/storage"
+)
+
+// SessionInfo A session an account is signed in with, as shown on a "where
+// you're signed in" page.
+type SessionInfo struct {
+	Created  time.Time `json:"created"`
+	DeviceID string    `json:"device_id"`
+	ID       string    `json:"id"`
+	IP       string    `json:"ip"`
+	// UserAgent Of the browser that signed in.
+	UserAgent string `json:"user_agent"`
+
+	// The following are filled in by List.
+
+	// Current Set on the session of the request that asked for the list.
+	Current      bool      `json:"current,omitempty"`
+	Expiration   time.Time `json:"expiration"`
+	LastActivity time.Time `json:"last_activity"`
+}
+
+// SessionIndex Keeps track of the sessions each account is signed in with,
+// so that a user can see them and sign out of other devices.
+type SessionIndex interface {
+	// Add A session to the index of an account, call it when a user signs
+	// in.
+	Add(accountID string, info *SessionInfo) error
+	// List The active sessions of an account.
+	List(accountID string) ([]*SessionInfo, error)
+	// Remove A session from the index of an account, without ending it. Call
+	// it when a user signs out.
+	Remove(accountID, sessionID string) error
+	// Revoke End a session of an account, signing it out.
+	Revoke(accountID, sessionID string) error
+	// RevokeAll End every session of an account, except the session with the
+	// ID except, which can be empty.
+	RevokeAll(accountID, except string) error
+}
+
+// SessionIndexExec An implementation of SessionIndex that keeps the index in
+// storage and ends sessions by removing them from the session storage. Each
+// session has a key of its own, so that instances signing in to the same
+// account at once do not overwrite each other.
+//
+//	NOTE: Sessions kept in the client's cookies, see session.CookieStorage,
+//	cannot be ended from another device.
+type SessionIndexExec struct {
+	sessions *session.SessionStore
+	store    storage.Storage
+}
+
+const (
+	PrefixSessionIndex = "sessionindex"
+
+	// SessionKeyAccountID The session item that holds the ID of the account
+	// that is signed in.
+	SessionKeyAccountID = "accountID"
+)
+
+var (
+	_ SessionIndex = (*SessionIndexExec)(nil)
+
+	// sessionIndexNow Allows tests to control the clock.
+	sessionIndexNow = time.Now
+)
+
+// NewSessionIndexExec Initialize a session index kept in store, for the
+// sessions of the session store.
+func NewSessionIndexExec(store storage.Storage, sessions *session.SessionStore) *SessionIndexExec {
+	return &SessionIndexExec{
+		sessions: sessions,
+		store:    store,
+	}
+}
+
+// NewSessionInfo Describe the session of a request that has just signed in.
+func NewSessionInfo(sessionID, deviceID string, r *http.Request) *SessionInfo {
+	return &SessionInfo{
+		Created:   sessionIndexNow().UTC(),
+		DeviceID:  deviceID,
+		ID:        sessionID,
+		IP:        r.RemoteAddr,
+		UserAgent: r.Header.Get("User-Agent"),
+	}
+}
+
+// Add A session to the index of an account, replacing one with the same ID.
+// Sessions of the account that have expired or no longer exist, such as
+// those given a new ID by Manager.Regenerate, are removed from the index.
+func (si *SessionIndexExec) Add(accountID string, info *SessionInfo) error {
+	if e := si.saveInfo(accountID, info); e != nil {
+		return e
+	}
+
+	_, stale, e1 := si.load(accountID)
+	if e1 != nil {
+		return e1
+	}
+
+	for _, id := range stale {
+		if id == info.ID {
+			continue
+		}
+
+		if e := si.Remove(accountID, id); e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// IndexLocation Where the sessions of an account are kept in storage, one
+// key per session.
+func (si *SessionIndexExec) IndexLocation(accountID string) string {
+	return fmt.Sprintf(PrefixSessionIndex+"/%v", accountID)
+}
+
+// InfoLocation Where a session of an account is kept in storage.
+func (si *SessionIndexExec) InfoLocation(accountID, sessionID string) string {
+	return fmt.Sprintf("%v/%v.json", si.IndexLocation(accountID), sessionID)
+}
+
+// List The active sessions of an account, oldest first, with the last
+// activity and expiration from the session storage. Sessions that have
+// expired or no longer exist are left out, but stay in the index until the
+// next Add, so that listing never writes.
+func (si *SessionIndexExec) List(accountID string) ([]*SessionInfo, error) {
+	active, _, e1 := si.load(accountID)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	return active, nil
+}
+
+// Remove A session from the index of an account.
+func (si *SessionIndexExec) Remove(accountID, sessionID string) error {
+	location := si.InfoLocation(accountID, sessionID)
+	if !si.store.Exist(location) {
+		return nil
+	}
+
+	return si.store.Remove(location)
+}
+
+// Revoke End a session of an account. A SessionNotFoundError is returned
+// when the account has no such session, so that one account cannot end the
+// sessions of another.
+func (si *SessionIndexExec) Revoke(accountID, sessionID string) error {
+	// The ID becomes part of a path, so only accept session IDs.
+	if _, e := uuid.Parse(sessionID); e != nil {
+		return &SessionNotFoundError{sessionID}
+	}
+
+	if !si.store.Exist(si.InfoLocation(accountID, sessionID)) {
+		return &SessionNotFoundError{sessionID}
+	}
+
+	if e := si.end(sessionID); e != nil {
+		return e
+	}
+
+	return si.Remove(accountID, sessionID)
+}
+
+// RevokeAll End every session of an account, except one.
+func (si *SessionIndexExec) RevokeAll(accountID, except string) error {
+	ids, e1 := si.ids(accountID)
+	if e1 != nil {
+		return e1
+	}
+
+	for _, id := range ids {
+		if id == except {
+			continue
+		}
+
+		if e := si.end(id); e != nil {
+			return e
+		}
+
+		if e := si.Remove(accountID, id); e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// end Remove a session from the session storage, a session that is already
+// gone is not an error.
+func (si *SessionIndexExec) end(sessionID string) error {
+	key := session.StorageKey(si.sessions.Location(), sessionID)
+	if _, e := si.sessions.Storage().Load(key); e != nil {
+		if errors.Is(e, fs.ErrNotExist) {
+			return nil
+		}
+		return e
+	}
+
+	return si.sessions.Storage().Remove(key)
+}
+
+// ids The IDs of the sessions in the index of an account.
+func (si *SessionIndexExec) ids(accountID string) ([]string, error) {
+	names, e1 := si.store.List(si.IndexLocation(accountID))
+	if e1 != nil {
+		return nil, e1
+	}
+
+	ids := make([]string, 0, len(names))
+	for _, name := range names {
+		if id, ok := strings.CutSuffix(name, ".json"); ok {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+// load The sessions in the index of an account, split into the active ones
+// and the IDs of those that have expired or no longer exist. Any other error
+// loading a session is returned, so that a session is never taken for stale
+// because its storage failed.
+func (si *SessionIndexExec) load(accountID string) ([]*SessionInfo, []string, error) {
+	ids, e1 := si.ids(accountID)
+	if e1 != nil {
+		return nil, nil, e1
+	}
+
+	now := sessionIndexNow().UTC()
+	active := make([]*SessionInfo, 0, len(ids))
+	stale := make([]string, 0)
+
+	for _, id := range ids {
+		data, e2 := si.store.Load(si.InfoLocation(accountID, id))
+		if e2 != nil {
+			// Removed since it was listed.
+			continue
+		}
+
+		info := &SessionInfo{}
+		if e := json.Unmarshal(data, info); e != nil {
+			return nil, nil, fmt.Errorf(stderr.DecodeJSON, e.Error())
+		}
+
+		sessionData, e3 := si.sessions.Storage().Load(session.StorageKey(si.sessions.Location(), id))
+		if errors.Is(e3, fs.ErrNotExist) {
+			stale = append(stale, id)
+			continue
+		}
+		if e3 != nil {
+			// It may only be unreachable for now, so it is not stale.
+			return nil, nil, e3
+		}
+
+		d := &session.Data{}
+		if e := json.Unmarshal(sessionData, d); e != nil || now.After(d.Expiration) {
+			stale = append(stale, id)
+			continue
+		}
+
+		info.Expiration = d.Expiration
+		info.LastActivity = d.LastActivity
+		active = append(active, info)
+	}
+
+	slices.SortStableFunc(active, func(a, b *SessionInfo) int { return a.Created.Compare(b.Created) })
+
+	return active, stale, nil
+}
+
+func (si *SessionIndexExec) saveInfo(accountID string, info *SessionInfo) error {
+	// Only the fields that are kept, the rest are filled in by List.
+	data, e1 := json.Marshal(&SessionInfo{
+		Created:   info.Created,
+		DeviceID:  info.DeviceID,
+		ID:        info.ID,
+		IP:        info.IP,
+		UserAgent: info.UserAgent,
+	})
+	if e1 != nil {
+		return fmt.Errorf(stderr.EncodeJSON, e1.Error())
+	}
+
+	return si.store.Save(si.InfoLocation(accountID, info.ID), data)
+}
@@ -0,0 +1,180 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/kohirens/www"
+	"github.com/kohirens/www/session"
+)
+
+// sessionRequest The JSON body of the session routes.
+type sessionRequest struct {
+	ID string `json:"id"`
+}
+
+// ListSessions Route that lists the sessions the account of the request is
+// signed in with. For example:
+//
+//	sessions := session.NewSessionStore(sessionStorage, "sessions", time.Hour)
+//	app.AddService(backend.KeySessionStore, sessions)
+//	app.AddService(backend.KeySessionIndex, backend.NewSessionIndexExec(store, sessions))
+//	app.AddRoute("/api/sessions", backend.ListSessions)
+//	app.AddRoute("/api/sessions/revoke", backend.RevokeSession)
+//	app.AddRoute("/api/sessions/revoke-others", backend.RevokeOtherSessions)
+func ListSessions(w http.ResponseWriter, r *http.Request, a App) error {
+	if r.Method != http.MethodGet {
+		www.Respond405(w, http.MethodGet)
+		return nil
+	}
+
+	si, sm, accountID, ok, e1 := sessionAccount(w, r, a)
+	if !ok {
+		return e1
+	}
+
+	infos, e2 := si.List(accountID)
+	if e2 != nil {
+		return e2
+	}
+
+	current := sm.ID().String()
+	for _, info := range infos {
+		info.Current = info.ID == current
+	}
+
+	return respondJSON(w, http.StatusOK, infos)
+}
+
+// RevokeOtherSessions Route that signs the account of the request out of
+// every other session.
+func RevokeOtherSessions(w http.ResponseWriter, r *http.Request, a App) error {
+	if r.Method != http.MethodPost {
+		www.Respond405(w, http.MethodPost)
+		return nil
+	}
+
+	si, sm, accountID, ok, e1 := sessionAccount(w, r, a)
+	if !ok {
+		return e1
+	}
+
+	if e := si.RevokeAll(accountID, sm.ID().String()); e != nil {
+		return e
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+// RevokeSession Route that signs the account of the request out of the
+// session with the "id" in the JSON body.
+func RevokeSession(w http.ResponseWriter, r *http.Request, a App) error {
+	if r.Method != http.MethodPost {
+		www.Respond405(w, http.MethodPost)
+		return nil
+	}
+
+	si, _, accountID, ok, e1 := sessionAccount(w, r, a)
+	if !ok {
+		return e1
+	}
+
+	req := &sessionRequest{}
+	if e := json.NewDecoder(r.Body).Decode(req); e != nil {
+		return respondError(w, http.StatusBadRequest, fmt.Errorf(stderr.DecodeJSON, e.Error()))
+	}
+
+	if e := si.Revoke(accountID, req.ID); e != nil {
+		var nf *SessionNotFoundError
+		if errors.As(e, &nf) {
+			return respondError(w, http.StatusNotFound, e)
+		}
+		return e
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+// TrackSignIn Record that the session of the request is signed in to an
+// account. Call it from a login route after Manager.Regenerate. The session
+// is added to the SessionIndex when one is registered at KeySessionIndex.
+func TrackSignIn(r *http.Request, a App, accountID, deviceID string) error {
+	sm, e1 := SessionManager(r, a)
+	if e1 != nil {
+		return e1
+	}
+
+	sm.Set(SessionKeyAccountID, []byte(accountID))
+
+	si, e2 := sessionIndex(a)
+	if e2 != nil {
+		return nil
+	}
+
+	return si.Add(accountID, NewSessionInfo(sm.ID().String(), deviceID, r))
+}
+
+// TrackSignOut Record that the session of the request is signing out. Call it
+// from a logout route before Manager.Regenerate gives the session a new ID.
+func TrackSignOut(r *http.Request, a App) error {
+	sm, e1 := SessionManager(r, a)
+	if e1 != nil {
+		return e1
+	}
+
+	accountID := string(sm.Get(SessionKeyAccountID))
+	if accountID == "" {
+		return nil
+	}
+
+	_ = sm.Remove(SessionKeyAccountID)
+
+	si, e2 := sessionIndex(a)
+	if e2 != nil {
+		return nil
+	}
+
+	return si.Remove(accountID, sm.ID().String())
+}
+
+// sessionAccount Get the session index and the account the session of the
+// request is signed in to. When ok is false the route must return the error,
+// which is nil when a response has already been written.
+func sessionAccount(w http.ResponseWriter, r *http.Request, a App) (SessionIndex, *session.Manager, string, bool, error) {
+	si, e1 := sessionIndex(a)
+	if e1 != nil {
+		return nil, nil, "", false, e1
+	}
+
+	sm, e2 := SessionManager(r, a)
+	if e2 != nil {
+		return nil, nil, "", false, e2
+	}
+
+	accountID := string(sm.Get(SessionKeyAccountID))
+	if accountID == "" {
+		return nil, nil, "", false, respondError(w, http.StatusUnauthorized, fmt.Errorf("%v", stderr.NotSignedIn))
+	}
+
+	return si, sm, accountID, true, nil
+}
+
+func sessionIndex(a App) (SessionIndex, error) {
+	x, e1 := a.Service(KeySessionIndex)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	si, ok := x.(SessionIndex)
+	if !ok {
+		return nil, fmt.Errorf(stderr.ServiceTypeMatch, KeySessionIndex)
+	}
+
+	return si, nil
+}
@@ -0,0 +1,329 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kohirens/www/session"
+	"github.com/kohirens/www/storage"
+)
+
+// newSessionIndexApp An app with a session index and a signed-in session per
+// account.
+func newSessionIndexApp(t *testing.T) (App, *SessionIndexExec, *session.SessionStore) {
+	t.Helper()
+
+	store := storage.NewMemoryStorage(0, 0)
+	sessions := session.NewSessionStore(session.NewMemoryStorage(0), "sessions", time.Hour)
+	si := NewSessionIndexExec(store, sessions)
+
+	app := NewWithDefaults("test", store)
+	app.AddService(KeySessionStore, sessions)
+	app.AddService(KeySessionIndex, si)
+
+	return app, si, sessions
+}
+
+// signIn Sign a new session in to an account, as a login route would.
+func signIn(t *testing.T, a App, sessions *session.SessionStore, accountID string) *http.Request {
+	t.Helper()
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("User-Agent", "test-agent")
+	r = r.WithContext(session.WithManager(r.Context(), sessions.New()))
+
+	if e := TrackSignIn(r, a, accountID, "device-1"); e != nil {
+		t.Fatalf("TrackSignIn() error = %v", e)
+	}
+
+	sm, _ := session.FromRequest(r)
+	if e := sm.Save(); e != nil {
+		t.Fatal(e)
+	}
+
+	return r
+}
+
+func TestSessionIndexExec(t *testing.T) {
+	app, si, sessions := newSessionIndexApp(t)
+
+	r1 := signIn(t, app, sessions, "a1")
+	r2 := signIn(t, app, sessions, "a1")
+	r3 := signIn(t, app, sessions, "a1")
+	other := signIn(t, app, sessions, "a2")
+	sm1, _ := session.FromRequest(r1)
+	sm2, _ := session.FromRequest(r2)
+	sm3, _ := session.FromRequest(r3)
+	smOther, _ := session.FromRequest(other)
+
+	infos, e1 := si.List("a1")
+	if e1 != nil {
+		t.Fatalf("List() error = %v", e1)
+	}
+	if len(infos) != 3 {
+		t.Fatalf("List() = %v sessions, want 3", len(infos))
+	}
+	if infos[0].UserAgent != "test-agent" || infos[0].DeviceID != "device-1" || infos[0].LastActivity.IsZero() {
+		t.Errorf("List() = %+v, want the user agent, device and last activity", infos[0])
+	}
+
+	// One account cannot end the sessions of another.
+	var nf *SessionNotFoundError
+	if e := si.Revoke("a1", smOther.ID().String()); !errors.As(e, &nf) {
+		t.Errorf("Revoke() of another account's session error = %v, want a SessionNotFoundError", e)
+	}
+
+	if e := si.Revoke("a1", sm2.ID().String()); e != nil {
+		t.Fatalf("Revoke() error = %v", e)
+	}
+	if e := sessions.New().Restore(sm2.ID().String()); e == nil {
+		t.Errorf("Restore() of a revoked session did not fail")
+	}
+
+	if e := si.RevokeAll("a1", sm1.ID().String()); e != nil {
+		t.Fatalf("RevokeAll() error = %v", e)
+	}
+	if e := sessions.New().Restore(sm3.ID().String()); e == nil {
+		t.Errorf("Restore() of a revoked session did not fail")
+	}
+	if e := sessions.New().Restore(sm1.ID().String()); e != nil {
+		t.Errorf("RevokeAll() ended the session it was told to keep: %v", e)
+	}
+
+	if infos, _ := si.List("a1"); len(infos) != 1 || infos[0].ID != sm1.ID().String() {
+		t.Errorf("List() after RevokeAll() = %v, want only %v", infos, sm1.ID())
+	}
+
+	if infos, _ := si.List("a2"); len(infos) != 1 {
+		t.Errorf("List() of the other account = %v sessions, want 1", len(infos))
+	}
+}
+
+func TestSessionIndexExec_Add_Prunes(t *testing.T) {
+	app, si, sessions := newSessionIndexApp(t)
+	r := signIn(t, app, sessions, "a1")
+	sm, _ := session.FromRequest(r)
+
+	// A session that was never saved, as after Manager.Regenerate.
+	_ = si.Add("a1", &SessionInfo{ID: "gone"})
+
+	sessionIndexNow = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	t.Cleanup(func() { sessionIndexNow = time.Now })
+
+	if infos, _ := si.List("a1"); len(infos) != 0 {
+		t.Errorf("List() = %v, want the expired and missing sessions left out", infos)
+	}
+
+	// Listing never writes.
+	for _, id := range []string{"gone", sm.ID().String()} {
+		if !si.store.Exist(si.InfoLocation("a1", id)) {
+			t.Errorf("List() removed %v from the index", id)
+		}
+	}
+
+	if e := si.Add("a1", &SessionInfo{ID: "new"}); e != nil {
+		t.Fatalf("Add() error = %v", e)
+	}
+
+	sessionIndexNow = time.Now
+	if infos, _ := si.List("a1"); len(infos) != 0 {
+		t.Errorf("List() = %v, want %v removed from the index", infos, sm.ID())
+	}
+	if si.store.Exist(si.InfoLocation("a1", "gone")) {
+		t.Errorf("Add() left the missing session in the index")
+	}
+	if !si.store.Exist(si.InfoLocation("a1", "new")) {
+		t.Errorf("Add() removed the session it added")
+	}
+}
+
+func TestSessionIndexExec_Add_Instances(t *testing.T) {
+	_, si, sessions := newSessionIndexApp(t)
+	// Another instance of the app, sharing the same storage.
+	other := NewSessionIndexExec(si.store, sessions)
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			idx := si
+			if i%2 == 1 {
+				idx = other
+			}
+
+			sm := sessions.New()
+			sm.Set("k", []byte("v"))
+			if e := sm.Save(); e != nil {
+				t.Error(e)
+				return
+			}
+
+			if e := idx.Add("a1", NewSessionInfo(sm.ID().String(), "device-1", httptest.NewRequest("GET", "/", nil))); e != nil {
+				t.Errorf("Add() error = %v", e)
+			}
+		}()
+	}
+	wg.Wait()
+
+	infos, _ := si.List("a1")
+	if len(infos) != 20 {
+		t.Errorf("List() = %v sessions, want all 20 kept", len(infos))
+	}
+}
+
+func TestSessionIndexExec_LocalStorage(t *testing.T) {
+	store, e1 := storage.NewLocalStorage(t.TempDir())
+	if e1 != nil {
+		t.Fatal(e1)
+	}
+
+	sessions := session.NewSessionStore(session.NewMemoryStorage(0), "sessions", time.Hour)
+	si := NewSessionIndexExec(store, sessions)
+
+	app := NewWithDefaults("test", store)
+	app.AddService(KeySessionStore, sessions)
+	app.AddService(KeySessionIndex, si)
+
+	// The directory of a new account is made as it signs in.
+	r := signIn(t, app, sessions, "a1")
+	sm, _ := session.FromRequest(r)
+
+	if infos, e := si.List("a1"); e != nil || len(infos) != 1 {
+		t.Fatalf("List() = %v, %v, want 1 session", infos, e)
+	}
+
+	if e := si.Revoke("a1", sm.ID().String()); e != nil {
+		t.Errorf("Revoke() error = %v", e)
+	}
+}
+
+// unavailableStorage Session storage that fails to load, as when its
+// database cannot be reached.
+type unavailableStorage struct {
+	session.Storage
+	down bool
+}
+
+func (s *unavailableStorage) Load(id string) ([]byte, error) {
+	if s.down {
+		return nil, errors.New("unavailable")
+	}
+	return s.Storage.Load(id)
+}
+
+func TestSessionIndexExec_Unavailable(t *testing.T) {
+	sessionStorage := &unavailableStorage{Storage: session.NewMemoryStorage(0)}
+	sessions := session.NewSessionStore(sessionStorage, "sessions", time.Hour)
+	si := NewSessionIndexExec(storage.NewMemoryStorage(0, 0), sessions)
+
+	app := NewWithDefaults("test", storage.NewMemoryStorage(0, 0))
+	app.AddService(KeySessionStore, sessions)
+	app.AddService(KeySessionIndex, si)
+
+	r := signIn(t, app, sessions, "a1")
+	sm, _ := session.FromRequest(r)
+
+	sessionStorage.down = true
+
+	if _, e := si.List("a1"); e == nil {
+		t.Errorf("List() with the session storage down did not fail")
+	}
+	if e := si.Add("a1", &SessionInfo{ID: "new"}); e == nil {
+		t.Errorf("Add() with the session storage down did not fail")
+	}
+	if e := si.Revoke("a1", sm.ID().String()); e == nil {
+		t.Errorf("Revoke() with the session storage down did not fail")
+	}
+
+	sessionStorage.down = false
+
+	if infos, _ := si.List("a1"); len(infos) != 1 || infos[0].ID != sm.ID().String() {
+		t.Errorf("List() = %v, want %v kept while the session storage was down", infos, sm.ID())
+	}
+}
+
+func TestTrackSignOut(t *testing.T) {
+	app, si, sessions := newSessionIndexApp(t)
+	r := signIn(t, app, sessions, "a1")
+	sm, _ := session.FromRequest(r)
+
+	if e := TrackSignOut(r, app); e != nil {
+		t.Fatalf("TrackSignOut() error = %v", e)
+	}
+
+	if got := sm.Get(SessionKeyAccountID); got != nil {
+		t.Errorf("TrackSignOut() left account ID %s in the session", got)
+	}
+
+	if infos, _ := si.List("a1"); len(infos) != 0 {
+		t.Errorf("List() after TrackSignOut() = %v, want none", infos)
+	}
+}
+
+func TestSessionRoutes(t *testing.T) {
+	app, _, sessions := newSessionIndexApp(t)
+	current := signIn(t, app, sessions, "a1")
+	other := signIn(t, app, sessions, "a1")
+	smOther, _ := session.FromRequest(other)
+
+	call := func(route Route, method string, body any, r *http.Request) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, "/api/sessions", bytes.NewReader(b)).WithContext(r.Context())
+		w := httptest.NewRecorder()
+		if e := route(w, req, app); e != nil {
+			t.Fatalf("route error = %v", e)
+		}
+		return w
+	}
+
+	w1 := call(ListSessions, "GET", nil, current)
+	var infos []*SessionInfo
+	_ = json.Unmarshal(w1.Body.Bytes(), &infos)
+	if w1.Code != http.StatusOK || len(infos) != 2 {
+		t.Fatalf("ListSessions() = %v %s, want 2 sessions", w1.Code, w1.Body.String())
+	}
+
+	currents := 0
+	for _, info := range infos {
+		if info.Current {
+			currents++
+		}
+	}
+	if currents != 1 {
+		t.Errorf("ListSessions() marked %v sessions current, want 1", currents)
+	}
+
+	anonymous := httptest.NewRequest("GET", "/", nil)
+	anonymous = anonymous.WithContext(session.WithManager(anonymous.Context(), sessions.New()))
+
+	tests := []struct {
+		name     string
+		route    Route
+		method   string
+		body     any
+		r        *http.Request
+		wantCode int
+	}{
+		{"list-wrong-method", ListSessions, "POST", nil, current, http.StatusMethodNotAllowed},
+		{"list-not-signed-in", ListSessions, "GET", nil, anonymous, http.StatusUnauthorized},
+		{"revoke-missing", RevokeSession, "POST", map[string]any{"id": "nope"}, current, http.StatusNotFound},
+		{"revoke", RevokeSession, "POST", map[string]any{"id": smOther.ID().String()}, current, http.StatusNoContent},
+		{"revoke-others", RevokeOtherSessions, "POST", nil, current, http.StatusNoContent},
+		{"revoke-others-wrong-method", RevokeOtherSessions, "GET", nil, current, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := call(tt.route, tt.method, tt.body, tt.r); w.Code != tt.wantCode {
+				t.Errorf("status = %v, want %v: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+		})
+	}
+}
@@ -1,11 +1,14 @@
 package backend
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"maps"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
 	"text/template"
 
 	"github.com/kohirens/www/storage"
@@ -17,6 +20,7 @@ type Renderer struct {
 	suffix    string
 	Vars      map[string]any
 	functions template.FuncMap
+	mutex     sync.Mutex
 }
 
 type TemplateManager interface {
@@ -46,8 +50,20 @@ type TemplateManager interface {
 	RenderFiles(w io.Writer, vars map[string]any, names ...string) (*template.Template, error)
 }
 
+// RequestRenderer An optional interface for a TemplateManager that can make
+// a copy of itself for one request, with variables that only that request
+// sees. Renderer implements it.
+type RequestRenderer interface {
+	ForRequest(vars map[string]any) TemplateManager
+}
+
 type Variables map[string]any
 
+// templatesKey The key of the TemplateManager of a request in its context.
+type templatesKey struct{}
+
+var _ RequestRenderer = (*Renderer)(nil)
+
 const ps = string(os.PathSeparator)
 
 func NewTemplateManager(store storage.Storage, location, suffix string) TemplateManager {
@@ -67,8 +83,24 @@ func (m *Renderer) AddFunctions(functions template.FuncMap) {
 	}
 }
 
+// Templates The TemplateManager of a request, with the variables that
+// ServeHTTP adds for the request, such as URL_Path. It falls back to the
+// TemplateManager of the app, which all requests share.
+func Templates(r *http.Request, a App) TemplateManager {
+	if r != nil {
+		if tm, ok := r.Context().Value(templatesKey{}).(TemplateManager); ok {
+			return tm
+		}
+	}
+
+	return a.TmplManager()
+}
+
 // AddVar Add an item to the variable map.
 func (m *Renderer) AddVar(k string, v any) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
 	m.Vars[k] = v
 }
 
@@ -76,9 +108,28 @@ func (m *Renderer) AddVar(k string, v any) {
 //
 //	NOTE: When a key matches an existing key it will overwrite its value.
 func (m *Renderer) AppendVars(vars map[string]any) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
 	maps.Copy(m.Vars, vars)
 }
 
+// ForRequest A copy of the Renderer with the variables added to a copy of
+// its own, so that requests served at the same time do not see each other's
+// variables.
+func (m *Renderer) ForRequest(vars map[string]any) TemplateManager {
+	c := &Renderer{
+		store:     m.store,
+		location:  m.location,
+		suffix:    m.suffix,
+		Vars:      m.copyVars(),
+		functions: maps.Clone(m.functions),
+	}
+	maps.Copy(c.Vars, vars)
+
+	return c
+}
+
 // Load Parse a template into memory, but it will not render it, instead, the
 // template.Template object is returned so that you can render it when you want.
 func (m *Renderer) Load(name string) (*template.Template, error) {
@@ -145,12 +196,11 @@ func (m *Renderer) Render(name string, w io.Writer, vars map[string]any) error {
 		return e1
 	}
 
-	// Combine vars with any previously added to the manager.
-	if vars != nil {
-		m.AppendVars(vars)
-	}
+	// Combine vars with a copy of those added to the manager.
+	data := m.copyVars()
+	maps.Copy(data, vars)
 
-	return t.Execute(w, m.Vars)
+	return t.Execute(w, data)
 }
 
 // RenderFiles Parse multiple templates that produces the desired output.
@@ -163,20 +213,39 @@ func (m *Renderer) RenderFiles(w io.Writer, vars map[string]any, names ...string
 		return nil, e1
 	}
 
-	// Combine vars with any previously added to the manager.
-	if vars != nil {
-		m.AppendVars(vars)
-	}
+	// Combine vars with a copy of those added to the manager.
+	data := m.copyVars()
+	maps.Copy(data, vars)
 
-	if e := t.Execute(w, m.Vars); e != nil {
+	if e := t.Execute(w, data); e != nil {
 		return nil, fmt.Errorf(stderr.RenderFiles, e.Error())
 	}
 	return t, nil
 }
 
+// copyVars A copy of the variables, so that a template can use them while
+// others are added.
+func (m *Renderer) copyVars() map[string]any {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return maps.Clone(m.Vars)
+}
+
 func buildFilename(m *Renderer, name string) string {
 	if len(m.location) > 0 && m.location[len(m.location)-1] != '/' {
 		return m.location + ps + name + "." + m.suffix
 	}
 	return m.location + name + "." + m.suffix
 }
+
+// withTemplates Attach a copy of the TemplateManager with the variables of
+// the request to it, when the TemplateManager is a RequestRenderer.
+func withTemplates(r *http.Request, tm TemplateManager, vars map[string]any) *http.Request {
+	rr, ok := tm.(RequestRenderer)
+	if !ok {
+		return r
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), templatesKey{}, rr.ForRequest(vars)))
+}
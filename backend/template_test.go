@@ -2,10 +2,17 @@ package backend
 
 import (
 	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"text/template"
+	"time"
 
+	"github.com/kohirens/www/session"
 	"github.com/kohirens/www/storage"
 )
 
@@ -49,6 +56,61 @@ func TestHandler_Render(t *testing.T) {
 	}
 }
 
+func TestRenderer_ForRequest(t *testing.T) {
+	testWd, _ := filepath.Abs(fixtureDir)
+	fixtures, _ := storage.NewLocalStorage(testWd)
+
+	m := NewTemplateManager(fixtures, "", "tmpl")
+	m.AddVar("TestVar", "shared")
+
+	rm := m.(RequestRenderer).ForRequest(Variables{"TestVar": "request"})
+	w := &bytes.Buffer{}
+	if e := rm.Render("test-render-01", w, nil); e != nil || w.String() != "request" {
+		t.Errorf("Render() = %v, %v, want request", w.String(), e)
+	}
+
+	w.Reset()
+	if e := m.Render("test-render-01", w, Variables{"TestVar": "call"}); e != nil || w.String() != "call" {
+		t.Errorf("Render() = %v, %v, want call", w.String(), e)
+	}
+
+	if got := m.(*Renderer).Vars["TestVar"]; got != "shared" {
+		t.Errorf("Vars[TestVar] = %v, want shared", got)
+	}
+}
+
+func TestApi_ServeHTTP_ConcurrentTemplates(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.Mkdir(filepath.Join(dir, TmplDir), 0700)
+	_ = os.WriteFile(filepath.Join(dir, TmplDir, "query."+TmplSuffix), []byte("{{.URL_Query}}"), 0600)
+
+	store, _ := storage.NewLocalStorage(dir)
+	app := NewWithDefaults("test", store)
+	app.AuthManager().Add(KeyGoogleProvider, &mockProvider{})
+	app.AddService(KeySessionStore, session.NewSessionStore(session.NewMemoryStorage(0), "", time.Minute))
+	app.AddRoute("/", func(w http.ResponseWriter, r *http.Request, a App) error {
+		// Give other requests time to add their variables.
+		time.Sleep(time.Millisecond)
+		return Templates(r, a).Render("query", w, nil)
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(want string) {
+			defer wg.Done()
+
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest("GET", "/?"+want, nil))
+
+			if got := w.Body.String(); got != want {
+				t.Errorf("ServeHTTP() rendered %v, want %v", got, want)
+			}
+		}(fmt.Sprintf("n=%v", i))
+	}
+	wg.Wait()
+}
+
 func TestRenderer_AddFunctions(t *testing.T) {
 	testWd, _ := filepath.Abs(fixtureDir)
 	fixtures, _ := storage.NewLocalStorage(testWd)
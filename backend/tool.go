@@ -13,7 +13,7 @@ func InArray(value string, array []string) bool {
 
 // isLoggedIn indicates when a client is logged in or not.
 func isLoggedIn(a *Api, w http.ResponseWriter, r *http.Request) bool {
-	sm, e1 := a.Session()
+	sm, e1 := SessionManager(r, a)
 	if e1 != nil {
 		return false
 	}
@@ -0,0 +1,238 @@
+package www
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	headerACAllowCredentials = "Access-Control-Allow-Credentials"
+	headerACAllowHeaders     = "Access-Control-Allow-Headers"
+	headerACAllowMethods     = "Access-Control-Allow-Methods"
+	headerACAllowOrigin      = "Access-Control-Allow-Origin"
+	headerACExposeHeaders    = "Access-Control-Expose-Headers"
+	headerACMaxAge           = "Access-Control-Max-Age"
+	headerACRequestHeaders   = "Access-Control-Request-Headers"
+	headerACRequestMethod    = "Access-Control-Request-Method"
+	headerOrigin             = "Origin"
+	headerVary               = "Vary"
+)
+
+// CORS A Cross-Origin Resource Sharing policy. It answers preflight requests
+// and adds the Access-Control-* headers to actual responses, for origins that
+// the policy allows.
+//
+//	See https://fetch.spec.whatwg.org/#http-cors-protocol
+//	Also see https://developer.mozilla.org/en-US/docs/Web/HTTP/CORS
+type CORS struct {
+	// AllowedOrigins Origins that are allowed to make cross-origin requests.
+	// Use "*" to allow any origin, or a single wildcard in the host to allow
+	// subdomains, for example "https://*.example.com".
+	AllowedOrigins []string
+	// AllowedMethods HTTP methods the client may use. Defaults to GET, HEAD
+	// and POST when empty.
+	AllowedMethods []string
+	// AllowedHeaders Request headers the client may send. Use "*" to allow
+	// any header.
+	AllowedHeaders []string
+	// AllowCredentials Allow cookies and authorization headers to be sent.
+	// It cannot be set with a "*" origin, see Validate, since any site could
+	// then make requests with the credentials of the user.
+	AllowCredentials bool
+	// ExposedHeaders Response headers the client is allowed to read.
+	ExposedHeaders []string
+	// MaxAge How long, in seconds, the client may cache a preflight response.
+	// Zero leaves the header off.
+	MaxAge int
+}
+
+var defaultCORSMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+
+// AllowOrigin Return true when the origin matches the policy. A "*" origin
+// matches nothing when the policy allows credentials.
+func (c *CORS) AllowOrigin(origin string) bool {
+	if origin == "" {
+		return false
+	}
+
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" && !c.AllowCredentials || strings.EqualFold(allowed, origin) {
+			return true
+		}
+
+		if matchWildcard(strings.ToLower(allowed), strings.ToLower(origin)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowMethod Return true when the method is allowed by the policy.
+func (c *CORS) AllowMethod(method string) bool {
+	return !NotImplemented(method, c.methods())
+}
+
+// AllowHeaders Return true when every header in the comma-delimited list is
+// allowed by the policy.
+func (c *CORS) AllowHeaders(headers string) bool {
+	for _, h := range strings.Split(headers, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+
+		found := false
+		for _, allowed := range c.AllowedHeaders {
+			if allowed == "*" || strings.EqualFold(allowed, h) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Handler Wrap a http.Handler so that it responds to preflight requests and
+// adds CORS headers to every other response.
+func (c *CORS) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.Preflight(w, r) {
+			return
+		}
+
+		c.SetHeaders(w, r)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// IsPreflight Return true when the request is a CORS preflight request.
+func IsPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions &&
+		r.Header.Get(headerOrigin) != "" &&
+		r.Header.Get(headerACRequestMethod) != ""
+}
+
+// Preflight Respond to a CORS preflight request. Returns false, writing
+// nothing, when the request is not a preflight request. Otherwise, the
+// response is written and true is returned; a request from an origin, with a
+// method, or with headers the policy does not allow gets a 403 Forbidden.
+func (c *CORS) Preflight(w http.ResponseWriter, r *http.Request) bool {
+	if !IsPreflight(r) {
+		return false
+	}
+
+	origin := r.Header.Get(headerOrigin)
+	h := w.Header()
+	h.Add(headerVary, headerOrigin)
+	h.Add(headerVary, headerACRequestMethod)
+	h.Add(headerVary, headerACRequestHeaders)
+
+	if !c.AllowOrigin(origin) ||
+		!c.AllowMethod(r.Header.Get(headerACRequestMethod)) ||
+		!c.AllowHeaders(r.Header.Get(headerACRequestHeaders)) {
+		h.Set("Content-Length", "0")
+		w.WriteHeader(http.StatusForbidden)
+		return true
+	}
+
+	c.setOrigin(h, origin)
+	h.Set(headerACAllowMethods, strings.Join(c.methods(), ", "))
+
+	if reqHeaders := r.Header.Get(headerACRequestHeaders); reqHeaders != "" {
+		// Echo the requested headers since they have passed the check; this
+		// also covers a "*" policy when credentials are allowed.
+		h.Set(headerACAllowHeaders, reqHeaders)
+	}
+
+	if c.MaxAge > 0 {
+		h.Set(headerACMaxAge, strconv.Itoa(c.MaxAge))
+	}
+
+	h.Set("Content-Length", "0")
+	w.WriteHeader(http.StatusNoContent)
+
+	return true
+}
+
+// SetHeaders Add CORS headers to an actual (non-preflight) response. This is
+// no-op when the request has no Origin header or the origin is not allowed.
+func (c *CORS) SetHeaders(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get(headerOrigin)
+	h := w.Header()
+	h.Add(headerVary, headerOrigin)
+
+	if !c.AllowOrigin(origin) {
+		return
+	}
+
+	c.setOrigin(h, origin)
+
+	if len(c.ExposedHeaders) > 0 {
+		h.Set(headerACExposeHeaders, strings.Join(c.ExposedHeaders, ", "))
+	}
+}
+
+// Validate Return an error when the policy is unsafe, which is when it allows
+// credentials from any origin.
+func (c *CORS) Validate() error {
+	if c.AllowCredentials && slices.Contains(c.AllowedOrigins, "*") {
+		return fmt.Errorf("%v", Stderr.CORSCredentialsWildcard)
+	}
+
+	return nil
+}
+
+func (c *CORS) methods() []string {
+	if len(c.AllowedMethods) == 0 {
+		return defaultCORSMethods
+	}
+	return c.AllowedMethods
+}
+
+func (c *CORS) setOrigin(h http.Header, origin string) {
+	if c.AllowCredentials {
+		h.Set(headerACAllowOrigin, origin)
+		h.Set(headerACAllowCredentials, "true")
+		return
+	}
+
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			h.Set(headerACAllowOrigin, "*")
+			return
+		}
+	}
+
+	h.Set(headerACAllowOrigin, origin)
+}
+
+// matchWildcard Match an origin against a pattern with a single "*", for
+// example "https://*.example.com". The wildcard must match at least one
+// character, so the pattern above does not match "https://.example.com" nor
+// "https://example.com".
+func matchWildcard(pattern, origin string) bool {
+	before, after, found := strings.Cut(pattern, "*")
+	if !found {
+		return false
+	}
+
+	if len(origin) <= len(before)+len(after) ||
+		!strings.HasPrefix(origin, before) ||
+		!strings.HasSuffix(origin, after) {
+		return false
+	}
+
+	// Do not let the wildcard span into the scheme, path or port.
+	middle := origin[len(before) : len(origin)-len(after)]
+
+	return !strings.ContainsAny(middle, "/:")
+}
@@ -0,0 +1,239 @@
+package www
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS_AllowOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		want    bool
+	}{
+		{"exact", []string{"https://app.example.com"}, "https://app.example.com", true},
+		{"any", []string{"*"}, "https://app.example.com", true},
+		{"subdomain", []string{"https://*.example.com"}, "https://app.example.com", true},
+		{"nested-subdomain", []string{"https://*.example.com"}, "https://a.b.example.com", true},
+		{"apex-not-a-subdomain", []string{"https://*.example.com"}, "https://example.com", false},
+		{"suffix-attack", []string{"https://*.example.com"}, "https://evilexample.com", false},
+		{"wrong-scheme", []string{"https://*.example.com"}, "http://app.example.com", false},
+		{"port-not-matched", []string{"https://*.example.com"}, "https://app.example.com:8443", false},
+		{"no-origin", []string{"*"}, "", false},
+		{"not-listed", []string{"https://app.example.com"}, "https://other.example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &CORS{AllowedOrigins: tt.allowed}
+			if got := c.AllowOrigin(tt.origin); got != tt.want {
+				t.Errorf("AllowOrigin() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCORS_Preflight(t *testing.T) {
+	policy := &CORS{
+		AllowedOrigins:   []string{"https://*.example.com"},
+		AllowedMethods:   []string{"GET", "PUT"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		AllowCredentials: true,
+		MaxAge:           600,
+	}
+	tests := []struct {
+		name       string
+		method     string
+		headers    map[string]string
+		want       bool
+		wantCode   int
+		wantOrigin string
+	}{
+		{
+			"not-options",
+			"GET",
+			map[string]string{"Origin": "https://app.example.com"},
+			false,
+			0,
+			"",
+		},
+		{
+			"options-without-request-method",
+			"OPTIONS",
+			map[string]string{"Origin": "https://app.example.com"},
+			false,
+			0,
+			"",
+		},
+		{
+			"allowed",
+			"OPTIONS",
+			map[string]string{
+				"Origin":                         "https://app.example.com",
+				"Access-Control-Request-Method":  "PUT",
+				"Access-Control-Request-Headers": "content-type",
+			},
+			true,
+			204,
+			"https://app.example.com",
+		},
+		{
+			"origin-not-allowed",
+			"OPTIONS",
+			map[string]string{
+				"Origin":                        "https://example.org",
+				"Access-Control-Request-Method": "GET",
+			},
+			true,
+			403,
+			"",
+		},
+		{
+			"method-not-allowed",
+			"OPTIONS",
+			map[string]string{
+				"Origin":                        "https://app.example.com",
+				"Access-Control-Request-Method": "DELETE",
+			},
+			true,
+			403,
+			"",
+		},
+		{
+			"header-not-allowed",
+			"OPTIONS",
+			map[string]string{
+				"Origin":                         "https://app.example.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "X-Secret",
+			},
+			true,
+			403,
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/api/meals", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			w := NewResponse()
+
+			if got := policy.Preflight(w, r); got != tt.want {
+				t.Errorf("Preflight() = %v, want %v", got, tt.want)
+				return
+			}
+
+			if w.StatusCode != tt.wantCode {
+				t.Errorf("Preflight() status = %v, want %v", w.StatusCode, tt.wantCode)
+				return
+			}
+
+			if got := w.Header().Get(headerACAllowOrigin); got != tt.wantOrigin {
+				t.Errorf("Preflight() %v = %q, want %q", headerACAllowOrigin, got, tt.wantOrigin)
+				return
+			}
+
+			if tt.wantCode == http.StatusNoContent {
+				if got := w.Header().Get(headerACMaxAge); got != "600" {
+					t.Errorf("Preflight() %v = %q, want %q", headerACMaxAge, got, "600")
+				}
+				if got := w.Header().Get(headerACAllowCredentials); got != "true" {
+					t.Errorf("Preflight() %v = %q, want %q", headerACAllowCredentials, got, "true")
+				}
+			}
+		})
+	}
+}
+
+func TestCORS_SetHeaders(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      *CORS
+		origin      string
+		wantOrigin  string
+		wantExposed string
+	}{
+		{
+			"any-origin",
+			&CORS{AllowedOrigins: []string{"*"}, ExposedHeaders: []string{"X-Total"}},
+			"https://app.example.com",
+			"*",
+			"X-Total",
+		},
+		{
+			"any-origin-with-credentials-denied",
+			&CORS{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+			"https://app.example.com",
+			"",
+			"",
+		},
+		{
+			"not-allowed",
+			&CORS{AllowedOrigins: []string{"https://app.example.com"}, ExposedHeaders: []string{"X-Total"}},
+			"https://example.org",
+			"",
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/meals", nil)
+			r.Header.Set("Origin", tt.origin)
+			w := NewResponse()
+
+			tt.policy.SetHeaders(w, r)
+
+			if got := w.Header().Get(headerACAllowOrigin); got != tt.wantOrigin {
+				t.Errorf("SetHeaders() %v = %q, want %q", headerACAllowOrigin, got, tt.wantOrigin)
+			}
+			if got := w.Header().Get(headerACExposeHeaders); got != tt.wantExposed {
+				t.Errorf("SetHeaders() %v = %q, want %q", headerACExposeHeaders, got, tt.wantExposed)
+			}
+		})
+	}
+}
+
+func TestCORS_Handler(t *testing.T) {
+	policy := &CORS{AllowedOrigins: []string{"https://app.example.com"}}
+	called := false
+	h := policy.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	r := httptest.NewRequest("OPTIONS", "/api/meals", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	r.Header.Set("Access-Control-Request-Method", "POST")
+	w := httptest.NewRecorder()
+
+	h.ServeHTTP(w, r)
+
+	if called {
+		t.Errorf("Handler() called the next handler on a preflight request")
+	}
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Handler() status = %v, want %v", w.Code, http.StatusNoContent)
+	}
+}
+
+func TestCORS_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  *CORS
+		wantErr bool
+	}{
+		{"any-origin", &CORS{AllowedOrigins: []string{"*"}}, false},
+		{"credentials", &CORS{AllowedOrigins: []string{"https://*.example.com"}, AllowCredentials: true}, false},
+		{"any-origin-with-credentials", &CORS{AllowedOrigins: []string{"*"}, AllowCredentials: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if e := tt.policy.Validate(); (e != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", e, tt.wantErr)
+			}
+		})
+	}
+}
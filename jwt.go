@@ -0,0 +1,148 @@
+package www
+
+import (
+	"crypto/hmac"
+	"fmt"
+	"strings"
+	"time"
+
+	jwt "github.com/kohirens/json-web-token"
+)
+
+// JWTVerifier Verify bearer tokens that are signed JWTs. Set Secret to accept
+// HS256 tokens, PublicKey to accept RS256 tokens, or both. Issuer and
+// Audience are only checked when set. Tokens must have an "exp" claim, so
+// that a leaked token is not accepted forever.
+type JWTVerifier struct {
+	Audience string
+	Issuer   string
+	// Leeway Allowed for clock skew when checking "exp" and "nbf".
+	Leeway time.Duration
+	// PublicKey PEM encoded RSA public key for RS256.
+	PublicKey []byte
+	// Secret Shared key for HS256.
+	Secret []byte
+}
+
+// jwtNow Allows tests to control the clock.
+var jwtNow = time.Now
+
+// Verify Check the signature and registered claims of a JWT and return the
+// identity it carries. The "scope" claim, a space separated list, becomes the
+// Scopes of the identity.
+//
+//	NOTE: The algorithm in the header only picks between the keys that are
+//	set, so a token cannot switch to "none" or sign with the public key as an
+//	HS256 secret.
+func (v *JWTVerifier) Verify(token string) (*Identity, error) {
+	info, e1 := jwt.Parse(token)
+	if e1 != nil {
+		return nil, fmt.Errorf(Stderr.JWTParse, e1.Error())
+	}
+
+	if info.Type != "JWS" {
+		return nil, fmt.Errorf(Stderr.JWTParse, info.Type+" is not supported")
+	}
+
+	if e := v.verifySignature(info); e != nil {
+		return nil, e
+	}
+
+	if e := v.verifyClaims(info.Payload); e != nil {
+		return nil, e
+	}
+
+	id := &Identity{
+		Scheme: SchemeBearer,
+		Claims: info.Payload,
+	}
+
+	if sub, ok := info.Payload["sub"].(string); ok {
+		id.Subject = sub
+	}
+
+	if scope, ok := info.Payload["scope"].(string); ok {
+		id.Scopes = strings.Fields(scope)
+	}
+
+	return id, nil
+}
+
+func (v *JWTVerifier) verifySignature(info *jwt.Info) error {
+	signed := info.EncodedHeader + "." + info.EncodedPayload
+
+	switch {
+	case info.Algorithm == "HS256" && len(v.Secret) > 0:
+		want, e1 := jwt.HS256(info.EncodedHeader, info.EncodedPayload, v.Secret)
+		if e1 != nil {
+			return fmt.Errorf(Stderr.JWTSignature, e1.Error())
+		}
+		if !hmac.Equal([]byte(want), []byte(info.EncodedSignature)) {
+			return fmt.Errorf(Stderr.JWTSignature, "mismatch")
+		}
+	case info.Algorithm == "RS256" && len(v.PublicKey) > 0:
+		e2 := jwt.ValidateRS256(v.PublicKey, []byte(info.EncodedSignature), []byte(signed))
+		if e2 != nil {
+			return fmt.Errorf(Stderr.JWTSignature, e2.Error())
+		}
+	default:
+		return fmt.Errorf(Stderr.JWTAlgorithm, info.Algorithm)
+	}
+
+	return nil
+}
+
+func (v *JWTVerifier) verifyClaims(claims jwt.ClaimSet) error {
+	now := jwtNow()
+
+	exp, ok := numericDate(claims["exp"])
+	if !ok {
+		return fmt.Errorf("%v", Stderr.JWTExpiryMissing)
+	}
+
+	if !now.Before(exp.Add(v.Leeway)) {
+		return fmt.Errorf("%v", Stderr.JWTExpired)
+	}
+
+	if nbf, ok := numericDate(claims["nbf"]); ok && now.Add(v.Leeway).Before(nbf) {
+		return fmt.Errorf("%v", Stderr.JWTNotYetValid)
+	}
+
+	if v.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != v.Issuer {
+			return fmt.Errorf(Stderr.JWTIssuer, iss)
+		}
+	}
+
+	if v.Audience != "" && !hasAudience(claims["aud"], v.Audience) {
+		return fmt.Errorf(Stderr.JWTAudience, v.Audience)
+	}
+
+	return nil
+}
+
+// hasAudience The "aud" claim may be a single string or an array of them.
+func hasAudience(aud any, want string) bool {
+	switch a := aud.(type) {
+	case string:
+		return a == want
+	case []any:
+		for _, v := range a {
+			if s, ok := v.(string); ok && s == want {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// numericDate Convert a JSON number of seconds since the epoch to a time.
+func numericDate(v any) (time.Time, bool) {
+	f, ok := v.(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return time.Unix(int64(f), 0), true
+}
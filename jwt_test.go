@@ -0,0 +1,81 @@
+package www
+
+import (
+	"testing"
+	"time"
+
+	jwt "github.com/kohirens/json-web-token"
+)
+
+func TestJWTVerifier_Verify(t *testing.T) {
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	jwtNow = func() time.Time { return clock }
+	t.Cleanup(func() { jwtNow = time.Now })
+
+	secret := []byte("1234567890")
+	verifier := &JWTVerifier{
+		Audience: "api",
+		Issuer:   "https://example.com",
+		Secret:   secret,
+	}
+	sign := func(alg string, claims jwt.ClaimSet, key []byte) string {
+		token, e1 := jwt.Token(jwt.ClaimSet{"alg": alg, "typ": "JWT"}, claims, key)
+		if e1 != nil {
+			t.Fatal(e1)
+		}
+		return token
+	}
+	valid := func() jwt.ClaimSet {
+		return jwt.ClaimSet{
+			"sub":   "account-1",
+			"iss":   "https://example.com",
+			"aud":   []string{"web", "api"},
+			"exp":   clock.Add(time.Hour).Unix(),
+			"scope": "read write",
+		}
+	}
+	with := func(k string, v any) jwt.ClaimSet {
+		c := valid()
+		c[k] = v
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantSub string
+		wantErr bool
+	}{
+		{"valid", sign("HS256", valid(), secret), "account-1", false},
+		{"wrong-secret", sign("HS256", valid(), []byte("wrong")), "", true},
+		{"expired", sign("HS256", with("exp", clock.Add(-time.Second).Unix()), secret), "", true},
+		{"no-expiry", sign("HS256", with("exp", nil), secret), "", true},
+		{"not-yet-valid", sign("HS256", with("nbf", clock.Add(time.Minute).Unix()), secret), "", true},
+		{"wrong-issuer", sign("HS256", with("iss", "https://evil.example.com"), secret), "", true},
+		{"wrong-audience", sign("HS256", with("aud", "web"), secret), "", true},
+		{"alg-none", "eyJhbGciOiJub25lIn0.eyJzdWIiOiJhY2NvdW50LTEifQ.", "", true},
+		{"garbage", "a.b.c", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := verifier.Verify(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if got.Subject != tt.wantSub {
+				t.Errorf("Verify() Subject = %v, want %v", got.Subject, tt.wantSub)
+				return
+			}
+
+			if !got.HasScope("write") {
+				t.Errorf("Verify() Scopes = %v, want write", got.Scopes)
+			}
+		})
+	}
+}
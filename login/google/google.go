@@ -4,12 +4,13 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/kohirens/sso"
 	"github.com/kohirens/sso/pkg/google"
 	"github.com/kohirens/stdlib/logger"
 	"github.com/kohirens/www/backend"
-	"github.com/kohirens/www/session"
+	"github.com/kohirens/www/ratelimit"
 	"github.com/kohirens/www/validation"
 )
 
@@ -31,10 +32,28 @@ var (
 	Log = &logger.Standard{}
 	// SignOutRedirect A location to send the client after they sign out.
 	SignOutRedirect = "/"
+	// SignInLimiter Throttles AuthLink and SignIn by the IP of the client,
+	// clients over the limit get a 429 Too Many Requests. The state is kept
+	// in memory, on a fleet of servers such as AWS Lambda functions set a
+	// limiter with a shared store. Set it to nil to turn throttling off.
+	SignInLimiter = ratelimit.New(
+		ratelimit.NewSlidingWindow(10, time.Minute),
+		ratelimit.NewMemoryStore(),
+		ratelimit.ByIP,
+	)
 )
 
-// AuthLink Build link to authenticate with Google.
+// AuthLink Build link to authenticate with Google, see SignInLimiter.
 func AuthLink(w http.ResponseWriter, r *http.Request, a backend.App) error {
+	return limited(authLink)(w, r, a)
+}
+
+// SignIn Begin the authentication process for a client, see SignInLimiter.
+func SignIn(w http.ResponseWriter, r *http.Request, a backend.App) error {
+	return limited(signIn)(w, r, a)
+}
+
+func authLink(w http.ResponseWriter, r *http.Request, a backend.App) error {
 	email, emailOK := validation.Email(r.URL.Query().Get(fEmail))
 	if !emailOK {
 		email = "" // It's not required, so it is O.K. to leave it out.
@@ -64,8 +83,7 @@ func AuthLink(w http.ResponseWriter, r *http.Request, a backend.App) error {
 	return nil
 }
 
-// SignIn Begin the authentication process for a client.
-func SignIn(w http.ResponseWriter, r *http.Request, a backend.App) error {
+func signIn(w http.ResponseWriter, r *http.Request, a backend.App) error {
 	if e := r.ParseForm(); e != nil {
 		return fmt.Errorf(stderr.ParseSignInData, e.Error())
 	}
@@ -116,6 +134,23 @@ func SignOut(w http.ResponseWriter, r *http.Request, a backend.App) error {
 		Log.Errf(stderr.SignOut, e)
 	}
 
+	sm, e4 := backend.SessionManager(r, a)
+	if e4 != nil {
+		return e4
+	}
+
+	// Take the session out of the index of the account before its ID changes.
+	if e := backend.TrackSignOut(r, a); e != nil {
+		Log.Errf("%v", e.Error())
+	}
+
+	// Drop the data of the logged-in session and issue a new session ID.
+	sm.RemoveAll()
+	if e := sm.Regenerate(); e != nil {
+		return e
+	}
+	sm.SetCookie(w, r)
+
 	body := []byte(fmt.Sprintf(backend.MetaRefresh, endpoint))
 	_, e3 := w.Write(body)
 	if e3 != nil {
@@ -157,11 +192,16 @@ func Callback(w http.ResponseWriter, r *http.Request, a backend.App) error {
 	am := amX.(backend.AccountManager)
 
 	// Retrieve the session manager.
-	smX, e7 := a.Service(backend.KeySessionManager)
+	sm, e7 := backend.SessionManager(r, a)
 	if e7 != nil {
 		return e7
 	}
-	sm := smX.(*session.Manager)
+
+	// Issue a new session ID now that the client is logged in, so that an ID
+	// planted before login cannot be used to hijack the session.
+	if e := sm.Regenerate(); e != nil {
+		return e
+	}
 
 	// Get user agent data.
 	userAgent := r.Header.Get("User-Agent")
@@ -244,6 +284,11 @@ func Callback(w http.ResponseWriter, r *http.Request, a backend.App) error {
 		return e
 	}
 
+	// Tie the session to the account, so that it is listed with the others.
+	if e := backend.TrackSignIn(r, a, account.ID, deviceID); e != nil {
+		return e
+	}
+
 	// Set the session ID cookie.
 	sm.SetCookie(w, r)
 
@@ -331,3 +376,12 @@ func YesCookie(
 
 	return loginInfo, account
 }
+
+// limited Throttle a route with the SignInLimiter, when it is set.
+func limited(route backend.Route) backend.Route {
+	if SignInLimiter == nil {
+		return route
+	}
+
+	return SignInLimiter.Route(route)
+}
@@ -2,11 +2,15 @@ package google
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/kohirens/stdlib/test"
 	"github.com/kohirens/www/backend"
+	"github.com/kohirens/www/ratelimit"
+	"github.com/kohirens/www/session"
 )
 
 func TestAuthLink(t *testing.T) {
@@ -133,6 +137,31 @@ func TestSignIn(t *testing.T) {
 	}
 }
 
+func TestSignIn_Limited(t *testing.T) {
+	saved := SignInLimiter
+	SignInLimiter = ratelimit.New(ratelimit.NewSlidingWindow(1, time.Minute), ratelimit.NewMemoryStore(), ratelimit.ByIP)
+	t.Cleanup(func() { SignInLimiter = saved })
+
+	goodAuth := backend.NewAuthManager()
+	goodAuth.Add(backend.KeyGoogleProvider, &MockProvider{
+		ExpectedAuthLink: "good-link",
+	})
+	a := &MockApp{Authorizer: goodAuth}
+
+	for _, want := range []int{http.StatusTemporaryRedirect, http.StatusTooManyRequests} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/api/sign-in", nil)
+
+		if e := SignIn(w, r, a); e != nil {
+			t.Fatalf("SignIn() error = %v", e)
+		}
+
+		if w.Code != want {
+			t.Errorf("SignIn() status = %v, want %v", w.Code, want)
+		}
+	}
+}
+
 func TestSignOut(t *testing.T) {
 	goodAuth := backend.NewAuthManager()
 	goodAuth.Add(backend.KeyGoogleProvider, &MockProvider{
@@ -164,6 +193,9 @@ func TestSignOut(t *testing.T) {
 			nil,
 			&MockApp{
 				Authorizer: goodAuth,
+				Services: map[string]any{
+					backend.KeySessionManager: session.NewManager(&MockSessionStorage{}, "", time.Minute),
+				},
 			},
 			false,
 		},
@@ -178,6 +210,51 @@ func TestSignOut(t *testing.T) {
 	}
 }
 
+func TestSignOut_RegeneratesSession(t *testing.T) {
+	auth := backend.NewAuthManager()
+	auth.Add(backend.KeyGoogleProvider, &MockProvider{})
+	store := &MockSessionStorage{}
+	sm := session.NewManager(store, "", time.Minute)
+	sm.Set("loggedIn", []byte("true"))
+	if e := sm.Save(); e != nil {
+		t.Fatal(e)
+	}
+	oldID := sm.ID().String()
+
+	r := httptest.NewRequest("GET", "/api/sign-out", nil)
+	r.AddCookie(&http.Cookie{Name: session.IDKey, Value: oldID})
+	w := httptest.NewRecorder()
+	a := &MockApp{
+		Authorizer: auth,
+		Services:   map[string]any{backend.KeySessionManager: sm},
+	}
+
+	if e := SignOut(w, r, a); e != nil {
+		t.Fatalf("SignOut() error = %v", e)
+	}
+
+	newID := sm.ID().String()
+	if newID == oldID {
+		t.Errorf("SignOut() kept session ID %v", oldID)
+		return
+	}
+
+	if _, e := store.Load(oldID + session.Suffix); e == nil {
+		t.Errorf("SignOut() did not remove the old session %v", oldID)
+		return
+	}
+
+	if sm.Get("loggedIn") != nil {
+		t.Errorf("SignOut() kept the session data")
+		return
+	}
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != session.IDKey || cookies[0].Value != newID {
+		t.Errorf("SignOut() cookies = %v, want %v=%v", cookies, session.IDKey, newID)
+	}
+}
+
 // We cannot write this test because it uses Google Provider Proprietary methods.
 // Unless apple also will have the same methods.
 func xTestCallback(t *testing.T) {
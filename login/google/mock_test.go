@@ -1,6 +1,7 @@
 package google
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/kohirens/www/awslambda"
@@ -10,6 +11,7 @@ import (
 type MockApp struct {
 	Authorizer backend.AuthManager
 	name       string
+	Services   map[string]any
 }
 
 func (m *MockApp) LoadGPG() {
@@ -71,8 +73,37 @@ func (m *MockApp) ServeLambda(event *awslambda.Input) (*awslambda.Output, error)
 }
 
 func (m *MockApp) Service(key string) (interface{}, error) {
-	//TODO implement me
-	panic("implement me")
+	s, ok := m.Services[key]
+	if !ok {
+		return nil, fmt.Errorf("service %q was not found", key)
+	}
+	return s, nil
+}
+
+// MockSessionStorage Keeps sessions in memory.
+type MockSessionStorage struct {
+	data map[string][]byte
+}
+
+func (ms *MockSessionStorage) Load(id string) ([]byte, error) {
+	b, ok := ms.data[id]
+	if !ok {
+		return nil, fmt.Errorf("session %v not found", id)
+	}
+	return b, nil
+}
+
+func (ms *MockSessionStorage) Remove(key string) error {
+	delete(ms.data, key)
+	return nil
+}
+
+func (ms *MockSessionStorage) Save(id string, data []byte) error {
+	if ms.data == nil {
+		ms.data = make(map[string][]byte)
+	}
+	ms.data[id] = data
+	return nil
 }
 
 type MockProvider struct {
@@ -4,16 +4,46 @@ var Stderr = struct {
 	AuthCodeInvalid,
 	AuthCodeNotSet,
 	AuthHeaderMissing,
+	AuthSchemeUnsupported,
 	CannotEncodeToJson,
+	CORSCredentialsWildcard,
+	CredentialsInvalid,
 	DecodeBase64,
 	FieldNotFound,
+	HashFormat,
+	HashPassword,
+	JWTAlgorithm,
+	JWTAudience,
+	JWTExpired,
+	JWTExpiryMissing,
+	JWTIssuer,
+	JWTNotYetValid,
+	JWTParse,
+	JWTSignature,
+	RandomBytes,
+	TokenInvalid,
 	WriteResponseBody string
 }{
-	AuthCodeInvalid:    "incorrect authorization code was sent",
-	AuthCodeNotSet:     "authorization code was not set in the environment",
-	AuthHeaderMissing:  "authorization header is missing",
-	CannotEncodeToJson: "could not JSON encode content: %v",
-	DecodeBase64:       "cannot decode base64 value %v",
-	FieldNotFound:      "could not find field %v",
-	WriteResponseBody:  "cannot write response body %v",
+	AuthCodeInvalid:         "incorrect authorization code was sent",
+	AuthCodeNotSet:          "authorization code was not set in the environment",
+	AuthHeaderMissing:       "authorization header is missing",
+	AuthSchemeUnsupported:   "authorization scheme %q is not supported",
+	CannotEncodeToJson:      "could not JSON encode content: %v",
+	CORSCredentialsWildcard: "a CORS policy that allows credentials cannot allow any origin with \"*\", list the origins",
+	CredentialsInvalid:      "invalid username or password",
+	DecodeBase64:            "cannot decode base64 value %v",
+	FieldNotFound:           "could not find field %v",
+	HashFormat:              "cannot read password hash: %v",
+	HashPassword:            "cannot hash password: %v",
+	JWTAlgorithm:            "JWT algorithm %q is not allowed",
+	JWTAudience:             "JWT audience does not include %q",
+	JWTExpired:              "JWT has expired",
+	JWTExpiryMissing:        "JWT has no \"exp\" claim",
+	JWTIssuer:               "JWT issuer %q is not trusted",
+	JWTNotYetValid:          "JWT is not valid yet",
+	JWTParse:                "cannot parse JWT: %v",
+	JWTSignature:            "JWT signature is invalid: %v",
+	RandomBytes:             "cannot read random bytes: %v",
+	TokenInvalid:            "invalid bearer token",
+	WriteResponseBody:       "cannot write response body %v",
 }
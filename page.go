@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -151,6 +152,22 @@ func Respond405(w http.ResponseWriter, allowedMethods string) {
 	w.WriteHeader(http.StatusMethodNotAllowed)
 }
 
+// Respond429 Send a 429 Too Many Requests HTTP response.
+//
+//	retryAfter is rounded up to whole seconds and sent in the Retry-After
+//	header, so that the client knows when it may try again.
+//
+//	Also see: https://www.rfc-editor.org/rfc/rfc6585#section-4
+func Respond429(w http.ResponseWriter, retryAfter time.Duration) {
+	code := http.StatusTooManyRequests
+	seconds := int((retryAfter + time.Second - 1) / time.Second)
+
+	w.Header().Set("Content-Type", ContentTypeHtml)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.WriteHeader(code)
+	writeBody(w, code, http.StatusText(code))
+}
+
 // Respond500 Send a 500 Internal Server Error HTTP response.
 func Respond500(w http.ResponseWriter, body []byte, contentType string) {
 	RespondWithStatus(w, http.StatusInternalServerError, body, contentType)
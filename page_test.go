@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestGetHeader(t *testing.T) {
@@ -161,6 +162,35 @@ func TestRespond405(t *testing.T) {
 	}
 }
 
+// See https://developer.mozilla.org/en-US/docs/Web/HTTP/Status/429
+func TestRespond429(t *testing.T) {
+	tests := []struct {
+		name           string
+		retryAfter     time.Duration
+		w              *Response
+		wantCode       int
+		wantRetryAfter string
+	}{
+		{"whole-seconds", 3 * time.Second, NewResponse(), 429, "3"},
+		{"rounds-up", 1500 * time.Millisecond, NewResponse(), 429, "2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Respond429(tt.w, tt.retryAfter)
+
+			if tt.w.StatusCode != tt.wantCode {
+				t.Errorf("Respond429() = %v, want %v", tt.w.StatusCode, tt.wantCode)
+				return
+			}
+
+			if got := tt.w.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("Respond429() Retry-After = %v, want %v", got, tt.wantRetryAfter)
+				return
+			}
+		})
+	}
+}
+
 func TestRespondJSON(t *testing.T) {
 	type jsonMsg struct {
 		Msg string `json:"msg"`
@@ -0,0 +1,136 @@
+package www
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Argon2Params Cost parameters for hashing passwords with Argon2id. The
+// defaults follow the OWASP Password Storage Cheat Sheet.
+//
+//	See https://cheatsheetseries.owasp.org/cheatsheets/Password_Storage_Cheat_Sheet.html#argon2id
+type Argon2Params struct {
+	Memory  uint32 // Memory in KiB.
+	Time    uint32
+	Threads uint8
+	SaltLen uint32
+	KeyLen  uint32
+}
+
+var (
+	// DefaultArgon2Params Used by HashPassword.
+	DefaultArgon2Params = &Argon2Params{
+		Memory:  19 * 1024,
+		Time:    2,
+		Threads: 1,
+		SaltLen: 16,
+		KeyLen:  32,
+	}
+
+	// dummyHash Verified against when a user cannot be found, so that the
+	// response takes as long as it does for a wrong password. Otherwise, the
+	// timing would tell an attacker which usernames exist. It is hashed the
+	// first time it is needed, so importing the package costs nothing.
+	dummyHash = sync.OnceValue(func() string {
+		hash, _ := HashPassword("not a real password")
+		return hash
+	})
+)
+
+// HashPassword Hash a password with Argon2id, in the PHC string format:
+//
+//	$argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>
+func HashPassword(password string) (string, error) {
+	return HashPasswordArgon2(password, DefaultArgon2Params)
+}
+
+// HashPasswordArgon2 Hash a password with Argon2id using the parameters.
+func HashPasswordArgon2(password string, p *Argon2Params) (string, error) {
+	salt := make([]byte, p.SaltLen)
+	if _, e := rand.Read(salt); e != nil {
+		return "", fmt.Errorf(Stderr.RandomBytes, e.Error())
+	}
+
+	key := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		p.Memory,
+		p.Time,
+		p.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// HashPasswordBcrypt Hash a password with bcrypt at the default cost.
+func HashPasswordBcrypt(password string) (string, error) {
+	hash, e1 := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if e1 != nil {
+		return "", fmt.Errorf(Stderr.HashPassword, e1.Error())
+	}
+
+	return string(hash), nil
+}
+
+// VerifyPassword Compare a password to a hash made by HashPassword,
+// HashPasswordArgon2 or HashPasswordBcrypt. The comparison is constant-time.
+func VerifyPassword(hash, password string) (bool, error) {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return verifyArgon2(hash, password)
+	case strings.HasPrefix(hash, "$2a$"),
+		strings.HasPrefix(hash, "$2b$"),
+		strings.HasPrefix(hash, "$2y$"):
+		e1 := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if e1 == nil {
+			return true, nil
+		}
+		if e1 == bcrypt.ErrMismatchedHashAndPassword {
+			return false, nil
+		}
+		return false, fmt.Errorf(Stderr.HashFormat, e1.Error())
+	}
+
+	return false, fmt.Errorf(Stderr.HashFormat, "unknown algorithm")
+}
+
+func verifyArgon2(hash, password string) (bool, error) {
+	// "", "argon2id", "v=19", "m=19456,t=2,p=1", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false, fmt.Errorf(Stderr.HashFormat, "argon2id needs 6 fields")
+	}
+
+	var version int
+	if _, e := fmt.Sscanf(parts[2], "v=%d", &version); e != nil || version != argon2.Version {
+		return false, fmt.Errorf(Stderr.HashFormat, "unsupported argon2 version")
+	}
+
+	p := &Argon2Params{}
+	if _, e := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); e != nil {
+		return false, fmt.Errorf(Stderr.HashFormat, e.Error())
+	}
+
+	salt, e1 := base64.RawStdEncoding.DecodeString(parts[4])
+	if e1 != nil {
+		return false, fmt.Errorf(Stderr.HashFormat, e1.Error())
+	}
+
+	key, e2 := base64.RawStdEncoding.DecodeString(parts[5])
+	if e2 != nil {
+		return false, fmt.Errorf(Stderr.HashFormat, e2.Error())
+	}
+
+	got := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, uint32(len(key)))
+
+	return subtle.ConstantTimeCompare(got, key) == 1, nil
+}
@@ -0,0 +1,46 @@
+package www
+
+import "testing"
+
+func TestVerifyPassword(t *testing.T) {
+	argon, _ := HashPassword("0123")
+	bcrypt, _ := HashPasswordBcrypt("0123")
+
+	tests := []struct {
+		name     string
+		hash     string
+		password string
+		want     bool
+		wantErr  bool
+	}{
+		{"argon2id", argon, "0123", true, false},
+		{"argon2id-wrong", argon, "1234", false, false},
+		{"bcrypt", bcrypt, "0123", true, false},
+		{"bcrypt-wrong", bcrypt, "1234", false, false},
+		{"unknown-algorithm", "$md5$abc", "0123", false, true},
+		{"argon2id-truncated", "$argon2id$v=19$m=19456,t=2,p=1$abc", "0123", false, true},
+		{"plain-text", "0123", "0123", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyPassword(tt.hash, tt.password)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyPassword() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("VerifyPassword() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashPassword_Salted(t *testing.T) {
+	a, _ := HashPassword("0123")
+	b, _ := HashPassword("0123")
+
+	if a == b {
+		t.Errorf("HashPassword() = %v twice, want a different salt each time", a)
+	}
+}
@@ -0,0 +1,186 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// Algorithm Decides if a request is allowed based on the state kept for a key.
+type Algorithm interface {
+	// Check Report the decision for the next request without consuming it.
+	Check(state *State, now time.Time) *Result
+	// Take Consume a request from the state and report the decision. The
+	// state is only changed when the request is allowed.
+	Take(state *State, now time.Time) *Result
+	// TTL How long the state for an idle key must be kept before it can be
+	// forgotten without changing any decision.
+	TTL() time.Duration
+}
+
+// TokenBucket Allows bursts up to Capacity, then refills at Rate tokens per
+// second. Each request takes one token.
+type TokenBucket struct {
+	Capacity int
+	Rate     float64
+}
+
+// SlidingWindow Allows at most Limit requests in any Window. It uses the
+// sliding window counter approximation, which weighs the count of the
+// previous window by how much of it still overlaps the sliding window. This
+// keeps the state a fixed size no matter how many requests are made.
+type SlidingWindow struct {
+	Limit  int
+	Window time.Duration
+}
+
+// NewTokenBucket Allow a burst of capacity requests that refills at a rate
+// of capacity per period.
+func NewTokenBucket(capacity int, period time.Duration) *TokenBucket {
+	return &TokenBucket{
+		Capacity: capacity,
+		Rate:     float64(capacity) / period.Seconds(),
+	}
+}
+
+// NewSlidingWindow Allow limit requests in any window.
+func NewSlidingWindow(limit int, window time.Duration) *SlidingWindow {
+	return &SlidingWindow{
+		Limit:  limit,
+		Window: window,
+	}
+}
+
+// Check Report the decision for the next request without consuming it.
+func (tb *TokenBucket) Check(state *State, now time.Time) *Result {
+	tokens := tb.refill(state, now)
+	return tb.result(tokens)
+}
+
+// Take Consume a token.
+func (tb *TokenBucket) Take(state *State, now time.Time) *Result {
+	tokens := tb.refill(state, now)
+	res := tb.result(tokens)
+
+	if res.Allowed {
+		tokens--
+		res.Remaining = int(math.Floor(tokens))
+	}
+
+	state.Tokens = tokens
+	state.Updated = now
+
+	return res
+}
+
+// TTL The time it takes an empty bucket to fill.
+func (tb *TokenBucket) TTL() time.Duration {
+	return time.Duration(float64(tb.Capacity) / tb.Rate * float64(time.Second))
+}
+
+func (tb *TokenBucket) refill(state *State, now time.Time) float64 {
+	if state.Updated.IsZero() {
+		return float64(tb.Capacity)
+	}
+
+	elapsed := now.Sub(state.Updated).Seconds()
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	return math.Min(float64(tb.Capacity), state.Tokens+elapsed*tb.Rate)
+}
+
+func (tb *TokenBucket) result(tokens float64) *Result {
+	res := &Result{
+		Allowed:   tokens >= 1,
+		Limit:     tb.Capacity,
+		Remaining: int(math.Floor(tokens)),
+	}
+
+	if !res.Allowed {
+		res.RetryAfter = time.Duration((1 - tokens) / tb.Rate * float64(time.Second))
+	}
+
+	return res
+}
+
+// Check Report the decision for the next request without consuming it.
+func (sw *SlidingWindow) Check(state *State, now time.Time) *Result {
+	sw.slide(state, now)
+	return sw.result(state, now)
+}
+
+// Take Count a request in the current window.
+func (sw *SlidingWindow) Take(state *State, now time.Time) *Result {
+	sw.slide(state, now)
+	res := sw.result(state, now)
+
+	if res.Allowed {
+		state.Count++
+		res.Remaining--
+	}
+
+	state.Updated = now
+
+	return res
+}
+
+// TTL Two windows, after which the count of the previous window no longer
+// has any weight.
+func (sw *SlidingWindow) TTL() time.Duration {
+	return 2 * sw.Window
+}
+
+// slide Move the state to the window that now falls in.
+func (sw *SlidingWindow) slide(state *State, now time.Time) {
+	start := now.Truncate(sw.Window)
+
+	switch {
+	case state.WindowStart.Equal(start):
+		return
+	case state.WindowStart.Add(sw.Window).Equal(start):
+		state.PrevCount = state.Count
+	default:
+		state.PrevCount = 0
+	}
+
+	state.Count = 0
+	state.WindowStart = start
+}
+
+func (sw *SlidingWindow) result(state *State, now time.Time) *Result {
+	weight := 1 - float64(now.Sub(state.WindowStart))/float64(sw.Window)
+	estimate := float64(state.PrevCount)*weight + float64(state.Count)
+
+	res := &Result{
+		Allowed:   estimate+1 <= float64(sw.Limit),
+		Limit:     sw.Limit,
+		Remaining: int(math.Max(0, math.Floor(float64(sw.Limit)-estimate))),
+	}
+
+	if res.Allowed {
+		return res
+	}
+
+	// Solve prev * (1 - t/Window) + count + 1 <= Limit for t, the time into
+	// a window at which the previous window has lost enough weight. When the
+	// current window alone is full, it becomes the previous window.
+	start, prev, count := state.WindowStart, state.PrevCount, state.Count
+	if count+1 > sw.Limit {
+		start, prev, count = start.Add(sw.Window), count, 0
+	}
+
+	if prev == 0 || sw.Limit < 1 {
+		res.RetryAfter = sw.Window
+		return res
+	}
+
+	need := 1 - float64(sw.Limit-count-1)/float64(prev)
+	res.RetryAfter = start.Add(time.Duration(need * float64(sw.Window))).Sub(now)
+
+	if res.RetryAfter < 0 {
+		res.RetryAfter = 0
+	}
+
+	return res
+}
@@ -0,0 +1,151 @@
+// Package dynamo keeps rate limit state in a DynamoDB table, so that a fleet
+// of servers, such as AWS Lambda functions, share the same counts. Writes are
+// conditional on the version of the state, so concurrent updates are never
+// lost.
+//
+//	The table needs a string partition key named "ID". Enable TTL on the
+//	"TTL" attribute to have DynamoDB delete idle keys.
+package dynamo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/kohirens/www/ratelimit"
+)
+
+// API The subset of the DynamoDB client used by the store.
+type API interface {
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+}
+
+// Store Keeps rate limit state in a DynamoDB table.
+type Store struct {
+	Context context.Context
+	name    string
+	svc     API
+}
+
+const (
+	attrData    = "Data"
+	attrID      = "ID"
+	attrTTL     = "TTL"
+	attrVersion = "Version"
+)
+
+var _ ratelimit.Store = (*Store)(nil)
+
+// NewStore Initialize a store on a DynamoDB table.
+func NewStore(ctx context.Context, svc API, table string) *Store {
+	return &Store{
+		Context: ctx,
+		name:    table,
+		svc:     svc,
+	}
+}
+
+// Load The state for a key.
+func (s *Store) Load(key string) (*ratelimit.State, error) {
+	result, e1 := s.svc.GetItem(s.Context, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.name),
+		ConsistentRead: aws.Bool(true),
+		Key: map[string]types.AttributeValue{
+			attrID: &types.AttributeValueMemberS{Value: key},
+		},
+	})
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.GetItem, key, s.name, e1.Error())
+	}
+
+	state := &ratelimit.State{}
+	if result.Item == nil {
+		return state, nil
+	}
+
+	data, ok := result.Item[attrData].(*types.AttributeValueMemberB)
+	if !ok {
+		return nil, fmt.Errorf(stderr.AttributeType, attrData, key)
+	}
+
+	if e := json.Unmarshal(data.Value, state); e != nil {
+		return nil, fmt.Errorf(stderr.DecodeJSON, e.Error())
+	}
+
+	// DynamoDB deletes expired items eventually, so they may still be read.
+	if !state.Expires.IsZero() && time.Now().After(state.Expires) {
+		return &ratelimit.State{Version: state.Version}, nil
+	}
+
+	return state, nil
+}
+
+// Save The state for a key, on the condition that no other server has saved
+// it since it was loaded.
+func (s *Store) Save(key string, state *ratelimit.State, ttl time.Duration) error {
+	loaded := state.Version
+	next := *state
+	next.Version++
+	next.Expires = time.Now().Add(ttl)
+
+	data, e1 := json.Marshal(&next)
+	if e1 != nil {
+		return fmt.Errorf(stderr.EncodeJSON, e1.Error())
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(s.name),
+		Item: map[string]types.AttributeValue{
+			attrID:      &types.AttributeValueMemberS{Value: key},
+			attrData:    &types.AttributeValueMemberB{Value: data},
+			attrTTL:     &types.AttributeValueMemberN{Value: strconv.FormatInt(next.Expires.Unix(), 10)},
+			attrVersion: &types.AttributeValueMemberN{Value: strconv.FormatInt(next.Version, 10)},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(#id) OR #v = :v"),
+		ExpressionAttributeNames: map[string]string{
+			"#id": attrID,
+			"#v":  attrVersion,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":v": &types.AttributeValueMemberN{Value: strconv.FormatInt(loaded, 10)},
+		},
+	}
+
+	_, e2 := s.svc.PutItem(s.Context, input)
+
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(e2, &ccf) {
+		return &ratelimit.ConflictError{Key: key}
+	}
+
+	if e2 != nil {
+		return fmt.Errorf(stderr.PutItem, key, s.name, e2.Error())
+	}
+
+	*state = next
+
+	return nil
+}
+
+// Remove The state for a key.
+func (s *Store) Remove(key string) error {
+	_, e1 := s.svc.DeleteItem(s.Context, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.name),
+		Key: map[string]types.AttributeValue{
+			attrID: &types.AttributeValueMemberS{Value: key},
+		},
+	})
+	if e1 != nil {
+		return fmt.Errorf(stderr.DeleteItem, key, s.name, e1.Error())
+	}
+
+	return nil
+}
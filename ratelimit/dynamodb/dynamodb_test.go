@@ -0,0 +1,83 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/kohirens/www/ratelimit"
+)
+
+// MockAPI A table in memory that honors the version condition of PutItem.
+type MockAPI struct {
+	items map[string]map[string]types.AttributeValue
+}
+
+func (m *MockAPI) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	delete(m.items, params.Key[attrID].(*types.AttributeValueMemberS).Value)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func (m *MockAPI) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{
+		Item: m.items[params.Key[attrID].(*types.AttributeValueMemberS).Value],
+	}, nil
+}
+
+func (m *MockAPI) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	id := params.Item[attrID].(*types.AttributeValueMemberS).Value
+	want := params.ExpressionAttributeValues[":v"].(*types.AttributeValueMemberN).Value
+
+	if stored, ok := m.items[id]; ok {
+		if stored[attrVersion].(*types.AttributeValueMemberN).Value != want {
+			return nil, &types.ConditionalCheckFailedException{}
+		}
+	}
+
+	m.items[id] = params.Item
+
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func TestStore(t *testing.T) {
+	s := NewStore(
+		context.Background(),
+		&MockAPI{items: make(map[string]map[string]types.AttributeValue)},
+		"rate-limits",
+	)
+	key := "ip:192.0.2.1"
+
+	first, e1 := s.Load(key)
+	if e1 != nil {
+		t.Fatalf("Load() error = %v", e1)
+	}
+	second, _ := s.Load(key)
+
+	first.Count = 1
+	if e := s.Save(key, first, time.Minute); e != nil {
+		t.Fatalf("Save() error = %v", e)
+	}
+
+	second.Count = 5
+	var ce *ratelimit.ConflictError
+	if e := s.Save(key, second, time.Minute); !errors.As(e, &ce) {
+		t.Fatalf("Save() error = %v, want a ConflictError", e)
+	}
+
+	got, _ := s.Load(key)
+	if got.Count != 1 || got.Version != 1 {
+		t.Fatalf("Load() = %+v, want Count 1 and Version 1", got)
+	}
+
+	if e := s.Remove(key); e != nil {
+		t.Fatalf("Remove() error = %v", e)
+	}
+
+	got, _ = s.Load(key)
+	if got.Count != 0 {
+		t.Errorf("Load() after Remove() Count = %v, want 0", got.Count)
+	}
+}
@@ -0,0 +1,17 @@
+package dynamo
+
+var stderr = struct {
+	AttributeType,
+	DecodeJSON,
+	DeleteItem,
+	EncodeJSON,
+	GetItem,
+	PutItem string
+}{
+	AttributeType: "attribute %v of item %v is not the expected type",
+	DecodeJSON:    "could not decode JSON: %v",
+	DeleteItem:    "could not delete item %v from dynamodb table %v: %v",
+	EncodeJSON:    "could not encode JSON: %v",
+	GetItem:       "could not get item %v from dynamodb table %v: %v",
+	PutItem:       "could not put item %v to dynamodb table %v: %v",
+}
@@ -0,0 +1,26 @@
+package ratelimit
+
+import (
+	"fmt"
+	"time"
+)
+
+// ConflictError Thrown by a Store when the state was changed by another
+// server since it was loaded.
+type ConflictError struct {
+	Key string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf(stderr.Conflict, e.Key)
+}
+
+// LimitError Thrown when a key has run out of attempts.
+type LimitError struct {
+	key        string
+	RetryAfter time.Duration
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf(stderr.Limited, e.key, e.RetryAfter)
+}
@@ -0,0 +1,25 @@
+package ratelimit
+
+var stderr = struct {
+	ClearAttempts,
+	Conflict,
+	DecodeJSON,
+	EncodeJSON,
+	Limited,
+	LoadState,
+	SaveState string
+}{
+	ClearAttempts: "cannot clear the attempts for %v: %v",
+	Conflict:      "rate limit state for %v was changed by another server",
+	DecodeJSON:    "cannot decode rate limit state: %v",
+	EncodeJSON:    "cannot encode rate limit state: %v",
+	Limited:       "too many requests for %v, retry after %v",
+	LoadState:     "cannot load rate limit state for %v: %v",
+	SaveState:     "cannot save rate limit state for %v: %v",
+}
+
+var stdout = struct {
+	Limited string
+}{
+	Limited: "rate limited %v, retry after %v",
+}
@@ -0,0 +1,299 @@
+// Package ratelimit throttles requests per key, such as a client IP, session
+// ID or account ID. Use it to protect endpoints like sign-in from brute-force
+// attacks. State can be kept in memory for a single server, or in a shared
+// store for a fleet of servers like AWS Lambda functions.
+package ratelimit
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kohirens/stdlib/logger"
+	"github.com/kohirens/www"
+	"github.com/kohirens/www/backend"
+	"github.com/kohirens/www/session"
+)
+
+// KeyFunc Derive the key to limit a request by. An empty key skips limiting
+// for the request.
+type KeyFunc func(r *http.Request) string
+
+// Limiter Applies an algorithm to requests grouped by a key.
+type Limiter struct {
+	algorithm Algorithm
+	keyFunc   KeyFunc
+	// mutex Serializes updates made by this server, the store detects
+	// updates made by other servers.
+	mutex sync.Mutex
+	// Retries How many times to retry an update that conflicts with another
+	// server before giving up.
+	Retries int
+	store   Store
+}
+
+// Result The decision for a request.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+const (
+	headerLimit     = "RateLimit-Limit"
+	headerRemaining = "RateLimit-Remaining"
+)
+
+var (
+	Log = &logger.Standard{}
+	// now Allows tests to control the clock.
+	now = time.Now
+)
+
+// New Initialize a rate limiter.
+func New(algorithm Algorithm, store Store, keyFunc KeyFunc) *Limiter {
+	return &Limiter{
+		algorithm: algorithm,
+		keyFunc:   keyFunc,
+		Retries:   3,
+		store:     store,
+	}
+}
+
+// Allow Consume a request for a key and report the decision.
+func (l *Limiter) Allow(key string) (*Result, error) {
+	var res *Result
+
+	e1 := l.update(key, func(state *State) bool {
+		res = l.algorithm.Take(state, now())
+		return res.Allowed
+	})
+
+	return res, e1
+}
+
+// Attempt Guard against brute-force attempts at fn, such as checking a
+// password. The attempt is consumed before fn is called, so attempts made in
+// parallel cannot all get past the limit; a successful attempt then clears
+// the key, so only failed attempts add up. When the key has run out of
+// attempts, fn is not called and a *LimitError is returned.
+func (l *Limiter) Attempt(key string, fn func() error) error {
+	res, e1 := l.Allow(key)
+	if e1 != nil {
+		return e1
+	}
+
+	if !res.Allowed {
+		return &LimitError{key, res.RetryAfter}
+	}
+
+	if e := fn(); e != nil {
+		return e
+	}
+
+	if e := l.Reset(key); e != nil {
+		Log.Errf(stderr.ClearAttempts, key, e.Error())
+	}
+
+	return nil
+}
+
+// Authenticated Wrap a route like backend.Authenticated, and limit the
+// failed attempts at credentials, such as guessing a password, by the key of
+// the request. A client that runs out of attempts gets a 429 Too Many
+// Requests. Requests without credentials are not counted, since browsers
+// send them to get the challenge. For example:
+//
+//	limiter := ratelimit.New(
+//		ratelimit.NewSlidingWindow(5, time.Minute),
+//		ratelimit.NewMemoryStore(),
+//		ratelimit.ByIP,
+//	)
+//	app.AddRoute("/api/admin", limiter.Authenticated(auth, adminRoute))
+func (l *Limiter) Authenticated(auth *www.Authenticator, next backend.Route) backend.Route {
+	authenticated := backend.Authenticated(auth, next)
+
+	return func(w http.ResponseWriter, r *http.Request, a backend.App) error {
+		key := l.keyFunc(r)
+		if key == "" || r.Header.Get("Authorization") == "" {
+			return authenticated(w, r, a)
+		}
+
+		var id *www.Identity
+		e1 := l.Attempt(key, func() error {
+			var e error
+			id, e = auth.Authenticate(r)
+			return e
+		})
+
+		var le *LimitError
+		var ae *www.AuthError
+
+		switch {
+		case errors.As(e1, &le):
+			Log.Warnf(stdout.Limited, key, le.RetryAfter)
+			www.Respond429(w, le.RetryAfter)
+			return nil
+		case errors.As(e1, &ae):
+			auth.Challenge(w, e1)
+			return nil
+		case e1 != nil:
+			return e1
+		}
+
+		return next(w, r.WithContext(www.WithIdentity(r.Context(), id)), a)
+	}
+}
+
+// Handler Wrap a http.Handler so that requests over the limit get a 429 Too
+// Many Requests response.
+func (l *Limiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.limit(w, r) {
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Reset Clear the state for a key.
+func (l *Limiter) Reset(key string) error {
+	return l.store.Remove(key)
+}
+
+// Route Wrap a backend.Route so that requests over the limit get a 429 Too
+// Many Requests response. For example:
+//
+//	limiter := ratelimit.New(
+//		ratelimit.NewSlidingWindow(5, time.Minute),
+//		ratelimit.NewMemoryStore(),
+//		ratelimit.ByIP,
+//	)
+//	app.AddRoute("/api/meals", limiter.Route(meals))
+func (l *Limiter) Route(next backend.Route) backend.Route {
+	return func(w http.ResponseWriter, r *http.Request, a backend.App) error {
+		if l.limit(w, r) {
+			return nil
+		}
+
+		return next(w, r, a)
+	}
+}
+
+// limit Respond with a 429 and return true when the request is over the limit.
+// Errors from the store are logged and the request is let through, so that an
+// outage of the store does not take the site down with it.
+func (l *Limiter) limit(w http.ResponseWriter, r *http.Request) bool {
+	key := l.keyFunc(r)
+	if key == "" {
+		return false
+	}
+
+	res, e1 := l.Allow(key)
+	if e1 != nil {
+		Log.Errf("%v", e1.Error())
+		return false
+	}
+
+	w.Header().Set(headerLimit, strconv.Itoa(res.Limit))
+	w.Header().Set(headerRemaining, strconv.Itoa(res.Remaining))
+
+	if !res.Allowed {
+		Log.Warnf(stdout.Limited, key, res.RetryAfter)
+		www.Respond429(w, res.RetryAfter)
+		return true
+	}
+
+	return false
+}
+
+// update Apply fn to the state for a key, retrying when another server has
+// updated the same key in the meantime. The state is only saved when fn
+// returns true.
+func (l *Limiter) update(key string, fn func(state *State) bool) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	var e1 error
+	for i := 0; i <= l.Retries; i++ {
+		state, e2 := l.store.Load(key)
+		if e2 != nil {
+			return e2
+		}
+
+		if !fn(state) {
+			return nil
+		}
+
+		e1 = l.store.Save(key, state, l.algorithm.TTL())
+
+		var ce *ConflictError
+		if !errors.As(e1, &ce) {
+			return e1
+		}
+	}
+
+	return e1
+}
+
+// ByIP Limit by the IP address of the client.
+//
+//	This uses http.Request.RemoteAddr, which awslambda.NewRequest sets from
+//	the source IP of the Lambda event. Behind a proxy or CDN, use
+//	ByForwardedIP instead.
+func ByIP(r *http.Request) string {
+	host, _, e1 := net.SplitHostPort(r.RemoteAddr)
+	if e1 != nil {
+		host = r.RemoteAddr
+	}
+
+	if host == "" {
+		return ""
+	}
+
+	return "ip:" + host
+}
+
+// ByForwardedIP Limit by the first address in the X-Forwarded-For header,
+// falling back to ByIP.
+//
+//	NOTE: Only use this behind a proxy or CDN, such as CloudFront, that
+//	overwrites the header, otherwise clients can set it to anything.
+func ByForwardedIP(r *http.Request) string {
+	forwarded := r.Header.Get("X-Forwarded-For")
+	if forwarded == "" {
+		return ByIP(r)
+	}
+
+	ip, _, _ := strings.Cut(forwarded, ",")
+
+	return "ip:" + strings.TrimSpace(ip)
+}
+
+// BySessionID Limit by the session ID cookie.
+func BySessionID(r *http.Request) string {
+	c, e1 := r.Cookie(session.IDKey)
+	if e1 != nil || c.Value == "" {
+		return ""
+	}
+
+	return "sid:" + c.Value
+}
+
+// ByAccountID Limit by an account ID that accountID looks up for the request.
+func ByAccountID(accountID func(r *http.Request) string) KeyFunc {
+	return func(r *http.Request) string {
+		id := accountID(r)
+		if id == "" {
+			return ""
+		}
+
+		return "account:" + id
+	}
+}
@@ -0,0 +1,395 @@
+package ratelimit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kohirens/stdlib/test"
+	"github.com/kohirens/www"
+	"github.com/kohirens/www/backend"
+	"github.com/kohirens/www/storage"
+)
+
+const tmpDir = "tmp"
+
+func TestMain(m *testing.M) {
+	test.ResetDir(tmpDir, 0777)
+	os.Exit(m.Run())
+}
+
+// setClock Fix the time used by the limiter, returning a function to move it
+// forward.
+func setClock(t *testing.T, start time.Time) func(d time.Duration) {
+	current := start
+	now = func() time.Time { return current }
+	t.Cleanup(func() { now = time.Now })
+
+	return func(d time.Duration) { current = current.Add(d) }
+}
+
+func TestTokenBucket(t *testing.T) {
+	tests := []struct {
+		name    string
+		bucket  *TokenBucket
+		pattern []time.Duration // time to wait before each request
+		want    []bool
+	}{
+		{
+			"burst-then-limited",
+			NewTokenBucket(3, 3*time.Second),
+			[]time.Duration{0, 0, 0, 0},
+			[]bool{true, true, true, false},
+		},
+		{
+			"refills",
+			NewTokenBucket(2, 2*time.Second),
+			[]time.Duration{0, 0, 0, time.Second},
+			[]bool{true, true, false, true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &State{}
+			clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+			for i, wait := range tt.pattern {
+				clock = clock.Add(wait)
+				got := tt.bucket.Take(state, clock)
+				if got.Allowed != tt.want[i] {
+					t.Errorf("Take() request %v = %v, want %v", i, got.Allowed, tt.want[i])
+					return
+				}
+
+				if !got.Allowed && got.RetryAfter <= 0 {
+					t.Errorf("Take() request %v RetryAfter = %v, want > 0", i, got.RetryAfter)
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	tests := []struct {
+		name    string
+		window  *SlidingWindow
+		pattern []time.Duration // time to wait before each request
+		want    []bool
+	}{
+		{
+			"limited-in-window",
+			NewSlidingWindow(2, time.Minute),
+			[]time.Duration{0, time.Second, time.Second},
+			[]bool{true, true, false},
+		},
+		{
+			"previous-window-still-counts",
+			NewSlidingWindow(2, time.Minute),
+			[]time.Duration{50 * time.Second, time.Second, 10 * time.Second},
+			[]bool{true, true, false},
+		},
+		{
+			"allowed-after-two-windows",
+			NewSlidingWindow(2, time.Minute),
+			[]time.Duration{0, 0, 0, 2 * time.Minute},
+			[]bool{true, true, false, true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &State{}
+			clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+			for i, wait := range tt.pattern {
+				clock = clock.Add(wait)
+				got := tt.window.Take(state, clock)
+				if got.Allowed != tt.want[i] {
+					t.Errorf("Take() request %v = %v, want %v", i, got.Allowed, tt.want[i])
+					return
+				}
+
+				if got.Allowed {
+					continue
+				}
+
+				// Waiting RetryAfter must be enough to be allowed again.
+				retry := *state
+				if !tt.window.Check(&retry, clock.Add(got.RetryAfter+time.Millisecond)).Allowed {
+					t.Errorf("Check() after RetryAfter %v is still limited", got.RetryAfter)
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestLimiter_Route(t *testing.T) {
+	advance := setClock(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	limiter := New(NewSlidingWindow(2, time.Minute), NewMemoryStore(), ByIP)
+	route := limiter.Route(func(w http.ResponseWriter, r *http.Request, a backend.App) error {
+		w.WriteHeader(http.StatusOK)
+		return nil
+	})
+
+	tests := []struct {
+		name           string
+		remoteAddr     string
+		wait           time.Duration
+		wantCode       int
+		wantRetryAfter string
+	}{
+		{"first", "192.0.2.1:1234", 0, 200, ""},
+		{"second", "192.0.2.1:1234", 0, 200, ""},
+		{"limited", "192.0.2.1:1234", 0, 429, "90"},
+		{"other-ip", "192.0.2.2:1234", 0, 200, ""},
+		{"next-windows", "192.0.2.1:1234", 2 * time.Minute, 200, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			advance(tt.wait)
+			r := httptest.NewRequest("POST", "/api/sign-in", nil)
+			r.RemoteAddr = tt.remoteAddr
+			w := httptest.NewRecorder()
+
+			if e := route(w, r, nil); e != nil {
+				t.Errorf("Route() error = %v", e)
+				return
+			}
+
+			if w.Code != tt.wantCode {
+				t.Errorf("Route() status = %v, want %v", w.Code, tt.wantCode)
+				return
+			}
+
+			if got := w.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("Route() Retry-After = %q, want %q", got, tt.wantRetryAfter)
+			}
+		})
+	}
+}
+
+func TestLimiter_Attempt(t *testing.T) {
+	advance := setClock(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	limiter := New(NewSlidingWindow(2, time.Minute), NewMemoryStore(), ByIP)
+	errWrongPassword := errors.New("wrong password")
+
+	tests := []struct {
+		name      string
+		attempt   error
+		wait      time.Duration
+		wantErr   error
+		wantLimit bool
+	}{
+		{"fail-1", errWrongPassword, 0, errWrongPassword, false},
+		{"fail-2", errWrongPassword, 0, errWrongPassword, false},
+		{"locked-out", nil, 0, nil, true},
+		{"success-after-wait", nil, 2 * time.Minute, nil, false},
+		{"cleared-by-success", errWrongPassword, 0, errWrongPassword, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			advance(tt.wait)
+			called := false
+
+			err := limiter.Attempt("ip:192.0.2.1", func() error {
+				called = true
+				return tt.attempt
+			})
+
+			var le *LimitError
+			if gotLimit := errors.As(err, &le); gotLimit != tt.wantLimit {
+				t.Errorf("Attempt() error = %v, want limited %v", err, tt.wantLimit)
+				return
+			}
+
+			if called == tt.wantLimit {
+				t.Errorf("Attempt() called fn = %v when limited = %v", called, tt.wantLimit)
+				return
+			}
+
+			if !tt.wantLimit && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Attempt() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLimiter_Attempt_Parallel(t *testing.T) {
+	limiter := New(NewSlidingWindow(3, time.Minute), NewMemoryStore(), ByIP)
+	errWrongPassword := errors.New("wrong password")
+
+	var calls atomic.Int32
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = limiter.Attempt("ip:192.0.2.1", func() error {
+				calls.Add(1)
+				time.Sleep(time.Millisecond)
+				return errWrongPassword
+			})
+		}()
+	}
+	wg.Wait()
+
+	if got := calls.Load(); got != 3 {
+		t.Errorf("Attempt() in parallel called fn %v times, want 3", got)
+	}
+}
+
+func TestLimiter_Authenticated(t *testing.T) {
+	setClock(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	hash, _ := www.HashPassword("0123")
+	auth := &www.Authenticator{
+		Realm: "test",
+		Users: www.StaticUsers{"abcd": {Username: "abcd", PasswordHash: hash}},
+	}
+	limiter := New(NewSlidingWindow(2, time.Minute), NewMemoryStore(), ByIP)
+	route := limiter.Authenticated(auth, func(w http.ResponseWriter, r *http.Request, a backend.App) error {
+		w.WriteHeader(http.StatusOK)
+		return nil
+	})
+
+	tests := []struct {
+		name     string
+		password string
+		wantCode int
+	}{
+		{"no-credentials", "", http.StatusUnauthorized},
+		{"wrong-1", "wrong", http.StatusUnauthorized},
+		{"wrong-2", "wrong", http.StatusUnauthorized},
+		{"locked-out", "0123", http.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/admin", nil)
+			r.RemoteAddr = "192.0.2.1:1234"
+			if tt.password != "" {
+				r.SetBasicAuth("abcd", tt.password)
+			}
+			w := httptest.NewRecorder()
+
+			if e := route(w, r, nil); e != nil {
+				t.Fatalf("Authenticated() error = %v", e)
+			}
+
+			if w.Code != tt.wantCode {
+				t.Errorf("Authenticated() status = %v, want %v", w.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestStores(t *testing.T) {
+	local, _ := storage.NewLocalStorage(tmpDir)
+	tests := []struct {
+		name  string
+		store Store
+	}{
+		{"memory", NewMemoryStore()},
+		{"storage", NewStorageStore(local, "")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := "ip:2001:db8::1"
+
+			first, e1 := tt.store.Load(key)
+			if e1 != nil {
+				t.Fatalf("Load() error = %v", e1)
+			}
+			second, _ := tt.store.Load(key)
+
+			first.Count = 1
+			if e := tt.store.Save(key, first, time.Minute); e != nil {
+				t.Fatalf("Save() error = %v", e)
+			}
+
+			// The second copy is now stale and must not overwrite the first.
+			second.Count = 5
+			var ce *ConflictError
+			if e := tt.store.Save(key, second, time.Minute); !errors.As(e, &ce) {
+				t.Errorf("Save() error = %v, want a ConflictError", e)
+				return
+			}
+
+			got, _ := tt.store.Load(key)
+			if got.Count != 1 {
+				t.Errorf("Load() Count = %v, want 1", got.Count)
+				return
+			}
+
+			if e := tt.store.Remove(key); e != nil {
+				t.Errorf("Remove() error = %v", e)
+				return
+			}
+
+			got, _ = tt.store.Load(key)
+			if got.Count != 0 {
+				t.Errorf("Load() after Remove() Count = %v, want 0", got.Count)
+			}
+		})
+	}
+}
+
+func TestKeyFuncs(t *testing.T) {
+	tests := []struct {
+		name    string
+		keyFunc KeyFunc
+		setup   func(r *http.Request)
+		want    string
+	}{
+		{
+			"ip",
+			ByIP,
+			func(r *http.Request) { r.RemoteAddr = "192.0.2.1:1234" },
+			"ip:192.0.2.1",
+		},
+		{
+			"ip-without-port",
+			ByIP,
+			func(r *http.Request) { r.RemoteAddr = "192.0.2.1" },
+			"ip:192.0.2.1",
+		},
+		{
+			"forwarded-ip",
+			ByForwardedIP,
+			func(r *http.Request) { r.Header.Set("X-Forwarded-For", "198.51.100.7, 10.0.0.1") },
+			"ip:198.51.100.7",
+		},
+		{
+			"session-id",
+			BySessionID,
+			func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "_sid_", Value: "abc"}) },
+			"sid:abc",
+		},
+		{
+			"no-session-id",
+			BySessionID,
+			func(r *http.Request) {},
+			"",
+		},
+		{
+			"account-id",
+			ByAccountID(func(r *http.Request) string { return "1234" }),
+			func(r *http.Request) {},
+			"account:1234",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			tt.setup(r)
+
+			if got := tt.keyFunc(r); got != tt.want {
+				t.Errorf("KeyFunc() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
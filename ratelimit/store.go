@@ -0,0 +1,223 @@
+package ratelimit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kohirens/www/storage"
+)
+
+// State Kept for each key; it holds enough to serve any of the algorithms in
+// this package.
+type State struct {
+	// Tokens Left in a TokenBucket.
+	Tokens float64 `json:"tokens"`
+	// Count Requests made in the current SlidingWindow.
+	Count int `json:"count"`
+	// PrevCount Requests made in the previous SlidingWindow.
+	PrevCount int `json:"prev_count"`
+	// WindowStart When the current SlidingWindow started.
+	WindowStart time.Time `json:"window_start"`
+	// Updated When the state was last changed.
+	Updated time.Time `json:"updated"`
+	// Expires When the state can be forgotten.
+	Expires time.Time `json:"expires"`
+	// Version Incremented on every save, so that a store can detect when
+	// the state was changed by someone else since it was loaded.
+	Version int64 `json:"version"`
+}
+
+// Store Keeps the state of each key. Implementations must return a
+// *ConflictError from Save when the stored version no longer matches the
+// version of the state that was loaded, so that concurrent servers do not
+// overwrite each other's counts.
+type Store interface {
+	// Load The state for a key. A key that has no state, or whose state has
+	// expired, returns a new zero State.
+	Load(key string) (*State, error)
+	// Save The state for a key, it may be forgotten after ttl.
+	Save(key string, state *State, ttl time.Duration) error
+	// Remove The state for a key.
+	Remove(key string) error
+}
+
+// MemoryStore Keeps state in memory, for a single server.
+type MemoryStore struct {
+	mutex  sync.Mutex
+	states map[string]State
+}
+
+// StorageStore Keeps state in a storage.Storage, for example an S3 bucket
+// shared by a fleet of Lambda functions.
+//
+//	NOTE: storage.Storage has no conditional writes, so the version check is
+//	best-effort; two servers saving at the same instant can lose a count.
+//	Use the DynamoDB store when that matters.
+type StorageStore struct {
+	// Prefix Location in storage to keep the state.
+	Prefix string
+	store  storage.Storage
+}
+
+var (
+	_ Store = (*MemoryStore)(nil)
+	_ Store = (*StorageStore)(nil)
+)
+
+// NewMemoryStore Initialize an in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		states: make(map[string]State),
+	}
+}
+
+// NewStorageStore Initialize a store that keeps state in a storage.Storage
+// under prefix.
+func NewStorageStore(store storage.Storage, prefix string) *StorageStore {
+	return &StorageStore{
+		Prefix: prefix,
+		store:  store,
+	}
+}
+
+// Load The state for a key.
+func (ms *MemoryStore) Load(key string) (*State, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	state, ok := ms.states[key]
+	if !ok || expired(&state) {
+		delete(ms.states, key)
+		return &State{}, nil
+	}
+
+	return &state, nil
+}
+
+// Save The state for a key.
+func (ms *MemoryStore) Save(key string, state *State, ttl time.Duration) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	stored, ok := ms.states[key]
+	if ok && !expired(&stored) && stored.Version != state.Version {
+		return &ConflictError{key}
+	}
+
+	state.Version++
+	state.Expires = time.Now().Add(ttl)
+	ms.states[key] = *state
+
+	return nil
+}
+
+// Remove The state for a key.
+func (ms *MemoryStore) Remove(key string) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	delete(ms.states, key)
+
+	return nil
+}
+
+// Sweep Forget expired state, call this periodically on long-running servers
+// to keep memory in check.
+func (ms *MemoryStore) Sweep() int {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	n := 0
+	for key, state := range ms.states {
+		if expired(&state) {
+			delete(ms.states, key)
+			n++
+		}
+	}
+
+	return n
+}
+
+// Load The state for a key.
+func (ss *StorageStore) Load(key string) (*State, error) {
+	location := ss.Location(key)
+
+	if !ss.store.Exist(location) {
+		return &State{}, nil
+	}
+
+	data, e1 := ss.store.Load(location)
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.LoadState, key, e1.Error())
+	}
+
+	state := &State{}
+	if e := json.Unmarshal(data, state); e != nil {
+		return nil, fmt.Errorf(stderr.DecodeJSON, e.Error())
+	}
+
+	if expired(state) {
+		return &State{}, nil
+	}
+
+	return state, nil
+}
+
+// Location Where the state for a key is kept in storage. The key is hashed
+// since it may contain characters, like those of an IPv6 address, that are
+// not safe in a filename.
+func (ss *StorageStore) Location(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:]) + ".json"
+
+	if ss.Prefix == "" {
+		return name
+	}
+
+	return ss.Prefix + "/" + name
+}
+
+// Save The state for a key.
+func (ss *StorageStore) Save(key string, state *State, ttl time.Duration) error {
+	stored, e1 := ss.Load(key)
+	if e1 != nil {
+		return e1
+	}
+
+	if stored.Version != 0 && stored.Version != state.Version {
+		return &ConflictError{key}
+	}
+
+	state.Version++
+	state.Expires = time.Now().Add(ttl)
+
+	data, e2 := json.Marshal(state)
+	if e2 != nil {
+		return fmt.Errorf(stderr.EncodeJSON, e2.Error())
+	}
+
+	if e := ss.store.Save(ss.Location(key), data); e != nil {
+		return fmt.Errorf(stderr.SaveState, key, e.Error())
+	}
+
+	return nil
+}
+
+// Remove The state for a key.
+func (ss *StorageStore) Remove(key string) error {
+	location := ss.Location(key)
+
+	if !ss.store.Exist(location) {
+		return nil
+	}
+
+	return ss.store.Remove(location)
+}
+
+func expired(state *State) bool {
+	return !state.Expires.IsZero() && time.Now().After(state.Expires)
+}
@@ -0,0 +1,141 @@
+package session
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+)
+
+// Cipher Encrypts and decrypts session payloads. A *gpg.Capsule and a
+// backend.App satisfy it, as does AESGCM.
+type Cipher interface {
+	Encrypt(message []byte) ([]byte, error)
+	Decrypt(encryptedMessage []byte) ([]byte, error)
+}
+
+// AEAD An optional interface for a Cipher that authenticates additional
+// data along with the message. EncryptedStorage passes the session ID, so
+// that a payload copied to another session does not decrypt. AESGCM
+// implements it.
+type AEAD interface {
+	DecryptWith(encryptedMessage, additionalData []byte) ([]byte, error)
+	EncryptWith(message, additionalData []byte) ([]byte, error)
+}
+
+// AESGCM A symmetric Cipher using AES in GCM mode, which is much faster than
+// GPG and produces smaller payloads, a better fit for sessions that are read
+// and written on every request.
+//
+// Every payload is tagged with the ID of the key that encrypted it, so keys
+// can be rotated: make a new key the current key, and keep the old keys
+// around until the sessions they encrypted have expired.
+type AESGCM struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+var _ AEAD = (*AESGCM)(nil)
+
+const aesgcmVersion = 1
+
+// NewAESGCM Initialize a cipher that encrypts with the key currentID, and
+// decrypts with any of the keys. Each key must be 16, 24 or 32 bytes, to
+// select AES-128, AES-192 or AES-256, and key IDs must be 1 to 255 bytes.
+func NewAESGCM(currentID string, keys map[string][]byte) (*AESGCM, error) {
+	if _, ok := keys[currentID]; !ok {
+		return nil, fmt.Errorf(stderr.CipherKeyNotFound, currentID)
+	}
+
+	c := &AESGCM{
+		current: currentID,
+		keys:    make(map[string]cipher.AEAD, len(keys)),
+	}
+
+	for id, key := range keys {
+		if len(id) < 1 || len(id) > 255 {
+			return nil, fmt.Errorf(stderr.CipherKeyID, id)
+		}
+
+		block, e1 := aes.NewCipher(key)
+		if e1 != nil {
+			return nil, fmt.Errorf(stderr.CipherKey, id, e1.Error())
+		}
+
+		aead, e2 := cipher.NewGCM(block)
+		if e2 != nil {
+			return nil, fmt.Errorf(stderr.CipherKey, id, e2.Error())
+		}
+
+		c.keys[id] = aead
+	}
+
+	return c, nil
+}
+
+// Decrypt A payload made by Encrypt, with whichever key made it.
+func (c *AESGCM) Decrypt(encryptedMessage []byte) ([]byte, error) {
+	return c.DecryptWith(encryptedMessage, nil)
+}
+
+// DecryptWith A payload made by EncryptWith with the same additional data.
+func (c *AESGCM) DecryptWith(encryptedMessage, additionalData []byte) ([]byte, error) {
+	if len(encryptedMessage) < 2 || encryptedMessage[0] != aesgcmVersion {
+		return nil, fmt.Errorf("%v", stderr.CipherFormat)
+	}
+
+	idLen := int(encryptedMessage[1])
+	if len(encryptedMessage) < 2+idLen {
+		return nil, fmt.Errorf("%v", stderr.CipherFormat)
+	}
+
+	header := encryptedMessage[:2+idLen]
+	id := string(header[2:])
+
+	aead, ok := c.keys[id]
+	if !ok {
+		return nil, fmt.Errorf(stderr.CipherKeyNotFound, id)
+	}
+
+	rest := encryptedMessage[len(header):]
+	if len(rest) < aead.NonceSize() {
+		return nil, fmt.Errorf("%v", stderr.CipherFormat)
+	}
+
+	nonce, sealed := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+
+	// The header is authenticated, so the key ID cannot be tampered with.
+	plaintext, e1 := aead.Open(nil, nonce, sealed, append(bytes.Clone(header), additionalData...))
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.Decrypt, e1.Error())
+	}
+
+	return plaintext, nil
+}
+
+// Encrypt A payload with the current key. The payload is laid out as:
+//
+//	version (1 byte) | key ID length (1 byte) | key ID | nonce | ciphertext
+func (c *AESGCM) Encrypt(message []byte) ([]byte, error) {
+	return c.EncryptWith(message, nil)
+}
+
+// EncryptWith A payload with the current key, authenticating the additional
+// data, which is not part of the payload.
+func (c *AESGCM) EncryptWith(message, additionalData []byte) ([]byte, error) {
+	aead := c.keys[c.current]
+
+	header := append([]byte{aesgcmVersion, byte(len(c.current))}, c.current...)
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, e := rand.Read(nonce); e != nil {
+		return nil, fmt.Errorf(stderr.Encrypt, e.Error())
+	}
+
+	out := make([]byte, 0, len(header)+len(nonce)+len(message)+aead.Overhead())
+	out = append(out, header...)
+	out = append(out, nonce...)
+
+	return aead.Seal(out, nonce, message, append(bytes.Clone(header), additionalData...)), nil
+}
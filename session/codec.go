@@ -0,0 +1,54 @@
+package session
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// Codec Turns values into bytes to keep in a session and back again, see
+// Key.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	// GobCodec Encodes values with encoding/gob, which keeps Go types that
+	// JSON cannot, but only Go can read.
+	GobCodec Codec = gobCodec{}
+	// JSONCodec Encodes values as JSON, the default for a Key.
+	JSONCodec Codec = jsonCodec{}
+	// MsgPackCodec Encodes values in the MessagePack binary format, which is
+	// smaller than JSON and keeps []byte as is. It supports booleans,
+	// numbers, strings, byte slices, slices, arrays, maps, pointers and
+	// structs, whose exported fields are kept by name or by a msgpack tag.
+	// Types that implement encoding.TextMarshaler, such as time.Time, are
+	// kept as strings. Extension types are not supported.
+	MsgPackCodec Codec = msgpackCodec{}
+)
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if e := gob.NewEncoder(buf).Encode(v); e != nil {
+		return nil, e
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
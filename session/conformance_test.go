@@ -0,0 +1,59 @@
+package session_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kohirens/www/session"
+	"github.com/kohirens/www/session/sessiontest"
+	"github.com/kohirens/www/storage"
+)
+
+func TestEncryptedStorage_Conformance(t *testing.T) {
+	sessiontest.Run(t, func(t *testing.T) session.Storage {
+		c, e1 := session.NewAESGCM("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+		if e1 != nil {
+			t.Fatal(e1)
+		}
+
+		return session.NewEncryptedStorage(session.NewMemoryStorage(0), c)
+	})
+}
+
+func TestLocalStorage_Conformance(t *testing.T) {
+	sessiontest.Run(t, func(t *testing.T) session.Storage {
+		return session.NewStorageLocal(t.TempDir())
+	})
+}
+
+func TestLocalStorage_ConformanceSharded(t *testing.T) {
+	sessiontest.Run(t, func(t *testing.T) session.Storage {
+		s := session.NewStorageLocal(t.TempDir())
+		s.Shards = 2
+		return s
+	})
+}
+
+func TestStorageLocalStorage_Conformance(t *testing.T) {
+	sessiontest.Run(t, func(t *testing.T) session.Storage {
+		dir := t.TempDir()
+		if e := os.Mkdir(filepath.Join(dir, sessiontest.Location), 0700); e != nil {
+			t.Fatal(e)
+		}
+
+		s, e1 := storage.NewLocalStorage(dir)
+		if e1 != nil {
+			t.Fatal(e1)
+		}
+
+		return s
+	})
+}
+
+func TestMemoryStorage_Conformance(t *testing.T) {
+	sessiontest.Run(t, func(t *testing.T) session.Storage {
+		return session.NewMemoryStorage(0)
+	})
+}
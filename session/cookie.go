@@ -0,0 +1,262 @@
+package session
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CookieCarrier An optional interface for a Storage that keeps sessions in
+// the cookies of the client, instead of on the server. The Manager reads the
+// cookies in LoadFromCookie, and sends them in SetCookie and RefreshCookie.
+type CookieCarrier interface {
+	// ReadCookies Take the session from the cookies of a request.
+	ReadCookies(r *http.Request)
+	// Cookies To send to the client when the session was saved or removed
+	// since the cookies were read, otherwise nil.
+	Cookies(expires time.Time) []*http.Cookie
+}
+
+// RequestStorage An optional interface for a Storage that holds the state
+// of one request, such as CookieStorage. A Manager is made with a copy from
+// ForRequest, so that requests served at the same time do not share it.
+type RequestStorage interface {
+	ForRequest() Storage
+}
+
+// CookieStorage Keeps the session in the cookies of the client, so there is
+// no round-trip to a database for every request. This suits low-traffic
+// sites, such as a Lambda function, that keep little in the session.
+//
+// The session is encrypted with an AEAD cipher, such as AESGCM, bound to its
+// ID, then split over as many cookies as it needs, named DataKey followed by
+// a number. The cipher authenticates what it encrypts, so a client cannot
+// tamper with its own session. Browsers limit a cookie to about 4 KB, and
+// servers limit the size of request headers, so keep the session small.
+//
+//	NOTE: A CookieStorage holds the session of one request, so each Manager
+//	made with it uses its own copy, see RequestStorage.
+//
+//	NOTE: The cookies are sent when the Manager sets the ID cookie, so they
+//	are lost when the response is written before the session is saved. An
+//	awslambda.Output keeps headers set after the body, but for a net/http
+//	server, call Save and RefreshCookie before writing the response.
+type CookieStorage struct {
+	// ChunkSize The most bytes of the session to put in each cookie.
+	ChunkSize int
+	// MaxCookies The most cookies to split the session over, Save fails for
+	// a session that needs more.
+	MaxCookies int
+	// Name The cookie names are this followed by a number.
+	Name   string
+	cipher AEAD
+	// dirty Set when the session was saved or removed since the cookies were
+	// read.
+	dirty bool
+	// key Of the session in the cookies.
+	key   string
+	mutex sync.Mutex
+	// read The number of cookies read from the request.
+	read int
+	// value The encrypted and encoded session, empty when removed.
+	value string
+}
+
+const (
+	DataKey = "_sd_" // DataKey Prefix of the cookies of a CookieStorage.
+)
+
+var (
+	_ CookieCarrier  = (*CookieStorage)(nil)
+	_ NativeExpirer  = (*CookieStorage)(nil)
+	_ RequestStorage = (*CookieStorage)(nil)
+	_ Storage        = (*CookieStorage)(nil)
+)
+
+// NewCookieStorage Initialize storage that keeps sessions in cookies,
+// encrypted and authenticated with the cipher.
+func NewCookieStorage(cipher AEAD) *CookieStorage {
+	return &CookieStorage{
+		ChunkSize:  3800,
+		MaxCookies: 5,
+		Name:       DataKey,
+		cipher:     cipher,
+	}
+}
+
+// Cookies To send to the client when the session was saved or removed since
+// the cookies were read. Cookies left over from a larger session are
+// expired.
+func (cs *CookieStorage) Cookies(expires time.Time) []*http.Cookie {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	if !cs.dirty {
+		return nil
+	}
+
+	chunks := cs.chunks(cs.value)
+	cookies := make([]*http.Cookie, 0, max(len(chunks), cs.read))
+
+	for i, chunk := range chunks {
+		cookies = append(cookies, cs.cookie(i, chunk, expires))
+	}
+
+	for i := len(chunks); i < cs.read; i++ {
+		c := cs.cookie(i, "", time.Unix(0, 0))
+		c.MaxAge = -1
+		cookies = append(cookies, c)
+	}
+
+	cs.dirty = false
+	cs.read = len(chunks)
+
+	return cookies
+}
+
+// ForRequest A CookieStorage with the same settings and no session.
+func (cs *CookieStorage) ForRequest() Storage {
+	return &CookieStorage{
+		ChunkSize:  cs.ChunkSize,
+		MaxCookies: cs.MaxCookies,
+		Name:       cs.Name,
+		cipher:     cs.cipher,
+	}
+}
+
+// Load Decrypt the session from the cookies. It fails when the cookies were
+// changed, or hold a session saved under another ID.
+func (cs *CookieStorage) Load(id string) ([]byte, error) {
+	cs.mutex.Lock()
+	value := cs.value
+	cs.mutex.Unlock()
+
+	if value == "" {
+		return nil, fmt.Errorf(stderr.NoDataCookie, id, fs.ErrNotExist)
+	}
+
+	encrypted, e1 := base64.RawURLEncoding.DecodeString(value)
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.DecodeCookie, e1.Error())
+	}
+
+	data, e2 := cs.cipher.DecryptWith(encrypted, []byte(id))
+	if e2 != nil {
+		return nil, fmt.Errorf(stderr.DecryptSession, id, e2.Error())
+	}
+
+	cs.mutex.Lock()
+	cs.key = id
+	cs.mutex.Unlock()
+
+	return data, nil
+}
+
+// NativeExpiry Sessions are not kept on the server, and the cookies expire
+// with the session, so there is nothing for a Reaper to do.
+func (cs *CookieStorage) NativeExpiry() bool {
+	return true
+}
+
+// ReadCookies Take the session from the cookies of a request, replacing any
+// session taken from an earlier request.
+func (cs *CookieStorage) ReadCookies(r *http.Request) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	sb := strings.Builder{}
+	n := 0
+	for ; ; n++ {
+		c, e := r.Cookie(cs.Name + strconv.Itoa(n))
+		if e != nil {
+			break
+		}
+		sb.WriteString(c.Value)
+	}
+
+	cs.dirty = false
+	cs.key = ""
+	cs.read = n
+	cs.value = sb.String()
+}
+
+// Remove The session from the cookies. This is no-op when the cookies hold
+// another session, such as after Manager.Regenerate saved the session under
+// a new ID.
+func (cs *CookieStorage) Remove(key string) error {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	if cs.key != "" && cs.key != key {
+		return nil
+	}
+
+	cs.dirty = true
+	cs.key = key
+	cs.value = ""
+
+	return nil
+}
+
+// Save Encrypt the session, it is sent to the client with the next call to
+// Cookies.
+func (cs *CookieStorage) Save(id string, data []byte) error {
+	encrypted, e1 := cs.cipher.EncryptWith(data, []byte(id))
+	if e1 != nil {
+		return fmt.Errorf(stderr.EncryptSession, id, e1.Error())
+	}
+
+	value := base64.RawURLEncoding.EncodeToString(encrypted)
+
+	if n := len(cs.chunks(value)); n > cs.MaxCookies {
+		return fmt.Errorf(stderr.CookieTooLarge, id, n, cs.MaxCookies)
+	}
+
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	cs.dirty = true
+	cs.key = id
+	cs.value = value
+
+	return nil
+}
+
+// chunks Split the value to fit in cookies.
+func (cs *CookieStorage) chunks(value string) []string {
+	var chunks []string
+	for len(value) > cs.ChunkSize {
+		chunks = append(chunks, value[:cs.ChunkSize])
+		value = value[cs.ChunkSize:]
+	}
+
+	if value != "" {
+		chunks = append(chunks, value)
+	}
+
+	return chunks
+}
+
+// cookie Make the nth data cookie, with the same attributes as the ID cookie.
+func (cs *CookieStorage) cookie(n int, value string, expires time.Time) *http.Cookie {
+	c := &http.Cookie{
+		Expires:  expires,
+		Name:     cs.Name + strconv.Itoa(n),
+		Path:     IDCookiePath,
+		Secure:   true,
+		HttpOnly: true,
+		Value:    value,
+		SameSite: http.SameSiteStrictMode,
+	}
+
+	if IDCookieDomain != "" {
+		c.Domain = IDCookieDomain
+	}
+
+	return c
+}
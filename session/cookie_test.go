@@ -0,0 +1,215 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestCookieStorage(t *testing.T) *CookieStorage {
+	t.Helper()
+
+	c, e1 := NewAESGCM("k1", map[string][]byte{"k1": testKey(1, 32)})
+	if e1 != nil {
+		t.Fatal(e1)
+	}
+
+	return NewCookieStorage(c)
+}
+
+// requestWith Make a request with the cookies a response set.
+func requestWith(cookies []*http.Cookie) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range cookies {
+		if c.MaxAge >= 0 {
+			r.AddCookie(c)
+		}
+	}
+
+	return r
+}
+
+func TestCookieStorage_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		chunkSize int
+		value     string
+		wantMany  bool
+	}{
+		{"one-cookie", 3800, "small", false},
+		{"many-cookies", 400, strings.Repeat("x", 250), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := newTestCookieStorage(t)
+			cs.ChunkSize = tt.chunkSize
+			sm := NewManager(cs, "sessions", time.Minute)
+			sm.Set("k", []byte(tt.value))
+
+			if e := sm.Save(); e != nil {
+				t.Fatalf("Save() error = %v", e)
+			}
+
+			w := httptest.NewRecorder()
+			sm.SetCookie(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			cookies := w.Result().Cookies()
+
+			gotData := 0
+			for _, c := range cookies {
+				if strings.HasPrefix(c.Name, DataKey) {
+					gotData++
+				}
+			}
+			if gotData < 1 || (gotData > 1) != tt.wantMany {
+				t.Errorf("SetCookie() sent %v data cookies, want many %v", gotData, tt.wantMany)
+			}
+
+			sm2 := NewManager(newTestCookieStorage(t), "sessions", time.Minute)
+			sm2.storage.(*CookieStorage).ChunkSize = tt.chunkSize
+			if e := sm2.LoadFromCookie(requestWith(cookies)); e != nil {
+				t.Fatalf("LoadFromCookie() error = %v", e)
+			}
+
+			if got := string(sm2.Get("k")); got != tt.value {
+				t.Errorf("Get() = %v, want %v", got, tt.value)
+			}
+		})
+	}
+}
+
+func TestCookieStorage_Cookies(t *testing.T) {
+	cs := newTestCookieStorage(t)
+	cs.ChunkSize = 10
+	cs.MaxCookies = 100
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for i := 0; i < 3; i++ {
+		r.AddCookie(&http.Cookie{Name: DataKey + string(rune('0'+i)), Value: "v"})
+	}
+	cs.ReadCookies(r)
+
+	if got := cs.Cookies(time.Now()); got != nil {
+		t.Errorf("Cookies() = %v, want nil before a save", got)
+	}
+
+	if e := cs.Remove("sessions/1.json"); e != nil {
+		t.Fatal(e)
+	}
+
+	got := cs.Cookies(time.Now())
+	if len(got) != 3 {
+		t.Fatalf("Cookies() returned %v cookies, want 3", len(got))
+	}
+
+	for _, c := range got {
+		if c.MaxAge != -1 {
+			t.Errorf("Cookies() %v MaxAge = %v, want -1", c.Name, c.MaxAge)
+		}
+	}
+}
+
+func TestCookieStorage_Save(t *testing.T) {
+	cs := newTestCookieStorage(t)
+	cs.ChunkSize = 10
+	cs.MaxCookies = 2
+
+	if e := cs.Save("sessions/1.json", []byte(strings.Repeat("x", 100))); e == nil {
+		t.Errorf("Save() of a session that needs too many cookies did not fail")
+	}
+}
+
+func TestCookieStorage_Tampered(t *testing.T) {
+	cs := newTestCookieStorage(t)
+	sm := NewManager(cs, "sessions", time.Minute)
+	sm.Set("admin", []byte("no"))
+	_ = sm.Save()
+
+	w := httptest.NewRecorder()
+	sm.SetCookie(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookies := w.Result().Cookies()
+
+	for _, c := range cookies {
+		if c.Name == DataKey+"0" {
+			b := []byte(c.Value)
+			b[len(b)-2] ^= 1
+			c.Value = string(b)
+		}
+	}
+
+	sm2 := NewManager(newTestCookieStorage(t), "sessions", time.Minute)
+	if e := sm2.LoadFromCookie(requestWith(cookies)); e == nil {
+		t.Errorf("LoadFromCookie() with a tampered session cookie did not fail")
+	}
+}
+
+func TestCookieStorage_OtherID(t *testing.T) {
+	cs := newTestCookieStorage(t)
+	if e := cs.Save("sessions/1.json", []byte(`{"admin":"yes"}`)); e != nil {
+		t.Fatalf("Save() error = %v", e)
+	}
+
+	// The cookies of one session sent with the ID of another.
+	if _, e := cs.Load("sessions/2.json"); e == nil {
+		t.Errorf("Load() of a session saved under another ID did not fail")
+	}
+
+	if _, e := cs.Load("sessions/1.json"); e != nil {
+		t.Errorf("Load() error = %v", e)
+	}
+}
+
+func TestCookieStorage_Parallel(t *testing.T) {
+	store := NewSessionStore(newTestCookieStorage(t), "sessions", time.Minute)
+
+	// Two requests that overlap, each must send its own session.
+	sm1, sm2 := store.New(), store.New()
+	sm1.Set("k", []byte("1"))
+	sm2.Set("k", []byte("2"))
+	_ = sm1.Save()
+	_ = sm2.Save()
+
+	w := httptest.NewRecorder()
+	sm1.SetCookie(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	sm3 := store.New()
+	if e := sm3.LoadFromCookie(requestWith(w.Result().Cookies())); e != nil {
+		t.Fatalf("LoadFromCookie() error = %v", e)
+	}
+
+	if got := string(sm3.Get("k")); got != "1" {
+		t.Fatalf("Get() = %v, want 1", got)
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(want string) {
+			defer wg.Done()
+
+			sm := store.New()
+			sm.Set("k", []byte(want))
+			if e := sm.Save(); e != nil {
+				t.Errorf("Save() error = %v", e)
+				return
+			}
+
+			w := httptest.NewRecorder()
+			sm.SetCookie(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			sm2 := store.New()
+			if e := sm2.LoadFromCookie(requestWith(w.Result().Cookies())); e != nil {
+				t.Errorf("LoadFromCookie() error = %v", e)
+				return
+			}
+
+			if got := string(sm2.Get("k")); got != want {
+				t.Errorf("Get() = %v, want %v", got, want)
+			}
+		}(strconv.Itoa(i))
+	}
+	wg.Wait()
+}
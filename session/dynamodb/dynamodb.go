@@ -1,84 +1,371 @@
+// Package dynamo keeps sessions in a DynamoDB table. Writes are conditional
+// on the version of the session that was loaded, so a save from one server
+// never silently overwrites a save from another.
+//
+//	The table needs a string partition key named "ID". Enable TTL on the
+//	"TTL" attribute to have DynamoDB delete expired sessions, see EnableTTL.
 package dynamo
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"github.com/kohirens/stdlib/logger"
-	"log"
+	"io/fs"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/kohirens/stdlib/logger"
+	"github.com/kohirens/www/session"
 )
 
+// API The subset of the DynamoDB client used by the store.
+type API interface {
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	DescribeTimeToLive(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error)
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateTimeToLive(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error)
+}
+
+// Config Options for connecting to DynamoDB, leave a field empty to use the
+// AWS SDK default, which is read from the environment.
+type Config struct {
+	// Endpoint A custom endpoint, such as "http://localhost:8000" for
+	// DynamoDB local.
+	Endpoint string
+	Region   string
+}
+
+// StorageTable Keeps sessions in a DynamoDB table. A session.Manager is made
+// with a copy of it, see ForRequest, so that each request saves on the
+// condition of the version it loaded.
 type StorageTable struct {
 	Context context.Context
+	mutex   sync.Mutex
 	name    string
-	svc     *dynamodb.Client
+	// pruneAt The number of versions at which expired ones are dropped.
+	pruneAt int
+	svc     API
+	// table What is known of the table, shared with the copies.
+	table *tableState
+	// versions The version of each session when it was last loaded or saved,
+	// which a save is conditional on.
+	versions map[string]version
 }
 
+// tableState What is known of a table.
+type tableState struct {
+	mutex sync.Mutex
+	// ttl Whether TTL is enabled on the table, nil until it is known.
+	ttl *bool
+}
+
+// version Of a session, kept until the session expires.
+type version struct {
+	expires time.Time
+	n       int64
+}
+
+const (
+	// minPrune The fewest versions kept before expired ones are dropped.
+	minPrune = 1024
+
+	attrData    = "Data"
+	attrID      = "ID"
+	attrTTL     = "TTL"
+	attrVersion = "Version"
+)
+
 var Log = logger.Standard{}
 
+// versionNow Allows tests to control the clock.
+var versionNow = time.Now
+
+var (
+	_ session.ExpirySaver    = (*StorageTable)(nil)
+	_ session.NativeExpirer  = (*StorageTable)(nil)
+	_ session.RequestStorage = (*StorageTable)(nil)
+	_ session.Storage        = (*StorageTable)(nil)
+)
+
+// NewStorageTable Initialize a client to use a DynamoDB table as session
+// storage. Credentials are expected to be configured in the environment to be
+// picked up by the AWS SDK.
+func NewStorageTable(ctx context.Context, table string, cfg *Config) (*StorageTable, error) {
+	var opts []func(*config.LoadOptions) error
+	if cfg != nil && cfg.Region != "" {
+		opts = append(opts, config.WithRegion(cfg.Region))
+	}
+
+	awsCfg, e1 := config.LoadDefaultConfig(ctx, opts...)
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.AwsConfig, e1.Error())
+	}
+
+	svc := dynamodb.NewFromConfig(awsCfg, func(o *dynamodb.Options) {
+		if cfg != nil && cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+	})
+
+	return NewStorageTableWithClient(ctx, svc, table), nil
+}
+
+// NewStorageTableWithClient Initialize session storage on a DynamoDB table
+// with a client you configured.
+func NewStorageTableWithClient(ctx context.Context, svc API, table string) *StorageTable {
+	return &StorageTable{
+		Context:  ctx,
+		name:     table,
+		pruneAt:  minPrune,
+		svc:      svc,
+		table:    &tableState{},
+		versions: make(map[string]version),
+	}
+}
+
+// NewStorageClient Initializes a DynamoDB client to use as session storage.
+// Credentials are expected to be configured in the environment to be picked up
+// by the AWS SDK. Panics on failure.
+//
+// Deprecated: This is hardcoded to the us-east-1 region, use NewStorageTable.
+func NewStorageClient(table string) *StorageTable {
+	st, e1 := NewStorageTable(context.Background(), table, &Config{Region: "us-east-1"})
+	if e1 != nil {
+		panic(e1.Error())
+	}
+
+	return st
+}
+
+// EnableTTL Turn on TTL for the table, so that DynamoDB deletes sessions some
+// time after they expire. This only needs to be done once for a table.
+func (c *StorageTable) EnableTTL() error {
+	_, e1 := c.svc.UpdateTimeToLive(c.context(), &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(c.name),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String(attrTTL),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	if e1 != nil {
+		return fmt.Errorf(stderr.UpdateTTL, c.name, e1.Error())
+	}
+
+	c.table.mutex.Lock()
+	c.table.ttl = aws.Bool(true)
+	c.table.mutex.Unlock()
+
+	return nil
+}
+
+// ForRequest A StorageTable on the same table with versions of its own, so
+// that two requests of this server that load the same session cannot both
+// save it, as they could if they shared the version the first one saved.
+func (c *StorageTable) ForRequest() session.Storage {
+	return &StorageTable{
+		Context:  c.Context,
+		name:     c.name,
+		pruneAt:  minPrune,
+		svc:      c.svc,
+		table:    c.table,
+		versions: make(map[string]version),
+	}
+}
+
 // Load Session data from a DynamoDB table, the ID serves as the ID key for the
 // table.
 func (c *StorageTable) Load(id string) ([]byte, error) {
-	// Load data from the DynamoDB table
-	input := &dynamodb.GetItemInput{
-		TableName: aws.String(c.name),
+	result, e1 := c.svc.GetItem(c.context(), &dynamodb.GetItemInput{
+		TableName:      aws.String(c.name),
+		ConsistentRead: aws.Bool(true),
 		Key: map[string]types.AttributeValue{
-			"ID": &types.AttributeValueMemberS{Value: id},
+			attrID: &types.AttributeValueMemberS{Value: id},
 		},
+	})
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.GetItem, id, c.name, e1.Error())
 	}
 
-	result, err := c.svc.GetItem(context.Background(), input)
-	if err != nil {
-		return nil, err
+	if result.Item == nil {
+		return nil, fmt.Errorf(stderr.NoItem, id, c.name, fs.ErrNotExist)
 	}
 
-	if result.Item == nil {
-		return nil, fmt.Errorf("no item found with ID %s", id)
+	var data []byte
+	switch v := result.Item[attrData].(type) {
+	case *types.AttributeValueMemberB:
+		data = v.Value
+	case *types.AttributeValueMemberS:
+		data = []byte(v.Value)
+	default:
+		return nil, fmt.Errorf(stderr.AttributeType, attrData, id)
+	}
+
+	loaded := version{}
+	if v, ok := result.Item[attrVersion].(*types.AttributeValueMemberN); ok {
+		n, e2 := strconv.ParseInt(v.Value, 10, 64)
+		if e2 != nil {
+			return nil, fmt.Errorf(stderr.AttributeType, attrVersion, id)
+		}
+		loaded.n = n
+	}
+
+	if v, ok := result.Item[attrTTL].(*types.AttributeValueMemberN); ok {
+		if n, e := strconv.ParseInt(v.Value, 10, 64); e == nil {
+			loaded.expires = time.Unix(n, 0)
+		}
 	}
 
-	itemData := result.Item["Data"].(*types.AttributeValueMemberN).Value
+	c.setVersion(id, loaded)
 
-	return []byte(itemData), nil
+	return data, nil
 }
 
-// Save Session data to a DynamoDB table.
-func (c *StorageTable) Save(name string, data []byte) error {
-	// Save data to the DynamoDB table
-	input := &dynamodb.PutItemInput{
+// NativeExpiry Report whether TTL is enabled on the TTL attribute of the
+// table, in which case DynamoDB deletes expired sessions, so there is no
+// need for a session.Reaper. The table is only asked until it answers.
+func (c *StorageTable) NativeExpiry() bool {
+	c.table.mutex.Lock()
+	known := c.table.ttl
+	c.table.mutex.Unlock()
+
+	if known != nil {
+		return *known
+	}
+
+	out, e1 := c.svc.DescribeTimeToLive(c.context(), &dynamodb.DescribeTimeToLiveInput{
 		TableName: aws.String(c.name),
-		Item: map[string]types.AttributeValue{
-			"ID":   &types.AttributeValueMemberS{Value: name},
-			"Data": &types.AttributeValueMemberN{Value: string(data)},
+	})
+	if e1 != nil {
+		Log.Errf(stderr.DescribeTTL, c.name, e1.Error())
+		return false
+	}
+
+	d := out.TimeToLiveDescription
+	enabled := d != nil && aws.ToString(d.AttributeName) == attrTTL &&
+		(d.TimeToLiveStatus == types.TimeToLiveStatusEnabled || d.TimeToLiveStatus == types.TimeToLiveStatusEnabling)
+
+	c.table.mutex.Lock()
+	c.table.ttl = &enabled
+	c.table.mutex.Unlock()
+
+	return enabled
+}
+
+// Remove A session from the DynamoDB table.
+func (c *StorageTable) Remove(key string) error {
+	_, e1 := c.svc.DeleteItem(c.context(), &dynamodb.DeleteItemInput{
+		TableName: aws.String(c.name),
+		Key: map[string]types.AttributeValue{
+			attrID: &types.AttributeValueMemberS{Value: key},
 		},
+	})
+	if e1 != nil {
+		return fmt.Errorf(stderr.DeleteItem, key, c.name, e1.Error())
+	}
+
+	c.mutex.Lock()
+	delete(c.versions, key)
+	c.mutex.Unlock()
+
+	return nil
+}
+
+// Save Session data to a DynamoDB table, with the TTL attribute set from the
+// expiration of the session, see SaveUntil. It fails when the expiration
+// cannot be read from the data.
+func (c *StorageTable) Save(name string, data []byte) error {
+	exp, e1 := session.ExpirationOf(data)
+	if e1 != nil {
+		return fmt.Errorf(stderr.NoExpiration, name)
+	}
+
+	return c.SaveUntil(name, data, exp)
+}
+
+// SaveUntil Session data to a DynamoDB table with the TTL attribute set to
+// the expiration, on the condition that no other server has saved it since
+// it was loaded. Otherwise, a session.ConflictError is returned.
+func (c *StorageTable) SaveUntil(name string, data []byte, expiration time.Time) error {
+	if expiration.IsZero() {
+		return fmt.Errorf(stderr.NoExpiration, name)
+	}
+
+	c.mutex.Lock()
+	loaded, known := c.versions[name]
+	c.mutex.Unlock()
+
+	item := map[string]types.AttributeValue{
+		attrID:      &types.AttributeValueMemberS{Value: name},
+		attrData:    &types.AttributeValueMemberB{Value: data},
+		attrTTL:     &types.AttributeValueMemberN{Value: strconv.FormatInt(expiration.Unix(), 10)},
+		attrVersion: &types.AttributeValueMemberN{Value: strconv.FormatInt(loaded.n+1, 10)},
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName:                aws.String(c.name),
+		Item:                     item,
+		ConditionExpression:      aws.String("attribute_not_exists(#id)"),
+		ExpressionAttributeNames: map[string]string{"#id": attrID},
+	}
+
+	if known {
+		input.ConditionExpression = aws.String("#v = :v")
+		input.ExpressionAttributeNames = map[string]string{"#v": attrVersion}
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":v": &types.AttributeValueMemberN{Value: strconv.FormatInt(loaded.n, 10)},
+		}
+	}
+
+	_, e2 := c.svc.PutItem(c.context(), input)
+
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(e2, &ccf) {
+		return &session.ConflictError{ID: name}
 	}
 
-	// Verify the results.
-	_, e2 := c.svc.PutItem(context.Background(), input)
 	if e2 != nil {
-		return fmt.Errorf(stderr.PutItem, c.name, e2.Error())
+		return fmt.Errorf(stderr.PutItem, name, c.name, e2.Error())
 	}
 
+	c.setVersion(name, version{expires: expiration, n: loaded.n + 1})
+
 	return nil
 }
 
-// NewStorageClient Initializes an S3 client to use as session storage.
-// Credentials are expected to be configured in the environment to be picked up
-// by the AWS SDK. Panics on failure.
-func NewStorageClient(table string) *StorageTable {
-	// Load the AWS configuration
-	cfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion("us-east-1"))
-	if err != nil {
-		log.Fatal(err)
+// context The Context field, or a background context when it is not set.
+func (c *StorageTable) context() context.Context {
+	if c.Context == nil {
+		return context.Background()
 	}
 
-	svc := dynamodb.NewFromConfig(cfg)
-	// Create a DynamoDB client
-	return &StorageTable{
-		name: table,
-		svc:  svc,
+	return c.Context
+}
+
+// setVersion Remember the version of a session. Versions of sessions that
+// expired are dropped each time the number of versions doubles, so that
+// sessions that are never removed do not grow the map forever.
+func (c *StorageTable) setVersion(id string, v version) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.versions[id] = v
+
+	if len(c.versions) < c.pruneAt {
+		return
 	}
+
+	now := versionNow()
+	for k, kept := range c.versions {
+		if !kept.expires.IsZero() && now.After(kept.expires) {
+			delete(c.versions, k)
+		}
+	}
+
+	c.pruneAt = max(2*len(c.versions), minPrune)
 }
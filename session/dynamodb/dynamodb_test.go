@@ -0,0 +1,281 @@
+package dynamo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/kohirens/www/session"
+	"github.com/kohirens/www/session/sessiontest"
+)
+
+// MockAPI A table in memory that honors the conditions of PutItem.
+type MockAPI struct {
+	items map[string]map[string]types.AttributeValue
+	ttl   *types.TimeToLiveSpecification
+}
+
+func (m *MockAPI) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	delete(m.items, params.Key[attrID].(*types.AttributeValueMemberS).Value)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func (m *MockAPI) DescribeTimeToLive(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error) {
+	d := &types.TimeToLiveDescription{TimeToLiveStatus: types.TimeToLiveStatusDisabled}
+	if m.ttl != nil && *m.ttl.Enabled {
+		d.AttributeName = m.ttl.AttributeName
+		d.TimeToLiveStatus = types.TimeToLiveStatusEnabled
+	}
+	return &dynamodb.DescribeTimeToLiveOutput{TimeToLiveDescription: d}, nil
+}
+
+func (m *MockAPI) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{
+		Item: m.items[params.Key[attrID].(*types.AttributeValueMemberS).Value],
+	}, nil
+}
+
+func (m *MockAPI) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	id := params.Item[attrID].(*types.AttributeValueMemberS).Value
+	stored, exists := m.items[id]
+
+	if want, ok := params.ExpressionAttributeValues[":v"]; ok {
+		if !exists || stored[attrVersion].(*types.AttributeValueMemberN).Value != want.(*types.AttributeValueMemberN).Value {
+			return nil, &types.ConditionalCheckFailedException{}
+		}
+	} else if exists {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+
+	m.items[id] = params.Item
+
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (m *MockAPI) UpdateTimeToLive(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error) {
+	m.ttl = params.TimeToLiveSpecification
+	return &dynamodb.UpdateTimeToLiveOutput{}, nil
+}
+
+func newMockAPI() *MockAPI {
+	return &MockAPI{items: make(map[string]map[string]types.AttributeValue)}
+}
+
+func TestNewStorageTable(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+
+	st, e1 := NewStorageTable(context.Background(), "sessions", &Config{
+		Endpoint: "http://localhost:8000",
+		Region:   "eu-west-1",
+	})
+	if e1 != nil {
+		t.Fatalf("NewStorageTable() error = %v", e1)
+	}
+
+	opts := st.svc.(*dynamodb.Client).Options()
+	if opts.Region != "eu-west-1" {
+		t.Errorf("NewStorageTable() region = %v, want eu-west-1", opts.Region)
+	}
+	if opts.BaseEndpoint == nil || *opts.BaseEndpoint != "http://localhost:8000" {
+		t.Errorf("NewStorageTable() endpoint = %v, want http://localhost:8000", opts.BaseEndpoint)
+	}
+}
+
+func TestStorageTable(t *testing.T) {
+	api := newMockAPI()
+	sm := session.NewManager(NewStorageTableWithClient(context.Background(), api, "sessions"), "", time.Hour)
+	sm.Set("k", []byte("v"))
+
+	if e := sm.Save(); e != nil {
+		t.Fatalf("Save() error = %v", e)
+	}
+
+	key := sm.ID().String() + session.Suffix
+	item := api.items[key]
+
+	if _, ok := item[attrData].(*types.AttributeValueMemberB); !ok {
+		t.Errorf("Save() stored %T, want binary data", item[attrData])
+	}
+
+	ttl, ok := item[attrTTL].(*types.AttributeValueMemberN)
+	if !ok || ttl.Value == "" {
+		t.Errorf("Save() did not set the TTL attribute")
+	}
+
+	sm2 := session.NewManager(NewStorageTableWithClient(context.Background(), api, "sessions"), "", time.Hour)
+	if e := sm2.Restore(sm.ID().String()); e != nil {
+		t.Fatalf("Restore() error = %v", e)
+	}
+
+	if got := string(sm2.Get("k")); got != "v" {
+		t.Errorf("Get() = %v, want v", got)
+	}
+
+	st := NewStorageTableWithClient(context.Background(), api, "sessions")
+	if e := st.Remove(key); e != nil {
+		t.Fatalf("Remove() error = %v", e)
+	}
+
+	if _, e := st.Load(key); e == nil {
+		t.Errorf("Load() after Remove() did not fail")
+	}
+}
+
+func TestStorageTable_Conflict(t *testing.T) {
+	api := newMockAPI()
+	data, _ := json.Marshal(&session.Data{Expiration: time.Now().Add(time.Hour)})
+
+	first := NewStorageTableWithClient(context.Background(), api, "sessions")
+	second := NewStorageTableWithClient(context.Background(), api, "sessions")
+
+	if e := first.Save("s1.json", data); e != nil {
+		t.Fatalf("Save() error = %v", e)
+	}
+
+	if _, e := second.Load("s1.json"); e != nil {
+		t.Fatalf("Load() error = %v", e)
+	}
+
+	if e := first.Save("s1.json", data); e != nil {
+		t.Fatalf("Save() error = %v", e)
+	}
+
+	var ce *session.ConflictError
+	if e := second.Save("s1.json", data); !errors.As(e, &ce) {
+		t.Fatalf("Save() error = %v, want a ConflictError", e)
+	}
+
+	// A new session cannot overwrite one that exists.
+	third := NewStorageTableWithClient(context.Background(), api, "sessions")
+	if e := third.Save("s1.json", data); !errors.As(e, &ce) {
+		t.Fatalf("Save() error = %v, want a ConflictError", e)
+	}
+}
+
+func TestStorageTable_Managers(t *testing.T) {
+	c, _ := session.NewAESGCM("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+
+	tests := []struct {
+		name  string
+		store func(st *StorageTable) session.Storage
+	}{
+		{"table", func(st *StorageTable) session.Storage { return st }},
+		{"encrypted", func(st *StorageTable) session.Storage { return session.NewEncryptedStorage(st, c) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// One table, shared by the requests of a server.
+			store := tt.store(NewStorageTableWithClient(context.Background(), newMockAPI(), "sessions"))
+
+			sm := session.NewManager(store, "", time.Hour)
+			sm.Set("k", []byte("v"))
+			if e := sm.Save(); e != nil {
+				t.Fatalf("Save() error = %v", e)
+			}
+
+			first := session.NewManager(store, "", time.Hour)
+			second := session.NewManager(store, "", time.Hour)
+			for _, m := range []*session.Manager{first, second} {
+				if e := m.Restore(sm.ID().String()); e != nil {
+					t.Fatalf("Restore() error = %v", e)
+				}
+			}
+
+			first.Set("k", []byte("first"))
+			if e := first.Save(); e != nil {
+				t.Fatalf("Save() error = %v", e)
+			}
+
+			second.Set("k", []byte("second"))
+			var ce *session.ConflictError
+			if e := second.Save(); !errors.As(e, &ce) {
+				t.Errorf("Save() of a session saved since it was loaded error = %v, want a ConflictError", e)
+			}
+		})
+	}
+}
+
+func TestStorageTable_EncryptedPayload(t *testing.T) {
+	api := newMockAPI()
+	st := NewStorageTableWithClient(context.Background(), api, "sessions")
+
+	if e := st.Save("s1.json", []byte{1, 2, 3}); e == nil {
+		t.Errorf("Save() of a payload it cannot read did not fail")
+	}
+
+	c, _ := session.NewAESGCM("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+	sm := session.NewManager(session.NewEncryptedStorage(st, c), "", time.Hour)
+	sm.Set("k", []byte("v"))
+	if e := sm.Save(); e != nil {
+		t.Fatalf("Save() error = %v", e)
+	}
+
+	ttl, ok := api.items[sm.ID().String()+session.Suffix][attrTTL].(*types.AttributeValueMemberN)
+	if want := strconv.FormatInt(sm.Expiration().Unix(), 10); !ok || ttl.Value != want {
+		t.Errorf("Save() TTL = %v, want %v", ttl, want)
+	}
+}
+
+func TestStorageTable_Versions(t *testing.T) {
+	now := time.Now()
+	versionNow = func() time.Time { return now }
+	t.Cleanup(func() { versionNow = time.Now })
+
+	st := NewStorageTableWithClient(context.Background(), newMockAPI(), "sessions")
+	st.pruneAt = 3
+
+	_ = st.SaveUntil("expired.json", []byte("{}"), now.Add(time.Minute))
+	_ = st.SaveUntil("active.json", []byte("{}"), now.Add(time.Hour))
+
+	now = now.Add(2 * time.Minute)
+	_ = st.SaveUntil("new.json", []byte("{}"), now.Add(time.Hour))
+
+	if _, ok := st.versions["expired.json"]; ok || len(st.versions) != 2 {
+		t.Errorf("SaveUntil() versions = %v, want the expired session dropped", st.versions)
+	}
+
+	_ = st.Remove("active.json")
+	if _, ok := st.versions["active.json"]; ok {
+		t.Errorf("Remove() kept the version of the session")
+	}
+}
+
+func TestStorageTable_EnableTTL(t *testing.T) {
+	api := newMockAPI()
+	st := NewStorageTableWithClient(context.Background(), api, "sessions")
+
+	if e := st.EnableTTL(); e != nil {
+		t.Fatalf("EnableTTL() error = %v", e)
+	}
+
+	if api.ttl == nil || *api.ttl.AttributeName != attrTTL || !*api.ttl.Enabled {
+		t.Errorf("EnableTTL() = %+v, want TTL enabled on %v", api.ttl, attrTTL)
+	}
+}
+
+func TestStorageTable_NativeExpiry(t *testing.T) {
+	api := newMockAPI()
+
+	if NewStorageTableWithClient(context.Background(), api, "sessions").NativeExpiry() {
+		t.Errorf("NativeExpiry() = true, want false when TTL is disabled")
+	}
+
+	_ = NewStorageTableWithClient(context.Background(), api, "sessions").EnableTTL()
+
+	if !NewStorageTableWithClient(context.Background(), api, "sessions").NativeExpiry() {
+		t.Errorf("NativeExpiry() = false, want true when TTL is enabled")
+	}
+}
+
+func TestStorageTable_Conformance(t *testing.T) {
+	sessiontest.Run(t, func(t *testing.T) session.Storage {
+		return NewStorageTableWithClient(context.Background(), newMockAPI(), "sessions")
+	})
+}
@@ -1,14 +1,23 @@
 package dynamo
 
-var stdout = struct {
-}{}
-
 var stderr = struct {
-	DecodeJSON,
-	EncodeJSON,
-	PutItem string
+	AttributeType,
+	AwsConfig,
+	DeleteItem,
+	DescribeTTL,
+	GetItem,
+	NoExpiration,
+	NoItem,
+	PutItem,
+	UpdateTTL string
 }{
-	DecodeJSON: "could not decode JSON: %v",
-	EncodeJSON: "could not encode JSON: %v",
-	PutItem:    "could not put item %v to dynamodb table %v: %v",
+	AttributeType: "attribute %v of item %v is not the expected type",
+	AwsConfig:     "could not load the AWS config: %v",
+	DeleteItem:    "could not delete item %v from dynamodb table %v: %v",
+	DescribeTTL:   "could not describe the TTL of dynamodb table %v: %v",
+	GetItem:       "could not get item %v from dynamodb table %v: %v",
+	NoExpiration:  "session %v has no expiration to set the TTL attribute from",
+	NoItem:        "no item found with ID %v in dynamodb table %v: %w",
+	PutItem:       "could not put item %v to dynamodb table %v: %v",
+	UpdateTTL:     "could not enable TTL on dynamodb table %v: %v",
 }
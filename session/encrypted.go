@@ -0,0 +1,156 @@
+package session
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// EncryptedStorage Wraps any Storage so that sessions are encrypted at rest.
+// Sessions hold sensitive data, such as OAuth tokens, so use this with every
+// storage medium that is not already encrypted by the application. Each
+// payload is bound to the ID it was saved under, as additional data of an
+// AEAD cipher or inside the message of any other Cipher, so a payload copied
+// to another session does not load.
+//
+//	store := session.NewEncryptedStorage(dynamoStore, aesgcm)
+//	sm := session.NewManager(store, "sessions", time.Hour)
+type EncryptedStorage struct {
+	cipher  Cipher
+	storage Storage
+}
+
+var (
+	_ ExpirySaver    = (*EncryptedStorage)(nil)
+	_ Lister         = (*EncryptedStorage)(nil)
+	_ NativeExpirer  = (*EncryptedStorage)(nil)
+	_ RequestStorage = (*EncryptedStorage)(nil)
+	_ Storage        = (*EncryptedStorage)(nil)
+)
+
+// NewEncryptedStorage Initialize storage that encrypts with cipher before
+// saving to storage, and decrypts after loading from it.
+func NewEncryptedStorage(storage Storage, cipher Cipher) *EncryptedStorage {
+	return &EncryptedStorage{
+		cipher:  cipher,
+		storage: storage,
+	}
+}
+
+// ForRequest An EncryptedStorage on a copy of the underlying storage, when
+// it is a RequestStorage. Otherwise, the same EncryptedStorage.
+func (es *EncryptedStorage) ForRequest() Storage {
+	rs, ok := es.storage.(RequestStorage)
+	if !ok {
+		return es
+	}
+
+	return &EncryptedStorage{cipher: es.cipher, storage: rs.ForRequest()}
+}
+
+// List The sessions in the underlying storage, when it can list them.
+func (es *EncryptedStorage) List(location string) ([]string, error) {
+	lister, ok := es.storage.(Lister)
+	if !ok {
+		return nil, fmt.Errorf("%v", stderr.NotListable)
+	}
+
+	return lister.List(location)
+}
+
+// Load Decrypt a session from storage.
+func (es *EncryptedStorage) Load(id string) ([]byte, error) {
+	encrypted, e1 := es.storage.Load(id)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	data, e2 := es.decrypt(id, encrypted)
+	if e2 != nil {
+		return nil, fmt.Errorf(stderr.DecryptSession, id, e2.Error())
+	}
+
+	return data, nil
+}
+
+// NativeExpiry Report whether the underlying storage expires sessions
+// itself, which it can only do when it is told the expiration with
+// SaveUntil.
+func (es *EncryptedStorage) NativeExpiry() bool {
+	ne, ok := es.storage.(NativeExpirer)
+	_, saver := es.storage.(ExpirySaver)
+	return ok && saver && ne.NativeExpiry()
+}
+
+// Remove A session from storage.
+func (es *EncryptedStorage) Remove(key string) error {
+	return es.storage.Remove(key)
+}
+
+// Save Encrypt a session to storage. The expiration is read from the data
+// before it is encrypted, and passed on when the underlying storage is an
+// ExpirySaver.
+func (es *EncryptedStorage) Save(id string, data []byte) error {
+	if exp, e := ExpirationOf(data); e == nil {
+		return es.SaveUntil(id, data, exp)
+	}
+
+	encrypted, e1 := es.encrypt(id, data)
+	if e1 != nil {
+		return e1
+	}
+
+	return es.storage.Save(id, encrypted)
+}
+
+// SaveUntil Encrypt a session to storage, passing the expiration on when the
+// underlying storage is an ExpirySaver.
+func (es *EncryptedStorage) SaveUntil(id string, data []byte, expiration time.Time) error {
+	encrypted, e1 := es.encrypt(id, data)
+	if e1 != nil {
+		return e1
+	}
+
+	if saver, ok := es.storage.(ExpirySaver); ok {
+		return saver.SaveUntil(id, encrypted, expiration)
+	}
+
+	return es.storage.Save(id, encrypted)
+}
+
+// decrypt The data of the session saved under id.
+func (es *EncryptedStorage) decrypt(id string, encrypted []byte) ([]byte, error) {
+	if aead, ok := es.cipher.(AEAD); ok {
+		return aead.DecryptWith(encrypted, []byte(id))
+	}
+
+	message, e1 := es.cipher.Decrypt(encrypted)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	// Without additional data, the ID is the first line of the message.
+	prefix := []byte(id + "\n")
+	if !bytes.HasPrefix(message, prefix) {
+		return nil, fmt.Errorf("%v", stderr.CipherSession)
+	}
+
+	return message[len(prefix):], nil
+}
+
+// encrypt The data of the session saved under id.
+func (es *EncryptedStorage) encrypt(id string, data []byte) ([]byte, error) {
+	var encrypted []byte
+	var e1 error
+	if aead, ok := es.cipher.(AEAD); ok {
+		encrypted, e1 = aead.EncryptWith(data, []byte(id))
+	} else {
+		encrypted, e1 = es.cipher.Encrypt(append([]byte(id+"\n"), data...))
+	}
+
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.EncryptSession, id, e1.Error())
+	}
+
+	return encrypted, nil
+}
@@ -0,0 +1,256 @@
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kohirens/www/gpg"
+)
+
+func testKey(b byte, size int) []byte {
+	return bytes.Repeat([]byte{b}, size)
+}
+
+func TestNewAESGCM(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string
+		keys    map[string][]byte
+		wantErr bool
+	}{
+		{"aes-128", "k1", map[string][]byte{"k1": testKey(1, 16)}, false},
+		{"aes-256", "k1", map[string][]byte{"k1": testKey(1, 32)}, false},
+		{"bad-key-size", "k1", map[string][]byte{"k1": testKey(1, 10)}, true},
+		{"current-not-found", "k2", map[string][]byte{"k1": testKey(1, 32)}, true},
+		{"empty-key-id", "", map[string][]byte{"": testKey(1, 32)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAESGCM(tt.current, tt.keys)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewAESGCM() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAESGCM_Rotation(t *testing.T) {
+	old, _ := NewAESGCM("2024", map[string][]byte{"2024": testKey(1, 32)})
+	rotated, _ := NewAESGCM("2025", map[string][]byte{
+		"2024": testKey(1, 32),
+		"2025": testKey(2, 32),
+	})
+
+	message := []byte(`{"Id":"1234"}`)
+
+	fromOld, _ := old.Encrypt(message)
+	got, e1 := rotated.Decrypt(fromOld)
+	if e1 != nil {
+		t.Fatalf("Decrypt() with an old key error = %v", e1)
+	}
+	if !bytes.Equal(got, message) {
+		t.Errorf("Decrypt() = %s, want %s", got, message)
+	}
+
+	fromNew, _ := rotated.Encrypt(message)
+	if _, e := old.Decrypt(fromNew); e == nil {
+		t.Errorf("Decrypt() of a payload from an unknown key did not fail")
+	}
+}
+
+func TestAESGCM_Tampered(t *testing.T) {
+	c, _ := NewAESGCM("k1", map[string][]byte{
+		"k1": testKey(1, 32),
+		"k2": testKey(2, 32),
+	})
+
+	encrypted, _ := c.Encrypt([]byte("secret"))
+
+	tests := []struct {
+		name   string
+		tamper func(b []byte) []byte
+	}{
+		{"empty", func(b []byte) []byte { return nil }},
+		{"version", func(b []byte) []byte { b[0] = 9; return b }},
+		{"key-id", func(b []byte) []byte { b[3] = '2'; return b }},
+		{"ciphertext", func(b []byte) []byte { b[len(b)-1] ^= 0xff; return b }},
+		{"truncated", func(b []byte) []byte { return b[:6] }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := tt.tamper(bytes.Clone(encrypted))
+			if _, e := c.Decrypt(b); e == nil {
+				t.Errorf("Decrypt() of a tampered payload did not fail")
+			}
+		})
+	}
+}
+
+func TestEncryptedStorage(t *testing.T) {
+	aesgcm, _ := NewAESGCM("k1", map[string][]byte{"k1": testKey(1, 32)})
+	capsule, e1 := gpg.NewCapsule(
+		"../gpg/testdata/gpg-test.public.asc",
+		"../gpg/testdata/gpg-test.private.asc",
+		"test1234",
+	)
+	if e1 != nil {
+		t.Fatal(e1)
+	}
+
+	tests := []struct {
+		name   string
+		cipher Cipher
+	}{
+		{"aes-gcm", aesgcm},
+		{"gpg", capsule},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &MockListStorage{data: map[string][]byte{}}
+			sm := NewManager(NewEncryptedStorage(inner, tt.cipher), "sessions", time.Minute)
+			sm.Set("token", []byte("oauth-token"))
+
+			if e := sm.Save(); e != nil {
+				t.Fatalf("Save() error = %v", e)
+			}
+
+			key := "sessions/" + sm.ID().String() + Suffix
+			if json.Valid(inner.data[key]) {
+				t.Errorf("Save() stored the session in plain text")
+			}
+
+			sm2 := NewManager(NewEncryptedStorage(inner, tt.cipher), "sessions", time.Minute)
+			if e := sm2.Restore(sm.ID().String()); e != nil {
+				t.Fatalf("Restore() error = %v", e)
+			}
+
+			if got := sm2.Get("token"); string(got) != "oauth-token" {
+				t.Errorf("Get() = %s, want oauth-token", got)
+			}
+		})
+	}
+}
+
+// ttlStorage A storage that expires sessions itself, like a DynamoDB table
+// with TTL enabled.
+type ttlStorage struct {
+	MockListStorage
+	expires map[string]time.Time
+	now     time.Time
+}
+
+func (ts *ttlStorage) Load(id string) ([]byte, error) {
+	if exp, ok := ts.expires[id]; !ok || !ts.now.Before(exp) {
+		return nil, errors.New("not found")
+	}
+	return ts.MockListStorage.Load(id)
+}
+
+func (ts *ttlStorage) NativeExpiry() bool {
+	return true
+}
+
+func (ts *ttlStorage) SaveUntil(id string, data []byte, expiration time.Time) error {
+	ts.expires[id] = expiration
+	return ts.MockListStorage.Save(id, data)
+}
+
+func TestEncryptedStorage_NativeExpiry(t *testing.T) {
+	aesgcm, _ := NewAESGCM("k1", map[string][]byte{"k1": testKey(1, 32)})
+
+	inner := &ttlStorage{
+		MockListStorage: MockListStorage{data: map[string][]byte{}},
+		expires:         map[string]time.Time{},
+		now:             time.Now(),
+	}
+	es := NewEncryptedStorage(inner, aesgcm)
+
+	if !es.NativeExpiry() {
+		t.Fatalf("NativeExpiry() = false, want true")
+	}
+
+	sm := NewManager(es, "sessions", time.Minute)
+	sm.Set("token", []byte("oauth-token"))
+	if e := sm.Save(); e != nil {
+		t.Fatalf("Save() error = %v", e)
+	}
+
+	key := "sessions/" + sm.ID().String() + Suffix
+	if got := inner.expires[key]; !got.Equal(sm.Expiration()) {
+		t.Fatalf("SaveUntil() expiration = %v, want %v", got, sm.Expiration())
+	}
+
+	inner.now = sm.Expiration().Add(time.Second)
+	if _, e := es.Load(key); e == nil {
+		t.Errorf("Load() of an expired session did not fail")
+	}
+
+	noTTL := NewEncryptedStorage(&MockListStorage{data: map[string][]byte{}}, aesgcm)
+	if noTTL.NativeExpiry() {
+		t.Errorf("NativeExpiry() = true for storage that does not expire sessions")
+	}
+}
+
+func TestEncryptedStorage_BoundToID(t *testing.T) {
+	aesgcm, _ := NewAESGCM("k1", map[string][]byte{"k1": testKey(1, 32)})
+	capsule, e1 := gpg.NewCapsule(
+		"../gpg/testdata/gpg-test.public.asc",
+		"../gpg/testdata/gpg-test.private.asc",
+		"test1234",
+	)
+	if e1 != nil {
+		t.Fatal(e1)
+	}
+
+	tests := []struct {
+		name   string
+		cipher Cipher
+	}{
+		{"aes-gcm", aesgcm},
+		{"gpg", capsule},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &MockListStorage{data: map[string][]byte{}}
+			es := NewEncryptedStorage(inner, tt.cipher)
+
+			if e := es.Save("sessions/a.json", []byte(`{"Items":{}}`)); e != nil {
+				t.Fatalf("Save() error = %v", e)
+			}
+
+			if _, e := es.Load("sessions/a.json"); e != nil {
+				t.Fatalf("Load() error = %v", e)
+			}
+
+			inner.data["sessions/b.json"] = inner.data["sessions/a.json"]
+			if _, e := es.Load("sessions/b.json"); e == nil {
+				t.Errorf("Load() of a payload copied from another session did not fail")
+			}
+		})
+	}
+}
+
+func TestEncryptedStorage_Memory(t *testing.T) {
+	aesgcm, _ := NewAESGCM("k1", map[string][]byte{"k1": testKey(1, 32)})
+	ms := NewMemoryStorage(0)
+	es := NewEncryptedStorage(ms, aesgcm)
+
+	if !es.NativeExpiry() {
+		t.Fatalf("NativeExpiry() = false, want true")
+	}
+
+	if e := ms.Save("sessions/a.json", []byte{1, 2, 3}); e == nil {
+		t.Errorf("Save() of a payload it cannot read did not fail")
+	}
+
+	if e := es.SaveUntil("sessions/a.json", []byte(`{}`), time.Now().Add(-time.Second)); e != nil {
+		t.Fatalf("SaveUntil() error = %v", e)
+	}
+
+	if _, e := es.Load("sessions/a.json"); e == nil {
+		t.Errorf("Load() of an expired session did not fail")
+	}
+}
@@ -40,3 +40,22 @@ type InvalidIDError struct {
 func (e InvalidIDError) Error() string {
 	return stderr.InvalidSessionID + e.id
 }
+
+// ConflictError The session was saved by another request since it was loaded,
+// so saving it would lose those changes.
+type ConflictError struct {
+	ID string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf(stderr.Conflict, e.ID)
+}
+
+// NoSuchKeyError The session does not have an item with the key.
+type NoSuchKeyError struct {
+	Key string
+}
+
+func (e *NoSuchKeyError) Error() string {
+	return fmt.Sprintf(stderr.NoSuchKey, e.Key)
+}
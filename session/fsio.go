@@ -1,12 +1,14 @@
 package session
 
 import (
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
-
-	"github.com/kohirens/stdlib/fsio"
+	"strings"
 )
 
 // NewStorageLocal Initialize local session storage.
@@ -16,44 +18,193 @@ func NewStorageLocal(workDir string) *LocalStorage {
 	}
 }
 
+// LocalStorage Keeps each session in a file under WorkDir, for servers with
+// a local or shared file system.
+//
+// Files are written to a temporary file then renamed, so a reader never sees
+// a partial session, and they can only be read by the owner. Writes and
+// removals hold an exclusive lock on the directory of the file, so several
+// processes can share the storage.
+//
+//	NOTE: Locks are advisory flock locks, which are not available on
+//	Windows, where they are skipped.
 type LocalStorage struct {
+	// Shards The number of directory levels to spread sessions over, each
+	// level has up to 256 directories named from the hash of the session
+	// file name. Use it when there are so many sessions that a single
+	// directory gets slow. Zero puts every session in the same directory.
+	// Changing it makes the sessions already saved unreachable.
+	Shards  int
 	WorkDir string
 }
 
+const (
+	localDirPerms  = 0700
+	localFilePerms = 0600
+	localTmpPrefix = ".tmp-"
+)
+
+var (
+	_ Lister  = (*LocalStorage)(nil)
+	_ Storage = (*LocalStorage)(nil)
+)
+
+// List The sessions in a location, looking through the shard directories.
+func (ls *LocalStorage) List(location string) ([]string, error) {
+	dir, e1 := ls.dir(location)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	names := make([]string, 0)
+
+	e2 := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir && errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipAll
+			}
+			return err
+		}
+
+		depth := strings.Count(strings.TrimPrefix(path, dir), string(os.PathSeparator))
+		if d.IsDir() {
+			if depth > ls.Shards {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if depth == ls.Shards+1 && d.Type().IsRegular() && !strings.HasPrefix(d.Name(), localTmpPrefix) {
+			names = append(names, d.Name())
+		}
+
+		return nil
+	})
+	if e2 != nil {
+		return nil, fmt.Errorf(stderr.ListFiles, dir, e2.Error())
+	}
+
+	return names, nil
+}
+
 // Load Session data from local file storage.
-func (ls *LocalStorage) Load(id string) (*Data, error) {
-	f := filepath.Join(ls.WorkDir, id)
-	if !fsio.Exist(f) {
-		return nil, fmt.Errorf("file %v not found", f)
+func (ls *LocalStorage) Load(id string) ([]byte, error) {
+	f, e1 := ls.path(id)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	content, e2 := os.ReadFile(f)
+	if e2 != nil {
+		return nil, fmt.Errorf(stderr.ReadFile, f, e2)
 	}
 
-	content, e1 := os.ReadFile(f)
+	return content, nil
+}
+
+// Remove A session file from storage.
+func (ls *LocalStorage) Remove(key string) error {
+	f, e1 := ls.path(key)
 	if e1 != nil {
-		return nil, fmt.Errorf(stderr.ReadFile, f, e1.Error())
+		return e1
 	}
 
-	data := &Data{}
+	unlock, e2 := lockDir(filepath.Dir(f))
+	if e2 != nil {
+		return e2
+	}
+	defer unlock()
 
-	if e := json.Unmarshal(content, data); e != nil {
-		fmt.Printf("JSON to decode: %v\n", string(content))
-		return nil, fmt.Errorf(stderr.DecodeJSON, f, e)
+	if e := os.Remove(f); e != nil {
+		return fmt.Errorf(stderr.RemoveSession, key, e.Error())
 	}
 
-	return data, nil
+	return nil
 }
 
 // Save Session data to a local file for storage.
-func (ls *LocalStorage) Save(data *Data) error {
-	f := filepath.Join(ls.WorkDir, data.Id.String())
+func (ls *LocalStorage) Save(id string, data []byte) error {
+	f, e1 := ls.path(id)
+	if e1 != nil {
+		return e1
+	}
+
+	dir := filepath.Dir(f)
+	if e := os.MkdirAll(dir, localDirPerms); e != nil {
+		return fmt.Errorf(stderr.WriteFile, f, e)
+	}
 
-	content, e2 := json.Marshal(data)
+	unlock, e2 := lockDir(dir)
 	if e2 != nil {
-		return fmt.Errorf(stderr.EncodeJSON, e2)
+		return e2
+	}
+	defer unlock()
+
+	tmp, e3 := os.CreateTemp(dir, localTmpPrefix+"*")
+	if e3 != nil {
+		return fmt.Errorf(stderr.WriteFile, f, e3)
+	}
+
+	// Clean up the temporary file, unless it was renamed.
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, e := tmp.Write(data); e != nil {
+		_ = tmp.Close()
+		return fmt.Errorf(stderr.WriteFile, f, e)
+	}
+
+	if e := tmp.Chmod(localFilePerms); e != nil {
+		_ = tmp.Close()
+		return fmt.Errorf(stderr.WriteFile, f, e)
+	}
+
+	if e := tmp.Sync(); e != nil {
+		_ = tmp.Close()
+		return fmt.Errorf(stderr.WriteFile, f, e)
+	}
+
+	if e := tmp.Close(); e != nil {
+		return fmt.Errorf(stderr.WriteFile, f, e)
 	}
 
-	if e := os.WriteFile(f, content, fsio.DefaultFilePerms); e != nil {
-		return fmt.Errorf(stderr.WriteFile, e)
+	if e := os.Rename(tmp.Name(), f); e != nil {
+		return fmt.Errorf(stderr.WriteFile, f, e)
 	}
 
 	return nil
 }
+
+// dir The directory of a location, which must stay in the WorkDir.
+func (ls *LocalStorage) dir(location string) (string, error) {
+	if location == "" {
+		return filepath.Clean(ls.WorkDir), nil
+	}
+
+	if !filepath.IsLocal(location) {
+		return "", fmt.Errorf(stderr.InvalidKey, location)
+	}
+
+	return filepath.Join(ls.WorkDir, location), nil
+}
+
+// path The file of a session, in its shard directories. The key must stay in
+// the WorkDir.
+func (ls *LocalStorage) path(key string) (string, error) {
+	if key == "" || !filepath.IsLocal(key) {
+		return "", fmt.Errorf(stderr.InvalidKey, key)
+	}
+
+	dir, name := filepath.Split(key)
+
+	parts := []string{ls.WorkDir, dir}
+	if ls.Shards > 0 {
+		sum := sha256.Sum256([]byte(name))
+		h := hex.EncodeToString(sum[:])
+		for i := 0; i < ls.Shards && i < len(sum); i++ {
+			parts = append(parts, h[i*2:i*2+2])
+		}
+	}
+	parts = append(parts, name)
+
+	return filepath.Join(parts...), nil
+}
@@ -0,0 +1,129 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestLocalStorage_Save(t *testing.T) {
+	tests := []struct {
+		name   string
+		shards int
+		depth  int
+	}{
+		{"flat", 0, 0},
+		{"one-shard", 1, 1},
+		{"two-shards", 2, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ls := NewStorageLocal(t.TempDir())
+			ls.Shards = tt.shards
+
+			if e := ls.Save("sessions/s1.json", []byte("{}")); e != nil {
+				t.Fatalf("Save() error = %v", e)
+			}
+
+			matches, _ := filepath.Glob(filepath.Join(ls.WorkDir, "sessions", filepath.Join(slices.Repeat([]string{"??"}, tt.depth)...), "s1.json"))
+			if len(matches) != 1 {
+				t.Fatalf("Save() did not write s1.json %v directories deep", tt.depth)
+			}
+
+			if runtime.GOOS == "windows" {
+				return
+			}
+
+			info, _ := os.Stat(matches[0])
+			if got := info.Mode().Perm(); got != localFilePerms {
+				t.Errorf("Save() file permissions = %v, want %v", got, os.FileMode(localFilePerms))
+			}
+
+			dir, _ := os.Stat(filepath.Dir(matches[0]))
+			if got := dir.Mode().Perm(); got != localDirPerms {
+				t.Errorf("Save() directory permissions = %v, want %v", got, os.FileMode(localDirPerms))
+			}
+
+			names, e1 := ls.List("sessions")
+			if e1 != nil {
+				t.Fatalf("List() error = %v", e1)
+			}
+			if len(names) != 1 || names[0] != "s1.json" {
+				t.Errorf("List() = %v, want [s1.json]", names)
+			}
+		})
+	}
+}
+
+func TestLocalStorage_InvalidKey(t *testing.T) {
+	ls := NewStorageLocal(t.TempDir())
+
+	for _, key := range []string{"", "../s1.json", "sessions/../../s1.json", "/etc/passwd"} {
+		if e := ls.Save(key, []byte("{}")); e == nil {
+			t.Errorf("Save(%q) did not fail", key)
+		}
+
+		if _, e := ls.Load(key); e == nil {
+			t.Errorf("Load(%q) did not fail", key)
+		}
+	}
+
+	if _, e := ls.List("../sessions"); e == nil {
+		t.Errorf("List() of a location outside the work directory did not fail")
+	}
+}
+
+func TestLocalStorage_List(t *testing.T) {
+	ls := NewStorageLocal(t.TempDir())
+
+	names, e1 := ls.List("missing")
+	if e1 != nil || len(names) != 0 {
+		t.Errorf("List() of a missing location = %v, %v, want no sessions", names, e1)
+	}
+
+	_ = ls.Save("sessions/s1.json", []byte("{}"))
+	_ = ls.Save("sessions/sub/s2.json", []byte("{}"))
+	_ = os.WriteFile(filepath.Join(ls.WorkDir, "sessions", localTmpPrefix+"123"), nil, 0600)
+
+	names, _ = ls.List("sessions")
+	if len(names) != 1 || names[0] != "s1.json" {
+		t.Errorf("List() = %v, want [s1.json]", names)
+	}
+}
+
+func TestLocalStorage_ConcurrentSave(t *testing.T) {
+	ls := NewStorageLocal(t.TempDir())
+	ls.Shards = 1
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			data, _ := json.Marshal(map[string]string{"writer": fmt.Sprint(i)})
+			if e := ls.Save("sessions/s1.json", data); e != nil {
+				t.Errorf("Save() error = %v", e)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	got, e1 := ls.Load("sessions/s1.json")
+	if e1 != nil {
+		t.Fatalf("Load() error = %v", e1)
+	}
+
+	if !json.Valid(got) {
+		t.Errorf("Load() = %s, want a whole session from one writer", got)
+	}
+
+	tmp, _ := filepath.Glob(filepath.Join(ls.WorkDir, "sessions", "*", localTmpPrefix+"*"))
+	if len(tmp) != 0 {
+		t.Errorf("Save() left temporary files %v", tmp)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package session
+
+// lockDir Does nothing, as there are no advisory locks to use on this system.
+func lockDir(dir string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,28 @@
+//go:build unix
+
+package session
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// lockDir Hold an exclusive advisory lock on a directory, until the returned
+// function is called.
+func lockDir(dir string) (func(), error) {
+	f, e1 := os.Open(dir)
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.LockDir, dir, e1.Error())
+	}
+
+	if e := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); e != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf(stderr.LockDir, dir, e.Error())
+	}
+
+	return func() {
+		_ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		_ = f.Close()
+	}, nil
+}
@@ -14,6 +14,10 @@ import (
 
 // Manager This is the container/interface for your session. Needed to make a
 // new session or restore an existing one.
+//
+// The methods of a Manager are safe to call from several goroutines, but it
+// holds the session of one client. A server should make a Manager for each
+// request with a SessionStore.
 type Manager struct {
 	data    *Data
 	storage Storage
@@ -22,7 +26,13 @@ type Manager struct {
 	hasUpdates bool
 	location   string
 	mutex      sync.Mutex
-	timeout    time.Duration
+	policy     *Policy
+	// reissueCookie Set when the ID or expiration changes, so that SetCookie
+	// replaces the ID cookie the client already has.
+	reissueCookie bool
+	// stored Set when the session is known to be in storage under its
+	// current ID.
+	stored bool
 }
 
 // Get Retrieve data from the session.
@@ -39,6 +49,9 @@ func (m *Manager) Get(key string) []byte {
 
 // Expiration Retrieve expiration time.
 func (m *Manager) Expiration() time.Time {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
 	return m.data.Expiration
 }
 
@@ -48,6 +61,9 @@ func (m *Manager) Expiration() time.Time {
 // time a call to the backend was made from the client. This made session length
 // indefinite. Not the intended purpose.
 func (m *Manager) HasExpired() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
 	currentTime := time.Now().UTC()
 	sessionTime := m.data.Expiration.UTC()
 	hasExpired := time.Now().UTC().After(m.data.Expiration)
@@ -59,28 +75,22 @@ func (m *Manager) HasExpired() bool {
 	return hasExpired
 }
 
-// ID Of the session as an HTTP cookie with secure and http-only (cannot be read by JavaScript) enabled.
-// The domain parameter is optional, and only set when it is not an emptry string.
+// ID Of the session.
 func (m *Manager) ID() *uuid.UUID {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
 	return m.data.Id
 }
 
+// IDCookie The session ID as an HTTP cookie with secure and http-only (cannot
+// be read by JavaScript) enabled. The domain parameter is optional, and only
+// set when it is not an emptry string.
 func (m *Manager) IDCookie(cookiePath, domain string) *http.Cookie {
-	c := &http.Cookie{
-		Expires:  m.data.Expiration,
-		Name:     IDKey,
-		Path:     cookiePath,
-		Secure:   true,
-		HttpOnly: true,
-		Value:    m.data.Id.String(),
-		SameSite: http.SameSiteStrictMode,
-	}
-
-	if domain != "" {
-		c.Domain = domain
-	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 
-	return c
+	return m.idCookie(cookiePath, domain)
 }
 
 // LoadFromCookie will load a session from an HTTP cookie.
@@ -91,20 +101,35 @@ func (m *Manager) LoadFromCookie(r *http.Request) error {
 		return NoSessionCookieError{}
 	}
 
-	if e := m.Restore(idCookie.Value); e != nil {
+	if cc, ok := m.storage.(CookieCarrier); ok {
+		cc.ReadCookies(r)
+	}
+
+	m.mutex.Lock()
+
+	if e := m.restore(idCookie.Value); e != nil {
+		m.mutex.Unlock()
 		return RestoreError{e.Error()}
 	}
 
+	expiration := m.data.Expiration
+	m.mutex.Unlock()
+
 	// Indicate the session has expired.
-	if time.Now().UTC().After(m.data.Expiration.UTC()) {
-		return ExpiredError{m.data.Expiration}
+	if time.Now().UTC().After(expiration.UTC()) {
+		return ExpiredError{expiration}
 	}
 
+	m.Touch()
+
 	return nil
 }
 
 // Remove data from a session
 func (m *Manager) Remove(key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
 	// verify the key exists
 	_, ok := m.data.Items[key]
 	if !ok {
@@ -120,23 +145,105 @@ func (m *Manager) Remove(key string) error {
 	return nil
 }
 
+// Regenerate Give the session a new ID, keeping its data. The data is saved
+// under the new ID and the record for the old ID is removed from storage, then
+// SetCookie sends the client the new ID.
+//
+//	Call this whenever the privileges of a session change, such as when a
+//	user logs in or out. Otherwise, an attacker who planted a session ID in
+//	the client before login, or who learned it, keeps a valid session after
+//	the user logs in. This is known as session fixation, see
+//	https://owasp.org/www-community/attacks/Session_fixation
+func (m *Manager) Regenerate() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.storage == nil {
+		return StorageError{}
+	}
+
+	oldID := m.data.Id
+	m.data.Id = GenerateID()
+	m.hasUpdates = true
+	m.reissueCookie = true
+
+	// Save under the new ID before removing the old, so that the data is not
+	// lost if either fails.
+	if e := m.save(); e != nil {
+		return e
+	}
+
+	if !m.stored {
+		m.stored = true
+		return nil
+	}
+
+	if e := m.storage.Remove(m.storagePath(oldID.String())); e != nil {
+		return fmt.Errorf(stderr.RemoveSession, oldID.String(), e.Error())
+	}
+
+	return nil
+}
+
 // RemoveAll When you need to scrub the data from the session and fast.
 func (m *Manager) RemoveAll() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
 	m.data.Items = Store{}
 }
 
+// RefreshCookie Send the client the ID cookie again, only when its ID or
+// expiration has changed since it was sent, such as when Touch extends the
+// session. Call it before the response is written. With a CookieCarrier
+// storage, this also sends the session cookies when the session was saved.
+func (m *Manager) RefreshCookie(w http.ResponseWriter) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.refreshCookie(w)
+}
+
+// Remaining Time left before the session expires.
+func (m *Manager) Remaining() time.Duration {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.data.Expiration.Sub(time.Now().UTC())
+}
+
 // Reset When you need to scrub the data from the session and fast.
 func (m *Manager) Reset() {
-	m.data = newData(m.timeout)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.data = newData(m.policy)
+	m.stored = false
 }
 
 // Restart an expired session without removing any data.
 func (m *Manager) Restart() {
-	m.data.Expiration = time.Now().UTC().Add(m.timeout)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now().UTC()
+	m.data.Created = now
+	m.data.LastActivity = now
+	m.data.Expiration = m.policy.expiration(m.data)
+	m.hasUpdates = true
+	m.reissueCookie = true
 }
 
 // Restore Restores the session by ID as a string.
 func (m *Manager) Restore(id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.restore(id)
+}
+
+// restore Restores the session by ID, the caller must hold the mutex.
+func (m *Manager) restore(id string) error {
 	// Skip empty string.
 	if strings.Trim(id, " \n\t\r") == "" {
 		return fmt.Errorf("%v", stderr.EmptySessionID)
@@ -170,6 +277,7 @@ func (m *Manager) Restore(id string) error {
 	}
 
 	m.data = data
+	m.stored = true
 
 	Log.Infof("%v", stdout.Restored)
 
@@ -181,16 +289,7 @@ func (m *Manager) Save() error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	dataBytes, e1 := json.Marshal(m.data)
-	if e1 != nil {
-		return fmt.Errorf(stderr.EncodeJSON, e1)
-	}
-
-	if m.hasUpdates {
-		return m.storage.Save(m.storagePath(m.ID().String()), dataBytes)
-	}
-
-	return nil
+	return m.save()
 }
 
 // Set Store data in the session.
@@ -201,11 +300,38 @@ func (m *Manager) Set(key string, value []byte) {
 	m.data.Items[key] = value
 }
 
+// SetRememberMe Mark the session as one the user wants to stay logged in to,
+// so that the Policy.RememberMe limit applies.
+func (m *Manager) SetRememberMe(remember bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.data.RememberMe == remember {
+		return
+	}
+
+	m.data.RememberMe = remember
+	m.data.Expiration = m.policy.expiration(m.data)
+	m.hasUpdates = true
+	m.reissueCookie = true
+}
+
 // SetCookie stored the session ID in a secure HTTP cookie.
 //
 //	This is no-op if the cookie has been previously set and the session has not
-//	expired or the cookie deleted.
+//	expired or the cookie deleted, unless the ID was changed by Regenerate or
+//	the expiration was changed by Touch, Restart or SetRememberMe.
 func (m *Manager) SetCookie(w http.ResponseWriter, r *http.Request) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.reissueCookie {
+		m.refreshCookie(w)
+		return
+	}
+
+	m.setDataCookies(w)
+
 	idCookie, e1 := r.Cookie(IDKey)
 	// Verify there is no cookie before we set a new one.
 	// This is to prevent making orphans of sessions by overwriting them by
@@ -217,24 +343,133 @@ func (m *Manager) SetCookie(w http.ResponseWriter, r *http.Request) {
 	if idCookie != nil {
 		Log.Dbugf("%v", stdout.IDCookieFound)
 		Log.Dbugf(stdout.IDCookieValue, idCookie.Value)
-		Log.Dbugf(stdout.IDSessionValue, m.ID().String())
+		Log.Dbugf(stdout.IDSessionValue, m.data.Id.String())
 		// This could happen when a session expires, as the transition from
 		// session expiration to logging is a work in progress.
-		if idCookie.Value != m.ID().String() {
-			Log.Warnf(stderr.PhenomenonMismatchCookie, idCookie.Value, m.ID().String())
+		if idCookie.Value != m.data.Id.String() {
+			Log.Warnf(stderr.PhenomenonMismatchCookie, idCookie.Value, m.data.Id.String())
 		}
 		return
 	}
 
-	idCookie = m.IDCookie(IDCookiePath, IDCookieDomain)
+	idCookie = m.idCookie(IDCookiePath, IDCookieDomain)
 	Log.Infof("%v", stdout.IDSet)
 	http.SetCookie(w, idCookie)
 }
 
+// Touch Record activity on the session, extending it when the Policy has an
+// Idle limit. LoadFromCookie calls this for every request that restores a
+// session that has not expired. The OnExtended and OnExpiring hooks of the
+// Policy are called from here.
+func (m *Manager) Touch() {
+	m.mutex.Lock()
+
+	now := time.Now().UTC()
+	previous := m.data.Expiration
+
+	// Sessions saved before there was a Policy have no creation time, work
+	// it out from the expiration so that the session is not extended.
+	if m.data.Created.IsZero() {
+		m.data.Created = m.data.Expiration.Add(-m.policy.Absolute)
+	}
+	m.data.LastActivity = now
+	m.data.Expiration = m.policy.expiration(m.data)
+
+	extended := m.data.Expiration.After(previous)
+	if extended {
+		m.hasUpdates = true
+		m.reissueCookie = true
+	}
+
+	expiration := m.data.Expiration
+	remaining := expiration.Sub(now)
+
+	// Unlock before calling hooks, so that they can use the manager.
+	m.mutex.Unlock()
+
+	if extended && m.policy.OnExtended != nil {
+		m.policy.OnExtended(m, previous, expiration)
+	}
+
+	if m.policy.Warn > 0 && remaining <= m.policy.Warn && m.policy.OnExpiring != nil {
+		m.policy.OnExpiring(m, remaining)
+	}
+}
+
+// idCookie The session ID cookie, the caller must hold the mutex.
+func (m *Manager) idCookie(cookiePath, domain string) *http.Cookie {
+	c := &http.Cookie{
+		Expires:  m.data.Expiration,
+		Name:     IDKey,
+		Path:     cookiePath,
+		Secure:   true,
+		HttpOnly: true,
+		Value:    m.data.Id.String(),
+		SameSite: http.SameSiteStrictMode,
+	}
+
+	if domain != "" {
+		c.Domain = domain
+	}
+
+	return c
+}
+
+// refreshCookie See RefreshCookie, the caller must hold the mutex.
+func (m *Manager) refreshCookie(w http.ResponseWriter) {
+	m.setDataCookies(w)
+
+	if !m.reissueCookie {
+		return
+	}
+
+	Log.Infof("%v", stdout.IDReissued)
+	http.SetCookie(w, m.idCookie(IDCookiePath, IDCookieDomain))
+	m.reissueCookie = false
+}
+
+// save Writes session data to its storage, the caller must hold the mutex.
+func (m *Manager) save() error {
+	if !m.hasUpdates {
+		return nil
+	}
+
+	dataBytes, e1 := json.Marshal(m.data)
+	if e1 != nil {
+		return fmt.Errorf(stderr.EncodeJSON, e1)
+	}
+
+	id := m.storagePath(m.data.Id.String())
+
+	var e2 error
+	if es, ok := m.storage.(ExpirySaver); ok {
+		e2 = es.SaveUntil(id, dataBytes, m.data.Expiration)
+	} else {
+		e2 = m.storage.Save(id, dataBytes)
+	}
+
+	if e2 != nil {
+		return e2
+	}
+
+	m.stored = true
+
+	return nil
+}
+
+// setDataCookies Send the cookies of a CookieCarrier storage, if it has any.
+func (m *Manager) setDataCookies(w http.ResponseWriter) {
+	cc, ok := m.storage.(CookieCarrier)
+	if !ok {
+		return
+	}
+
+	for _, c := range cc.Cookies(m.data.Expiration) {
+		http.SetCookie(w, c)
+	}
+}
+
 // storagePath Returns a path to load/save a session to/from.
 func (m *Manager) storagePath(id string) string {
-	if m.location != "" {
-		return m.location + "/" + id + Suffix
-	}
-	return id + Suffix
+	return StorageKey(m.location, id)
 }
@@ -68,8 +68,8 @@ type MockStorage struct {
 }
 
 func (ms *MockStorage) Remove(key string) error {
-	//TODO implement me
-	panic("implement me")
+	delete(ms.data, key)
+	return nil
 }
 
 func (ms *MockStorage) Load(id string) ([]byte, error) {
@@ -80,10 +80,9 @@ func (ms *MockStorage) Load(id string) ([]byte, error) {
 			panic(e1.Error())
 		}
 		sd := &Data{
-			&uid,
-			time.Now().Add(time.Minute + 5), //exp.Format("2006-01-02T15:04:05Z07:00"),
-			Store{"test2": []byte("54321")},
-			false,
+			Id:         &uid,
+			Expiration: time.Now().Add(time.Minute + 5), //exp.Format("2006-01-02T15:04:05Z07:00"),
+			Items:      Store{"test2": []byte("54321")},
 		}
 		b, e := json.Marshal(sd)
 		if e != nil {
@@ -272,6 +271,60 @@ func TestManager_HasExpired(t *testing.T) {
 	}
 }
 
+func TestManager_Regenerate(t *testing.T) {
+	tests := []struct {
+		name      string
+		cookie    string
+		wantSaved bool
+	}{
+		{"restored", "9e934ad9-cf7a-4ab9-b8aa-9e619b30badb", true},
+		{"new", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := &MockStorage{data: make(Store, 10)}
+			m := NewManager(ms, "", time.Minute)
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: IDKey, Value: tt.cookie})
+			}
+			_ = m.LoadFromCookie(r)
+			oldID := m.ID().String()
+			items := m.Get("test2")
+
+			if e := m.Regenerate(); e != nil {
+				t.Errorf("Manager.Regenerate() error = %v", e)
+				return
+			}
+
+			newID := m.ID().String()
+			if newID == oldID {
+				t.Errorf("Manager.Regenerate() kept ID %v", oldID)
+				return
+			}
+
+			if got := m.Get("test2"); !reflect.DeepEqual(got, items) {
+				t.Errorf("Manager.Regenerate() items = %v, want %v", got, items)
+				return
+			}
+
+			if _, ok := ms.data[newID+Suffix]; !ok {
+				t.Errorf("Manager.Regenerate() did not save %v", newID)
+				return
+			}
+
+			// The same request still carries the old ID, the cookie must be
+			// replaced anyway.
+			w := &MockResponse{}
+			m.SetCookie(w, r)
+			cookies := w.Header()["Set-Cookie"]
+			if len(cookies) != 1 || !strings.Contains(cookies[0], IDKey+"="+newID) {
+				t.Errorf("Manager.SetCookie() = %v, want the new ID %v", cookies, newID)
+			}
+		})
+	}
+}
+
 type MockResponse struct {
 	Headers http.Header
 }
@@ -295,8 +348,8 @@ type MockStorage2 struct {
 }
 
 func (ms *MockStorage2) Remove(key string) error {
-	//TODO implement me
-	panic("implement me")
+	delete(ms.data, key)
+	return nil
 }
 
 func (ms *MockStorage2) Load(id string) ([]byte, error) {
@@ -307,10 +360,9 @@ func (ms *MockStorage2) Load(id string) ([]byte, error) {
 			panic(e1)
 		}
 		sd := &Data{
-			&sid,
-			time.Now().Add(time.Minute + 5), //exp.Format("2006-01-02T15:04:05Z07:00"),
-			ms.data,
-			false,
+			Id:         &sid,
+			Expiration: time.Now().Add(time.Minute + 5), //exp.Format("2006-01-02T15:04:05Z07:00"),
+			Items:      ms.data,
 		}
 		b, e := json.Marshal(sd)
 		if e != nil {
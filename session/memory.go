@@ -0,0 +1,74 @@
+package session
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kohirens/www/storage"
+)
+
+// MemoryStorage Keeps sessions in memory, for tests and for servers that run
+// as a single process. Each session expires at its Expiration, and expired
+// sessions are swept as new ones are saved, so there is no need for a
+// Reaper. It is safe for concurrent use.
+//
+//	NOTE: Sessions are lost when the process stops, and are not shared with
+//	other processes, such as other instances of a Lambda function.
+type MemoryStorage struct {
+	store *storage.MemoryStorage
+}
+
+var (
+	_ ExpirySaver   = (*MemoryStorage)(nil)
+	_ Lister        = (*MemoryStorage)(nil)
+	_ NativeExpirer = (*MemoryStorage)(nil)
+	_ Storage       = (*MemoryStorage)(nil)
+)
+
+// NewMemoryStorage Initialize session storage in memory that keeps at most
+// maxEntries sessions, evicting the least recently used. Zero for no limit.
+func NewMemoryStorage(maxEntries int) *MemoryStorage {
+	return &MemoryStorage{
+		store: storage.NewMemoryStorage(maxEntries, 0),
+	}
+}
+
+// List The sessions in a location.
+func (ms *MemoryStorage) List(location string) ([]string, error) {
+	return ms.store.List(location)
+}
+
+// Load The session from memory.
+func (ms *MemoryStorage) Load(id string) ([]byte, error) {
+	return ms.store.Load(id)
+}
+
+// NativeExpiry Expired sessions are dropped from memory.
+func (ms *MemoryStorage) NativeExpiry() bool {
+	return true
+}
+
+// Remove A session from memory.
+func (ms *MemoryStorage) Remove(key string) error {
+	return ms.store.Remove(key)
+}
+
+// Save The session to memory, until the expiration read from the data, see
+// SaveUntil. It fails when the expiration cannot be read.
+func (ms *MemoryStorage) Save(id string, data []byte) error {
+	exp, e1 := ExpirationOf(data)
+	if e1 != nil {
+		return e1
+	}
+
+	return ms.SaveUntil(id, data, exp)
+}
+
+// SaveUntil The session to memory, until the expiration.
+func (ms *MemoryStorage) SaveUntil(id string, data []byte, expiration time.Time) error {
+	if expiration.IsZero() {
+		return fmt.Errorf(stderr.NoExpiration, id)
+	}
+
+	return ms.store.SaveUntil(id, data, expiration)
+}
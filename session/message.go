@@ -1,34 +1,90 @@
 package session
 
 var stderr = struct {
+	CipherFormat,
+	CipherKey,
+	CipherKeyID,
+	CipherKeyNotFound,
+	CipherSession,
+	Conflict,
+	CookieTooLarge,
+	DecodeCookie,
 	DecodeJSON,
+	DecodeSession,
+	DecodeValue,
+	Decrypt,
+	DecryptSession,
 	EmptySessionID,
 	EncodeJSON,
+	EncodeValue,
+	Encrypt,
+	EncryptSession,
 	ExpiredCookie,
+	InvalidKey,
 	InvalidSessionID,
+	ListFiles,
+	LockDir,
+	MsgPackAssign,
+	MsgPackDecode,
+	MsgPackTarget,
+	MsgPackType,
+	NoDataCookie,
+	NoExpiration,
 	NoIDCookieFound,
 	NoStorage,
 	NoSuchKey,
+	NotListable,
 	PhenomenonMismatchCookie,
 	ReadFile,
+	RemoveSession,
 	RestoreSession,
 	SessionStrange,
+	Sweep,
+	SweepSession,
 	UUID,
 	WriteFile string
 }{
-	DecodeJSON: "could not decode JSON from file %v: %w",
+	CipherFormat:      "encrypted session is not in the expected format",
+	CipherKey:         "invalid session key %v: %v",
+	CipherKeyID:       "session key ID %q must be 1 to 255 bytes",
+	CipherKeyNotFound: "session key %q not found",
+	CipherSession:     "payload belongs to another session",
+	Conflict:          "session %v was saved by another request since it was loaded",
+	CookieTooLarge:    "session %v needs %v cookies, more than the limit of %v",
+	DecodeCookie:      "could not decode session cookie: %v",
+	DecodeJSON:        "could not decode JSON from file %v: %w",
 	//DecodeJSON:     "could not decode json data: %v",
+	DecodeSession:            "could not decode session: %v",
+	DecodeValue:              "could not decode session value %v: %v",
+	Decrypt:                  "could not decrypt: %v",
+	DecryptSession:           "could not decrypt session %v: %v",
 	EmptySessionID:           "session ID is empty",
 	EncodeJSON:               "could not encode JSON: %w",
+	EncodeValue:              "could not encode session value %v: %v",
+	Encrypt:                  "could not encrypt: %v",
+	EncryptSession:           "could not encrypt session %v: %v",
 	ExpiredCookie:            "session has expired at %v",
+	InvalidKey:               "session key %q must be a relative path that stays in the storage directory",
 	InvalidSessionID:         "invalid session id ",
+	ListFiles:                "could not list sessions in %v: %v",
+	LockDir:                  "could not lock directory %v: %v",
+	MsgPackAssign:            "msgpack cannot set a %T to a %v",
+	MsgPackDecode:            "invalid msgpack data: %v",
+	MsgPackTarget:            "msgpack must decode into a non-nil pointer, not %T",
+	MsgPackType:              "msgpack cannot encode a %v",
+	NoDataCookie:             "no cookies found for session %v: %w",
+	NoExpiration:             "session %v has no expiration",
 	NoIDCookieFound:          "no session ID cookie found",
 	NoStorage:                "storage has not been set",
 	NoSuchKey:                "the key %v was not found in the session",
+	NotListable:              "session storage cannot list sessions, it must implement session.Lister",
 	PhenomenonMismatchCookie: "session ID found in cookie value %v, does not match current session ID value %v",
 	ReadFile:                 "could not read file %v: %w",
+	RemoveSession:            "could not remove session %v: %v",
 	RestoreSession:           "cannot to restore session ",
 	SessionStrange:           "strangeness detected, the session is out of sync. expiring the current session cookie, the user will have to start a new session",
+	Sweep:                    "could not sweep expired sessions: %v",
+	SweepSession:             "skipping session %v while sweeping: %v",
 	UUID:                     "cannot generate UUID: %v",
 	WriteFile:                "could not write content to file %v: %w",
 }
@@ -38,17 +94,21 @@ var stdout = struct {
 	IDCookieFound,
 	IDCookieValue,
 	IDSessionValue,
+	IDReissued,
 	IDSet,
 	Restored,
 	SessionExpired,
-	SessionTime string
+	SessionTime,
+	Swept string
 }{
 	CurrentTime:    "current time %v",
 	IDCookieFound:  "attempting to set a session ID cookie, but one has been found",
 	IDCookieValue:  "cookie session ID: %v",
 	IDSessionValue: "current session ID: %v",
+	IDReissued:     "replacing the session ID cookie with a new ID or expiration",
 	IDSet:          "setting a session ID cookie now",
 	Restored:       "session restored",
 	SessionExpired: "session has expired %v",
 	SessionTime:    "session time %v",
+	Swept:          "removed %v of %v sessions checked, %v failed",
 }
@@ -4,17 +4,25 @@ var stdout = struct {
 }{}
 
 var stderr = struct {
-	Connect           string
-	CannotInsertData  string
-	CannotLoadSession string
-	CannotSaveSession string
-	CannotUpsertData  string
-	EnvVarUnset       string
+	Connect             string
+	CannotCreateIndexes string
+	CannotInsertData    string
+	CannotLoadSession   string
+	CannotRemoveSession string
+	CannotSaveSession   string
+	CannotUpsertData    string
+	EnvVarUnset         string
+	NoExpiration        string
+	NoSession           string
 }{
-	Connect:           "could not connect to the database: %w",
-	CannotInsertData:  "could not insert data into %v.%v; %v",
-	CannotLoadSession: "could not load session data; %v",
-	CannotSaveSession: "could not save session data; %v",
-	CannotUpsertData:  "could not upsert data, %v",
-	EnvVarUnset:       "environment variable %v has not been set",
+	Connect:             "could not connect to the database: %w",
+	CannotCreateIndexes: "could not create the session indexes; %v",
+	CannotInsertData:    "could not insert data into %v.%v; %v",
+	CannotLoadSession:   "could not load session data; %v",
+	CannotRemoveSession: "could not remove session %v; %v",
+	CannotSaveSession:   "could not save session data; %v",
+	CannotUpsertData:    "could not upsert data, %v",
+	EnvVarUnset:         "environment variable %v has not been set",
+	NoExpiration:        "session %v has no expiration for the TTL index",
+	NoSession:           "no session found with ID %v: %w",
 }
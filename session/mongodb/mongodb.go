@@ -1,6 +1,4 @@
-// Package mongodb
-// Deprecated see github.com/kohirens/mongodb standalone library. This will be
-// removed in the next major release.
+// Package mongodb keeps sessions in a MongoDB collection.
 package mongodb
 
 import (
@@ -16,6 +14,11 @@ const (
 	ConnectionEnvVar = "MONGODB_CONNECTION"
 )
 
+// Connection Connect to the MongoDB server in the MONGODB_CONNECTION
+// environment variable.
+//
+// Deprecated: See the github.com/kohirens/mongodb standalone library. This
+// will be removed in the next major release.
 func Connection() (*mongo.Client, error) {
 	dbConnStr, ok3 := os.LookupEnv(ConnectionEnvVar)
 	if !ok3 {
@@ -35,6 +38,10 @@ func Connection() (*mongo.Client, error) {
 	return client, nil
 }
 
+// InsertOne Insert a document into a collection.
+//
+// Deprecated: See the github.com/kohirens/mongodb standalone library. This
+// will be removed in the next major release.
 func InsertOne(doc interface{}, database, collection string, c *mongo.Client) (*mongo.InsertOneResult, error) {
 	coll := c.Database(database).Collection(collection)
 
@@ -47,6 +54,9 @@ func InsertOne(doc interface{}, database, collection string, c *mongo.Client) (*
 }
 
 // UpsertOne Update an existing document or insert when it cannot be found.
+//
+// Deprecated: See the github.com/kohirens/mongodb standalone library. This
+// will be removed in the next major release.
 func UpsertOne(query interface{}, doc interface{}, collection *mongo.Collection, hint ...interface{}) (*mongo.UpdateResult, error) {
 	truthy := true // because they require a pointer instead of a copy.
 	opts := &options.UpdateOptions{
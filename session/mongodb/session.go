@@ -2,48 +2,200 @@ package mongodb
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"sync"
+	"time"
+
+	"github.com/kohirens/www/session"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// Collection The subset of a *mongo.Collection used by the store.
+type Collection interface {
+	DeleteOne(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error)
+	FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) *mongo.SingleResult
+	Indexes() mongo.IndexView
+	ReplaceOne(ctx context.Context, filter interface{}, replacement interface{}, opts ...*options.ReplaceOptions) (*mongo.UpdateResult, error)
+}
+
+// StorageDocument Keeps each session as a document in a MongoDB collection.
+//
+//	Call EnsureIndexes once for the collection, so that sessions are found
+//	quickly and MongoDB deletes them when they expire.
 type StorageDocument struct {
-	collection *mongo.Collection
+	Context    context.Context
+	collection Collection
+	mutex      sync.Mutex
+	// ttl Whether the collection has a TTL index on the expiration, nil until
+	// it is known.
+	ttl *bool
 }
 
-func NewStorageMongoDB(c *mongo.Client, database, collection string) *StorageDocument {
+// document The shape of a session in the collection.
+type document struct {
+	SessionID string `bson:"session_id"`
+	// Expiration A date for the TTL index.
+	Expiration time.Time `bson:"expiration"`
+	// Data The session exactly as the Manager saved it.
+	Data []byte `bson:"data"`
+}
+
+var (
+	_ session.ExpirySaver   = (*StorageDocument)(nil)
+	_ session.NativeExpirer = (*StorageDocument)(nil)
+	_ session.Storage       = (*StorageDocument)(nil)
+)
+
+// NewStorageDocument Initialize session storage on a MongoDB collection.
+func NewStorageDocument(ctx context.Context, c *mongo.Client, database, collection string) *StorageDocument {
+	return NewStorageDocumentWithCollection(ctx, c.Database(database).Collection(collection))
+}
+
+// NewStorageDocumentWithCollection Initialize session storage on a
+// collection you configured.
+func NewStorageDocumentWithCollection(ctx context.Context, c Collection) *StorageDocument {
 	return &StorageDocument{
-		collection: c.Database(database).Collection(collection),
+		Context:    ctx,
+		collection: c,
 	}
 }
 
-func (sd *StorageDocument) Save(name string, data []byte) error {
-	query := map[string][]byte{"session_id": []byte(name)}
+// NewStorageMongoDB Initialize session storage on a MongoDB collection.
+//
+// Deprecated: Use NewStorageDocument, which takes a context.
+func NewStorageMongoDB(c *mongo.Client, database, collection string) *StorageDocument {
+	return NewStorageDocument(context.Background(), c, database, collection)
+}
 
-	_, e1 := UpsertOne(
-		query,
-		data,
-		sd.collection,
-	)
+// EnsureIndexes Create a unique index on the session ID, and a TTL index on
+// the expiration so that MongoDB deletes expired sessions. This is no-op for
+// indexes that already exist.
+func (sd *StorageDocument) EnsureIndexes() error {
+	_, e1 := sd.collection.Indexes().CreateMany(sd.context(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "session_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expiration", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
 	if e1 != nil {
-		return fmt.Errorf(stderr.CannotSaveSession, e1.Error())
+		return fmt.Errorf(stderr.CannotCreateIndexes, e1.Error())
 	}
 
+	enabled := true
+	sd.mutex.Lock()
+	sd.ttl = &enabled
+	sd.mutex.Unlock()
+
 	return nil
 }
 
+// Load The session as JSON.
 func (sd *StorageDocument) Load(id string) ([]byte, error) {
-	query := bson.M{"session_id": id}
-	result := sd.collection.FindOne(context.TODO(), query)
+	doc := &document{}
 
-	bsonDoc, e1 := result.Raw()
+	e1 := sd.collection.FindOne(sd.context(), bson.M{"session_id": id}).Decode(doc)
+	if errors.Is(e1, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf(stderr.NoSession, id, fs.ErrNotExist)
+	}
 	if e1 != nil {
 		return nil, fmt.Errorf(stderr.CannotLoadSession, e1.Error())
 	}
 
-	byteAry, e2 := bson.Marshal(bsonDoc)
-	if e2 != nil {
-		return nil, fmt.Errorf(stderr.CannotLoadSession, e2.Error())
+	return doc.Data, nil
+}
+
+// NativeExpiry Report whether the collection has the TTL index made by
+// EnsureIndexes, in which case MongoDB deletes expired sessions, so there is
+// no need for a session.Reaper. The collection is only asked until it
+// answers.
+func (sd *StorageDocument) NativeExpiry() bool {
+	sd.mutex.Lock()
+	known := sd.ttl
+	sd.mutex.Unlock()
+
+	if known != nil {
+		return *known
+	}
+
+	specs, e1 := sd.collection.Indexes().ListSpecifications(sd.context())
+	if e1 != nil {
+		return false
+	}
+
+	enabled := false
+	for _, spec := range specs {
+		_, e := spec.KeysDocument.LookupErr("expiration")
+		if e == nil && spec.ExpireAfterSeconds != nil {
+			enabled = true
+		}
+	}
+
+	sd.mutex.Lock()
+	sd.ttl = &enabled
+	sd.mutex.Unlock()
+
+	return enabled
+}
+
+// Remove A session from the collection.
+func (sd *StorageDocument) Remove(key string) error {
+	if _, e := sd.collection.DeleteOne(sd.context(), bson.M{"session_id": key}); e != nil {
+		return fmt.Errorf(stderr.CannotRemoveSession, key, e.Error())
+	}
+
+	return nil
+}
+
+// Save The session, with the expiration read from the data, see SaveUntil.
+// It fails when the expiration cannot be read.
+func (sd *StorageDocument) Save(name string, data []byte) error {
+	exp, e1 := session.ExpirationOf(data)
+	if e1 != nil {
+		return fmt.Errorf(stderr.NoExpiration, name)
+	}
+
+	return sd.SaveUntil(name, data, exp)
+}
+
+// SaveUntil The session, replacing the document it had, with the expiration
+// for the TTL index.
+func (sd *StorageDocument) SaveUntil(name string, data []byte, expiration time.Time) error {
+	if expiration.IsZero() {
+		return fmt.Errorf(stderr.NoExpiration, name)
 	}
-	return byteAry, nil
+
+	doc := &document{
+		SessionID:  name,
+		Expiration: expiration.UTC(),
+		Data:       data,
+	}
+
+	_, e1 := sd.collection.ReplaceOne(
+		sd.context(),
+		bson.M{"session_id": name},
+		doc,
+		options.Replace().SetUpsert(true),
+	)
+	if e1 != nil {
+		return fmt.Errorf(stderr.CannotSaveSession, e1.Error())
+	}
+
+	return nil
+}
+
+// context The Context field, or a background context when it is not set.
+func (sd *StorageDocument) context() context.Context {
+	if sd.Context == nil {
+		return context.Background()
+	}
+
+	return sd.Context
 }
@@ -0,0 +1,200 @@
+package mongodb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/kohirens/www/session"
+	"github.com/kohirens/www/session/sessiontest"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func newTestStorage(mt *mtest.T) *StorageDocument {
+	return &StorageDocument{Context: context.Background(), collection: mt.Coll}
+}
+
+// MockCollection A collection in memory, keyed by session_id.
+type MockCollection struct {
+	docs map[string]bson.Raw
+}
+
+func (m *MockCollection) DeleteOne(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	id := filter.(bson.M)["session_id"].(string)
+	_, ok := m.docs[id]
+	delete(m.docs, id)
+	if ok {
+		return &mongo.DeleteResult{DeletedCount: 1}, nil
+	}
+	return &mongo.DeleteResult{}, nil
+}
+
+func (m *MockCollection) FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) *mongo.SingleResult {
+	doc, ok := m.docs[filter.(bson.M)["session_id"].(string)]
+	if !ok {
+		return mongo.NewSingleResultFromDocument(bson.D{}, mongo.ErrNoDocuments, nil)
+	}
+	return mongo.NewSingleResultFromDocument(doc, nil, nil)
+}
+
+func (m *MockCollection) Indexes() mongo.IndexView {
+	return mongo.IndexView{}
+}
+
+func (m *MockCollection) ReplaceOne(ctx context.Context, filter interface{}, replacement interface{}, opts ...*options.ReplaceOptions) (*mongo.UpdateResult, error) {
+	doc, e1 := bson.Marshal(replacement)
+	if e1 != nil {
+		return nil, e1
+	}
+	m.docs[filter.(bson.M)["session_id"].(string)] = doc
+	return &mongo.UpdateResult{UpsertedCount: 1}, nil
+}
+
+func TestStorageDocument(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	exp := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	data, _ := json.Marshal(&session.Data{Expiration: exp})
+	ns := "db.sessions"
+
+	mt.Run("save", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		if e := newTestStorage(mt).Save("s1.json", data); e != nil {
+			mt.Fatalf("Save() error = %v", e)
+		}
+
+		cmd := mt.GetStartedEvent().Command
+		update := cmd.Lookup("updates").Array().Index(0).Value().Document()
+
+		if got := update.Lookup("q", "session_id").StringValue(); got != "s1.json" {
+			mt.Errorf("Save() queried session_id %v, want s1.json", got)
+		}
+		if !update.Lookup("upsert").Boolean() {
+			mt.Errorf("Save() did not upsert")
+		}
+		if got := update.Lookup("u", "expiration").Time().UTC(); !got.Equal(exp) {
+			mt.Errorf("Save() expiration = %v, want %v", got, exp)
+		}
+		if _, got := update.Lookup("u", "data").Binary(); !bytes.Equal(got, data) {
+			mt.Errorf("Save() data = %s, want %s", got, data)
+		}
+	})
+
+	mt.Run("save-unreadable", func(mt *mtest.T) {
+		if e := newTestStorage(mt).Save("s1.json", []byte{1, 2, 3}); e == nil {
+			mt.Errorf("Save() of a payload it cannot read did not fail")
+		}
+	})
+
+	mt.Run("save-encrypted", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		c, _ := session.NewAESGCM("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+		es := session.NewEncryptedStorage(newTestStorage(mt), c)
+
+		if e := es.Save("s1.json", data); e != nil {
+			mt.Fatalf("Save() error = %v", e)
+		}
+
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		if got := update.Lookup("u", "expiration").Time().UTC(); !got.Equal(exp) {
+			mt.Errorf("Save() expiration = %v, want %v", got, exp)
+		}
+		if _, got := update.Lookup("u", "data").Binary(); bytes.Equal(got, data) {
+			mt.Errorf("Save() stored the session in plain text")
+		}
+	})
+
+	mt.Run("native-expiry", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, ns, mtest.FirstBatch,
+			bson.D{{Key: "name", Value: "_id_"}, {Key: "key", Value: bson.D{{Key: "_id", Value: 1}}}},
+			bson.D{
+				{Key: "name", Value: "expiration_1"},
+				{Key: "key", Value: bson.D{{Key: "expiration", Value: 1}}},
+				{Key: "expireAfterSeconds", Value: int32(0)},
+			},
+		))
+
+		if !newTestStorage(mt).NativeExpiry() {
+			mt.Errorf("NativeExpiry() = false, want true with a TTL index")
+		}
+	})
+
+	mt.Run("native-expiry-no-index", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, ns, mtest.FirstBatch,
+			bson.D{{Key: "name", Value: "_id_"}, {Key: "key", Value: bson.D{{Key: "_id", Value: 1}}}},
+		))
+
+		if newTestStorage(mt).NativeExpiry() {
+			mt.Errorf("NativeExpiry() = true, want false without a TTL index")
+		}
+	})
+
+	mt.Run("load", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, bson.D{
+			{Key: "session_id", Value: "s1.json"},
+			{Key: "expiration", Value: primitive.NewDateTimeFromTime(exp)},
+			{Key: "data", Value: data},
+		}))
+
+		got, e1 := newTestStorage(mt).Load("s1.json")
+		if e1 != nil {
+			mt.Fatalf("Load() error = %v", e1)
+		}
+
+		if !bytes.Equal(got, data) {
+			mt.Errorf("Load() = %s, want %s", got, data)
+		}
+
+		if _, e := session.ExpirationOf(got); e != nil {
+			mt.Errorf("Load() did not return a session as JSON: %v", e)
+		}
+	})
+
+	mt.Run("load-not-found", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, ns, mtest.FirstBatch))
+
+		if _, e := newTestStorage(mt).Load("s1.json"); e == nil {
+			mt.Errorf("Load() of a missing session did not fail")
+		}
+	})
+
+	mt.Run("remove", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		if e := newTestStorage(mt).Remove("s1.json"); e != nil {
+			mt.Fatalf("Remove() error = %v", e)
+		}
+
+		if got := mt.GetStartedEvent().CommandName; got != "delete" {
+			mt.Errorf("Remove() sent %v, want delete", got)
+		}
+	})
+
+	mt.Run("ensure-indexes", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		if e := newTestStorage(mt).EnsureIndexes(); e != nil {
+			mt.Fatalf("EnsureIndexes() error = %v", e)
+		}
+
+		indexes := mt.GetStartedEvent().Command.Lookup("indexes").Array()
+		ttl := indexes.Index(1).Value().Document()
+		if _, e := ttl.LookupErr("expireAfterSeconds"); e != nil {
+			mt.Errorf("EnsureIndexes() did not make a TTL index: %v", ttl)
+		}
+	})
+}
+
+func TestStorageDocument_Conformance(t *testing.T) {
+	sessiontest.Run(t, func(t *testing.T) session.Storage {
+		return NewStorageDocumentWithCollection(context.Background(), &MockCollection{docs: map[string]bson.Raw{}})
+	})
+}
@@ -0,0 +1,653 @@
+package session
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// msgpackCodec A subset of MessagePack, see https://msgpack.org.
+type msgpackCodec struct{}
+
+// msgpackMap A decoded map, in the order it was encoded.
+type msgpackMap struct {
+	keys   []any
+	values []any
+}
+
+var textMarshaler = reflect.TypeFor[encoding.TextMarshaler]()
+var textUnmarshaler = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+func (msgpackCodec) Marshal(v any) ([]byte, error) {
+	return appendMsgPack(nil, reflect.ValueOf(v))
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf(stderr.MsgPackTarget, v)
+	}
+
+	d := &msgpackDecoder{data: data}
+	src, e1 := d.value()
+	if e1 != nil {
+		return e1
+	}
+
+	if d.pos != len(data) {
+		return fmt.Errorf(stderr.MsgPackDecode, "extra data after the value")
+	}
+
+	return assignMsgPack(rv.Elem(), src)
+}
+
+// appendMsgPack Encode a value on to b.
+func appendMsgPack(b []byte, v reflect.Value) ([]byte, error) {
+	if !v.IsValid() {
+		return append(b, 0xc0), nil
+	}
+
+	nilable := v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface
+	if v.Type().Implements(textMarshaler) && !(nilable && v.IsNil()) {
+		text, e := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if e != nil {
+			return nil, e
+		}
+		return appendMsgPackString(b, string(text)), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendMsgPackInt(b, v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return appendMsgPackUint(b, v.Uint()), nil
+	case reflect.Float32:
+		return binary.BigEndian.AppendUint32(append(b, 0xca), math.Float32bits(float32(v.Float()))), nil
+	case reflect.Float64:
+		return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(v.Float())), nil
+	case reflect.String:
+		return appendMsgPackString(b, v.String()), nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return append(b, 0xc0), nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return appendMsgPackBin(b, v.Bytes()), nil
+		}
+		return appendMsgPackArray(b, v)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			data := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(data), v)
+			return appendMsgPackBin(b, data), nil
+		}
+		return appendMsgPackArray(b, v)
+	case reflect.Map:
+		if v.IsNil() {
+			return append(b, 0xc0), nil
+		}
+		return appendMsgPackMap(b, v)
+	case reflect.Struct:
+		return appendMsgPackStruct(b, v)
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return append(b, 0xc0), nil
+		}
+		return appendMsgPack(b, v.Elem())
+	}
+
+	return nil, fmt.Errorf(stderr.MsgPackType, v.Type())
+}
+
+func appendMsgPackArray(b []byte, v reflect.Value) ([]byte, error) {
+	b = appendMsgPackHeader(b, v.Len(), 0x90, 16, 0xdc, 0xdd)
+
+	for i := 0; i < v.Len(); i++ {
+		var e error
+		if b, e = appendMsgPack(b, v.Index(i)); e != nil {
+			return nil, e
+		}
+	}
+
+	return b, nil
+}
+
+func appendMsgPackBin(b []byte, data []byte) []byte {
+	switch n := len(data); {
+	case n <= math.MaxUint8:
+		b = append(b, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xc5), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xc6), uint32(n))
+	}
+
+	return append(b, data...)
+}
+
+// appendMsgPackHeader The header of a string, array or map of n items, with
+// the n in the fixed byte when it is less than fixMax.
+func appendMsgPackHeader(b []byte, n int, fix byte, fixMax int, b16, b32 byte) []byte {
+	switch {
+	case n < fixMax:
+		return append(b, fix|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, b16), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, b32), uint32(n))
+	}
+}
+
+func appendMsgPackInt(b []byte, i int64) []byte {
+	switch {
+	case i >= 0:
+		return appendMsgPackUint(b, uint64(i))
+	case i >= -32:
+		return append(b, byte(int8(i)))
+	case i >= math.MinInt8:
+		return append(b, 0xd0, byte(int8(i)))
+	case i >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(int16(i)))
+	case i >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(int32(i)))
+	}
+
+	return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(i))
+}
+
+func appendMsgPackMap(b []byte, v reflect.Value) ([]byte, error) {
+	type pair struct{ key, value []byte }
+
+	// Sort by the encoded keys, so that a map always encodes the same.
+	pairs := make([]pair, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		k, e1 := appendMsgPack(nil, iter.Key())
+		if e1 != nil {
+			return nil, e1
+		}
+		val, e2 := appendMsgPack(nil, iter.Value())
+		if e2 != nil {
+			return nil, e2
+		}
+		pairs = append(pairs, pair{k, val})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return bytes.Compare(pairs[i].key, pairs[j].key) < 0 })
+
+	b = appendMsgPackHeader(b, len(pairs), 0x80, 16, 0xde, 0xdf)
+	for _, p := range pairs {
+		b = append(append(b, p.key...), p.value...)
+	}
+
+	return b, nil
+}
+
+func appendMsgPackString(b []byte, s string) []byte {
+	if len(s) < 32 || len(s) > math.MaxUint8 {
+		b = appendMsgPackHeader(b, len(s), 0xa0, 32, 0xda, 0xdb)
+	} else {
+		b = append(b, 0xd9, byte(len(s)))
+	}
+
+	return append(b, s...)
+}
+
+func appendMsgPackStruct(b []byte, v reflect.Value) ([]byte, error) {
+	fields := msgpackFields(v.Type())
+
+	b = appendMsgPackHeader(b, len(fields), 0x80, 16, 0xde, 0xdf)
+	for _, f := range fields {
+		b = appendMsgPackString(b, f.name)
+
+		var e error
+		if b, e = appendMsgPack(b, v.Field(f.index)); e != nil {
+			return nil, e
+		}
+	}
+
+	return b, nil
+}
+
+func appendMsgPackUint(b []byte, u uint64) []byte {
+	switch {
+	case u <= 0x7f:
+		return append(b, byte(u))
+	case u <= math.MaxUint8:
+		return append(b, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(u))
+	case u <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(u))
+	}
+
+	return binary.BigEndian.AppendUint64(append(b, 0xcf), u)
+}
+
+// msgpackField An exported field of a struct and the name it is kept under.
+type msgpackField struct {
+	index int
+	name  string
+}
+
+func msgpackFields(t reflect.Type) []msgpackField {
+	fields := make([]msgpackField, 0, t.NumField())
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("msgpack"); ok {
+			tag, _, _ = strings.Cut(tag, ",")
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+
+		fields = append(fields, msgpackField{i, name})
+	}
+
+	return fields
+}
+
+// msgpackDecoder Reads values from MessagePack data.
+type msgpackDecoder struct {
+	data []byte
+	pos  int
+}
+
+// next The next n bytes.
+func (d *msgpackDecoder) next(n int) ([]byte, error) {
+	if n < 0 || n > len(d.data)-d.pos {
+		return nil, fmt.Errorf(stderr.MsgPackDecode, "unexpected end of data")
+	}
+
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+
+	return b, nil
+}
+
+// length A length of 1, 2 or 4 bytes.
+func (d *msgpackDecoder) length(size int) (int, error) {
+	b, e1 := d.next(size)
+	if e1 != nil {
+		return 0, e1
+	}
+
+	switch size {
+	case 1:
+		return int(b[0]), nil
+	case 2:
+		return int(binary.BigEndian.Uint16(b)), nil
+	}
+
+	return int(binary.BigEndian.Uint32(b)), nil
+}
+
+// value Read the next value, as nil, bool, int64, uint64, float32, float64,
+// string, []byte, []any or *msgpackMap.
+func (d *msgpackDecoder) value() (any, error) {
+	b, e1 := d.next(1)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	c := b[0]
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return d.mapValue(int(c & 0x0f))
+	case c&0xf0 == 0x90:
+		return d.array(int(c & 0x0f))
+	case c&0xe0 == 0xa0:
+		return d.str(int(c & 0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, e := d.length(1 << (c - 0xc4))
+		if e != nil {
+			return nil, e
+		}
+		data, e2 := d.next(n)
+		if e2 != nil {
+			return nil, e2
+		}
+		return bytes.Clone(data), nil
+	case 0xca:
+		data, e := d.next(4)
+		if e != nil {
+			return nil, e
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(data)), nil
+	case 0xcb:
+		data, e := d.next(8)
+		if e != nil {
+			return nil, e
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(data)), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		data, e := d.next(1 << (c - 0xcc))
+		if e != nil {
+			return nil, e
+		}
+		var u uint64
+		for _, x := range data {
+			u = u<<8 | uint64(x)
+		}
+		return u, nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		data, e := d.next(size)
+		if e != nil {
+			return nil, e
+		}
+		var u uint64
+		for _, x := range data {
+			u = u<<8 | uint64(x)
+		}
+		// Sign extend from the size of the value.
+		shift := 64 - 8*size
+		return int64(u<<shift) >> shift, nil
+	case 0xd9, 0xda, 0xdb:
+		n, e := d.length(1 << (c - 0xd9))
+		if e != nil {
+			return nil, e
+		}
+		return d.str(n)
+	case 0xdc, 0xdd:
+		n, e := d.length(2 << (c - 0xdc))
+		if e != nil {
+			return nil, e
+		}
+		return d.array(n)
+	case 0xde, 0xdf:
+		n, e := d.length(2 << (c - 0xde))
+		if e != nil {
+			return nil, e
+		}
+		return d.mapValue(n)
+	}
+
+	return nil, fmt.Errorf(stderr.MsgPackDecode, fmt.Sprintf("unsupported type 0x%02x", c))
+}
+
+func (d *msgpackDecoder) array(n int) (any, error) {
+	// Each item takes at least a byte, so more than are left is corrupt.
+	if n > len(d.data)-d.pos {
+		return nil, fmt.Errorf(stderr.MsgPackDecode, "unexpected end of data")
+	}
+
+	items := make([]any, n)
+	for i := range items {
+		v, e := d.value()
+		if e != nil {
+			return nil, e
+		}
+		items[i] = v
+	}
+
+	return items, nil
+}
+
+func (d *msgpackDecoder) mapValue(n int) (any, error) {
+	if n > (len(d.data)-d.pos)/2 {
+		return nil, fmt.Errorf(stderr.MsgPackDecode, "unexpected end of data")
+	}
+
+	m := &msgpackMap{keys: make([]any, n), values: make([]any, n)}
+	for i := 0; i < n; i++ {
+		k, e1 := d.value()
+		if e1 != nil {
+			return nil, e1
+		}
+		v, e2 := d.value()
+		if e2 != nil {
+			return nil, e2
+		}
+		m.keys[i], m.values[i] = k, v
+	}
+
+	return m, nil
+}
+
+func (d *msgpackDecoder) str(n int) (any, error) {
+	b, e1 := d.next(n)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	return string(b), nil
+}
+
+// assignMsgPack Set dst to a decoded value.
+func assignMsgPack(dst reflect.Value, src any) error {
+	if s, ok := src.(string); ok && dst.Kind() != reflect.Pointer && dst.CanAddr() && dst.Addr().Type().Implements(textUnmarshaler) {
+		return dst.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	if src == nil {
+		dst.SetZero()
+		return nil
+	}
+
+	mismatch := fmt.Errorf(stderr.MsgPackAssign, src, dst.Type())
+
+	switch dst.Kind() {
+	case reflect.Pointer:
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return assignMsgPack(dst.Elem(), src)
+	case reflect.Interface:
+		if dst.NumMethod() != 0 {
+			return mismatch
+		}
+		dst.Set(reflect.ValueOf(msgpackNatural(src)))
+		return nil
+	case reflect.Bool:
+		b, ok := src.(bool)
+		if !ok {
+			return mismatch
+		}
+		dst.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		switch n := src.(type) {
+		case int64:
+			i = n
+		case uint64:
+			if n > math.MaxInt64 {
+				return mismatch
+			}
+			i = int64(n)
+		default:
+			return mismatch
+		}
+		if dst.OverflowInt(i) {
+			return mismatch
+		}
+		dst.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var u uint64
+		switch n := src.(type) {
+		case int64:
+			if n < 0 {
+				return mismatch
+			}
+			u = uint64(n)
+		case uint64:
+			u = n
+		default:
+			return mismatch
+		}
+		if dst.OverflowUint(u) {
+			return mismatch
+		}
+		dst.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		switch n := src.(type) {
+		case float32:
+			dst.SetFloat(float64(n))
+		case float64:
+			dst.SetFloat(n)
+		case int64:
+			dst.SetFloat(float64(n))
+		case uint64:
+			dst.SetFloat(float64(n))
+		default:
+			return mismatch
+		}
+		return nil
+	case reflect.String:
+		switch s := src.(type) {
+		case string:
+			dst.SetString(s)
+		case []byte:
+			dst.SetString(string(s))
+		default:
+			return mismatch
+		}
+		return nil
+	case reflect.Slice:
+		if dst.Type().Elem().Kind() == reflect.Uint8 {
+			switch s := src.(type) {
+			case []byte:
+				dst.SetBytes(s)
+				return nil
+			case string:
+				dst.SetBytes([]byte(s))
+				return nil
+			}
+		}
+		items, ok := src.([]any)
+		if !ok {
+			return mismatch
+		}
+		dst.Set(reflect.MakeSlice(dst.Type(), len(items), len(items)))
+		for i, item := range items {
+			if e := assignMsgPack(dst.Index(i), item); e != nil {
+				return e
+			}
+		}
+		return nil
+	case reflect.Array:
+		if data, ok := src.([]byte); ok && dst.Type().Elem().Kind() == reflect.Uint8 {
+			if len(data) != dst.Len() {
+				return mismatch
+			}
+			reflect.Copy(dst, reflect.ValueOf(data))
+			return nil
+		}
+		items, ok := src.([]any)
+		if !ok || len(items) != dst.Len() {
+			return mismatch
+		}
+		for i, item := range items {
+			if e := assignMsgPack(dst.Index(i), item); e != nil {
+				return e
+			}
+		}
+		return nil
+	case reflect.Map:
+		m, ok := src.(*msgpackMap)
+		if !ok {
+			return mismatch
+		}
+		dst.Set(reflect.MakeMapWithSize(dst.Type(), len(m.keys)))
+		for i := range m.keys {
+			k := reflect.New(dst.Type().Key()).Elem()
+			if e := assignMsgPack(k, m.keys[i]); e != nil {
+				return e
+			}
+			v := reflect.New(dst.Type().Elem()).Elem()
+			if e := assignMsgPack(v, m.values[i]); e != nil {
+				return e
+			}
+			dst.SetMapIndex(k, v)
+		}
+		return nil
+	case reflect.Struct:
+		m, ok := src.(*msgpackMap)
+		if !ok {
+			return mismatch
+		}
+		fields := msgpackFields(dst.Type())
+		for i, k := range m.keys {
+			name, _ := k.(string)
+			for _, f := range fields {
+				if f.name != name {
+					continue
+				}
+				if e := assignMsgPack(dst.Field(f.index), m.values[i]); e != nil {
+					return e
+				}
+				break
+			}
+		}
+		return nil
+	}
+
+	return mismatch
+}
+
+// msgpackNatural A decoded value as the Go types an any would hold, maps
+// become map[string]any when all the keys are strings.
+func msgpackNatural(src any) any {
+	switch v := src.(type) {
+	case []any:
+		for i, item := range v {
+			v[i] = msgpackNatural(item)
+		}
+		return v
+	case *msgpackMap:
+		strKeys := make(map[string]any, len(v.keys))
+		anyKeys := make(map[any]any, len(v.keys))
+		allStrings := true
+		for i, k := range v.keys {
+			value := msgpackNatural(v.values[i])
+			if s, ok := k.(string); ok {
+				strKeys[s] = value
+			} else {
+				allStrings = false
+			}
+			if reflect.TypeOf(k) != nil && reflect.TypeOf(k).Comparable() {
+				anyKeys[k] = value
+			}
+		}
+		if allStrings {
+			return strKeys
+		}
+		return anyKeys
+	}
+
+	return src
+}
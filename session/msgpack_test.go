@@ -0,0 +1,85 @@
+package session
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMsgPackCodec_Marshal(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  []byte
+	}{
+		{"nil", nil, []byte{0xc0}},
+		{"true", true, []byte{0xc3}},
+		{"fixint", 7, []byte{0x07}},
+		{"negative-fixint", -1, []byte{0xff}},
+		{"uint8", 200, []byte{0xcc, 0xc8}},
+		{"int8", -100, []byte{0xd0, 0x9c}},
+		{"uint16", 0x1234, []byte{0xcd, 0x12, 0x34}},
+		{"int64", int64(math.MinInt64), []byte{0xd3, 0x80, 0, 0, 0, 0, 0, 0, 0}},
+		{"float64", 1.5, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{"fixstr", "hi", []byte{0xa2, 'h', 'i'}},
+		{"str8", strings.Repeat("a", 32), append([]byte{0xd9, 32}, strings.Repeat("a", 32)...)},
+		{"bin", []byte{1, 2}, []byte{0xc4, 0x02, 1, 2}},
+		{"array", []int{1, 2}, []byte{0x92, 0x01, 0x02}},
+		{"map", map[string]int{"b": 2, "a": 1}, []byte{0x82, 0xa1, 'a', 0x01, 0xa1, 'b', 0x02}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, e1 := MsgPackCodec.Marshal(tt.value)
+			if e1 != nil {
+				t.Fatalf("Marshal() error = %v", e1)
+			}
+
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("Marshal() = % x, want % x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMsgPackCodec_Unmarshal(t *testing.T) {
+	var n int8
+	if e := MsgPackCodec.Unmarshal([]byte{0xcc, 0xc8}, &n); e == nil {
+		t.Errorf("Unmarshal() of 200 into an int8 did not fail")
+	}
+
+	var v any
+	if e := MsgPackCodec.Unmarshal([]byte{0x81, 0xa1, 'a', 0x92, 0x01, 0xc3}, &v); e != nil {
+		t.Fatalf("Unmarshal() error = %v", e)
+	}
+
+	want := map[string]any{"a": []any{int64(1), true}}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("Unmarshal() = %#v, want %#v", v, want)
+	}
+
+	for _, data := range [][]byte{{}, {0xd9, 0x05, 'a'}, {0xdd, 0xff, 0xff, 0xff, 0xff}, {0xc1}, {0x01, 0x02}} {
+		if e := MsgPackCodec.Unmarshal(data, &v); e == nil {
+			t.Errorf("Unmarshal(% x) did not fail", data)
+		}
+	}
+
+	if e := MsgPackCodec.Unmarshal([]byte{0x01}, v); e == nil {
+		t.Errorf("Unmarshal() into a non-pointer did not fail")
+	}
+}
+
+func FuzzMsgPackCodec_Unmarshal(f *testing.F) {
+	seed, _ := MsgPackCodec.Marshal(&testCart{Items: []string{"a"}, Counts: map[string]int{"a": 1}, Owner: &testOwner{}})
+	f.Add(seed)
+	f.Add([]byte{0xdf, 0x7f, 0xff, 0xff, 0xff})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var cart testCart
+		_ = MsgPackCodec.Unmarshal(data, &cart)
+
+		var v any
+		_ = MsgPackCodec.Unmarshal(data, &v)
+	})
+}
@@ -0,0 +1,76 @@
+package session
+
+import "time"
+
+// DefaultPolicy The limits of a Manager with a nil Policy, or with a Policy
+// that has neither an Idle nor an Absolute limit.
+var DefaultPolicy = Policy{Absolute: 24 * time.Hour, Idle: 30 * time.Minute}
+
+// Policy Limits how long a session lasts. A session expires at whichever
+// comes first: Idle after the last request, or Absolute after it was
+// created. Leave a limit at zero to turn it off, when both are off the
+// limits of DefaultPolicy apply.
+type Policy struct {
+	// Absolute The maximum lifetime of a session, no matter how active it
+	// is.
+	Absolute time.Duration
+	// Idle How long a session lasts without a request. Each request extends
+	// the session by Idle, up to the Absolute limit.
+	Idle time.Duration
+	// RememberMe Replaces both limits for sessions where the user asked to be
+	// remembered, see Manager.SetRememberMe. Zero means remember me has no
+	// effect.
+	RememberMe time.Duration
+	// Warn OnExpiring is called when a request comes in with less than this
+	// time left on the session.
+	Warn time.Duration
+
+	// OnExpiring Called when a session has less than Warn left, for example
+	// to tell the client to re-authenticate before it is logged out.
+	OnExpiring func(m *Manager, remaining time.Duration)
+	// OnExtended Called when a request pushes the expiration of a session
+	// forward.
+	OnExtended func(m *Manager, previous, expiration time.Time)
+}
+
+// expiration When a session expires under the policy.
+func (p *Policy) expiration(d *Data) time.Time {
+	idle, absolute := p.Idle, p.Absolute
+	if d.RememberMe && p.RememberMe != 0 {
+		idle, absolute = p.RememberMe, p.RememberMe
+	}
+
+	if idle == 0 && absolute == 0 {
+		idle, absolute = DefaultPolicy.Idle, DefaultPolicy.Absolute
+	}
+
+	var exp time.Time
+	if idle != 0 {
+		exp = d.LastActivity.Add(idle)
+	}
+
+	if absolute != 0 {
+		limit := d.Created.Add(absolute)
+		if exp.IsZero() || limit.Before(exp) {
+			exp = limit
+		}
+	}
+
+	if exp.IsZero() {
+		// DefaultPolicy has no limits either, keep what the session already
+		// has.
+		return d.Expiration
+	}
+
+	return exp.UTC()
+}
+
+// orDefault The policy, or a copy of DefaultPolicy when it is nil.
+func (p *Policy) orDefault() *Policy {
+	if p == nil {
+		d := DefaultPolicy
+		return &d
+	}
+
+	return p
+}
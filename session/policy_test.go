@@ -0,0 +1,139 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPolicy_expiration(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		policy       *Policy
+		lastActivity time.Duration // after start
+		rememberMe   bool
+		want         time.Duration // after start
+	}{
+		{"absolute-only", &Policy{Absolute: time.Hour}, 30 * time.Minute, false, time.Hour},
+		{"idle-only", &Policy{Idle: 10 * time.Minute}, 30 * time.Minute, false, 40 * time.Minute},
+		{"idle-before-absolute", &Policy{Idle: 10 * time.Minute, Absolute: time.Hour}, 0, false, 10 * time.Minute},
+		{"absolute-caps-idle", &Policy{Idle: 10 * time.Minute, Absolute: time.Hour}, 55 * time.Minute, false, time.Hour},
+		{"remember-me", &Policy{Idle: 10 * time.Minute, Absolute: time.Hour, RememberMe: 720 * time.Hour}, 0, true, 720 * time.Hour},
+		{"remember-me-unset", &Policy{Idle: 10 * time.Minute, Absolute: time.Hour}, 0, true, 10 * time.Minute},
+		{"no-limits", &Policy{}, 0, false, DefaultPolicy.Idle},
+		{"no-limits-remember-me", &Policy{}, 0, true, DefaultPolicy.Idle},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &Data{
+				Created:      start,
+				LastActivity: start.Add(tt.lastActivity),
+				RememberMe:   tt.rememberMe,
+			}
+
+			if got := tt.policy.expiration(d); !got.Equal(start.Add(tt.want)) {
+				t.Errorf("Policy.expiration() = %v, want %v", got, start.Add(tt.want))
+			}
+		})
+	}
+}
+
+func TestNewManagerWithPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy *Policy
+	}{
+		{"nil", nil},
+		{"zero", &Policy{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManagerWithPolicy(&MockStorage{}, "", tt.policy)
+
+			if m.HasExpired() {
+				t.Errorf("NewManagerWithPolicy() HasExpired() = true, want false")
+				return
+			}
+
+			if got := m.Remaining(); got < DefaultPolicy.Idle-time.Minute || got > DefaultPolicy.Idle {
+				t.Errorf("NewManagerWithPolicy() Remaining() = %v, want about %v", got, DefaultPolicy.Idle)
+				return
+			}
+
+			m.Touch()
+
+			if got := NewSessionStoreWithPolicy(&MockStorage{}, "", tt.policy).New(); got.HasExpired() {
+				t.Errorf("SessionStore.New() HasExpired() = true, want false")
+			}
+		})
+	}
+}
+
+func TestManager_Touch(t *testing.T) {
+	var extended, expiring bool
+	policy := &Policy{
+		Idle:       10 * time.Minute,
+		Absolute:   time.Hour,
+		Warn:       5 * time.Minute,
+		OnExtended: func(m *Manager, previous, expiration time.Time) { extended = true },
+		OnExpiring: func(m *Manager, remaining time.Duration) { expiring = true },
+	}
+
+	tests := []struct {
+		name         string
+		created      time.Duration // before now
+		lastActivity time.Duration // before now
+		wantExtended bool
+		wantExpiring bool
+	}{
+		{"extends-idle", 20 * time.Minute, 5 * time.Minute, true, false},
+		{"near-absolute-limit", 58 * time.Minute, time.Minute, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extended, expiring = false, false
+			m := NewManagerWithPolicy(&MockStorage{}, "", policy)
+			now := time.Now().UTC()
+			m.data.Created = now.Add(-tt.created)
+			m.data.LastActivity = now.Add(-tt.lastActivity)
+			m.data.Expiration = policy.expiration(m.data)
+			before := m.Expiration()
+
+			m.Touch()
+
+			if extended != tt.wantExtended || m.Expiration().After(before) != tt.wantExtended {
+				t.Errorf("Manager.Touch() extended = %v, want %v", extended, tt.wantExtended)
+				return
+			}
+
+			if m.reissueCookie != tt.wantExtended {
+				t.Errorf("Manager.Touch() reissueCookie = %v, want %v", m.reissueCookie, tt.wantExtended)
+				return
+			}
+
+			if expiring != tt.wantExpiring {
+				t.Errorf("Manager.Touch() expiring = %v, want %v", expiring, tt.wantExpiring)
+			}
+		})
+	}
+}
+
+func TestManager_SetRememberMe(t *testing.T) {
+	m := NewManagerWithPolicy(&MockStorage{}, "", &Policy{
+		Idle:       10 * time.Minute,
+		RememberMe: 720 * time.Hour,
+	})
+
+	m.SetRememberMe(true)
+
+	if got := m.Remaining(); got < 719*time.Hour {
+		t.Errorf("Manager.SetRememberMe() Remaining() = %v, want about 720h", got)
+		return
+	}
+
+	c := m.IDCookie("/", "")
+	if !c.Expires.Equal(m.Expiration()) {
+		t.Errorf("Manager.IDCookie() Expires = %v, want %v", c.Expires, m.Expiration())
+	}
+}
@@ -0,0 +1,175 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Lister An optional interface for a Storage that can enumerate the sessions
+// it holds, which the Reaper needs. LocalStorage, storage.LocalStorage and
+// storage.BucketStorage implement it.
+type Lister interface {
+	// List The names of the files in a location, relative to it.
+	List(location string) ([]string, error)
+}
+
+// ExpirySaver An optional interface for a Storage that needs to know when a
+// session expires. Manager.Save calls SaveUntil instead of Save with the
+// Expiration of the session, because the storage cannot always read it from
+// the data, such as when it is encrypted by an EncryptedStorage.
+type ExpirySaver interface {
+	SaveUntil(id string, data []byte, expiration time.Time) error
+}
+
+// NativeExpirer An optional interface for a Storage that deletes expired
+// sessions itself, such as with a DynamoDB TTL attribute or a MongoDB TTL
+// index. The Reaper skips such storage. A Storage that reports NativeExpiry
+// should implement ExpirySaver too, to expire sessions it cannot read.
+type NativeExpirer interface {
+	NativeExpiry() bool
+}
+
+// Reaper Removes sessions from storage that are past their Expiration plus
+// a grace period. Neither Manager.Reset nor an expired cookie removes the
+// old record, so without a reaper, storage grows forever.
+type Reaper struct {
+	// Grace Time after a session expires before it is removed, this leaves
+	// time to look into a session that just expired.
+	Grace    time.Duration
+	location string
+	storage  Storage
+}
+
+// SweepResult What a sweep did.
+type SweepResult struct {
+	// Checked Sessions that were loaded.
+	Checked int `json:"checked"`
+	// Failed Sessions that could not be loaded, decoded or removed.
+	Failed  int `json:"failed"`
+	Removed int `json:"removed"`
+}
+
+// reaperNow Allows tests to control the clock.
+var reaperNow = time.Now
+
+// NewReaper Initialize a reaper for the sessions that a Manager made with
+// the same storage and location.
+func NewReaper(storage Storage, location string, grace time.Duration) *Reaper {
+	return &Reaper{
+		Grace:    grace,
+		location: location,
+		storage:  storage,
+	}
+}
+
+// ExpirationOf Read the Expiration from a session saved by Manager.Save.
+func ExpirationOf(data []byte) (time.Time, error) {
+	d := &Data{}
+	if e := json.Unmarshal(data, d); e != nil {
+		return time.Time{}, fmt.Errorf(stderr.DecodeSession, e.Error())
+	}
+
+	return d.Expiration, nil
+}
+
+// Handler Sweep once, use it as the handler of a Lambda function that runs
+// on a schedule. For example, with github.com/aws/aws-lambda-go:
+//
+//	reaper := session.NewReaper(store, "sessions", time.Hour)
+//	lambda.Start(reaper.Handler)
+func (r *Reaper) Handler(ctx context.Context) (*SweepResult, error) {
+	return r.Sweep(ctx)
+}
+
+// Run Sweep every interval until ctx is done, use it as a background
+// goroutine on long-running servers:
+//
+//	go reaper.Run(ctx, 15*time.Minute)
+func (r *Reaper) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		res, e1 := r.Sweep(ctx)
+		if e1 != nil {
+			Log.Errf(stderr.Sweep, e1.Error())
+		} else {
+			Log.Infof(stdout.Swept, res.Removed, res.Checked, res.Failed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep Remove the expired sessions in storage. A session that cannot be
+// loaded or removed is counted as failed and the sweep moves on, so that one
+// bad record does not stop the rest from being cleaned up.
+func (r *Reaper) Sweep(ctx context.Context) (*SweepResult, error) {
+	res := &SweepResult{}
+
+	if ne, ok := r.storage.(NativeExpirer); ok && ne.NativeExpiry() {
+		return res, nil
+	}
+
+	lister, ok := r.storage.(Lister)
+	if !ok {
+		return nil, fmt.Errorf("%v", stderr.NotListable)
+	}
+
+	names, e1 := lister.List(r.location)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	cutoff := reaperNow().Add(-r.Grace)
+
+	for _, name := range names {
+		if e := ctx.Err(); e != nil {
+			return res, e
+		}
+
+		if !strings.HasSuffix(name, Suffix) {
+			continue
+		}
+
+		key := name
+		if r.location != "" {
+			key = r.location + "/" + name
+		}
+
+		data, e2 := r.storage.Load(key)
+		if e2 != nil {
+			Log.Warnf(stderr.SweepSession, key, e2.Error())
+			res.Failed++
+			continue
+		}
+		res.Checked++
+
+		exp, e3 := ExpirationOf(data)
+		if e3 != nil {
+			Log.Warnf(stderr.SweepSession, key, e3.Error())
+			res.Failed++
+			continue
+		}
+
+		if !exp.Before(cutoff) {
+			continue
+		}
+
+		if e := r.storage.Remove(key); e != nil {
+			Log.Warnf(stderr.SweepSession, key, e.Error())
+			res.Failed++
+			continue
+		}
+		res.Removed++
+	}
+
+	return res, nil
+}
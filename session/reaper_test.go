@@ -0,0 +1,136 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// MockListStorage Keeps sessions in memory and can list them.
+type MockListStorage struct {
+	data      map[string][]byte
+	removeErr error
+}
+
+func (ms *MockListStorage) List(location string) ([]string, error) {
+	names := make([]string, 0, len(ms.data))
+	for key := range ms.data {
+		if name, ok := strings.CutPrefix(key, location+"/"); ok {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func (ms *MockListStorage) Load(id string) ([]byte, error) {
+	b, ok := ms.data[id]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return b, nil
+}
+
+func (ms *MockListStorage) Remove(key string) error {
+	if ms.removeErr != nil {
+		return ms.removeErr
+	}
+	delete(ms.data, key)
+	return nil
+}
+
+func (ms *MockListStorage) Save(id string, data []byte) error {
+	ms.data[id] = data
+	return nil
+}
+
+func TestReaper_Sweep(t *testing.T) {
+	clock := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	reaperNow = func() time.Time { return clock }
+	t.Cleanup(func() { reaperNow = time.Now })
+
+	session := func(exp time.Time) []byte {
+		d := newData(&Policy{Absolute: time.Minute})
+		d.Expiration = exp
+		b, _ := json.Marshal(d)
+		return b
+	}
+
+	tests := []struct {
+		name      string
+		data      map[string][]byte
+		removeErr error
+		want      SweepResult
+		wantLeft  []string
+	}{
+		{
+			"removes-only-past-grace",
+			map[string][]byte{
+				"sessions/active.json":   session(clock.Add(time.Minute)),
+				"sessions/in-grace.json": session(clock.Add(-30 * time.Minute)),
+				"sessions/expired.json":  session(clock.Add(-2 * time.Hour)),
+				"sessions/notes.txt":     []byte("not a session"),
+				"other/expired.json":     session(clock.Add(-2 * time.Hour)),
+			},
+			nil,
+			SweepResult{Checked: 3, Removed: 1},
+			[]string{"sessions/active.json", "sessions/in-grace.json", "sessions/notes.txt", "other/expired.json"},
+		},
+		{
+			"bad-records-do-not-stop-the-sweep",
+			map[string][]byte{
+				"sessions/corrupt.json": []byte("{"),
+				"sessions/expired.json": session(clock.Add(-2 * time.Hour)),
+			},
+			nil,
+			SweepResult{Checked: 2, Failed: 1, Removed: 1},
+			[]string{"sessions/corrupt.json"},
+		},
+		{
+			"remove-fails",
+			map[string][]byte{
+				"sessions/expired.json": session(clock.Add(-2 * time.Hour)),
+			},
+			errors.New("access denied"),
+			SweepResult{Checked: 1, Failed: 1},
+			[]string{"sessions/expired.json"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := &MockListStorage{data: tt.data, removeErr: tt.removeErr}
+			reaper := NewReaper(ms, "sessions", time.Hour)
+
+			got, err := reaper.Sweep(context.Background())
+			if err != nil {
+				t.Errorf("Reaper.Sweep() error = %v", err)
+				return
+			}
+
+			if *got != tt.want {
+				t.Errorf("Reaper.Sweep() = %+v, want %+v", *got, tt.want)
+				return
+			}
+
+			for _, key := range tt.wantLeft {
+				if _, ok := ms.data[key]; !ok {
+					t.Errorf("Reaper.Sweep() removed %v", key)
+				}
+			}
+
+			if len(ms.data) != len(tt.wantLeft) {
+				t.Errorf("Reaper.Sweep() left %v sessions, want %v", len(ms.data), len(tt.wantLeft))
+			}
+		})
+	}
+}
+
+func TestReaper_SweepNotListable(t *testing.T) {
+	reaper := NewReaper(&MockStorage{}, "", time.Hour)
+
+	if _, err := reaper.Sweep(context.Background()); err == nil {
+		t.Errorf("Reaper.Sweep() error = nil, want an error for storage that cannot list")
+	}
+}
@@ -2,7 +2,8 @@
 // HTTP cookie header storage. Such as using files on servers that have file
 // storage access or database storage for those without. The latter options
 // can add more latency, so please consider your options and use or build an
-// implementation according to your use case. For clarity on the subject of
+// implementation according to your use case. Small sessions can stay in the
+// cookies with a CookieStorage. For clarity on the subject of
 // HTTP State Management please review the RFC at
 // https://datatracker.ietf.org/doc/html/rfc6265
 package session
@@ -20,16 +21,27 @@ type Data struct {
 	Expiration time.Time  `json,bson:"expiration"`
 	Items      Store      `json,bson:"session_data"`
 	CookieSet  bool       `json,bson:"cookie_set"`
+	// Created When the session started, the Absolute limit of a Policy
+	// counts from here.
+	Created time.Time `json,bson:"created"`
+	// LastActivity When the client last made a request, the Idle limit of a
+	// Policy counts from here.
+	LastActivity time.Time `json,bson:"last_activity"`
+	// RememberMe Set when the user asked to stay logged in, see
+	// Policy.RememberMe.
+	RememberMe bool `json,bson:"remember_me"`
 }
 
 // Storage An interface medium for storing the session data to anyplace an
 // implementer see fit. An implementor should especially take into consideration
 // sensitive data pertaining to the clients session. This simple interface does
-// implementation of encryption for Save and decryption for Load. Use this
+// not implement encryption for Save and decryption for Load, wrap a Storage in
+// an EncryptedStorage for that. Use this
 // to implement storage for mediums like File, Database, In-memory cache, etc.
 type Storage interface {
 	// Load The session from storage.
 	// No matter the storage medium this should always return JSON as a byte array.
+	// The error wraps fs.ErrNotExist when there is no such session.
 	Load(id string) ([]byte, error)
 
 	// Save The session data to the storage medium.
@@ -50,6 +62,9 @@ const (
 var (
 	// ExtendTime How much time the session is extended when a user loads a
 	// page after the initial start of the session
+	//
+	// Deprecated: This was never used, set Policy.Idle with
+	// NewManagerWithPolicy instead.
 	ExtendTime     = 5 * time.Minute
 	Log            = logger.Standard{}
 	IDCookiePath   = "/"     // IDCookiePath Any path in the domain.
@@ -70,21 +85,49 @@ func GenerateID() *uuid.UUID {
 }
 
 // NewManager Initialize a new session manager to handle session save, restore, get, and set.
+// Sessions expire timeout after they start, see NewManagerWithPolicy for
+// idle timeouts.
 func NewManager(storage Storage, location string, timeout time.Duration) *Manager {
+	return NewManagerWithPolicy(storage, location, &Policy{Absolute: timeout})
+}
+
+// NewManagerWithPolicy Initialize a new session manager whose sessions
+// expire according to the policy, or DefaultPolicy when it is nil. A
+// RequestStorage is copied, so the Manager has its own.
+func NewManagerWithPolicy(storage Storage, location string, policy *Policy) *Manager {
+	policy = policy.orDefault()
+
+	if rs, ok := storage.(RequestStorage); ok {
+		storage = rs.ForRequest()
+	}
+
 	return &Manager{
-		data:       newData(timeout),
+		data:       newData(policy),
 		storage:    storage,
 		hasUpdates: false,
 		location:   location,
-		timeout:    timeout, // Store it for use with Reset method.
+		policy:     policy, // Store it for use with Reset method.
 	}
 }
 
-func newData(timeout time.Duration) *Data {
-	return &Data{
-		GenerateID(),
-		time.Now().UTC().Add(timeout),
-		make(Store, 100),
-		false,
+// StorageKey Where a Manager keeps the session with the ID in storage, for
+// code that works on sessions other than the one of the current request.
+func StorageKey(location, id string) string {
+	if location != "" {
+		return location + "/" + id + Suffix
 	}
+	return id + Suffix
+}
+
+func newData(policy *Policy) *Data {
+	now := time.Now().UTC()
+	d := &Data{
+		Id:           GenerateID(),
+		Items:        make(Store, 100),
+		Created:      now,
+		LastActivity: now,
+	}
+	d.Expiration = policy.expiration(d)
+
+	return d
 }
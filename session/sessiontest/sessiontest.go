@@ -0,0 +1,134 @@
+// Package sessiontest checks that an implementation of session.Storage meets
+// the contract of the interface, so that a session.Manager can use it. Call
+// Run from a test of the implementation:
+//
+//	func TestConformance(t *testing.T) {
+//		sessiontest.Run(t, func(t *testing.T) session.Storage {
+//			return NewMyStore()
+//		})
+//	}
+package sessiontest
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kohirens/www/session"
+)
+
+// Location Where Run saves sessions. Storage that needs a location to be
+// made before saving to it must have it made by the function passed to Run.
+const Location = "sessiontest"
+
+// Run The conformance tests, each on storage returned by newStorage.
+func Run(t *testing.T, newStorage func(t *testing.T) session.Storage) {
+	t.Helper()
+
+	id := session.GenerateID()
+	key := Location + "/" + id.String() + session.Suffix
+	data, _ := json.Marshal(&session.Data{
+		Id:         id,
+		Expiration: time.Now().Add(time.Hour).UTC(),
+		Items:      session.Store{"k": []byte("v")},
+	})
+
+	t.Run("save-load", func(t *testing.T) {
+		s := newStorage(t)
+
+		if e := s.Save(key, data); e != nil {
+			t.Fatalf("Save() error = %v", e)
+		}
+
+		got, e1 := s.Load(key)
+		if e1 != nil {
+			t.Fatalf("Load() error = %v", e1)
+		}
+
+		if !bytes.Equal(got, data) {
+			t.Errorf("Load() = %s, want %s", got, data)
+		}
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		s := newStorage(t)
+
+		_ = s.Save(key, data)
+
+		d := &session.Data{}
+		_ = json.Unmarshal(data, d)
+		d.Items["k"] = []byte("changed")
+		changed, _ := json.Marshal(d)
+
+		if e := s.Save(key, changed); e != nil {
+			t.Fatalf("Save() error = %v", e)
+		}
+
+		got, _ := s.Load(key)
+		if !bytes.Equal(got, changed) {
+			t.Errorf("Load() = %s, want %s", got, changed)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		s := newStorage(t)
+
+		if _, e := s.Load(Location + "/missing" + session.Suffix); e == nil {
+			t.Errorf("Load() of a missing session did not fail")
+		}
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		s := newStorage(t)
+
+		_ = s.Save(key, data)
+		if e := s.Remove(key); e != nil {
+			t.Fatalf("Remove() error = %v", e)
+		}
+
+		if _, e := s.Load(key); e == nil {
+			t.Errorf("Load() after Remove() did not fail")
+		}
+	})
+
+	t.Run("manager", func(t *testing.T) {
+		s := newStorage(t)
+
+		sm := session.NewManager(s, Location, time.Hour)
+		sm.Set("k", []byte("v"))
+		if e := sm.Save(); e != nil {
+			t.Fatalf("Manager.Save() error = %v", e)
+		}
+
+		sm2 := session.NewManager(s, Location, time.Hour)
+		if e := sm2.Restore(sm.ID().String()); e != nil {
+			t.Fatalf("Manager.Restore() error = %v", e)
+		}
+
+		if got := string(sm2.Get("k")); got != "v" {
+			t.Errorf("Manager.Get() = %v, want v", got)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		s := newStorage(t)
+
+		lister, ok := s.(session.Lister)
+		if !ok {
+			t.Skip("storage is not a session.Lister")
+		}
+
+		_ = s.Save(key, data)
+
+		got, e1 := lister.List(Location)
+		if e1 != nil {
+			t.Fatalf("List() error = %v", e1)
+		}
+
+		if want := id.String() + session.Suffix; !slices.Contains(got, want) {
+			t.Errorf("List() = %v, want it to contain %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package session
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// SessionStore Makes a Manager for each request, all sharing the same
+// storage and policy. Register one SessionStore for the life of a server,
+// a Manager holds the session of one client so must not be shared between
+// requests.
+type SessionStore struct {
+	location string
+	policy   *Policy
+	storage  Storage
+}
+
+// managerKey The key of a Manager in a request context.
+type managerKey struct{}
+
+// NewSessionStore Initialize a store of sessions that expire timeout after
+// they start.
+func NewSessionStore(storage Storage, location string, timeout time.Duration) *SessionStore {
+	return NewSessionStoreWithPolicy(storage, location, &Policy{Absolute: timeout})
+}
+
+// NewSessionStoreWithPolicy Initialize a store of sessions that expire
+// according to the policy, or DefaultPolicy when it is nil.
+func NewSessionStoreWithPolicy(storage Storage, location string, policy *Policy) *SessionStore {
+	return &SessionStore{
+		location: location,
+		policy:   policy.orDefault(),
+		storage:  storage,
+	}
+}
+
+// New A Manager for one request, with a new session that can be replaced by
+// loading one with LoadFromCookie or Restore.
+func (s *SessionStore) New() *Manager {
+	return NewManagerWithPolicy(s.storage, s.location, s.policy)
+}
+
+// Location Where the sessions are kept in the storage, see StorageKey.
+func (s *SessionStore) Location() string {
+	return s.location
+}
+
+// Storage Where the sessions are kept.
+func (s *SessionStore) Storage() Storage {
+	return s.storage
+}
+
+// FromContext The Manager attached to a context with WithManager.
+func FromContext(ctx context.Context) (*Manager, bool) {
+	m, ok := ctx.Value(managerKey{}).(*Manager)
+	return m, ok
+}
+
+// FromRequest The Manager attached to the context of a request.
+func FromRequest(r *http.Request) (*Manager, bool) {
+	if r == nil {
+		return nil, false
+	}
+
+	return FromContext(r.Context())
+}
+
+// WithManager Attach the Manager of a request to its context.
+func WithManager(ctx context.Context, m *Manager) context.Context {
+	return context.WithValue(ctx, managerKey{}, m)
+}
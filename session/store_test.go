@@ -0,0 +1,97 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSessionStore_New(t *testing.T) {
+	store := NewSessionStore(NewMemoryStorage(0), "sessions", time.Hour)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			want := fmt.Sprint(i)
+			sm := store.New()
+			sm.Set("n", []byte(want))
+			if e := sm.Save(); e != nil {
+				t.Errorf("Save() error = %v", e)
+				return
+			}
+
+			sm2 := store.New()
+			if e := sm2.Restore(sm.ID().String()); e != nil {
+				t.Errorf("Restore() error = %v", e)
+				return
+			}
+
+			if got := string(sm2.Get("n")); got != want {
+				t.Errorf("Get() = %v, want %v", got, want)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestManager_Concurrent(t *testing.T) {
+	sm := NewManager(NewMemoryStorage(0), "sessions", time.Hour)
+
+	// Run with -race, each method must hold the mutex.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			key := fmt.Sprint(i)
+			sm.Set(key, []byte(key))
+			_ = sm.Get(key)
+			_ = sm.Remove(key)
+			_ = sm.Expiration()
+			_ = sm.HasExpired()
+			_ = sm.IDCookie("/", "")
+			sm.Touch()
+			_ = sm.Save()
+			_ = sm.Restore(sm.ID().String())
+			sm.SetCookie(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			sm.RefreshCookie(httptest.NewRecorder())
+			if i%5 == 0 {
+				sm.RemoveAll()
+				sm.Reset()
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestFromRequest(t *testing.T) {
+	sm := NewManager(NewMemoryStorage(0), "", time.Hour)
+	r := httptest.NewRequest("GET", "/", nil)
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		want    *Manager
+		wantOk  bool
+	}{
+		{"nil", nil, nil, false},
+		{"none", r, nil, false},
+		{"attached", r.WithContext(WithManager(context.Background(), sm)), sm, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := FromRequest(tt.request)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("FromRequest() = %p, %v, want %p, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
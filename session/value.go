@@ -0,0 +1,113 @@
+package session
+
+import (
+	"fmt"
+)
+
+// Key A session item that holds a value of type T, encoded by a Codec. This
+// saves every caller from encoding its values to []byte for Get and Set.
+//
+//	var cartKey = session.NewKey[*Cart]("cart")
+//
+//	cart, err := session.GetAs(sm, cartKey)
+type Key[T any] struct {
+	codec Codec
+	name  string
+}
+
+// flashPrefix Marks the items set with SetFlash.
+const flashPrefix = "_flash_"
+
+// NewKey A key for a value of type T, encoded as JSON.
+func NewKey[T any](name string) Key[T] {
+	return Key[T]{JSONCodec, name}
+}
+
+// NewKeyWithCodec A key for a value of type T, encoded by the codec.
+func NewKeyWithCodec[T any](name string, codec Codec) Key[T] {
+	return Key[T]{codec, name}
+}
+
+// Name Of the session item.
+func (k Key[T]) Name() string {
+	return k.name
+}
+
+// GetAs Retrieve a value from the session. A NoSuchKeyError is returned when
+// the session does not have it.
+func GetAs[T any](m *Manager, key Key[T]) (T, error) {
+	return decodeAs[T](key, m.Get(key.name))
+}
+
+// SetAs Store a value in the session.
+func SetAs[T any](m *Manager, key Key[T], value T) error {
+	b, e1 := key.codec.Marshal(value)
+	if e1 != nil {
+		return fmt.Errorf(stderr.EncodeValue, key.name, e1.Error())
+	}
+
+	m.Set(key.name, b)
+
+	return nil
+}
+
+// FlashAs Retrieve a flash message set with SetFlashAs, which removes it.
+func FlashAs[T any](m *Manager, key Key[T]) (T, error) {
+	return decodeAs[T](key, m.Flash(key.name))
+}
+
+// SetFlashAs Store a flash message that is removed the first time it is
+// read, see SetFlash.
+func SetFlashAs[T any](m *Manager, key Key[T], value T) error {
+	b, e1 := key.codec.Marshal(value)
+	if e1 != nil {
+		return fmt.Errorf(stderr.EncodeValue, key.name, e1.Error())
+	}
+
+	m.SetFlash(key.name, b)
+
+	return nil
+}
+
+// Flash Retrieve a flash message set with SetFlash, and remove it from the
+// session. It returns nil when there is no such message.
+func (m *Manager) Flash(key string) []byte {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	value, ok := m.data.Items[flashPrefix+key]
+	if !ok {
+		return nil
+	}
+
+	delete(m.data.Items, flashPrefix+key)
+	m.hasUpdates = true
+
+	return value
+}
+
+// SetFlash Store a message that lasts until it is read with Flash, such as a
+// notice to show on the page a form redirects to after it is posted. Flash
+// messages are kept apart from the items of Get and Set.
+func (m *Manager) SetFlash(key string, value []byte) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.hasUpdates = true
+	m.data.Items[flashPrefix+key] = value
+}
+
+// decodeAs Decode the value of a key.
+func decodeAs[T any](key Key[T], b []byte) (T, error) {
+	var value T
+
+	if b == nil {
+		return value, &NoSuchKeyError{key.name}
+	}
+
+	if e := key.codec.Unmarshal(b, &value); e != nil {
+		return value, fmt.Errorf(stderr.DecodeValue, key.name, e.Error())
+	}
+
+	return value, nil
+}
@@ -0,0 +1,112 @@
+package session
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testCart struct {
+	Items   []string
+	Counts  map[string]int
+	Owner   *testOwner
+	Raw     []byte
+	Updated time.Time
+	Total   float64
+	Paid    bool
+}
+
+type testOwner struct {
+	Name string `msgpack:"name"`
+	Age  uint8  `msgpack:"age"`
+	Note string `msgpack:"-"`
+}
+
+func TestGetAs(t *testing.T) {
+	want := &testCart{
+		Items:   []string{"apple", "pear"},
+		Counts:  map[string]int{"apple": 2, "pear": -1},
+		Owner:   &testOwner{Name: "Kim", Age: 42},
+		Raw:     []byte{0, 1, 255},
+		Updated: time.Date(2025, 1, 2, 3, 4, 5, 6, time.UTC),
+		Total:   12.5,
+		Paid:    true,
+	}
+
+	tests := []struct {
+		name  string
+		codec Codec
+	}{
+		{"gob", GobCodec},
+		{"json", JSONCodec},
+		{"msgpack", MsgPackCodec},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := NewManager(NewMemoryStorage(0), "", time.Hour)
+			key := NewKeyWithCodec[*testCart]("cart", tt.codec)
+
+			if e := SetAs(sm, key, want); e != nil {
+				t.Fatalf("SetAs() error = %v", e)
+			}
+
+			got, e1 := GetAs(sm, key)
+			if e1 != nil {
+				t.Fatalf("GetAs() error = %v", e1)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("GetAs() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestGetAs_Errors(t *testing.T) {
+	sm := NewManager(NewMemoryStorage(0), "", time.Hour)
+
+	var nk *NoSuchKeyError
+	if _, e := GetAs(sm, NewKey[int]("missing")); !errors.As(e, &nk) {
+		t.Errorf("GetAs() error = %v, want a NoSuchKeyError", e)
+	}
+
+	sm.Set("n", []byte("not a number"))
+	if _, e := GetAs(sm, NewKey[int]("n")); e == nil || errors.As(e, &nk) {
+		t.Errorf("GetAs() error = %v, want a decode error", e)
+	}
+}
+
+func TestFlash(t *testing.T) {
+	storage := NewMemoryStorage(0)
+	sm := NewManager(storage, "", time.Hour)
+	key := NewKey[string]("notice")
+
+	if e := SetFlashAs(sm, key, "saved"); e != nil {
+		t.Fatalf("SetFlashAs() error = %v", e)
+	}
+	sm.Set("notice", []byte("item"))
+	_ = sm.Save()
+
+	// The next request reads the message once.
+	sm2 := NewManager(storage, "", time.Hour)
+	_ = sm2.Restore(sm.ID().String())
+
+	got, e1 := FlashAs(sm2, key)
+	if e1 != nil || got != "saved" {
+		t.Errorf("FlashAs() = %v, %v, want saved", got, e1)
+	}
+
+	if got := string(sm2.Get("notice")); got != "item" {
+		t.Errorf("Get() = %v, want the item apart from the flash message", got)
+	}
+
+	_ = sm2.Save()
+
+	sm3 := NewManager(storage, "", time.Hour)
+	_ = sm3.Restore(sm.ID().String())
+
+	if got := sm3.Flash("notice"); got != nil {
+		t.Errorf("Flash() after it was read = %s, want nil", got)
+	}
+}
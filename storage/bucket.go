@@ -3,160 +3,413 @@ package storage
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 )
 
 type BucketStorage struct {
-	Name                  string
+	// Encryption See BucketConfig.Encryption.
+	Encryption Encryption
+	// KMSKeyID See BucketConfig.KMSKeyID.
+	KMSKeyID string
+	Name     string
+	// PartSize The size of the parts that Create uploads, objects smaller
+	// than this are uploaded in one request. S3 needs parts to be at least
+	// 5 MiB, DefaultPartSize is used when it is zero.
+	PartSize              int64
 	S3                    *s3.Client
 	Prefix                string
 	requestListParameters *RequestListParameters
 }
 
-var _ Storage = (*BucketStorage)(nil)
+// DefaultPartSize See BucketStorage.PartSize.
+const DefaultPartSize = 8 << 20
+
+var (
+	_ ContextStorage  = (*BucketStorage)(nil)
+	_ MetadataStorage = (*BucketStorage)(nil)
+	_ PagedStorage    = (*BucketStorage)(nil)
+	_ Storage         = (*BucketStorage)(nil)
+	_ StreamStorage   = (*BucketStorage)(nil)
+)
 
 // Exist Verify the object is in the bucket.
 func (s *BucketStorage) Exist(key string) bool {
-	fullKey := s.Location(key)
-
-	Log.Infof(stdout.LoadKey, fullKey)
+	return s.ExistContext(context.Background(), key)
+}
 
-	_, e1 := s.S3.HeadObject(
-		context.Background(),
-		&s3.HeadObjectInput{
-			Bucket:       &s.Name,
-			Key:          &fullKey,
-			ChecksumMode: types.ChecksumModeEnabled,
-		},
-	)
-	if e1 != nil {
-		return false
-	}
+// ExistContext See Exist.
+func (s *BucketStorage) ExistContext(ctx context.Context, key string) bool {
+	_, e1 := s.Stat(ctx, key)
 
-	return true
+	return e1 == nil
 }
 
 // List Files in a location in storage. It is not recursive, it only lists
-// files in the specified directory.
-//
-//	There is special care that must be taken with S3. It requires a call to
-//	ListObjectsV2, which requires parameters to be set. So there is a
-//	prerequisite to call BucketStorage.SetRequestParams before calling
-//	List on S3 storage. If you need to call BucketStorage.List multiple
-//	times, care MUST be taken to call BucketStorage.SetRequestParams should
-//	the parameters need to change. For that reason, once BucketStorage.List
-//	is called, the request parameters are reset to nil.
+// files in the specified directory. All the pages of the listing are
+// fetched, see ListPage to fetch one page at a time.
 func (s *BucketStorage) List(location string) ([]string, error) {
-	requestParameter := s.requestListParameters
-	if requestParameter == nil {
-		return []string{}, fmt.Errorf("%v", stderr.RequestListParameters)
+	return s.ListContext(context.Background(), location)
+}
+
+// ListContext See List. The RequestListParameters, when set, are reset once
+// the listing is done.
+func (s *BucketStorage) ListContext(ctx context.Context, location string) ([]string, error) {
+	opts := &ListOptions{}
+	if p := s.requestListParameters; p != nil {
+		opts.ContinuationToken = p.ContinuationToken
+		opts.MaxKeys = int32(p.MaxKeys)
+		s.requestListParameters = nil
 	}
 
-	filePath := s.Location(location)
+	files := make([]string, 0)
+	for name, e1 := range Names(ctx, s, location, opts) {
+		if e1 != nil {
+			return []string{}, e1
+		}
+		files = append(files, name)
+	}
 
-	Log.Dbugf(stdout.Load, filePath)
+	return files, nil
+}
 
-	lo, e1 := s.S3.ListObjectsV2(
-		context.Background(),
-		&s3.ListObjectsV2Input{
-			Bucket: &s.Name,
-			Prefix: &filePath,
-		},
-	)
+// ListPage A page of the objects under a location, which is a prefix that
+// ends with "/". The Prefixes are the common prefixes S3 groups the objects
+// of sub-locations into, when the listing is not recursive.
+func (s *BucketStorage) ListPage(ctx context.Context, location string, opts *ListOptions) (*ListPage, error) {
+	if opts == nil {
+		opts = &ListOptions{}
+	}
 
+	prefix, e1 := s.key(location)
 	if e1 != nil {
-		return []string{}, fmt.Errorf(stderr.ListFiles, e1.Error())
+		return nil, e1
+	}
+	if prefix != "" {
+		prefix += "/"
 	}
 
-	files := make([]string, 0)
+	Log.Dbugf(stdout.Load, prefix)
+
+	input := &s3.ListObjectsV2Input{
+		Bucket: &s.Name,
+		Prefix: &prefix,
+	}
+	if !opts.Recursive {
+		input.Delimiter = aws.String("/")
+	}
+	if opts.ContinuationToken != "" {
+		input.ContinuationToken = &opts.ContinuationToken
+	}
+	if opts.MaxKeys > 0 {
+		input.MaxKeys = &opts.MaxKeys
+	}
+	if opts.StartAfter != "" {
+		input.StartAfter = aws.String(prefix + opts.StartAfter)
+	}
+
+	lo, e2 := s.S3.ListObjectsV2(ctx, input)
+	if e2 != nil {
+		return nil, fmt.Errorf(stderr.ListFiles, e2.Error())
+	}
+
+	page := &ListPage{
+		Names:    make([]string, 0, len(lo.Contents)),
+		Prefixes: make([]string, 0, len(lo.CommonPrefixes)),
+	}
 	for _, v := range lo.Contents {
-		files = append(files, strings.Replace(*v.Key, filePath+"/", "", 1))
+		page.Names = append(page.Names, strings.TrimPrefix(aws.ToString(v.Key), prefix))
+	}
+	for _, v := range lo.CommonPrefixes {
+		p := strings.TrimPrefix(aws.ToString(v.Prefix), prefix)
+		page.Prefixes = append(page.Prefixes, strings.TrimSuffix(p, "/"))
+	}
+	if aws.ToBool(lo.IsTruncated) {
+		page.NextToken = aws.ToString(lo.NextContinuationToken)
 	}
 
-	return files, nil
+	return page, nil
 }
 
 // Load data from S3. It may be best to use a prefix, like the site domain,
 // to prevent key name collision in the bucket. See an example at
 // https://docs.aws.amazon.com/sdk-for-go/v2/developer-guide/s3-checksums.html#use-service-S3-checksum-download
 func (s *BucketStorage) Load(key string) ([]byte, error) {
-	fullKey := s.Location(key)
+	return s.LoadContext(context.Background(), key)
+}
+
+// LoadContext See Load.
+func (s *BucketStorage) LoadContext(ctx context.Context, key string) ([]byte, error) {
+	body, _, e1 := s.Open(ctx, key)
+	if e1 != nil {
+		return nil, e1
+	}
+	defer func() { _ = body.Close() }()
+
+	content, e2 := io.ReadAll(body)
+	if e2 != nil {
+		return nil, fmt.Errorf(stderr.ReadObject, key)
+	}
+
+	return content, nil
+}
+
+// Open An object in the bucket for reading, its checksum is validated as it
+// is read.
+func (s *BucketStorage) Open(ctx context.Context, key string) (io.ReadCloser, Info, error) {
+	fullKey, e1 := s.key(key)
+	if e1 != nil {
+		return nil, Info{}, e1
+	}
 
 	Log.Infof(stdout.LoadKey, fullKey)
 
-	obj, e1 := s.S3.GetObject(
-		context.Background(),
+	obj, e2 := s.S3.GetObject(
+		ctx,
 		&s3.GetObjectInput{
 			Bucket:       &s.Name,
 			Key:          &fullKey,
 			ChecksumMode: types.ChecksumModeEnabled,
 		},
 	)
-	if e1 != nil {
-		return nil, fmt.Errorf(stderr.LoadKey, key, s.Name, e1.Error())
+	if e2 != nil {
+		var nsk *types.NoSuchKey
+		if errors.As(e2, &nsk) {
+			return nil, Info{}, fmt.Errorf(stderr.NotFound, fullKey, fs.ErrNotExist)
+		}
+		return nil, Info{}, fmt.Errorf(stderr.LoadKey, key, s.Name, e2)
 	}
 
-	content, e2 := io.ReadAll(obj.Body)
-	if e2 != nil {
-		return nil, fmt.Errorf(stderr.ReadObject, key)
+	info := Info{
+		CacheControl: aws.ToString(obj.CacheControl),
+		Checksum: firstChecksum(
+			obj.ChecksumCRC32,
+			obj.ChecksumCRC32C,
+			obj.ChecksumCRC64NVME,
+			obj.ChecksumSHA1,
+			obj.ChecksumSHA256,
+		),
+		ContentType: aws.ToString(obj.ContentType),
+		ETag:        aws.ToString(obj.ETag),
+		Metadata:    obj.Metadata,
+		ModTime:     aws.ToTime(obj.LastModified),
+		Name:        key,
+		Size:        aws.ToInt64(obj.ContentLength),
 	}
 
-	return content, nil
+	return obj.Body, info, nil
 }
 
 // Location Mainly for internal use, this allows a prefix while ensuring all
-// methods use a consistent location.
+// methods use a consistent location. The Prefix and the key are joined with
+// a "/", and the key is normalized by NormalizeKey, so "a.txt" and "/a.txt"
+// are the same object. It is empty when the key is invalid.
 func (s *BucketStorage) Location(key string) string {
-	return s.Prefix + key
+	fullKey, _ := s.key(key)
+
+	return fullKey
 }
 
 // Save Uploads an object to S3, validating the checksum on success.
 // For an example, see
 // https://docs.aws.amazon.com/sdk-for-go/v2/developer-guide/s3-checksums.html#use-service-S3-checksum-upload
 func (s *BucketStorage) Save(key string, content []byte) error {
-	fullKey := s.Location(key)
+	return s.SaveContext(context.Background(), key, content)
+}
+
+// SaveContext See Save.
+func (s *BucketStorage) SaveContext(ctx context.Context, key string, content []byte) error {
+	return s.SaveWithOptions(ctx, key, content, nil)
+}
+
+// SaveWithOptions Upload an object with a content type, cache control and
+// user metadata, which S3 returns as x-amz-meta-* headers.
+func (s *BucketStorage) SaveWithOptions(ctx context.Context, key string, content []byte, opts *SaveOptions) error {
+	fullKey, e1 := s.key(key)
+	if e1 != nil {
+		return e1
+	}
 
 	Log.Infof(stdout.SaveKey, fullKey)
 
-	_, e1 := s.S3.PutObject(
-		context.Background(),
-		&s3.PutObjectInput{
-			Bucket:               &s.Name,
-			Key:                  &fullKey,
-			Body:                 bytes.NewReader(content),
-			ChecksumAlgorithm:    types.ChecksumAlgorithmCrc32,
-			ServerSideEncryption: "AES256",
+	input := &s3.PutObjectInput{
+		Bucket:            &s.Name,
+		Key:               &fullKey,
+		Body:              bytes.NewReader(content),
+		ChecksumAlgorithm: types.ChecksumAlgorithmCrc32,
+	}
+	input.ServerSideEncryption, input.SSEKMSKeyId = s.encryption()
+
+	if opts != nil {
+		if opts.CacheControl != "" {
+			input.CacheControl = &opts.CacheControl
+		}
+		if opts.ContentType != "" {
+			input.ContentType = &opts.ContentType
+		}
+		input.Metadata = opts.Metadata
+	}
+
+	_, e2 := s.S3.PutObject(ctx, input)
+	if e2 != nil {
+		return fmt.Errorf(stderr.PutObject, e2.Error())
+	}
+
+	return nil
+}
+
+// Stat Describe an object with a HEAD request, the checksum is the one S3
+// keeps for the object, base64 encoded.
+func (s *BucketStorage) Stat(ctx context.Context, key string) (Info, error) {
+	fullKey, e1 := s.key(key)
+	if e1 != nil {
+		return Info{}, e1
+	}
+
+	Log.Infof(stdout.LoadKey, fullKey)
+
+	obj, e2 := s.S3.HeadObject(
+		ctx,
+		&s3.HeadObjectInput{
+			Bucket:       &s.Name,
+			Key:          &fullKey,
+			ChecksumMode: types.ChecksumModeEnabled,
 		},
 	)
-	if e1 != nil {
-		return fmt.Errorf(stderr.PutObject, e1.Error())
+	if e2 != nil {
+		var nf *types.NotFound
+		if errors.As(e2, &nf) {
+			return Info{}, fmt.Errorf(stderr.NotFound, fullKey, fs.ErrNotExist)
+		}
+		return Info{}, fmt.Errorf(stderr.LoadKey, key, s.Name, e2)
 	}
 
-	return nil
+	return Info{
+		CacheControl: aws.ToString(obj.CacheControl),
+		Checksum: firstChecksum(
+			obj.ChecksumCRC32,
+			obj.ChecksumCRC32C,
+			obj.ChecksumCRC64NVME,
+			obj.ChecksumSHA1,
+			obj.ChecksumSHA256,
+		),
+		ContentType: aws.ToString(obj.ContentType),
+		ETag:        aws.ToString(obj.ETag),
+		Metadata:    obj.Metadata,
+		ModTime:     aws.ToTime(obj.LastModified),
+		Name:        key,
+		Size:        aws.ToInt64(obj.ContentLength),
+	}, nil
+}
+
+// firstChecksum The first of the checksums S3 returned, objects only have
+// the one they were uploaded with.
+func firstChecksum(checksums ...*string) string {
+	for _, c := range checksums {
+		if c != nil && *c != "" {
+			return *c
+		}
+	}
+
+	return ""
 }
 
 // Remove Delete an object from S3.
 func (s *BucketStorage) Remove(key string) error {
-	fullKey := s.Location(key)
+	return s.RemoveContext(context.Background(), key)
+}
+
+// RemoveContext See Remove.
+func (s *BucketStorage) RemoveContext(ctx context.Context, key string) error {
+	fullKey, e1 := s.key(key)
+	if e1 != nil {
+		return e1
+	}
 
 	Log.Infof(stdout.SaveKey, fullKey)
 
-	_, e1 := s.S3.DeleteObject(
-		context.Background(),
+	_, e2 := s.S3.DeleteObject(
+		ctx,
 		&s3.DeleteObjectInput{
 			Bucket: &s.Name,
 			Key:    &fullKey,
 		},
 	)
+	if e2 != nil {
+		return fmt.Errorf(stderr.DeleteObject, e2.Error())
+	}
+
+	return nil
+}
+
+// ExpireAfter Add a lifecycle rule to the bucket so that S3 deletes objects
+// under prefix a number of days after they were last saved. This is the
+// native way to expire sessions kept in a bucket, instead of a
+// session.Reaper. The rule is replaced when it already exists, other rules
+// are left as they are. A prefix that, with the Prefix of the storage, is
+// the whole bucket is an error, rather than a rule that deletes every object.
+//
+//	NOTE: S3 runs lifecycle rules once a day, so objects may outlive their
+//	expiration by up to a day. The caller needs the
+//	s3:GetLifecycleConfiguration and s3:PutLifecycleConfiguration permissions.
+func (s *BucketStorage) ExpireAfter(ctx context.Context, prefix string, days int32) error {
+	fullPrefix, e1 := s.key(prefix)
 	if e1 != nil {
-		return fmt.Errorf(stderr.DeleteObject, e1.Error())
+		return e1
+	}
+	if fullPrefix == "" {
+		return fmt.Errorf(stderr.LifecycleNoPrefix, s.Name)
+	}
+	// A prefix that ends with "/" only matches the objects under it.
+	if strings.HasSuffix(prefix, "/") {
+		fullPrefix += "/"
+	}
+	ruleID := "expire-" + fullPrefix
+
+	rules := make([]types.LifecycleRule, 0)
+
+	current, e2 := s.S3.GetBucketLifecycleConfiguration(
+		ctx,
+		&s3.GetBucketLifecycleConfigurationInput{Bucket: &s.Name},
+	)
+	if e2 != nil {
+		var apiErr smithy.APIError
+		if !errors.As(e2, &apiErr) || apiErr.ErrorCode() != "NoSuchLifecycleConfiguration" {
+			return fmt.Errorf(stderr.LifecycleConfig, s.Name, e2.Error())
+		}
+	} else {
+		for _, rule := range current.Rules {
+			if rule.ID == nil || *rule.ID != ruleID {
+				rules = append(rules, rule)
+			}
+		}
+	}
+
+	rules = append(rules, types.LifecycleRule{
+		ID:         &ruleID,
+		Status:     types.ExpirationStatusEnabled,
+		Filter:     &types.LifecycleRuleFilter{Prefix: &fullPrefix},
+		Expiration: &types.LifecycleExpiration{Days: &days},
+	})
+
+	_, e3 := s.S3.PutBucketLifecycleConfiguration(
+		ctx,
+		&s3.PutBucketLifecycleConfigurationInput{
+			Bucket:                 &s.Name,
+			LifecycleConfiguration: &types.BucketLifecycleConfiguration{Rules: rules},
+		},
+	)
+	if e3 != nil {
+		return fmt.Errorf(stderr.LifecycleConfig, s.Name, e3.Error())
 	}
 
 	return nil
@@ -164,23 +417,24 @@ func (s *BucketStorage) Remove(key string) error {
 
 // NewBucketStorage Initializes an S3 client to use as storage.
 // Credentials are expected to be configured in the environment to be picked up
-// by the AWS SDK. Panics on failure.
+// by the AWS SDK. See NewBucketStorageWithConfig to configure the client.
 func NewBucketStorage(bucket string, ctx context.Context) (*BucketStorage, error) {
-	cfg, err := config.LoadDefaultConfig(ctx)
-	if err != nil {
-		return nil, fmt.Errorf(stderr.AwsConfig, err)
-	}
-
-	return &BucketStorage{
-		Name: bucket,
-		S3:   s3.NewFromConfig(cfg),
-	}, nil
+	return NewBucketStorageWithConfig(ctx, &BucketConfig{Bucket: bucket})
 }
 
+// SetRequestListParameters Set the page size and continuation token of the
+// next List, they are reset once it is done.
+//
+// Deprecated: List no longer needs parameters, use ListPage with ListOptions
+// to control the listing.
 func (s *BucketStorage) SetRequestListParameters(requestListParameters *RequestListParameters) {
 	s.requestListParameters = requestListParameters
 }
 
+// RequestListParameters See SetRequestListParameters, only MaxKeys and
+// ContinuationToken are used.
+//
+// Deprecated: Use ListOptions.
 type RequestListParameters struct {
 	ListType          int       `url:"list-type"`
 	ContinuationToken string    `url:"continuation-token"`
@@ -191,3 +445,13 @@ type RequestListParameters struct {
 	Prefix            string    `url:"prefix"`
 	StartAfter        time.Time `url:"start-after"`
 }
+
+// key The key of an object under the Prefix, see Location.
+func (s *BucketStorage) key(key string) (string, error) {
+	normalized, e1 := NormalizeKey(key)
+	if e1 != nil {
+		return "", e1
+	}
+
+	return joinKey(s.Prefix, normalized), nil
+}
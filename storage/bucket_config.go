@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// BucketConfig Configures BucketStorage for S3, or an S3 compatible service
+// such as MinIO or LocalStack. Only Bucket is required, what is left empty
+// is picked up from the environment by the AWS SDK.
+type BucketConfig struct {
+	// AccessKeyID With SecretAccessKey, and SessionToken when the keys are
+	// temporary, are static credentials that are used instead of those in
+	// the environment.
+	AccessKeyID string
+	Bucket      string
+	// Encryption How objects are encrypted at rest, SSE-S3 by default.
+	Encryption Encryption
+	// Endpoint The URL of an S3 compatible service, such as
+	// "http://localhost:9000" for MinIO.
+	Endpoint string
+	// HTTPClient Sends the requests, such as one that trusts the certificate
+	// of a local service.
+	HTTPClient *http.Client
+	// KMSKeyID The ID or ARN of the KMS key objects are encrypted with, when
+	// the Encryption is EncryptionKMS. Empty for the AWS managed key.
+	KMSKeyID string
+	// PartSize See BucketStorage.PartSize.
+	PartSize int64
+	// PathStyle Address the bucket in the path of the URL, instead of the
+	// host name, which most S3 compatible services need.
+	PathStyle       bool
+	Prefix          string
+	Region          string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// Encryption The server-side encryption of the objects BucketStorage saves.
+type Encryption string
+
+const (
+	// EncryptionS3 Encrypt with keys that S3 manages, SSE-S3.
+	EncryptionS3 Encryption = ""
+	// EncryptionKMS Encrypt with a key in AWS KMS, SSE-KMS, see
+	// BucketConfig.KMSKeyID.
+	EncryptionKMS Encryption = "aws:kms"
+	// EncryptionNone Leave the encryption to the default of the bucket, for
+	// services that reject encryption headers.
+	EncryptionNone Encryption = "none"
+)
+
+// NewBucketStorageWithConfig Initializes an S3 client, configured by cfg, to
+// use as storage.
+func NewBucketStorageWithConfig(ctx context.Context, cfg *BucketConfig) (*BucketStorage, error) {
+	options := make([]func(*config.LoadOptions) error, 0, 2)
+
+	if cfg.Region != "" {
+		options = append(options, config.WithRegion(cfg.Region))
+	}
+
+	if cfg.AccessKeyID != "" {
+		options = append(options, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, cfg.SessionToken),
+		))
+	}
+
+	awsCfg, e1 := config.LoadDefaultConfig(ctx, options...)
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.AwsConfig, e1)
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+		if cfg.HTTPClient != nil {
+			o.HTTPClient = cfg.HTTPClient
+		}
+		o.UsePathStyle = cfg.PathStyle
+	})
+
+	return &BucketStorage{
+		Encryption: cfg.Encryption,
+		KMSKeyID:   cfg.KMSKeyID,
+		Name:       cfg.Bucket,
+		PartSize:   cfg.PartSize,
+		Prefix:     cfg.Prefix,
+		S3:         client,
+	}, nil
+}
+
+// encryption The server-side encryption headers for an upload.
+func (s *BucketStorage) encryption() (types.ServerSideEncryption, *string) {
+	switch s.Encryption {
+	case EncryptionNone:
+		return "", nil
+	case EncryptionKMS:
+		if s.KMSKeyID == "" {
+			return types.ServerSideEncryptionAwsKms, nil
+		}
+		return types.ServerSideEncryptionAwsKms, aws.String(s.KMSKeyID)
+	default:
+		return types.ServerSideEncryptionAes256, nil
+	}
+}
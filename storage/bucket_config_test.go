@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kohirens/www/storage/s3test"
+)
+
+// newTestBucket A BucketStorage of the bucket "b" on an s3test.Server,
+// configured by cfg, which can be nil.
+func newTestBucket(t *testing.T, cfg *BucketConfig) (*s3test.Server, *BucketStorage) {
+	t.Helper()
+
+	srv := s3test.NewServer()
+	t.Cleanup(srv.Close)
+
+	if cfg == nil {
+		cfg = &BucketConfig{}
+	}
+	cfg.AccessKeyID = "test"
+	cfg.Bucket = "b"
+	cfg.Endpoint = srv.URL
+	cfg.HTTPClient = srv.Client()
+	cfg.PathStyle = true
+	cfg.Region = "us-east-1"
+	cfg.SecretAccessKey = "test"
+
+	s, e1 := NewBucketStorageWithConfig(context.Background(), cfg)
+	if e1 != nil {
+		t.Fatal(e1)
+	}
+
+	return srv, s
+}
+
+func TestNewBucketStorageWithConfig_Encryption(t *testing.T) {
+	tests := []struct {
+		name       string
+		encryption Encryption
+		kmsKeyID   string
+		wantSSE    string
+		wantKeyID  string
+	}{
+		{"sse-s3", EncryptionS3, "", "AES256", ""},
+		{"sse-kms", EncryptionKMS, "alias/sessions", "aws:kms", "alias/sessions"},
+		{"sse-kms-aws-managed", EncryptionKMS, "", "aws:kms", ""},
+		{"none", EncryptionNone, "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, s := newTestBucket(t, &BucketConfig{Encryption: tt.encryption, KMSKeyID: tt.kmsKeyID})
+
+			if e := s.Save("a.txt", []byte("hello")); e != nil {
+				t.Fatalf("Save() error = %v", e)
+			}
+
+			obj, ok := srv.Object("b", "a.txt")
+			if !ok {
+				t.Fatalf("Save() did not put the object")
+			}
+
+			if got := obj.Header.Get("X-Amz-Server-Side-Encryption"); got != tt.wantSSE {
+				t.Errorf("Save() encryption = %q, want %q", got, tt.wantSSE)
+			}
+
+			if got := obj.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"); got != tt.wantKeyID {
+				t.Errorf("Save() KMS key = %q, want %q", got, tt.wantKeyID)
+			}
+		})
+	}
+}
+
+func TestBucketStorage_Offline(t *testing.T) {
+	srv, s := newTestBucket(t, &BucketConfig{Prefix: "p/"})
+	ctx := context.Background()
+
+	if e := s.Save("a.txt", []byte("hello")); e != nil {
+		t.Fatalf("Save() error = %v", e)
+	}
+
+	obj, _ := srv.Object("b", "p/a.txt")
+	if obj == nil || string(obj.Data) != "hello" {
+		t.Fatalf("Save() put %v, want the data decoded from aws-chunked encoding", obj)
+	}
+
+	info, e1 := s.Stat(ctx, "a.txt")
+	if e1 != nil || info.Checksum == "" {
+		t.Errorf("Stat() = %+v, %v, want the checksum of the upload", info, e1)
+	}
+
+	if e := s.ExpireAfter(ctx, "sessions/", 2); e != nil {
+		t.Errorf("ExpireAfter() error = %v", e)
+	}
+	if e := s.ExpireAfter(ctx, "sessions/", 3); e != nil {
+		t.Errorf("ExpireAfter() to replace the rule error = %v", e)
+	}
+	// Without a Prefix, an empty prefix would be the whole bucket.
+	_, whole := newTestBucket(t, nil)
+	for _, prefix := range []string{"", "/"} {
+		if e := whole.ExpireAfter(ctx, prefix, 2); e == nil {
+			t.Errorf("ExpireAfter(%q) of the whole bucket did not fail", prefix)
+		}
+	}
+
+	if e := s.Remove("a.txt"); e != nil || s.Exist("a.txt") {
+		t.Errorf("Remove() error = %v, or the object still exists", e)
+	}
+}
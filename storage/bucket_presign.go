@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+var _ PresignStorage = (*BucketStorage)(nil)
+
+// PresignGet A URL to download an object, signed with the credentials of the
+// client. The URL is only valid as long as the credentials are, so one signed
+// with temporary credentials, such as those of a Lambda function, may expire
+// before the duration.
+func (s *BucketStorage) PresignGet(ctx context.Context, key string, expires time.Duration) (*PresignedRequest, error) {
+	fullKey, e1 := s.key(key)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	req, e2 := s3.NewPresignClient(s.S3).PresignGetObject(
+		ctx,
+		&s3.GetObjectInput{Bucket: &s.Name, Key: &fullKey},
+		s3.WithPresignExpires(expires),
+	)
+	if e2 != nil {
+		return nil, fmt.Errorf(stderr.Presign, key, e2.Error())
+	}
+
+	return &PresignedRequest{
+		Expires: time.Now().Add(expires).Truncate(time.Second),
+		Method:  req.Method,
+		URL:     req.URL,
+	}, nil
+}
+
+// PresignPut A URL to upload an object, see PresignGet. The client must send
+// the headers of the request, which include those of the encryption. A size
+// can only be limited to an exact size, where MinSize is MaxSize, see
+// PresignPost to limit it to a range.
+func (s *BucketStorage) PresignPut(ctx context.Context, key string, expires time.Duration, opts *PresignOptions) (*PresignedRequest, error) {
+	if opts == nil {
+		opts = &PresignOptions{}
+	}
+
+	fullKey, e1 := s.key(key)
+	if e1 != nil {
+		return nil, e1
+	}
+	input := &s3.PutObjectInput{Bucket: &s.Name, Key: &fullKey}
+	input.ServerSideEncryption, input.SSEKMSKeyId = s.encryption()
+
+	presignOpts := []func(*s3.PresignOptions){s3.WithPresignExpires(expires)}
+
+	if opts.ContentType != "" {
+		// The SDK leaves the Content-Type out of a presigned PUT, so it is
+		// added back to be signed.
+		presignOpts = append(presignOpts, func(o *s3.PresignOptions) {
+			o.ClientOptions = append(o.ClientOptions, func(o *s3.Options) {
+				o.APIOptions = append(o.APIOptions, smithyhttp.SetHeaderValue("Content-Type", opts.ContentType))
+			})
+		})
+	}
+
+	if opts.MaxSize > 0 || opts.MinSize > 0 {
+		if opts.MaxSize != opts.MinSize {
+			return nil, fmt.Errorf("%v", stderr.PresignSize)
+		}
+		input.ContentLength = &opts.MaxSize
+	}
+
+	req, e2 := s3.NewPresignClient(s.S3).PresignPutObject(ctx, input, presignOpts...)
+	if e2 != nil {
+		return nil, fmt.Errorf(stderr.Presign, key, e2.Error())
+	}
+
+	// The host is in the URL, and a browser sets the length itself.
+	header := req.SignedHeader.Clone()
+	header.Del("Host")
+	header.Del("Content-Length")
+
+	return &PresignedRequest{
+		Expires: time.Now().Add(expires).Truncate(time.Second),
+		Header:  header,
+		Method:  req.Method,
+		URL:     req.URL,
+	}, nil
+}
+
+// PresignPost A form that a browser can post a file to, with a policy that
+// limits its content type and size. Post the Fields before the file, which
+// must be the last field of the form and named "file".
+//
+//	NOTE: The object is encrypted with the default encryption of the bucket.
+func (s *BucketStorage) PresignPost(ctx context.Context, key string, expires time.Duration, opts *PresignOptions) (*PresignedRequest, error) {
+	if opts == nil {
+		opts = &PresignOptions{}
+	}
+
+	fullKey, e1 := s.key(key)
+	if e1 != nil {
+		return nil, e1
+	}
+	conditions := make([]any, 0, 2)
+
+	if opts.ContentType != "" {
+		conditions = append(conditions, map[string]string{"Content-Type": opts.ContentType})
+	}
+
+	if opts.MaxSize > 0 {
+		conditions = append(conditions, []any{"content-length-range", opts.MinSize, opts.MaxSize})
+	}
+
+	req, e2 := s3.NewPresignClient(s.S3).PresignPostObject(
+		ctx,
+		&s3.PutObjectInput{Bucket: &s.Name, Key: &fullKey},
+		func(o *s3.PresignPostOptions) {
+			o.Conditions = conditions
+			o.Expires = expires
+		},
+	)
+	if e2 != nil {
+		return nil, fmt.Errorf(stderr.Presign, key, e2.Error())
+	}
+
+	fields := req.Values
+	if opts.ContentType != "" {
+		fields["Content-Type"] = opts.ContentType
+	}
+
+	return &PresignedRequest{
+		Expires: time.Now().Add(expires).Truncate(time.Second),
+		Fields:  fields,
+		Method:  http.MethodPost,
+		URL:     req.URL,
+	}, nil
+}
@@ -1,20 +1,11 @@
 package storage
 
 import (
-	"context"
-	"os"
 	"testing"
 )
 
 func TestBucketStorage_List(tr *testing.T) {
-	s, e1 := NewBucketStorage(
-		os.Getenv("S3_BUCKET_NAME"),
-		context.Background(),
-	)
-	if e1 != nil {
-		tr.Fatal(e1)
-	}
-	s.Prefix = "list"
+	_, s := newTestBucket(tr, &BucketConfig{Prefix: "list"})
 	if e := s.Save("/file-01.txt", []byte("01")); e != nil {
 		tr.Fatal(e)
 	}
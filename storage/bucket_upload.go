@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Create A writer that uploads an object to the bucket. Data is held in
+// memory up to PartSize, objects smaller than that are uploaded with a single
+// PutObject on Close. Larger objects are uploaded in parts as they are
+// written, and the upload is completed on Close or aborted on an error.
+//
+//	NOTE: An upload left incomplete, such as when the process dies before
+//	Close, is billed until it is aborted. Add a lifecycle rule with
+//	AbortIncompleteMultipartUpload to the bucket to clean these up.
+func (s *BucketStorage) Create(ctx context.Context, key string) io.WriteCloser {
+	if _, e := s.key(key); e != nil {
+		return &errWriter{e}
+	}
+
+	size := s.PartSize
+	if size <= 0 {
+		size = DefaultPartSize
+	}
+
+	return &multipartWriter{
+		ctx:      ctx,
+		key:      key,
+		partSize: int(size),
+		storage:  s,
+	}
+}
+
+// multipartWriter Uploads an object in parts of partSize.
+type multipartWriter struct {
+	buf      bytes.Buffer
+	closed   bool
+	ctx      context.Context
+	err      error
+	key      string
+	partSize int
+	parts    []types.CompletedPart
+	storage  *BucketStorage
+	uploadID *string
+}
+
+func (w *multipartWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errClosed
+	}
+
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	n, _ := w.buf.Write(p)
+
+	for w.buf.Len() >= w.partSize {
+		if e := w.uploadPart(w.buf.Next(w.partSize)); e != nil {
+			w.fail(e)
+			return n, w.err
+		}
+	}
+
+	return n, nil
+}
+
+func (w *multipartWriter) Close() error {
+	if w.closed {
+		return errClosed
+	}
+	w.closed = true
+
+	if w.err != nil {
+		return w.err
+	}
+
+	if w.uploadID == nil {
+		return w.storage.SaveContext(w.ctx, w.key, w.buf.Bytes())
+	}
+
+	if w.buf.Len() > 0 {
+		if e := w.uploadPart(w.buf.Bytes()); e != nil {
+			w.fail(e)
+			return w.err
+		}
+	}
+
+	fullKey := w.storage.Location(w.key)
+	_, e1 := w.storage.S3.CompleteMultipartUpload(
+		w.ctx,
+		&s3.CompleteMultipartUploadInput{
+			Bucket:          &w.storage.Name,
+			Key:             &fullKey,
+			UploadId:        w.uploadID,
+			MultipartUpload: &types.CompletedMultipartUpload{Parts: w.parts},
+		},
+	)
+	if e1 != nil {
+		w.fail(e1)
+		return w.err
+	}
+
+	return nil
+}
+
+// fail Abort the upload, so that the parts are not kept, and remember the
+// error for later calls.
+func (w *multipartWriter) fail(err error) {
+	w.err = fmt.Errorf(stderr.MultipartUpload, w.key, err.Error())
+
+	if w.uploadID == nil {
+		return
+	}
+
+	fullKey := w.storage.Location(w.key)
+	// Abort even when the context is done, else the parts are billed.
+	_, _ = w.storage.S3.AbortMultipartUpload(
+		context.WithoutCancel(w.ctx),
+		&s3.AbortMultipartUploadInput{
+			Bucket:   &w.storage.Name,
+			Key:      &fullKey,
+			UploadId: w.uploadID,
+		},
+	)
+}
+
+// uploadPart Upload the next part, starting the upload when this is the
+// first.
+func (w *multipartWriter) uploadPart(part []byte) error {
+	fullKey := w.storage.Location(w.key)
+
+	if w.uploadID == nil {
+		Log.Infof(stdout.SaveKey, fullKey)
+
+		input := &s3.CreateMultipartUploadInput{
+			Bucket:            &w.storage.Name,
+			Key:               &fullKey,
+			ChecksumAlgorithm: types.ChecksumAlgorithmCrc32,
+		}
+		input.ServerSideEncryption, input.SSEKMSKeyId = w.storage.encryption()
+
+		out, e1 := w.storage.S3.CreateMultipartUpload(w.ctx, input)
+		if e1 != nil {
+			return e1
+		}
+		w.uploadID = out.UploadId
+	}
+
+	number := int32(len(w.parts) + 1)
+	out, e2 := w.storage.S3.UploadPart(
+		w.ctx,
+		&s3.UploadPartInput{
+			Bucket:            &w.storage.Name,
+			Key:               &fullKey,
+			UploadId:          w.uploadID,
+			PartNumber:        &number,
+			Body:              bytes.NewReader(part),
+			ChecksumAlgorithm: types.ChecksumAlgorithmCrc32,
+		},
+	)
+	if e2 != nil {
+		return e2
+	}
+
+	w.parts = append(w.parts, types.CompletedPart{
+		ETag:          out.ETag,
+		PartNumber:    &number,
+		ChecksumCRC32: out.ChecksumCRC32,
+	})
+
+	return nil
+}
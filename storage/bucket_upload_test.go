@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBucketStorage_Create(t *testing.T) {
+	tests := []struct {
+		name      string
+		size      int
+		wantParts int
+	}{
+		{"small", 100, 0},
+		{"exact-part", 5 << 20, 1},
+		{"parts", 11 << 20, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, s := newTestBucket(t, &BucketConfig{PartSize: 5 << 20})
+
+			data := bytes.Repeat([]byte("0123456789"), tt.size/10)
+			w := s.Create(context.Background(), "big.bin")
+
+			// Write in odd sizes, so that parts are made across writes.
+			for chunk := range slices.Chunk(data, 1<<20+7) {
+				if _, e := w.Write(chunk); e != nil {
+					t.Fatalf("Write() error = %v", e)
+				}
+			}
+
+			if e := w.Close(); e != nil {
+				t.Fatalf("Close() error = %v", e)
+			}
+
+			obj, _ := srv.Object("b", "big.bin")
+			if obj == nil || !bytes.Equal(obj.Data, data) {
+				t.Fatalf("Create() did not upload the %v bytes", len(data))
+			}
+
+			requests := srv.Requests()
+			parts := 0
+			for _, r := range requests {
+				if r == "UploadPart" {
+					parts++
+				}
+			}
+			if parts != tt.wantParts {
+				t.Errorf("Create() uploaded %v parts, want %v: %v", parts, tt.wantParts, requests)
+			}
+
+			if tt.wantParts == 0 && !slices.Contains(requests, "PutObject") {
+				t.Errorf("Create() did not use PutObject for a small object: %v", requests)
+			}
+
+			if got := obj.Header.Get("X-Amz-Server-Side-Encryption"); got != "AES256" {
+				t.Errorf("Create() encryption = %q, want AES256", got)
+			}
+		})
+	}
+}
+
+func TestBucketStorage_Create_Canceled(t *testing.T) {
+	srv, s := newTestBucket(t, &BucketConfig{PartSize: 5 << 20})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := s.Create(ctx, "big.bin")
+
+	if _, e := w.Write(make([]byte, 6<<20)); e != nil {
+		t.Fatalf("Write() error = %v", e)
+	}
+
+	cancel()
+
+	if e := w.Close(); !errors.Is(e, context.Canceled) && e == nil {
+		t.Fatalf("Close() after cancel error = %v, want an error", e)
+	}
+
+	if _, ok := srv.Object("b", "big.bin"); ok {
+		t.Errorf("Close() after cancel saved the object")
+	}
+
+	if !slices.Contains(srv.Requests(), "AbortMultipartUpload") || srv.Uploads() != 0 {
+		t.Errorf("Close() after cancel did not abort the upload: %v", srv.Requests())
+	}
+}
+
+func TestBucketStorage_Open(t *testing.T) {
+	srv, s := newTestBucket(t, &BucketConfig{Prefix: "p/"})
+	srv.Now = func() time.Time { return time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC) }
+	srv.PutObject("b", "p/a.txt", []byte("hello"))
+
+	r, info, e1 := s.Open(context.Background(), "a.txt")
+	if e1 != nil {
+		t.Fatalf("Open() error = %v", e1)
+	}
+	defer func() { _ = r.Close() }()
+
+	got, _ := io.ReadAll(r)
+	if string(got) != "hello" {
+		t.Errorf("Open() read %q, want hello", got)
+	}
+
+	if info.Size != 5 || !info.ModTime.Equal(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("Open() info = %+v, want size 5 and the last modified time", info)
+	}
+
+	if _, _, e := s.Open(context.Background(), "missing.txt"); !errors.Is(e, fs.ErrNotExist) {
+		t.Errorf("Open() of a missing object error = %v, want fs.ErrNotExist", e)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, e := s.LoadContext(ctx, "a.txt"); !errors.Is(e, context.Canceled) {
+		t.Errorf("LoadContext() with a canceled context error = %v, want context.Canceled", e)
+	}
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// CacheStorage A read cache in front of storage, for data that is loaded
+// often and seldom changes, such as templates or secrets kept in a bucket.
+// Loaded data is kept in memory, the least recently used is evicted and it
+// expires after a TTL. Save and Remove invalidate the cached data, data
+// changed by another process is only seen once it expires, or after
+// Invalidate.
+type CacheStorage struct {
+	cache *MemoryStorage
+	// generations Of the names being loaded from storage, so that a load
+	// that began before the name was invalidated does not cache old data.
+	generations map[string]*cacheGeneration
+	mutex       sync.Mutex
+	storage     Storage
+}
+
+// cacheGeneration Counts the invalidations of a name, and the loads of it in
+// progress.
+type cacheGeneration struct {
+	loads int
+	n     uint64
+}
+
+var _ Storage = (*CacheStorage)(nil)
+
+// NewCacheStorage Initialize a cache of up to maxEntries loaded from s, that
+// expire after the ttl. Zero for no limit or for never.
+func NewCacheStorage(s Storage, maxEntries int, ttl time.Duration) *CacheStorage {
+	return &CacheStorage{
+		cache:       NewMemoryStorage(maxEntries, ttl),
+		generations: make(map[string]*cacheGeneration),
+		storage:     s,
+	}
+}
+
+// Exist Verify the data is cached or in storage.
+func (s *CacheStorage) Exist(name string) bool {
+	return s.cache.Exist(name) || s.storage.Exist(name)
+}
+
+// Invalidate Remove data from the cache, so that it is loaded again.
+func (s *CacheStorage) Invalidate(name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if g, ok := s.generations[name]; ok {
+		g.n++
+	}
+
+	_ = s.cache.Remove(name)
+}
+
+// List See Storage.List, names are never cached.
+func (s *CacheStorage) List(location string) ([]string, error) {
+	return s.storage.List(location)
+}
+
+// Load Retrieve data from the cache, or from storage to cache it. Data is
+// not cached when the name was invalidated while it was loading, since it
+// may be older than what was saved.
+func (s *CacheStorage) Load(name string) ([]byte, error) {
+	if data, e := s.cache.Load(name); e == nil {
+		return data, nil
+	}
+
+	s.mutex.Lock()
+	g, ok := s.generations[name]
+	if !ok {
+		g = &cacheGeneration{}
+		s.generations[name] = g
+	}
+	g.loads++
+	n := g.n
+	s.mutex.Unlock()
+
+	data, e1 := s.storage.Load(name)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	g.loads--
+	if g.loads == 0 {
+		delete(s.generations, name)
+	}
+
+	if e1 != nil {
+		return nil, e1
+	}
+
+	if g.n == n {
+		_ = s.cache.Save(name, data)
+	}
+
+	return data, nil
+}
+
+// Location See Storage.Location.
+func (s *CacheStorage) Location(name string) string {
+	return s.storage.Location(name)
+}
+
+// Remove Delete data from storage and the cache.
+func (s *CacheStorage) Remove(name string) error {
+	defer s.Invalidate(name)
+
+	return s.storage.Remove(name)
+}
+
+// Save Write data to storage, and invalidate the cached data.
+func (s *CacheStorage) Save(name string, data []byte) error {
+	defer s.Invalidate(name)
+
+	return s.storage.Save(name, data)
+}
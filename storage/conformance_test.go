@@ -0,0 +1,75 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kohirens/www/storage"
+	"github.com/kohirens/www/storage/s3test"
+	"github.com/kohirens/www/storage/storagetest"
+)
+
+func TestLocalStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		s, e1 := storage.NewLocalStorage(t.TempDir())
+		if e1 != nil {
+			t.Fatal(e1)
+		}
+
+		return s
+	})
+}
+
+func TestBucketStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		srv := s3test.NewServer()
+		t.Cleanup(srv.Close)
+
+		s, e1 := storage.NewBucketStorageWithConfig(context.Background(), &storage.BucketConfig{
+			AccessKeyID:     "test",
+			Bucket:          "b",
+			Endpoint:        srv.URL,
+			HTTPClient:      srv.Client(),
+			PathStyle:       true,
+			Region:          "us-east-1",
+			SecretAccessKey: "test",
+		})
+		if e1 != nil {
+			t.Fatal(e1)
+		}
+
+		return s
+	})
+}
+
+func TestMemoryStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return storage.NewMemoryStorage(0, 0)
+	})
+}
+
+func TestDecorators_Conformance(t *testing.T) {
+	decorators := map[string]func(s storage.Storage) storage.Storage{
+		"cache": func(s storage.Storage) storage.Storage {
+			return storage.NewCacheStorage(s, 10, time.Minute)
+		},
+		"fallback": func(s storage.Storage) storage.Storage {
+			return storage.NewFallbackStorage(s, storage.NewMemoryStorage(0, 0))
+		},
+		"instrumented": func(s storage.Storage) storage.Storage {
+			return storage.NewInstrumentedStorage(s, &storage.StatsRecorder{})
+		},
+		"prefixed": func(s storage.Storage) storage.Storage {
+			return storage.NewPrefixedStorage(s, "tenant-a")
+		},
+	}
+
+	for name, decorate := range decorators {
+		t.Run(name, func(t *testing.T) {
+			storagetest.Run(t, func(t *testing.T) storage.Storage {
+				return decorate(storage.NewMemoryStorage(0, 0))
+			})
+		})
+	}
+}
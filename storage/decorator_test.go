@@ -0,0 +1,267 @@
+package storage
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// countingStorage Counts the loads from a MemoryStorage.
+type countingStorage struct {
+	*MemoryStorage
+	loads int
+}
+
+func (s *countingStorage) Load(name string) ([]byte, error) {
+	s.loads++
+	return s.MemoryStorage.Load(name)
+}
+
+func TestCacheStorage(t *testing.T) {
+	clock := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	memoryNow = func() time.Time { return clock }
+	t.Cleanup(func() { memoryNow = time.Now })
+
+	backing := &countingStorage{MemoryStorage: NewMemoryStorage(0, 0)}
+	_ = backing.Save("a", []byte("1"))
+
+	s := NewCacheStorage(backing, 10, time.Minute)
+
+	load := func(want string, wantLoads int) {
+		t.Helper()
+
+		got, e1 := s.Load("a")
+		if e1 != nil {
+			t.Fatalf("Load() error = %v", e1)
+		}
+
+		if string(got) != want {
+			t.Errorf("Load() = %s, want %s", got, want)
+		}
+
+		if backing.loads != wantLoads {
+			t.Errorf("loads = %v, want %v", backing.loads, wantLoads)
+		}
+	}
+
+	load("1", 1)
+	load("1", 1)
+
+	_ = backing.Save("a", []byte("2"))
+	load("1", 1) // Changed behind the cache, so it is stale until it expires.
+
+	clock = clock.Add(time.Minute)
+	load("2", 2)
+
+	_ = s.Save("a", []byte("3"))
+	load("3", 3)
+
+	s.Invalidate("a")
+	load("3", 4)
+
+	_ = s.Remove("a")
+	if _, e := s.Load("a"); e == nil {
+		t.Errorf("Load() after Remove() did not fail")
+	}
+}
+
+// blockingStorage Waits for a signal before loading from a MemoryStorage.
+type blockingStorage struct {
+	*MemoryStorage
+	loading chan struct{}
+	resume  chan struct{}
+}
+
+func (s *blockingStorage) Load(name string) ([]byte, error) {
+	data, e := s.MemoryStorage.Load(name)
+	s.loading <- struct{}{}
+	<-s.resume
+	return data, e
+}
+
+func TestCacheStorage_SaveWhileLoading(t *testing.T) {
+	backing := &blockingStorage{
+		MemoryStorage: NewMemoryStorage(0, 0),
+		loading:       make(chan struct{}),
+		resume:        make(chan struct{}),
+	}
+	_ = backing.MemoryStorage.Save("a", []byte("old"))
+
+	s := NewCacheStorage(backing, 10, time.Minute)
+
+	done := make(chan []byte)
+	go func() {
+		data, _ := s.Load("a")
+		done <- data
+	}()
+
+	// Save while the load holds the old data.
+	<-backing.loading
+	if e := s.Save("a", []byte("new")); e != nil {
+		t.Fatalf("Save() error = %v", e)
+	}
+	close(backing.resume)
+
+	if got := <-done; string(got) != "old" {
+		t.Fatalf("Load() = %s, want old", got)
+	}
+
+	go func() { <-backing.loading }()
+	if got, _ := s.Load("a"); string(got) != "new" {
+		t.Errorf("Load() after Save() = %s, want new, not the old data cached by the load before", got)
+	}
+
+	if n := len(s.generations); n != 0 {
+		t.Errorf("generations = %v, want none once the loads are done", n)
+	}
+}
+
+func TestPrefixedStorage(t *testing.T) {
+	cases := []struct {
+		name   string
+		prefix string
+		key    string
+		want   string
+	}{
+		{"joined", "tenant-a", "a.txt", "tenant-a/a.txt"},
+		{"slashes", "/tenant-a/", "/a.txt", "tenant-a/a.txt"},
+		{"nested", "env/tenant-a", "dir/a.txt", "env/tenant-a/dir/a.txt"},
+		{"no-prefix", "", "a.txt", "a.txt"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			backing := NewMemoryStorage(0, 0)
+			s := NewPrefixedStorage(backing, c.prefix)
+
+			if got := s.Location(c.key); got != c.want {
+				t.Errorf("Location() = %v, want %v", got, c.want)
+			}
+
+			_ = s.Save(c.key, []byte("1"))
+
+			if !backing.Exist(c.want) {
+				t.Errorf("Save() did not save to %v", c.want)
+			}
+		})
+	}
+}
+
+func TestReadOnlyStorage(t *testing.T) {
+	backing := NewMemoryStorage(0, 0)
+	_ = backing.Save("a", []byte("1"))
+
+	s := NewReadOnlyStorage(backing)
+
+	if got, _ := s.Load("a"); string(got) != "1" {
+		t.Errorf("Load() = %s, want 1", got)
+	}
+
+	if e := s.Save("a", []byte("2")); !errors.Is(e, ErrReadOnly) {
+		t.Errorf("Save() error = %v, want ErrReadOnly", e)
+	}
+
+	if e := s.Remove("a"); !errors.Is(e, ErrReadOnly) {
+		t.Errorf("Remove() error = %v, want ErrReadOnly", e)
+	}
+
+	if !backing.Exist("a") {
+		t.Errorf("the read-only storage was changed")
+	}
+}
+
+func TestFallbackStorage(t *testing.T) {
+	local := NewMemoryStorage(0, 0)
+	bucket := NewMemoryStorage(0, 0)
+	_ = local.Save("dir/a", []byte("local"))
+	_ = bucket.Save("dir/a", []byte("bucket"))
+	_ = bucket.Save("dir/b", []byte("bucket"))
+
+	s := NewFallbackStorage(local, bucket)
+
+	if got, _ := s.Load("dir/a"); string(got) != "local" {
+		t.Errorf("Load() = %s, want local", got)
+	}
+
+	if got, _ := s.Load("dir/b"); string(got) != "bucket" {
+		t.Errorf("Load() = %s, want bucket", got)
+	}
+
+	if local.Exist("dir/b") {
+		t.Errorf("Load() filled the local storage without Fill")
+	}
+
+	names, _ := s.List("dir")
+	if want := []string{"a", "b"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("List() = %v, want %v", names, want)
+	}
+
+	s.Fill = true
+	_, _ = s.Load("dir/b")
+	if !local.Exist("dir/b") {
+		t.Errorf("Load() did not fill the local storage")
+	}
+
+	_ = s.Save("dir/c", []byte("local"))
+	if bucket.Exist("dir/c") {
+		t.Errorf("Save() wrote to the bucket, want only the first storage")
+	}
+
+	_ = s.Remove("dir/a")
+	if s.Exist("dir/a") {
+		t.Errorf("Remove() left data in a storage")
+	}
+
+	if _, e := s.Load("dir/missing"); !errors.Is(e, fs.ErrNotExist) {
+		t.Errorf("Load() of missing data error = %v, want fs.ErrNotExist", e)
+	}
+}
+
+// failingStorage Fails every load.
+type failingStorage struct {
+	*MemoryStorage
+}
+
+func (s *failingStorage) Load(string) ([]byte, error) {
+	return nil, errors.New("unavailable")
+}
+
+func TestFallbackStorage_Errors(t *testing.T) {
+	bucket := NewMemoryStorage(0, 0)
+	_ = bucket.Save("a", []byte("bucket"))
+
+	s := NewFallbackStorage(&failingStorage{NewMemoryStorage(0, 0)}, bucket)
+
+	if got, e := s.Load("a"); e == nil || errors.Is(e, fs.ErrNotExist) {
+		t.Errorf("Load() = %s, %v, want the error of the first storage", got, e)
+	}
+}
+
+func TestInstrumentedStorage(t *testing.T) {
+	clock := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	instrumentedNow = func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	}
+	t.Cleanup(func() { instrumentedNow = time.Now })
+
+	r := &StatsRecorder{}
+	s := NewInstrumentedStorage(NewMemoryStorage(0, 0), r)
+
+	_ = s.Save("a", []byte("1"))
+	_, _ = s.Load("a")
+	_, _ = s.Load("missing")
+	_ = s.Exist("a")
+
+	want := map[string]OpStats{
+		"Exist": {Count: 1, Latency: time.Millisecond, Max: time.Millisecond},
+		"Load":  {Count: 2, Errors: 1, Latency: 2 * time.Millisecond, Max: time.Millisecond},
+		"Save":  {Count: 1, Latency: time.Millisecond, Max: time.Millisecond},
+	}
+
+	if got := r.Stats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Stats() = %v, want %v", got, want)
+	}
+}
@@ -1,6 +1,12 @@
 package storage
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
+
+// errClosed Returned by writers used after Close.
+var errClosed = errors.New(stderr.WriterClosed)
 
 type ErrDirNoExist struct {
 	data string
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"errors"
+	"io/fs"
+	"slices"
+)
+
+// FallbackStorage Reads from a chain of storage, in order, such as local
+// files first and then a bucket. Data is loaded from the first storage that
+// has it, and saved to the first storage.
+type FallbackStorage struct {
+	// Fill Save data loaded from a later storage to those before it, so the
+	// next load is from the first. This makes a read-through cache of the
+	// first storage.
+	Fill    bool
+	storage []Storage
+}
+
+var _ Storage = (*FallbackStorage)(nil)
+
+// NewFallbackStorage Initialize a chain of storage, it needs at least one.
+func NewFallbackStorage(first Storage, rest ...Storage) *FallbackStorage {
+	return &FallbackStorage{storage: append([]Storage{first}, rest...)}
+}
+
+// Exist Verify the data is in any of the storage.
+func (s *FallbackStorage) Exist(name string) bool {
+	for _, st := range s.storage {
+		if st.Exist(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// List The names in a location of all the storage, sorted and without
+// duplicates.
+func (s *FallbackStorage) List(location string) ([]string, error) {
+	names := make([]string, 0)
+
+	for _, st := range s.storage {
+		found, e1 := st.List(location)
+		if e1 != nil {
+			return nil, e1
+		}
+		names = append(names, found...)
+	}
+
+	slices.Sort(names)
+
+	return slices.Compact(names), nil
+}
+
+// Load Retrieve data from the first storage that has it. Only data that is
+// not found, an error that wraps fs.ErrNotExist, is looked for in the next
+// storage; any other error is returned, so that a storage that is down does
+// not hide its data behind older data in a later one. When none have it, the
+// error is that of the last storage.
+func (s *FallbackStorage) Load(name string) ([]byte, error) {
+	var err error
+
+	for i, st := range s.storage {
+		data, e1 := st.Load(name)
+		if e1 != nil {
+			if !errors.Is(e1, fs.ErrNotExist) {
+				return nil, e1
+			}
+			err = e1
+			continue
+		}
+
+		if s.Fill {
+			for _, before := range s.storage[:i] {
+				_ = before.Save(name, data)
+			}
+		}
+
+		return data, nil
+	}
+
+	return nil, err
+}
+
+// Location The location in the first storage.
+func (s *FallbackStorage) Location(name string) string {
+	return s.storage[0].Location(name)
+}
+
+// Remove Delete data from all the storage, so that it cannot be loaded from
+// a later one. Data that is not in a storage is not an error.
+func (s *FallbackStorage) Remove(name string) error {
+	for _, st := range s.storage {
+		if !st.Exist(name) {
+			continue
+		}
+
+		if e := st.Remove(name); e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// Save Write data to the first storage.
+func (s *FallbackStorage) Save(name string, data []byte) error {
+	return s.storage[0].Save(name, data)
+}
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// InstrumentedStorage Records the latency and errors of every operation on
+// storage, such as to export them as metrics.
+type InstrumentedStorage struct {
+	recorder Recorder
+	storage  Storage
+}
+
+// Recorder Receives the measurements of InstrumentedStorage. The op is the
+// name of the method, such as "Load", and err is nil on success. It is
+// called concurrently.
+type Recorder interface {
+	Record(op string, latency time.Duration, err error)
+}
+
+// RecorderFunc Adapts a function to a Recorder.
+type RecorderFunc func(op string, latency time.Duration, err error)
+
+// StatsRecorder A Recorder that keeps the totals of each operation in memory.
+type StatsRecorder struct {
+	mutex sync.Mutex
+	stats map[string]OpStats
+}
+
+// OpStats The totals of an operation.
+type OpStats struct {
+	Count  int64
+	Errors int64
+	// Latency The sum of all the latencies, divide by Count for the mean.
+	Latency time.Duration
+	// Max The highest latency.
+	Max time.Duration
+}
+
+var _ Storage = (*InstrumentedStorage)(nil)
+
+// instrumentedNow Allows tests to control the clock.
+var instrumentedNow = time.Now
+
+// NewInstrumentedStorage Initialize storage that records the operations on s
+// with r.
+func NewInstrumentedStorage(s Storage, r Recorder) *InstrumentedStorage {
+	return &InstrumentedStorage{recorder: r, storage: s}
+}
+
+// Exist See Storage.Exist, it is never an error.
+func (s *InstrumentedStorage) Exist(name string) bool {
+	start := instrumentedNow()
+	found := s.storage.Exist(name)
+	s.record("Exist", start, nil)
+
+	return found
+}
+
+// List See Storage.List.
+func (s *InstrumentedStorage) List(location string) ([]string, error) {
+	start := instrumentedNow()
+	names, e1 := s.storage.List(location)
+	s.record("List", start, e1)
+
+	return names, e1
+}
+
+// Load See Storage.Load.
+func (s *InstrumentedStorage) Load(name string) ([]byte, error) {
+	start := instrumentedNow()
+	data, e1 := s.storage.Load(name)
+	s.record("Load", start, e1)
+
+	return data, e1
+}
+
+// Location See Storage.Location, it is not recorded.
+func (s *InstrumentedStorage) Location(name string) string {
+	return s.storage.Location(name)
+}
+
+// Remove See Storage.Remove.
+func (s *InstrumentedStorage) Remove(name string) error {
+	start := instrumentedNow()
+	e1 := s.storage.Remove(name)
+	s.record("Remove", start, e1)
+
+	return e1
+}
+
+// Save See Storage.Save.
+func (s *InstrumentedStorage) Save(name string, data []byte) error {
+	start := instrumentedNow()
+	e1 := s.storage.Save(name, data)
+	s.record("Save", start, e1)
+
+	return e1
+}
+
+func (s *InstrumentedStorage) record(op string, start time.Time, err error) {
+	s.recorder.Record(op, instrumentedNow().Sub(start), err)
+}
+
+// Record Calls f.
+func (f RecorderFunc) Record(op string, latency time.Duration, err error) {
+	f(op, latency, err)
+}
+
+// Record Add the measurement to the totals of the operation.
+func (r *StatsRecorder) Record(op string, latency time.Duration, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.stats == nil {
+		r.stats = make(map[string]OpStats)
+	}
+
+	st := r.stats[op]
+	st.Count++
+	if err != nil {
+		st.Errors++
+	}
+	st.Latency += latency
+	st.Max = max(st.Max, latency)
+	r.stats[op] = st
+}
+
+// Stats A copy of the totals, by operation.
+func (r *StatsRecorder) Stats() map[string]OpStats {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stats := make(map[string]OpStats, len(r.stats))
+	for op, st := range r.stats {
+		stats[op] = st
+	}
+
+	return stats
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxKeyLength The longest key in bytes, the limit of an S3 object key.
+const MaxKeyLength = 1024
+
+const (
+	// keySafe The characters other than letters and digits that S3 calls
+	// safe in an object key.
+	keySafe = "!-_.*'()"
+	// keyExtra The characters S3 allows with care that a key can also have,
+	// as they are common in names and safe in a POSIX path, such as the ":"
+	// of an ISO 8601 time.
+	keyExtra = " +,:=@~"
+)
+
+// InvalidKeyError Returned for a name that NormalizeKey rejects.
+type InvalidKeyError struct {
+	data string
+}
+
+func (e *InvalidKeyError) Error() string {
+	return fmt.Sprintf(stderr.InvalidKey, e.data)
+}
+
+// NormalizeKey Make a name into a key that is the same in every storage, and
+// that cannot escape its location, such as the WorkDir of LocalStorage or
+// the Prefix of BucketStorage. The separator is "/", a "\" is converted to
+// it, and leading, trailing and repeated separators, and "." segments are
+// removed. So "/templates\\a.html" is "templates/a.html". A name with a ".."
+// segment, invalid UTF-8, or longer than MaxKeyLength, is rejected with an
+// InvalidKeyError. So is a name with a character that is not allowed, see
+// ValidKeyRune. An empty name is the root location, which holds no data.
+func NormalizeKey(name string) (string, error) {
+	if len(name) > MaxKeyLength {
+		return "", &InvalidKeyError{name[:32] + "..."}
+	}
+
+	if !utf8.ValidString(name) || strings.ContainsFunc(name, invalidNameRune) {
+		return "", &InvalidKeyError{name}
+	}
+
+	segments := strings.Split(strings.ReplaceAll(name, `\`, "/"), "/")
+	key := make([]string, 0, len(segments))
+
+	for _, seg := range segments {
+		switch seg {
+		case "", ".":
+			continue
+		case "..":
+			return "", &InvalidKeyError{name}
+		}
+		key = append(key, seg)
+	}
+
+	return strings.Join(key, "/"), nil
+}
+
+// ValidKeyRune Whether a key can have the character r, other than the "/"
+// separator. Allowed are the characters S3 calls safe: ASCII letters and
+// digits and "!-_.*'()". Extended with the letters, marks and digits of
+// other scripts, so that "modèles" is a key, and " +,:=@~", so that keys
+// such as "logs/2024-01-02T03:04:05Z.json" can be reached.
+func ValidKeyRune(r rune) bool {
+	if r < utf8.RuneSelf {
+		return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' ||
+			strings.ContainsRune(keySafe+keyExtra, r)
+	}
+
+	return unicode.In(r, unicode.Letter, unicode.Mark, unicode.Digit)
+}
+
+// invalidNameRune Whether a name cannot have the character r, separators
+// included.
+func invalidNameRune(r rune) bool {
+	return r != '/' && r != '\\' && !ValidKeyRune(r)
+}
+
+// joinKey Join a prefix and a normalized key with a "/".
+func joinKey(prefix, key string) string {
+	prefix = strings.Trim(prefix, "/")
+
+	switch {
+	case prefix == "":
+		return key
+	case key == "":
+		return prefix
+	}
+
+	return prefix + "/" + key
+}
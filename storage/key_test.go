@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{"plain", "templates/a.html", "templates/a.html", false},
+		{"leading-slash", "/templates/a.html", "templates/a.html", false},
+		{"backslash", `templates\a.html`, "templates/a.html", false},
+		{"repeated", "templates//./a.html/", "templates/a.html", false},
+		{"root", "/", "", false},
+		{"dots-in-name", "a..b/.c", "a..b/.c", false},
+		{"unicode", "modèles/a.html", "modèles/a.html", false},
+		{"s3-safe", "a!-_.*'()b.txt", "a!-_.*'()b.txt", false},
+		{"extension", "a b+c,d=e@f~g.txt", "a b+c,d=e@f~g.txt", false},
+		{"timestamp", "logs/2024-01-02T03:04:05Z.json", "logs/2024-01-02T03:04:05Z.json", false},
+		{"ampersand", "a&b", "", true},
+		{"dollar", "$a", "", true},
+		{"query", "a?b=c", "", true},
+		{"percent", "a%2e%2e/b", "", true},
+		{"hash", "a#b", "", true},
+		{"angle", "<a>", "", true},
+		{"pipe", "a|b", "", true},
+		{"quote", `a"b`, "", true},
+		{"symbol", "a😀.txt", "", true},
+		{"parent", "../etc/passwd", "", true},
+		{"inner-parent", "a/../../etc/passwd", "", true},
+		{"backslash-parent", `a\..\..\etc`, "", true},
+		{"control", "a\x00.txt", "", true},
+		{"newline", "a\n.txt", "", true},
+		{"invalid-utf8", "a\xff.txt", "", true},
+		{"too-long", strings.Repeat("a", MaxKeyLength+1), "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, e1 := NormalizeKey(tt.key)
+			if (e1 != nil) != tt.wantErr {
+				t.Fatalf("NormalizeKey() error = %v, wantErr %v", e1, tt.wantErr)
+			}
+
+			var ik *InvalidKeyError
+			if tt.wantErr && !errors.As(e1, &ik) {
+				t.Errorf("NormalizeKey() error = %T, want *InvalidKeyError", e1)
+			}
+
+			if got != tt.want {
+				t.Errorf("NormalizeKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBucketStorage_Location(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		key    string
+		want   string
+	}{
+		{"no-prefix", "", "/a.txt", "a.txt"},
+		{"prefix", "p", "a.txt", "p/a.txt"},
+		{"prefix-slash", "p/", "/a.txt", "p/a.txt"},
+		{"root", "p/", "", "p"},
+		{"traversal", "p/", "../a.txt", ""},
+		{"not-allowed", "p/", "a?b.txt", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &BucketStorage{Prefix: tt.prefix}
+
+			if got := s.Location(tt.key); got != tt.want {
+				t.Errorf("Location() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBucketStorage_TimestampedKey(t *testing.T) {
+	srv, s := newTestBucket(t, &BucketConfig{Prefix: "p/"})
+
+	// Saved before keys were normalized, such as by another tool.
+	key := "logs/2024-01-02T03:04:05Z~1.json"
+	srv.PutObject("b", "p/"+key, []byte("log"))
+
+	if got, e := s.Load(key); e != nil || string(got) != "log" {
+		t.Errorf("Load() = %s, %v, want log", got, e)
+	}
+
+	if e := s.Remove(key); e != nil {
+		t.Errorf("Remove() error = %v", e)
+	}
+	if _, ok := srv.Object("b", "p/"+key); ok {
+		t.Errorf("Remove() left the object")
+	}
+}
+
+func TestLocalStorage_Traversal(t *testing.T) {
+	dir := t.TempDir()
+	s := &LocalStorage{WorkDir: filepath.Join(dir, "work")}
+
+	if e := s.Save("../escaped.txt", []byte("1")); e == nil {
+		t.Errorf("Save() outside the work dir did not fail")
+	}
+
+	if s.Exist("../work/../../etc/passwd") {
+		t.Errorf("Exist() outside the work dir = true, want false")
+	}
+
+	if _, e := s.Load(`..\..\etc\passwd`); e == nil {
+		t.Errorf("Load() outside the work dir did not fail")
+	}
+
+	if _, e := s.ListPage(t.Context(), "..", nil); e == nil {
+		t.Errorf("ListPage() outside the work dir did not fail")
+	}
+
+	var ik *InvalidKeyError
+	if e := s.Save("a?b.txt", []byte("1")); !errors.As(e, &ik) {
+		t.Errorf("Save() of a name that is not allowed error = %v, want an InvalidKeyError", e)
+	}
+}
+
+func TestValidKeyRune(t *testing.T) {
+	for _, r := range "azAZ09!-_.*'() +,:=@~èß日٣" {
+		if !ValidKeyRune(r) {
+			t.Errorf("ValidKeyRune(%q) = false, want true", r)
+		}
+	}
+
+	for _, r := range "/\\;?#%&$<>|\"`^{}[]\x00\t\n\u200b\ufffd😀" {
+		if ValidKeyRune(r) {
+			t.Errorf("ValidKeyRune(%q) = true, want false", r)
+		}
+	}
+}
+
+func FuzzNormalizeKey(f *testing.F) {
+	for _, seed := range []string{"a.txt", "/a/b", `a\b`, "../a", "a/../../b", "./.", "..", "a/..\\..", "\x00", "C:\\a"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, key string) {
+		got, e1 := NormalizeKey(key)
+		if e1 != nil {
+			return
+		}
+
+		if strings.HasPrefix(got, "/") || strings.HasSuffix(got, "/") || strings.Contains(got, "//") || strings.Contains(got, `\`) {
+			t.Errorf("NormalizeKey(%q) = %q, which has an extra separator", key, got)
+		}
+
+		for _, seg := range strings.Split(got, "/") {
+			if seg == ".." || seg == "." {
+				t.Errorf("NormalizeKey(%q) = %q, which has a %q segment", key, got, seg)
+			}
+		}
+
+		if i := strings.IndexFunc(got, invalidNameRune); i >= 0 {
+			t.Errorf("NormalizeKey(%q) = %q, which has a character that is not allowed at %v", key, got, i)
+		}
+
+		again, e2 := NormalizeKey(got)
+		if e2 != nil || again != got {
+			t.Errorf("NormalizeKey(%q) = %q, %v, want it unchanged", got, again, e2)
+		}
+	})
+}
+
+func FuzzLocalStorage_Location(f *testing.F) {
+	for _, seed := range []string{"a.txt", "../a", "a/../../b", `..\a`, "/etc/passwd", "a/./b/..", "NUL"} {
+		f.Add(seed)
+	}
+
+	workDir := filepath.Join("tmp", "work")
+	s := &LocalStorage{WorkDir: workDir}
+
+	f.Fuzz(func(t *testing.T, key string) {
+		got := s.Location(key)
+		if got == "" {
+			return
+		}
+
+		rel, e1 := filepath.Rel(workDir, got)
+		if e1 != nil || !(rel == "." || filepath.IsLocal(rel)) {
+			t.Errorf("Location(%q) = %q, which is outside %q", key, got, workDir)
+		}
+	})
+}
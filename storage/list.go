@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"iter"
+)
+
+// ListOptions Control how a location is listed, the zero value lists the
+// files directly in the location in pages of the storage's default size.
+type ListOptions struct {
+	// ContinuationToken The NextToken of the page before, to list the next
+	// page.
+	ContinuationToken string
+	// MaxKeys The most names in a page. Zero for the default of the storage,
+	// which is 1000 for BucketStorage and no limit for LocalStorage.
+	MaxKeys int32
+	// Recursive List the files in sub-locations as well, named relative to
+	// the location, such as "sub/a.txt".
+	Recursive bool
+	// StartAfter List the names that sort after this name, relative to the
+	// location.
+	StartAfter string
+}
+
+// ListPage A page of the names in a location, sorted.
+type ListPage struct {
+	// Names Of the files, relative to the location.
+	Names []string
+	// NextToken Set the ContinuationToken of ListOptions to this to list the
+	// next page. It is empty on the last page.
+	NextToken string
+	// Prefixes The sub-locations, when the listing is not recursive. Such as
+	// the directories of LocalStorage, or the common prefixes of
+	// BucketStorage.
+	Prefixes []string
+}
+
+// Names An iterator over the names in a location, listed a page at a time
+// when the storage is a PagedStorage. Otherwise, the names are those of
+// List, which is not recursive. Iteration stops after an error.
+//
+//	for name, err := range storage.Names(ctx, store, "uploads", nil) {
+//		if err != nil {
+//			return err
+//		}
+//	}
+func Names(ctx context.Context, s Storage, location string, opts *ListOptions) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		ps, ok := s.(PagedStorage)
+		if !ok {
+			names, e1 := listContext(ctx, s, location)
+			if e1 != nil {
+				yield("", e1)
+				return
+			}
+
+			for _, name := range names {
+				if !yield(name, nil) {
+					return
+				}
+			}
+			return
+		}
+
+		o := ListOptions{}
+		if opts != nil {
+			o = *opts
+		}
+
+		for {
+			page, e1 := ps.ListPage(ctx, location, &o)
+			if e1 != nil {
+				yield("", e1)
+				return
+			}
+
+			for _, name := range page.Names {
+				if !yield(name, nil) {
+					return
+				}
+			}
+
+			if page.NextToken == "" {
+				return
+			}
+
+			o.ContinuationToken = page.NextToken
+		}
+	}
+}
+
+// listContext List with the context when the storage is a ContextStorage.
+func listContext(ctx context.Context, s Storage, location string) ([]string, error) {
+	if cs, ok := s.(ContextStorage); ok {
+		return cs.ListContext(ctx, location)
+	}
+
+	if e := ctx.Err(); e != nil {
+		return nil, e
+	}
+
+	return s.List(location)
+}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestListPage(t *testing.T) {
+	files := []string{"loc/a.txt", "loc/b.txt", "loc/c.txt", "loc/sub/d.txt", "other/e.txt"}
+
+	tests := []struct {
+		name    string
+		storage func(t *testing.T) Storage
+	}{
+		{"local", func(t *testing.T) Storage {
+			s := &LocalStorage{WorkDir: t.TempDir()}
+			_ = os.MkdirAll(s.Location("loc/sub"), 0700)
+			_ = os.MkdirAll(s.Location("other"), 0700)
+			_ = os.WriteFile(s.Location("loc/"+localTmpPrefix+"123"), nil, 0600)
+			return s
+		}},
+		{"bucket", func(t *testing.T) Storage {
+			_, s := newTestBucket(t, &BucketConfig{Prefix: "p/"})
+			return s
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := tt.storage(t)
+
+			for _, f := range files {
+				if e := s.Save(f, []byte(f)); e != nil {
+					t.Fatalf("Save() error = %v", e)
+				}
+			}
+			_ = s.(MetadataStorage).SaveWithOptions(ctx, "loc/a.txt", []byte("a"), &SaveOptions{ContentType: "text/plain"})
+
+			got, e1 := s.List("loc")
+			if want := []string{"a.txt", "b.txt", "c.txt"}; e1 != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("List() = %v, %v, want %v", got, e1, want)
+			}
+
+			page, e2 := s.(PagedStorage).ListPage(ctx, "loc", nil)
+			if e2 != nil {
+				t.Fatalf("ListPage() error = %v", e2)
+			}
+			if want := []string{"sub"}; !reflect.DeepEqual(page.Prefixes, want) {
+				t.Errorf("ListPage() prefixes = %v, want %v", page.Prefixes, want)
+			}
+			if page.NextToken != "" {
+				t.Errorf("ListPage() next token = %q, want none on the last page", page.NextToken)
+			}
+
+			first, _ := s.(PagedStorage).ListPage(ctx, "loc", &ListOptions{MaxKeys: 2})
+			if want := []string{"a.txt", "b.txt"}; !reflect.DeepEqual(first.Names, want) || first.NextToken == "" {
+				t.Errorf("ListPage() with MaxKeys = %v and next token %q, want %v and a token", first.Names, first.NextToken, want)
+			}
+
+			names := map[string]*ListOptions{
+				"paged":       {MaxKeys: 1},
+				"recursive":   {MaxKeys: 1, Recursive: true},
+				"start-after": {StartAfter: "a.txt"},
+				"missing":     nil,
+			}
+			wants := map[string][]string{
+				"paged":       {"a.txt", "b.txt", "c.txt"},
+				"recursive":   {"a.txt", "b.txt", "c.txt", "sub/d.txt"},
+				"start-after": {"b.txt", "c.txt"},
+				"missing":     {},
+			}
+			for name, opts := range names {
+				location := "loc"
+				if name == "missing" {
+					location = "missing"
+				}
+
+				got := []string{}
+				for n, e := range Names(ctx, s, location, opts) {
+					if e != nil {
+						t.Fatalf("Names(%v) error = %v", name, e)
+					}
+					got = append(got, n)
+				}
+
+				if !reflect.DeepEqual(got, wants[name]) {
+					t.Errorf("Names(%v) = %v, want %v", name, got, wants[name])
+				}
+			}
+		})
+	}
+}
+
+func TestLocalStorage_ListPage_Empty(t *testing.T) {
+	s := &LocalStorage{WorkDir: t.TempDir()}
+	_ = os.Mkdir(filepath.Join(s.WorkDir, "empty"), 0700)
+
+	for _, location := range []string{"", "empty", "missing"} {
+		got, e1 := s.List(location)
+		if e1 != nil || len(got) != 0 {
+			t.Errorf("List(%q) = %v, %v, want no files", location, got, e1)
+		}
+	}
+}
@@ -1,19 +1,45 @@
 package storage
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/kohirens/stdlib/fsio"
 )
 
-var ps = string(os.PathSeparator)
+// localDirPerms Of the directories made for files, only the owner can use
+// them.
+const localDirPerms = 0700
+
+// localFilePerms Of the files and sidecar files, only the owner can read
+// them.
+const localFilePerms = 0600
+
+// localMetaSuffix Added to the name of a file to name the sidecar file that
+// holds its SaveOptions and checksum. Keys cannot end with it.
+const localMetaSuffix = ".meta.json"
+
+// localTmpPrefix Starts the names of the temporary files, that are renamed
+// once written.
+const localTmpPrefix = ".tmp-"
 
 // LocalStorage Save data in local files.
 type LocalStorage struct {
+	// Signer Signs the URLs of PresignGet and PresignPut, which cannot be
+	// used without it.
+	Signer  *URLSigner
 	WorkDir string
 }
 
@@ -29,7 +55,10 @@ func NewLocalStorage(wd string) (*LocalStorage, error) {
 
 // Exist Retrieve file from storage.
 func (s *LocalStorage) Exist(filename string) bool {
-	filePath := s.Location(filename)
+	filePath, e1 := s.path(filename)
+	if e1 != nil {
+		return false
+	}
 
 	Log.Dbugf(stdout.Load, filePath)
 
@@ -37,73 +66,494 @@ func (s *LocalStorage) Exist(filename string) bool {
 }
 
 // List Files in a location in storage. It is not recursive, it only lists
-// files in the specified directory.
+// files in the specified directory. A directory that does not exist has no
+// files.
 func (s *LocalStorage) List(location string) ([]string, error) {
-	filePath := s.Location(location)
+	return s.ListContext(context.Background(), location)
+}
+
+// Load Retrieve file from storage.
+func (s *LocalStorage) Load(filename string) ([]byte, error) {
+	filePath, e1 := s.path(filename)
+	if e1 != nil {
+		return nil, e1
+	}
+
 	Log.Dbugf(stdout.Load, filePath)
-	files := make([]string, 0)
-	prefix := filePath + ps
-	e1 := filepath.WalkDir(filePath, func(path string, d fs.DirEntry, err error) error {
-		filename := strings.Replace(path, prefix, "", 1)
-		if filename == "" {
+
+	if !fsio.Exist(filePath) {
+		return nil, fmt.Errorf(stderr.NotFound, filePath, fs.ErrNotExist)
+	}
+
+	content, e2 := os.ReadFile(filePath)
+	if e2 != nil {
+		return nil, &ErrReadFile{filePath + " " + e2.Error()}
+	}
+
+	return content, nil
+}
+
+// Save Write data to a file, making its directory when it does not exist.
+func (s *LocalStorage) Save(filename string, data []byte) error {
+	filePath, e1 := s.path(filename)
+	if e1 != nil {
+		return e1
+	}
+
+	if e := writeFile(filePath, data); e != nil {
+		return e
+	}
+
+	return removeMeta(filePath)
+}
+
+// Location The path of a file in the WorkDir, the name is normalized by
+// NormalizeKey. It is empty when the name is invalid.
+func (s *LocalStorage) Location(filename string) string {
+	filePath, _ := s.path(filename)
+
+	return filePath
+}
+
+// Remove Delete a file from storage.
+func (s *LocalStorage) Remove(filename string) error {
+	fullFilename, e1 := s.path(filename)
+	if e1 != nil {
+		return e1
+	}
+
+	if e := os.Remove(fullFilename); e != nil {
+		return fmt.Errorf(stderr.RemoveFile, e.Error())
+	}
+
+	return removeMeta(fullFilename)
+}
+
+var (
+	_ ContextStorage  = (*LocalStorage)(nil)
+	_ MetadataStorage = (*LocalStorage)(nil)
+	_ PagedStorage    = (*LocalStorage)(nil)
+	_ StreamStorage   = (*LocalStorage)(nil)
+)
+
+// Create A writer to a file in storage. The data is written to a temporary
+// file that replaces the file on Close, so readers never see part of it.
+func (s *LocalStorage) Create(ctx context.Context, filename string) io.WriteCloser {
+	filePath, e1 := s.path(filename)
+	if e1 != nil {
+		return &errWriter{e1}
+	}
+
+	if e := os.MkdirAll(filepath.Dir(filePath), localDirPerms); e != nil {
+		return &errWriter{&ErrWriteFile{e.Error()}}
+	}
+
+	tmp, e2 := os.CreateTemp(filepath.Dir(filePath), localTmpPrefix+"*")
+	if e2 != nil {
+		return &errWriter{&ErrWriteFile{e2.Error()}}
+	}
+
+	return &fileWriter{ctx: ctx, file: tmp, path: filePath}
+}
+
+// ExistContext See Exist.
+func (s *LocalStorage) ExistContext(ctx context.Context, filename string) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	return s.Exist(filename)
+}
+
+// ListContext See List.
+func (s *LocalStorage) ListContext(ctx context.Context, location string) ([]string, error) {
+	page, e1 := s.ListPage(ctx, location, nil)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	return page.Names, nil
+}
+
+// ListPage A page of the files in a directory, the directory is read whole
+// for each page. The ContinuationToken is the last name of the page before,
+// and Prefixes are only on the first page. The temporary files and the
+// sidecar files are left out.
+func (s *LocalStorage) ListPage(ctx context.Context, location string, opts *ListOptions) (*ListPage, error) {
+	if e := ctx.Err(); e != nil {
+		return nil, e
+	}
+
+	if opts == nil {
+		opts = &ListOptions{}
+	}
+
+	dir, e1 := s.path(location)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	Log.Dbugf(stdout.Load, dir)
+
+	page := &ListPage{Names: []string{}, Prefixes: []string{}}
+
+	e2 := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+
+		if path == dir {
+			return nil
+		}
+
+		if e := ctx.Err(); e != nil {
+			return e
+		}
+
+		if strings.HasPrefix(d.Name(), localTmpPrefix) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
 			return nil
 		}
 
-		files = append(files, filename)
+		rel, _ := filepath.Rel(dir, path)
+		rel = filepath.ToSlash(rel)
+
+		switch {
+		case d.IsDir() && !opts.Recursive:
+			page.Prefixes = append(page.Prefixes, rel)
+			return fs.SkipDir
+		case d.IsDir(), strings.HasSuffix(rel, localMetaSuffix):
+			return nil
+		}
+
+		page.Names = append(page.Names, rel)
 
 		return nil
 	})
-	if e1 != nil {
-		return nil, fmt.Errorf(stderr.ListFiles, e1.Error())
+	if e2 != nil {
+		if ctx.Err() != nil {
+			return nil, e2
+		}
+		return nil, fmt.Errorf(stderr.ListFiles, e2.Error())
 	}
 
-	if files[0] == filePath {
-		return files[1:], nil
+	// Walked in lexical order by directory, which is not the order of the
+	// names, "a.txt" sorts before "a/b.txt".
+	sort.Strings(page.Names)
+
+	after := max(opts.StartAfter, opts.ContinuationToken)
+	page.Names = slices.DeleteFunc(page.Names, func(name string) bool { return name <= after })
+	if opts.ContinuationToken != "" {
+		page.Prefixes = []string{}
 	}
+	page.Prefixes = slices.DeleteFunc(page.Prefixes, func(name string) bool { return name <= opts.StartAfter })
 
-	return files, nil
+	if opts.MaxKeys > 0 && len(page.Names) > int(opts.MaxKeys) {
+		page.Names = page.Names[:opts.MaxKeys]
+		page.NextToken = page.Names[len(page.Names)-1]
+	}
+
+	return page, nil
 }
 
-// Load Retrieve file from storage.
-func (s *LocalStorage) Load(filename string) ([]byte, error) {
-	filePath := s.Location(filename)
+// LoadContext See Load.
+func (s *LocalStorage) LoadContext(ctx context.Context, filename string) ([]byte, error) {
+	if e := ctx.Err(); e != nil {
+		return nil, e
+	}
+
+	return s.Load(filename)
+}
+
+// Open A file in storage for reading.
+func (s *LocalStorage) Open(ctx context.Context, filename string) (io.ReadCloser, Info, error) {
+	if e := ctx.Err(); e != nil {
+		return nil, Info{}, e
+	}
+
+	filePath, e1 := s.path(filename)
+	if e1 != nil {
+		return nil, Info{}, e1
+	}
 
 	Log.Dbugf(stdout.Load, filePath)
 
-	if !fsio.Exist(filePath) {
-		return nil, fmt.Errorf("%v %v", filePath, fs.ErrNotExist)
+	f, e2 := os.Open(filePath)
+	if e2 != nil {
+		return nil, Info{}, fmt.Errorf(stderr.NotFound, filePath, e2)
+	}
+
+	fi, e3 := f.Stat()
+	if e3 != nil {
+		_ = f.Close()
+		return nil, Info{}, &ErrReadFile{filePath + " " + e3.Error()}
+	}
+
+	info := Info{
+		ModTime: fi.ModTime(),
+		Name:    filename,
+		Size:    fi.Size(),
+	}
+
+	if e := loadMeta(filePath, &info); e != nil {
+		_ = f.Close()
+		return nil, Info{}, e
 	}
 
-	content, e1 := os.ReadFile(filePath)
+	if info.ETag == "" {
+		// Files saved by other means have no checksum.
+		info.ETag = fmt.Sprintf(`"%x-%x"`, info.ModTime.UnixNano(), info.Size)
+	}
+
+	return &contextReader{ctx, f}, info, nil
+}
+
+// RemoveContext See Remove.
+func (s *LocalStorage) RemoveContext(ctx context.Context, filename string) error {
+	if e := ctx.Err(); e != nil {
+		return e
+	}
+
+	return s.Remove(filename)
+}
+
+// SaveContext See Save.
+func (s *LocalStorage) SaveContext(ctx context.Context, filename string, data []byte) error {
+	if e := ctx.Err(); e != nil {
+		return e
+	}
+
+	return s.Save(filename, data)
+}
+
+// SaveWithOptions Write a file with its options and a SHA-256 hash of it
+// kept in a sidecar file, named after the file with the suffix ".meta.json",
+// which List leaves out. Without options it is Save, which keeps no sidecar.
+//
+//	NOTE: The file and its sidecar are written one after the other. The
+//	sidecar holds the size and modification time of the file, and is left
+//	out when they do not match, so a reader may see the new file without
+//	its options for a moment, but never with those of another.
+func (s *LocalStorage) SaveWithOptions(ctx context.Context, filename string, data []byte, opts *SaveOptions) error {
+	if e := ctx.Err(); e != nil {
+		return e
+	}
+
+	if opts == nil {
+		return s.Save(filename, data)
+	}
+
+	filePath, e1 := s.path(filename)
 	if e1 != nil {
-		return nil, &ErrReadFile{filePath + " " + e1.Error()}
+		return e1
 	}
 
-	return content, nil
+	if e := writeFile(filePath, data); e != nil {
+		return e
+	}
+
+	fi, e2 := os.Stat(filePath)
+	if e2 != nil {
+		return &ErrReadFile{filePath + " " + e2.Error()}
+	}
+
+	sum := sha256.Sum256(data)
+	meta := &localMeta{
+		Checksum:    hex.EncodeToString(sum[:]),
+		ModTime:     fi.ModTime(),
+		SaveOptions: *opts,
+		Size:        fi.Size(),
+	}
+
+	metaData, e3 := json.Marshal(meta)
+	if e3 != nil {
+		return &ErrEncodeJSON{e3.Error()}
+	}
+
+	return writeFile(filePath+localMetaSuffix, metaData)
 }
 
-// Save Write session data to the storage medium.
-func (s *LocalStorage) Save(filename string, data []byte) error {
-	filePath := s.Location(filename)
+// Stat Describe a file in storage, without reading it. The Checksum and
+// ETag are the SHA-256 hash kept by SaveWithOptions. Files saved without
+// options have no Checksum, and an ETag made from their size and
+// modification time.
+func (s *LocalStorage) Stat(ctx context.Context, filename string) (Info, error) {
+	r, info, e1 := s.Open(ctx, filename)
+	if e1 != nil {
+		return Info{}, e1
+	}
+
+	_ = r.Close()
+
+	return info, nil
+}
+
+// localMeta The content of a sidecar file.
+type localMeta struct {
+	SaveOptions
+	// Checksum A hex encoded SHA-256 hash of the file.
+	Checksum string `json:"checksum,omitempty"`
+	// ModTime Of the file the sidecar describes.
+	ModTime time.Time `json:"mod_time"`
+	// Size Of the file the sidecar describes.
+	Size int64 `json:"size"`
+}
+
+// loadMeta Fill in info from the sidecar of a file, when it has one that
+// describes the file of the info.
+func loadMeta(filePath string, info *Info) error {
+	data, e1 := os.ReadFile(filePath + localMetaSuffix)
+	if errors.Is(e1, fs.ErrNotExist) {
+		return nil
+	}
+	if e1 != nil {
+		return &ErrReadFile{filePath + localMetaSuffix + " " + e1.Error()}
+	}
+
+	meta := &localMeta{}
+	if e := json.Unmarshal(data, meta); e != nil {
+		return &ErrDecodeJSON{e.Error()}
+	}
 
-	if e := os.WriteFile(filePath, data, 0774); e != nil {
+	// Left from before the file was saved again.
+	if meta.Size != info.Size || !meta.ModTime.Equal(info.ModTime) {
+		return nil
+	}
+
+	info.CacheControl = meta.CacheControl
+	info.Checksum = meta.Checksum
+	info.ContentType = meta.ContentType
+	info.Metadata = meta.Metadata
+
+	if meta.Checksum != "" {
+		info.ETag = `"` + meta.Checksum + `"`
+	}
+
+	return nil
+}
+
+// writeFile Write data to a temporary file that is then renamed to the
+// file, so a reader never sees part of it. The directory of the file is made
+// when it does not exist.
+func writeFile(filePath string, data []byte) error {
+	if e := os.MkdirAll(filepath.Dir(filePath), localDirPerms); e != nil {
+		return &ErrWriteFile{e.Error()}
+	}
+
+	tmp, e1 := os.CreateTemp(filepath.Dir(filePath), localTmpPrefix+"*")
+	if e1 != nil {
+		return &ErrWriteFile{e1.Error()}
+	}
+
+	// Clean up the temporary file, unless it was renamed.
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, e := tmp.Write(data); e != nil {
+		_ = tmp.Close()
+		return &ErrWriteFile{e.Error()}
+	}
+
+	if e := tmp.Chmod(localFilePerms); e != nil {
+		_ = tmp.Close()
+		return &ErrWriteFile{e.Error()}
+	}
+
+	if e := tmp.Close(); e != nil {
+		return &ErrWriteFile{e.Error()}
+	}
+
+	if e := os.Rename(tmp.Name(), filePath); e != nil {
 		return &ErrWriteFile{e.Error()}
 	}
 
 	return nil
 }
 
-func (s *LocalStorage) Location(filename string) string {
-	return s.WorkDir + ps + filename
+// removeMeta Remove the sidecar of a file, when it has one.
+func removeMeta(filePath string) error {
+	e1 := os.Remove(filePath + localMetaSuffix)
+	if e1 != nil && !errors.Is(e1, fs.ErrNotExist) {
+		return fmt.Errorf(stderr.RemoveFile, e1.Error())
+	}
+
+	return nil
 }
 
-// Remove Delete a file from storage.
-func (s *LocalStorage) Remove(filename string) error {
-	fullFilename := s.Location(filename)
+// fileWriter Writes to a temporary file, that is renamed on Close.
+type fileWriter struct {
+	closed bool
+	ctx    context.Context
+	file   *os.File
+	path   string
+}
 
-	if e := os.Remove(fullFilename); e != nil {
-		return fmt.Errorf(stderr.RemoveFile, e.Error())
+func (w *fileWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errClosed
 	}
 
-	return nil
+	if e := w.ctx.Err(); e != nil {
+		return 0, e
+	}
+
+	n, e1 := w.file.Write(p)
+	if e1 != nil {
+		return n, &ErrWriteFile{e1.Error()}
+	}
+
+	return n, nil
+}
+
+func (w *fileWriter) Close() error {
+	if w.closed {
+		return errClosed
+	}
+	w.closed = true
+
+	// Clean up the temporary file, unless it was renamed.
+	defer func() { _ = os.Remove(w.file.Name()) }()
+
+	if e := w.file.Close(); e != nil {
+		return &ErrWriteFile{e.Error()}
+	}
+
+	if e := w.ctx.Err(); e != nil {
+		return e
+	}
+
+	if e := os.Chmod(w.file.Name(), localFilePerms); e != nil {
+		return &ErrWriteFile{e.Error()}
+	}
+
+	if e := os.Rename(w.file.Name(), w.path); e != nil {
+		return &ErrWriteFile{e.Error()}
+	}
+
+	return removeMeta(w.path)
+}
+
+// path The path of a file in the WorkDir, see NormalizeKey. An empty name is
+// the WorkDir. Names that end like a sidecar are invalid.
+func (s *LocalStorage) path(filename string) (string, error) {
+	key, e1 := NormalizeKey(filename)
+	if e1 != nil {
+		return "", e1
+	}
+
+	if strings.HasSuffix(key, localMetaSuffix) {
+		return "", &InvalidKeyError{filename}
+	}
+
+	rel := filepath.FromSlash(key)
+	// Catches what is only special on some systems, such as "NUL" on Windows.
+	if key != "" && !filepath.IsLocal(rel) {
+		return "", &InvalidKeyError{filename}
+	}
+
+	return filepath.Join(s.WorkDir, rel), nil
 }
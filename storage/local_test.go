@@ -107,7 +107,7 @@ func TestLocalStorage_List(t *testing.T) {
 		{
 			name:    "can_list_files",
 			workDir: "list",
-			want:    []string{"file-01.txt", "file-02.txt", "dir-01"},
+			want:    []string{"file-01.txt", "file-02.txt"},
 			wantErr: false,
 		},
 	}
@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"container/list"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStorage Keeps data in memory, for tests and for servers that run as
+// a single process. It is safe for concurrent use.
+type MemoryStorage struct {
+	// MaxEntries The most entries to keep, the least recently used entry is
+	// evicted to make room for a new one. Zero for no limit.
+	MaxEntries int
+	// TTL How long saved data lasts, zero for ever.
+	TTL   time.Duration
+	items map[string]*list.Element
+	mutex sync.Mutex
+	// order Entries from the most to the least recently used.
+	order *list.List
+	saves int
+}
+
+type memoryEntry struct {
+	data    []byte
+	expires time.Time
+	name    string
+}
+
+// memoryNow Allows tests to control the clock.
+var memoryNow = time.Now
+
+// memorySweepEvery Expired entries are swept after this many saves, so that
+// they do not pile up when nothing reads them.
+const memorySweepEvery = 128
+
+var _ Storage = (*MemoryStorage)(nil)
+
+// NewMemoryStorage Initialize storage in memory. See MaxEntries and TTL.
+func NewMemoryStorage(maxEntries int, ttl time.Duration) *MemoryStorage {
+	return &MemoryStorage{
+		MaxEntries: maxEntries,
+		TTL:        ttl,
+		items:      make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Exist Verify the data is in storage and has not expired.
+func (s *MemoryStorage) Exist(name string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.get(name) != nil
+}
+
+// List The names in a location, relative to it. It is not recursive, it only
+// lists names directly in the location.
+func (s *MemoryStorage) List(location string) ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	prefix := location
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	now := memoryNow()
+	names := make([]string, 0)
+
+	for name, el := range s.items {
+		if el.Value.(*memoryEntry).expired(now) {
+			continue
+		}
+
+		rel, ok := strings.CutPrefix(name, prefix)
+		if ok && rel != "" && !strings.Contains(rel, "/") {
+			names = append(names, rel)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Load Retrieve a copy of the data from storage.
+func (s *MemoryStorage) Load(filename string) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry := s.get(filename)
+	if entry == nil {
+		return nil, fmt.Errorf(stderr.NotFound, filename, fs.ErrNotExist)
+	}
+
+	return append([]byte(nil), entry.data...), nil
+}
+
+// Location The name in storage.
+func (s *MemoryStorage) Location(filename string) string {
+	return filename
+}
+
+// Remove Delete data from storage.
+func (s *MemoryStorage) Remove(filename string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if el, ok := s.items[filename]; ok {
+		s.order.Remove(el)
+		delete(s.items, filename)
+	}
+
+	return nil
+}
+
+// Save Write a copy of data to storage, it expires after the TTL.
+func (s *MemoryStorage) Save(filename string, data []byte) error {
+	var expires time.Time
+	if s.TTL > 0 {
+		expires = memoryNow().Add(s.TTL)
+	}
+
+	return s.SaveUntil(filename, data, expires)
+}
+
+// SaveUntil Write a copy of data to storage that expires at a time, zero for
+// never.
+func (s *MemoryStorage) SaveUntil(filename string, data []byte, expires time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry := &memoryEntry{
+		data:    append([]byte(nil), data...),
+		expires: expires,
+		name:    filename,
+	}
+
+	if el, ok := s.items[filename]; ok {
+		el.Value = entry
+		s.order.MoveToFront(el)
+	} else {
+		s.items[filename] = s.order.PushFront(entry)
+	}
+
+	s.saves++
+	if s.saves%memorySweepEvery == 0 {
+		s.sweep()
+	}
+
+	for s.MaxEntries > 0 && s.order.Len() > s.MaxEntries {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.items, oldest.Value.(*memoryEntry).name)
+	}
+
+	return nil
+}
+
+// Sweep Remove expired data, and return how much was removed.
+func (s *MemoryStorage) Sweep() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.sweep()
+}
+
+// get An entry that has not expired, marking it as the most recently used.
+// The caller must hold the mutex.
+func (s *MemoryStorage) get(name string) *memoryEntry {
+	el, ok := s.items[name]
+	if !ok {
+		return nil
+	}
+
+	entry := el.Value.(*memoryEntry)
+	if entry.expired(memoryNow()) {
+		s.order.Remove(el)
+		delete(s.items, name)
+		return nil
+	}
+
+	s.order.MoveToFront(el)
+
+	return entry
+}
+
+// sweep The caller must hold the mutex.
+func (s *MemoryStorage) sweep() int {
+	now := memoryNow()
+	n := 0
+
+	for name, el := range s.items {
+		if el.Value.(*memoryEntry).expired(now) {
+			s.order.Remove(el)
+			delete(s.items, name)
+			n++
+		}
+	}
+
+	return n
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMemoryStorage_MaxEntries(t *testing.T) {
+	s := NewMemoryStorage(2, 0)
+
+	_ = s.Save("a", []byte("a"))
+	_ = s.Save("b", []byte("b"))
+	_, _ = s.Load("a") // a is now used more recently than b.
+	_ = s.Save("c", []byte("c"))
+
+	if s.Exist("b") {
+		t.Errorf("Save() did not evict the least recently used entry")
+	}
+
+	if !s.Exist("a") || !s.Exist("c") {
+		t.Errorf("Save() evicted an entry that was recently used")
+	}
+}
+
+func TestMemoryStorage_TTL(t *testing.T) {
+	clock := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	memoryNow = func() time.Time { return clock }
+	t.Cleanup(func() { memoryNow = time.Now })
+
+	s := NewMemoryStorage(0, time.Minute)
+	_ = s.Save("a", []byte("a"))
+	_ = s.SaveUntil("b", []byte("b"), time.Time{})
+
+	clock = clock.Add(time.Minute)
+
+	if _, e := s.Load("a"); !errors.Is(e, fs.ErrNotExist) {
+		t.Errorf("Load() of expired data error = %v, want fs.ErrNotExist", e)
+	}
+
+	if !s.Exist("b") {
+		t.Errorf("Exist() of data saved until never = false, want true")
+	}
+
+	_ = s.Save("c", []byte("c"))
+	clock = clock.Add(time.Minute)
+
+	if n := s.Sweep(); n != 1 {
+		t.Errorf("Sweep() = %v, want 1", n)
+	}
+}
+
+func TestMemoryStorage_List(t *testing.T) {
+	s := NewMemoryStorage(0, 0)
+
+	for _, name := range []string{"list/b.txt", "list/a.txt", "list/sub/c.txt", "lister/d.txt"} {
+		_ = s.Save(name, []byte(name))
+	}
+
+	got, _ := s.List("list")
+	if want := []string{"a.txt", "b.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("List() = %v, want %v", got, want)
+	}
+}
@@ -6,28 +6,50 @@ var stderr = struct {
 	DeleteObject,
 	DirNoExist,
 	EncodeJSON,
+	InvalidKey,
+	LifecycleConfig,
+	LifecycleNoPrefix,
 	ReadFile,
-	RequestListParameters,
 	ListFiles,
 	LoadKey,
+	MultipartUpload,
+	NoSigner,
+	NotFound,
+	Presign,
+	PresignSize,
 	ReadObject,
+	ReadOnly,
 	RemoveFile,
 	PutObject,
-	WriteFile string
+	SignatureExpired,
+	SignatureInvalid,
+	WriteFile,
+	WriterClosed string
 }{
-	AwsConfig:             "failed to load AWS config: %v",
-	DecodeJSON:            "cannot decode JSON: %v",
-	DeleteObject:          "cannot delete object: %v",
-	DirNoExist:            "%v directory does not exist",
-	EncodeJSON:            "cannot encode JSON: %v",
-	ReadFile:              "cannot read file %v",
-	RequestListParameters: "RequestListParameters has not been set, it is a requirement to call BucketStorage.SetRequestListParameters(*) before calling BucketStorage.List(*). This is a condition to keep the Storage API consistent across mediums while allowing S3 to have its differences.",
-	ListFiles:             "cannot list files %v",
-	LoadKey:               "cannot load object key %v in bucket %v: %v",
-	PutObject:             "cannot put object: %v",
-	ReadObject:            "cannot read object: %v",
-	RemoveFile:            "cannot remove file %v",
-	WriteFile:             "attempting to write, but cannot %v",
+	AwsConfig:         "failed to load AWS config: %v",
+	DecodeJSON:        "cannot decode JSON: %v",
+	DeleteObject:      "cannot delete object: %v",
+	DirNoExist:        "%v directory does not exist",
+	EncodeJSON:        "cannot encode JSON: %v",
+	InvalidKey:        "invalid key %q, it must be valid UTF-8 of at most 1024 bytes, without \"..\" segments or characters that are not allowed",
+	LifecycleConfig:   "cannot configure the lifecycle of bucket %v: %v",
+	LifecycleNoPrefix: "a lifecycle rule for all of bucket %v would expire every object, give it a prefix",
+	ReadFile:          "cannot read file %v",
+	ListFiles:         "cannot list files %v",
+	LoadKey:           "cannot load object key %v in bucket %v: %w",
+	MultipartUpload:   "cannot upload object %v in parts: %v",
+	NoSigner:          "LocalStorage.Signer must be set to sign URLs",
+	NotFound:          "%v: %w",
+	Presign:           "cannot presign a request for object %v: %v",
+	PresignSize:       "a presigned PUT to a bucket can only limit the size to an exact size, use PresignPost for a range",
+	PutObject:         "cannot put object: %v",
+	ReadObject:        "cannot read object: %v",
+	ReadOnly:          "the storage is read-only",
+	RemoveFile:        "cannot remove file %v",
+	SignatureExpired:  "the signed URL expired at %v",
+	SignatureInvalid:  "the signature of the URL is invalid",
+	WriteFile:         "attempting to write, but cannot %v",
+	WriterClosed:      "the writer is closed",
 }
 var stdout = struct {
 	Load,
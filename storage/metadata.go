@@ -0,0 +1,20 @@
+package storage
+
+import (
+	"context"
+)
+
+// Stat Describe data in storage, without loading it when the storage is a
+// MetadataStorage. Otherwise, the data is loaded to learn its size.
+func Stat(ctx context.Context, s Storage, name string) (Info, error) {
+	if ms, ok := s.(MetadataStorage); ok {
+		return ms.Stat(ctx, name)
+	}
+
+	data, e1 := LoadContext(ctx, s, name)
+	if e1 != nil {
+		return Info{}, e1
+	}
+
+	return Info{Name: name, Size: int64(len(data))}, nil
+}
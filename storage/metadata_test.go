@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"testing"
+)
+
+func TestMetadataStorage(t *testing.T) {
+	opts := &SaveOptions{
+		CacheControl: "max-age=60",
+		ContentType:  "text/plain",
+		Metadata:     map[string]string{"owner": "kohirens"},
+	}
+
+	tests := []struct {
+		name    string
+		storage func(t *testing.T) MetadataStorage
+	}{
+		{"local", func(t *testing.T) MetadataStorage {
+			return &LocalStorage{WorkDir: t.TempDir()}
+		}},
+		{"bucket", func(t *testing.T) MetadataStorage {
+			_, s := newTestBucket(t, nil)
+			return s
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := tt.storage(t)
+
+			if e := s.SaveWithOptions(ctx, "a.txt", []byte("hello"), opts); e != nil {
+				t.Fatalf("SaveWithOptions() error = %v", e)
+			}
+
+			info, e1 := s.Stat(ctx, "a.txt")
+			if e1 != nil {
+				t.Fatalf("Stat() error = %v", e1)
+			}
+
+			if info.Size != 5 || info.ETag == "" || info.ModTime.IsZero() {
+				t.Errorf("Stat() = %+v, want the size, ETag and modification time", info)
+			}
+
+			if info.ContentType != opts.ContentType || info.CacheControl != opts.CacheControl {
+				t.Errorf("Stat() content type = %q and cache control = %q, want %q and %q", info.ContentType, info.CacheControl, opts.ContentType, opts.CacheControl)
+			}
+
+			if !maps.Equal(info.Metadata, opts.Metadata) {
+				t.Errorf("Stat() metadata = %v, want %v", info.Metadata, opts.Metadata)
+			}
+
+			// Saving again without options drops the metadata and changes
+			// the ETag.
+			if e := s.(Storage).Save("a.txt", []byte("bye")); e != nil {
+				t.Fatalf("Save() error = %v", e)
+			}
+
+			again, _ := s.Stat(ctx, "a.txt")
+			if again.ContentType == opts.ContentType || len(again.Metadata) != 0 {
+				t.Errorf("Stat() after Save() = %+v, want no metadata", again)
+			}
+			if again.ETag == info.ETag {
+				t.Errorf("Stat() after Save() ETag = %v, want a new ETag", again.ETag)
+			}
+
+			if _, e := s.Stat(ctx, "missing.txt"); !errors.Is(e, fs.ErrNotExist) {
+				t.Errorf("Stat() of missing data error = %v, want fs.ErrNotExist", e)
+			}
+		})
+	}
+}
+
+func TestLocalStorage_SaveWithOptions(t *testing.T) {
+	s := &LocalStorage{WorkDir: t.TempDir()}
+	ctx := context.Background()
+
+	if e := s.SaveWithOptions(ctx, "dir/a.txt", []byte("hello"), &SaveOptions{ContentType: "text/plain"}); e != nil {
+		t.Fatalf("SaveWithOptions() error = %v", e)
+	}
+
+	names, _ := s.List("dir")
+	if len(names) != 1 || names[0] != "a.txt" {
+		t.Errorf("List() = %v, want [a.txt] without the sidecar", names)
+	}
+
+	if e := s.Remove("dir/a.txt"); e != nil {
+		t.Fatalf("Remove() error = %v", e)
+	}
+
+	if _, e := os.Stat(s.Location("dir/a.txt") + localMetaSuffix); !errors.Is(e, fs.ErrNotExist) {
+		t.Errorf("Remove() left the sidecar, error = %v", e)
+	}
+}
+
+func TestLocalStorage_Stat(t *testing.T) {
+	s := &LocalStorage{WorkDir: t.TempDir()}
+	ctx := context.Background()
+
+	// sha256("hello")
+	want := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+	if e := s.SaveWithOptions(ctx, "a.txt", []byte("hello"), &SaveOptions{ContentType: "text/plain"}); e != nil {
+		t.Fatalf("SaveWithOptions() error = %v", e)
+	}
+
+	info, e1 := s.Stat(ctx, "a.txt")
+	if e1 != nil {
+		t.Fatalf("Stat() error = %v", e1)
+	}
+	if info.Checksum != want || info.ETag != `"`+want+`"` {
+		t.Errorf("Stat() checksum = %v and ETag = %v, want %v", info.Checksum, info.ETag, want)
+	}
+
+	for _, path := range []string{s.Location("a.txt"), s.Location("a.txt") + localMetaSuffix} {
+		fi, e := os.Stat(path)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if fi.Mode().Perm() != localFilePerms {
+			t.Errorf("%v mode = %v, want %v", path, fi.Mode().Perm(), os.FileMode(localFilePerms))
+		}
+	}
+
+	// As if the process stopped before the sidecar was written again.
+	_ = os.WriteFile(s.Location("a.txt"), []byte("changed"), 0600)
+
+	stale, _ := s.Stat(ctx, "a.txt")
+	if stale.Checksum != "" || stale.ContentType != "" || stale.ETag == info.ETag {
+		t.Errorf("Stat() with a sidecar of older data = %+v, want it left out", stale)
+	}
+
+	// Save keeps no sidecar.
+	if e := s.Save("b.txt", []byte("hello")); e != nil {
+		t.Fatalf("Save() error = %v", e)
+	}
+
+	if _, e := os.Stat(s.Location("b.txt") + localMetaSuffix); !errors.Is(e, fs.ErrNotExist) {
+		t.Errorf("Save() wrote a sidecar, error = %v", e)
+	}
+
+	plain, _ := s.Stat(ctx, "b.txt")
+	if plain.Checksum != "" || plain.ETag == "" {
+		t.Errorf("Stat() of a file without a sidecar = %+v, want an ETag and no checksum", plain)
+	}
+}
+
+func TestLocalStorage_MetaSuffix(t *testing.T) {
+	s := &LocalStorage{WorkDir: t.TempDir()}
+
+	if e := s.Save("a.txt", []byte("hello")); e != nil {
+		t.Fatalf("Save() error = %v", e)
+	}
+
+	var ik *InvalidKeyError
+	if e := s.Save("a.txt"+localMetaSuffix, []byte("{}")); !errors.As(e, &ik) {
+		t.Errorf("Save() of a sidecar name error = %v, want an InvalidKeyError", e)
+	}
+	if s.Exist("a.txt" + localMetaSuffix) {
+		t.Errorf("Exist() of a sidecar name = true, want false")
+	}
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"strings"
+)
+
+// PrefixedStorage Scopes storage to a namespace, such as a tenant or an
+// environment, by adding a prefix to every name. Unlike BucketStorage.Prefix,
+// the prefix and a name are joined with a "/", and it works with any
+// storage. Names are normalized by NormalizeKey, so a name cannot escape the
+// namespace.
+type PrefixedStorage struct {
+	prefix  string
+	storage Storage
+}
+
+var _ Storage = (*PrefixedStorage)(nil)
+
+// NewPrefixedStorage Initialize storage that keeps data in s under prefix.
+func NewPrefixedStorage(s Storage, prefix string) *PrefixedStorage {
+	return &PrefixedStorage{
+		prefix:  strings.Trim(prefix, "/"),
+		storage: s,
+	}
+}
+
+// Exist See Storage.Exist.
+func (s *PrefixedStorage) Exist(name string) bool {
+	key, e1 := s.name(name)
+	if e1 != nil {
+		return false
+	}
+
+	return s.storage.Exist(key)
+}
+
+// List See Storage.List, names are relative to the location, so they have
+// no prefix.
+func (s *PrefixedStorage) List(location string) ([]string, error) {
+	key, e1 := s.name(location)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	return s.storage.List(key)
+}
+
+// Load See Storage.Load.
+func (s *PrefixedStorage) Load(name string) ([]byte, error) {
+	key, e1 := s.name(name)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	return s.storage.Load(key)
+}
+
+// Location The location of the name, with the prefix, in the storage. It is
+// empty when the name is invalid.
+func (s *PrefixedStorage) Location(name string) string {
+	key, e1 := s.name(name)
+	if e1 != nil {
+		return ""
+	}
+
+	return s.storage.Location(key)
+}
+
+// Prefix Added to every name.
+func (s *PrefixedStorage) Prefix() string {
+	return s.prefix
+}
+
+// Remove See Storage.Remove.
+func (s *PrefixedStorage) Remove(name string) error {
+	key, e1 := s.name(name)
+	if e1 != nil {
+		return e1
+	}
+
+	return s.storage.Remove(key)
+}
+
+// Save See Storage.Save.
+func (s *PrefixedStorage) Save(name string, data []byte) error {
+	key, e1 := s.name(name)
+	if e1 != nil {
+		return e1
+	}
+
+	return s.storage.Save(key, data)
+}
+
+// name Normalize a name and add the prefix.
+func (s *PrefixedStorage) name(name string) (string, error) {
+	key, e1 := NormalizeKey(name)
+	if e1 != nil {
+		return "", e1
+	}
+
+	return joinKey(s.prefix, key), nil
+}
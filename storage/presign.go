@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PresignStorage An optional extension of Storage that signs URLs, so that a
+// client, such as a browser, downloads or uploads data directly instead of
+// through the server. LocalStorage, with a Signer, and BucketStorage
+// implement it.
+type PresignStorage interface {
+	// PresignGet A URL to download data, valid for the duration.
+	PresignGet(ctx context.Context, name string, expires time.Duration) (*PresignedRequest, error)
+	// PresignPut A URL to upload data, valid for the duration. The opts can
+	// be nil.
+	PresignPut(ctx context.Context, name string, expires time.Duration, opts *PresignOptions) (*PresignedRequest, error)
+}
+
+// PresignOptions Constrain the upload of a presigned request.
+type PresignOptions struct {
+	// ContentType The Content-Type the client must upload, empty for any.
+	ContentType string
+	// MaxSize The largest upload in bytes, zero for no limit.
+	MaxSize int64
+	// MinSize The smallest upload in bytes.
+	MinSize int64
+}
+
+// PresignedRequest A request that a client can make without credentials.
+type PresignedRequest struct {
+	Expires time.Time `json:"expires"`
+	// Fields The form fields to post with the file, for a POST.
+	Fields map[string]string `json:"fields,omitempty"`
+	// Header The headers the client must send, such as Content-Type.
+	Header http.Header `json:"headers,omitempty"`
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+}
+
+// URLSigner Signs URLs to data in LocalStorage with an HMAC. The URLs are
+// served by a route at BaseURL that verifies them, such as
+// backend.ServeSignedURL.
+type URLSigner struct {
+	// BaseURL Of the route, such as "https://example.com/api/files".
+	BaseURL string
+	// Key The secret of the HMAC, it should be at least 32 random bytes.
+	Key []byte
+}
+
+// SignedURL What a URLSigner signs into a URL.
+type SignedURL struct {
+	ContentType string
+	Expires     time.Time
+	MaxSize     int64
+	Method      string
+	MinSize     int64
+	Name        string
+}
+
+// signerNow Allows tests to control the clock.
+var signerNow = time.Now
+
+// Sign A URL for the request u describes.
+func (s *URLSigner) Sign(u *SignedURL) string {
+	q := url.Values{}
+	q.Set("name", u.Name)
+	q.Set("expires", strconv.FormatInt(u.Expires.Unix(), 10))
+	if u.ContentType != "" {
+		q.Set("content-type", u.ContentType)
+	}
+	if u.MaxSize > 0 {
+		q.Set("max-size", strconv.FormatInt(u.MaxSize, 10))
+	}
+	if u.MinSize > 0 {
+		q.Set("min-size", strconv.FormatInt(u.MinSize, 10))
+	}
+	q.Set("signature", s.signature(u.Method, q))
+
+	return s.BaseURL + "?" + q.Encode()
+}
+
+// Verify That the URL of a request was signed by Sign, for the method of the
+// request, and has not expired.
+func (s *URLSigner) Verify(r *http.Request) (*SignedURL, error) {
+	q := r.URL.Query()
+
+	want := s.signature(r.Method, q)
+	if !hmac.Equal([]byte(q.Get("signature")), []byte(want)) {
+		return nil, fmt.Errorf("%v", stderr.SignatureInvalid)
+	}
+
+	u := &SignedURL{
+		ContentType: q.Get("content-type"),
+		Method:      r.Method,
+		Name:        q.Get("name"),
+	}
+
+	expires, e1 := strconv.ParseInt(q.Get("expires"), 10, 64)
+	if e1 != nil {
+		return nil, fmt.Errorf("%v", stderr.SignatureInvalid)
+	}
+	u.Expires = time.Unix(expires, 0)
+
+	if !signerNow().Before(u.Expires) {
+		return nil, fmt.Errorf(stderr.SignatureExpired, u.Expires.UTC())
+	}
+
+	u.MaxSize, _ = strconv.ParseInt(q.Get("max-size"), 10, 64)
+	u.MinSize, _ = strconv.ParseInt(q.Get("min-size"), 10, 64)
+
+	return u, nil
+}
+
+// signature The HMAC of the method and the query, without its signature.
+func (s *URLSigner) signature(method string, q url.Values) string {
+	fields := []string{
+		method,
+		q.Get("name"),
+		q.Get("expires"),
+		q.Get("content-type"),
+		q.Get("max-size"),
+		q.Get("min-size"),
+	}
+
+	mac := hmac.New(sha256.New, s.Key)
+	mac.Write([]byte(strings.Join(fields, "\n")))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+var _ PresignStorage = (*LocalStorage)(nil)
+
+// PresignGet A URL to download a file, see LocalStorage.Signer.
+func (s *LocalStorage) PresignGet(ctx context.Context, filename string, expires time.Duration) (*PresignedRequest, error) {
+	return s.presign(ctx, http.MethodGet, filename, expires, nil)
+}
+
+// PresignPut A URL to upload a file, see LocalStorage.Signer.
+func (s *LocalStorage) PresignPut(ctx context.Context, filename string, expires time.Duration, opts *PresignOptions) (*PresignedRequest, error) {
+	return s.presign(ctx, http.MethodPut, filename, expires, opts)
+}
+
+func (s *LocalStorage) presign(ctx context.Context, method, filename string, expires time.Duration, opts *PresignOptions) (*PresignedRequest, error) {
+	if e := ctx.Err(); e != nil {
+		return nil, e
+	}
+
+	if s.Signer == nil {
+		return nil, fmt.Errorf("%v", stderr.NoSigner)
+	}
+
+	if opts == nil {
+		opts = &PresignOptions{}
+	}
+
+	u := &SignedURL{
+		ContentType: opts.ContentType,
+		Expires:     signerNow().Add(expires).Truncate(time.Second),
+		MaxSize:     opts.MaxSize,
+		Method:      method,
+		MinSize:     opts.MinSize,
+		Name:        filename,
+	}
+
+	req := &PresignedRequest{
+		Expires: u.Expires,
+		Method:  method,
+		URL:     s.Signer.Sign(u),
+	}
+
+	if opts.ContentType != "" {
+		req.Header = http.Header{"Content-Type": {opts.ContentType}}
+	}
+
+	return req, nil
+}
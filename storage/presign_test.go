@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestURLSigner(t *testing.T) {
+	signer := &URLSigner{BaseURL: "https://example.com/files", Key: []byte("0123456789abcdef0123456789abcdef")}
+	signed := signer.Sign(&SignedURL{
+		ContentType: "image/png",
+		Expires:     time.Now().Add(time.Minute),
+		MaxSize:     100,
+		Method:      http.MethodPut,
+		Name:        "a1/avatar.png",
+	})
+
+	tests := []struct {
+		name    string
+		method  string
+		url     string
+		now     time.Time
+		wantErr bool
+	}{
+		{"valid", http.MethodPut, signed, time.Now(), false},
+		{"other-method", http.MethodGet, signed, time.Now(), true},
+		{"tampered-name", http.MethodPut, strings.Replace(signed, "avatar", "other", 1), time.Now(), true},
+		{"tampered-size", http.MethodPut, strings.Replace(signed, "max-size=100", "max-size=999", 1), time.Now(), true},
+		{"other-key", http.MethodPut, (&URLSigner{BaseURL: signer.BaseURL, Key: []byte("other")}).Sign(&SignedURL{Method: http.MethodPut, Name: "a1/avatar.png", Expires: time.Now().Add(time.Minute)}), time.Now(), true},
+		{"expired", http.MethodPut, signed, time.Now().Add(2 * time.Minute), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signerNow = func() time.Time { return tt.now }
+			t.Cleanup(func() { signerNow = time.Now })
+
+			u, e1 := signer.Verify(httptest.NewRequest(tt.method, tt.url, nil))
+			if (e1 != nil) != tt.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %v", e1, tt.wantErr)
+			}
+
+			if !tt.wantErr && (u.Name != "a1/avatar.png" || u.ContentType != "image/png" || u.MaxSize != 100) {
+				t.Errorf("Verify() = %+v, want what was signed", u)
+			}
+		})
+	}
+}
+
+func TestLocalStorage_Presign(t *testing.T) {
+	s := &LocalStorage{WorkDir: t.TempDir()}
+
+	if _, e := s.PresignGet(context.Background(), "a.txt", time.Minute); e == nil {
+		t.Errorf("PresignGet() without a Signer did not fail")
+	}
+
+	s.Signer = &URLSigner{BaseURL: "https://example.com/files", Key: []byte("key")}
+
+	req, e1 := s.PresignPut(context.Background(), "a.txt", time.Minute, &PresignOptions{ContentType: "text/plain"})
+	if e1 != nil {
+		t.Fatalf("PresignPut() error = %v", e1)
+	}
+
+	if req.Method != http.MethodPut || req.Header.Get("Content-Type") != "text/plain" || !strings.HasPrefix(req.URL, s.Signer.BaseURL+"?") {
+		t.Errorf("PresignPut() = %+v, want a PUT to the base URL with the content type", req)
+	}
+
+	if _, e := s.Signer.Verify(httptest.NewRequest(req.Method, req.URL, nil)); e != nil {
+		t.Errorf("Verify() of the presigned URL error = %v", e)
+	}
+}
+
+func TestBucketStorage_Presign(t *testing.T) {
+	srv, s := newTestBucket(t, &BucketConfig{Prefix: "p/"})
+	ctx := context.Background()
+	client := srv.Client()
+
+	put, e1 := s.PresignPut(ctx, "a.txt", time.Minute, &PresignOptions{ContentType: "text/plain"})
+	if e1 != nil {
+		t.Fatalf("PresignPut() error = %v", e1)
+	}
+
+	r, _ := http.NewRequest(put.Method, put.URL, strings.NewReader("hello"))
+	r.Header = put.Header.Clone()
+	if res, e := client.Do(r); e != nil || res.StatusCode != http.StatusOK {
+		t.Fatalf("PUT to the presigned URL = %v, %v", res, e)
+	}
+
+	obj, _ := srv.Object("b", "p/a.txt")
+	if obj == nil || string(obj.Data) != "hello" || obj.Header.Get("Content-Type") != "text/plain" {
+		t.Errorf("PUT to the presigned URL put %+v, want hello as text/plain", obj)
+	}
+
+	get, e2 := s.PresignGet(ctx, "a.txt", time.Minute)
+	if e2 != nil {
+		t.Fatalf("PresignGet() error = %v", e2)
+	}
+
+	res, e3 := client.Get(get.URL)
+	if e3 != nil {
+		t.Fatalf("GET of the presigned URL error = %v", e3)
+	}
+	got, _ := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if string(got) != "hello" {
+		t.Errorf("GET of the presigned URL = %q, want hello", got)
+	}
+
+	if _, e := s.PresignPut(ctx, "a.txt", time.Minute, &PresignOptions{MaxSize: 10}); e == nil {
+		t.Errorf("PresignPut() with a size range did not fail")
+	}
+
+	post, e4 := s.PresignPost(ctx, "b.txt", time.Minute, &PresignOptions{ContentType: "text/plain", MaxSize: 10})
+	if e4 != nil {
+		t.Fatalf("PresignPost() error = %v", e4)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		data     string
+		wantCode int
+	}{
+		{"within-range", "hello", http.StatusNoContent},
+		{"too-large", "hello world!", http.StatusForbidden},
+	} {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		for k, v := range post.Fields {
+			_ = mw.WriteField(k, v)
+		}
+		fw, _ := mw.CreateFormFile("file", "b.txt")
+		_, _ = fw.Write([]byte(tt.data))
+		_ = mw.Close()
+
+		res, e := client.Post(post.URL, mw.FormDataContentType(), &body)
+		if e != nil || res.StatusCode != tt.wantCode {
+			t.Errorf("POST of the presigned form %v = %v, %v, want %v", tt.name, res.StatusCode, e, tt.wantCode)
+		}
+	}
+
+	if obj, _ := srv.Object("b", "p/b.txt"); obj == nil || string(obj.Data) != "hello" {
+		t.Errorf("POST of the presigned form put %+v, want hello", obj)
+	}
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"errors"
+)
+
+// ReadOnlyStorage Guards storage against changes, such as templates that are
+// deployed with the app. Save and Remove fail with ErrReadOnly.
+type ReadOnlyStorage struct {
+	storage Storage
+}
+
+// ErrReadOnly Returned by ReadOnlyStorage for a Save or Remove.
+var ErrReadOnly = errors.New(stderr.ReadOnly)
+
+var _ Storage = (*ReadOnlyStorage)(nil)
+
+// NewReadOnlyStorage Initialize storage that reads from s.
+func NewReadOnlyStorage(s Storage) *ReadOnlyStorage {
+	return &ReadOnlyStorage{storage: s}
+}
+
+// Exist See Storage.Exist.
+func (s *ReadOnlyStorage) Exist(name string) bool {
+	return s.storage.Exist(name)
+}
+
+// List See Storage.List.
+func (s *ReadOnlyStorage) List(location string) ([]string, error) {
+	return s.storage.List(location)
+}
+
+// Load See Storage.Load.
+func (s *ReadOnlyStorage) Load(name string) ([]byte, error) {
+	return s.storage.Load(name)
+}
+
+// Location See Storage.Location.
+func (s *ReadOnlyStorage) Location(name string) string {
+	return s.storage.Location(name)
+}
+
+// Remove Fails with ErrReadOnly.
+func (s *ReadOnlyStorage) Remove(string) error {
+	return ErrReadOnly
+}
+
+// Save Fails with ErrReadOnly.
+func (s *ReadOnlyStorage) Save(string, []byte) error {
+	return ErrReadOnly
+}
@@ -0,0 +1,240 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Client A minimal client for servers that speak the Redis protocol (RESP),
+// such as Redis, Valkey and KeyDB. It keeps one connection, which is opened
+// on the first command and again after a network error.
+type Client struct {
+	addr  string
+	conn  net.Conn
+	mutex sync.Mutex
+	opts  Options
+	rd    *bufio.Reader
+}
+
+// Options For connecting to the server.
+type Options struct {
+	// DB The database number to SELECT after connecting.
+	DB int
+	// Password To AUTH with after connecting, leave empty when the server
+	// does not require it.
+	Password string
+	// Timeout For dialing, and for each command when its context has no
+	// deadline. Defaults to 5 seconds.
+	Timeout time.Duration
+	// Username For servers with ACLs, leave empty for the default user.
+	Username string
+}
+
+// NewClient Initialize a client for the server at addr, such as
+// "localhost:6379". It does not connect until the first command.
+func NewClient(addr string, opts *Options) *Client {
+	c := &Client{addr: addr}
+	if opts != nil {
+		c.opts = *opts
+	}
+
+	if c.opts.Timeout == 0 {
+		c.opts.Timeout = 5 * time.Second
+	}
+
+	return c
+}
+
+// Close The connection to the server.
+func (c *Client) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.conn == nil {
+		return nil
+	}
+
+	e := c.conn.Close()
+	c.conn = nil
+
+	return e
+}
+
+// Do Send a command and read its reply. A reply is one of: nil, string for a
+// simple string, int64, []byte for a bulk string, or []any for an array. An
+// error reply from the server is returned as a *ReplyError.
+func (c *Client) Do(ctx context.Context, args ...string) (any, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.conn == nil {
+		if e := c.connect(ctx); e != nil {
+			return nil, e
+		}
+	}
+
+	reply, e1 := c.do(ctx, args)
+	if e1 != nil {
+		if _, ok := e1.(*ReplyError); !ok {
+			// The connection is in an unknown state, so start over.
+			_ = c.conn.Close()
+			c.conn = nil
+		}
+		return nil, e1
+	}
+
+	return reply, nil
+}
+
+// connect Dial the server, then authenticate and select the database.
+func (c *Client) connect(ctx context.Context) error {
+	d := net.Dialer{Timeout: c.opts.Timeout}
+
+	conn, e1 := d.DialContext(ctx, "tcp", c.addr)
+	if e1 != nil {
+		return fmt.Errorf(stderr.Connect, c.addr, e1.Error())
+	}
+
+	c.conn = conn
+	c.rd = bufio.NewReader(conn)
+
+	var setup [][]string
+	if c.opts.Password != "" {
+		if c.opts.Username != "" {
+			setup = append(setup, []string{"AUTH", c.opts.Username, c.opts.Password})
+		} else {
+			setup = append(setup, []string{"AUTH", c.opts.Password})
+		}
+	}
+
+	if c.opts.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.opts.DB)})
+	}
+
+	for _, args := range setup {
+		if _, e := c.do(ctx, args); e != nil {
+			_ = conn.Close()
+			c.conn = nil
+			return fmt.Errorf(stderr.Connect, c.addr, e.Error())
+		}
+	}
+
+	return nil
+}
+
+// do Write a command as an array of bulk strings and read the reply, the
+// caller must hold the mutex.
+func (c *Client) do(ctx context.Context, args []string) (any, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(c.opts.Timeout)
+	}
+
+	if e := c.conn.SetDeadline(deadline); e != nil {
+		return nil, fmt.Errorf(stderr.Command, args[0], e.Error())
+	}
+
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+
+	if _, e := c.conn.Write(buf); e != nil {
+		return nil, fmt.Errorf(stderr.Command, args[0], e.Error())
+	}
+
+	reply, e1 := readReply(c.rd)
+	if e1 != nil {
+		if _, ok := e1.(*ReplyError); ok {
+			return nil, e1
+		}
+		return nil, fmt.Errorf(stderr.Command, args[0], e1.Error())
+	}
+
+	return reply, nil
+}
+
+// readReply Read one RESP2 reply.
+func readReply(rd *bufio.Reader) (any, error) {
+	line, e1 := readLine(rd)
+	if e1 != nil {
+		return nil, e1
+	}
+
+	if len(line) == 0 {
+		return nil, fmt.Errorf("%v", stderr.Protocol)
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, &ReplyError{line[1:]}
+	case ':':
+		n, e := strconv.ParseInt(line[1:], 10, 64)
+		if e != nil {
+			return nil, fmt.Errorf("%v", stderr.Protocol)
+		}
+		return n, nil
+	case '$':
+		n, e := strconv.Atoi(line[1:])
+		if e != nil {
+			return nil, fmt.Errorf("%v", stderr.Protocol)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		b := make([]byte, n+2)
+		if _, e := io.ReadFull(rd, b); e != nil {
+			return nil, e
+		}
+		return b[:n], nil
+	case '*':
+		n, e := strconv.Atoi(line[1:])
+		if e != nil {
+			return nil, fmt.Errorf("%v", stderr.Protocol)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			item, e := readReply(rd)
+			if e != nil {
+				return nil, e
+			}
+			items[i] = item
+		}
+		return items, nil
+	}
+
+	return nil, fmt.Errorf("%v", stderr.Protocol)
+}
+
+// readLine Read up to CRLF, without it.
+func readLine(rd *bufio.Reader) (string, error) {
+	line, e1 := rd.ReadString('\n')
+	if e1 != nil {
+		return "", e1
+	}
+
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("%v", stderr.Protocol)
+	}
+
+	return line[:len(line)-2], nil
+}
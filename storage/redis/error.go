@@ -0,0 +1,29 @@
+package redis
+
+import (
+	"fmt"
+	"io/fs"
+)
+
+// NotFoundError The key is not on the server, or it has expired.
+type NotFoundError struct {
+	key string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf(stderr.NotFound, e.key)
+}
+
+// Unwrap Makes errors.Is(e, fs.ErrNotExist) true, as for the other storage.
+func (e *NotFoundError) Unwrap() error {
+	return fs.ErrNotExist
+}
+
+// ReplyError An error reply from the server, such as "ERR unknown command".
+type ReplyError struct {
+	msg string
+}
+
+func (e *ReplyError) Error() string {
+	return e.msg
+}
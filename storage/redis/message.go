@@ -0,0 +1,29 @@
+package redis
+
+var stderr = struct {
+	Command,
+	Connect,
+	List,
+	Load,
+	NoExpiration,
+	NotFound,
+	Protocol,
+	Remove,
+	Save string
+}{
+	Command:      "redis command %v failed: %v",
+	Connect:      "cannot connect to redis at %v: %v",
+	List:         "cannot list keys with prefix %v: %v",
+	Load:         "cannot load key %v: %v",
+	NoExpiration: "session %v has no expiration and the session store has no TTL",
+	NotFound:     "key %v not found",
+	Protocol:     "unexpected reply from the redis server",
+	Remove:       "cannot remove key %v: %v",
+	Save:         "cannot save key %v: %v",
+}
+
+var stdout = struct {
+	DefaultTTL string
+}{
+	DefaultTTL: "session %v has no expiration, saving it with the TTL of the session store",
+}
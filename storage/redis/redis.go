@@ -0,0 +1,248 @@
+// Package redis keeps data and sessions on a server that speaks the Redis
+// protocol, such as Redis, Valkey or KeyDB. Keys expire on the server, so
+// there is nothing to clean up.
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kohirens/stdlib/logger"
+	"github.com/kohirens/www/session"
+	"github.com/kohirens/www/storage"
+)
+
+// Storage General purpose storage, such as a cache, on a Redis server.
+type Storage struct {
+	Context context.Context
+	// Prefix Put in front of every key, to share a server with other apps.
+	Prefix string
+	// TTL How long saved data lasts, zero for ever.
+	TTL    time.Duration
+	client *Client
+}
+
+// SessionStore Session storage on a Redis server. Each session expires on
+// the server at its Expiration.
+type SessionStore struct {
+	Context context.Context
+	// Prefix Put in front of every key, to share a server with other apps.
+	Prefix string
+	// TTL How long a session lasts when Save cannot read its expiration.
+	// When zero, such a session is not saved.
+	TTL    time.Duration
+	client *Client
+}
+
+var Log = &logger.Standard{}
+
+// now Allows tests to control the clock.
+var now = time.Now
+
+var (
+	_ session.ExpirySaver   = (*SessionStore)(nil)
+	_ session.NativeExpirer = (*SessionStore)(nil)
+	_ session.Storage       = (*SessionStore)(nil)
+	_ storage.Storage       = (*Storage)(nil)
+)
+
+// NewStorage Initialize storage on the server the client connects to.
+func NewStorage(ctx context.Context, client *Client, prefix string, ttl time.Duration) *Storage {
+	return &Storage{
+		Context: ctx,
+		Prefix:  prefix,
+		TTL:     ttl,
+		client:  client,
+	}
+}
+
+// NewSessionStore Initialize session storage on the server the client
+// connects to.
+func NewSessionStore(ctx context.Context, client *Client, prefix string) *SessionStore {
+	return &SessionStore{
+		Context: ctx,
+		Prefix:  prefix,
+		client:  client,
+	}
+}
+
+// Exist Verify the key is in storage.
+func (s *Storage) Exist(name string) bool {
+	reply, e1 := s.client.Do(contextOr(s.Context), "EXISTS", s.Location(name))
+	if e1 != nil {
+		Log.Errf("%v", e1.Error())
+		return false
+	}
+
+	n, _ := reply.(int64)
+
+	return n > 0
+}
+
+// List The keys in a location, relative to it. Like the other storage, it is
+// not recursive, keys in sub-locations are left out.
+func (s *Storage) List(location string) ([]string, error) {
+	ctx := contextOr(s.Context)
+	prefix := s.Location(location)
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	names := make([]string, 0)
+	cursor := "0"
+
+	for {
+		reply, e1 := s.client.Do(ctx, "SCAN", cursor, "MATCH", escapeGlob(prefix)+"*", "COUNT", "100")
+		if e1 != nil {
+			return nil, fmt.Errorf(stderr.List, prefix, e1.Error())
+		}
+
+		page, ok := reply.([]any)
+		if !ok || len(page) != 2 {
+			return nil, fmt.Errorf(stderr.List, prefix, stderr.Protocol)
+		}
+
+		next, _ := page[0].([]byte)
+		keys, _ := page[1].([]any)
+
+		for _, k := range keys {
+			b, _ := k.([]byte)
+			name := strings.TrimPrefix(string(b), prefix)
+			if name != "" && !strings.Contains(name, "/") {
+				names = append(names, name)
+			}
+		}
+
+		cursor = string(next)
+		if cursor == "0" || cursor == "" {
+			break
+		}
+	}
+
+	return names, nil
+}
+
+// Load Retrieve data from storage.
+func (s *Storage) Load(filename string) ([]byte, error) {
+	return get(contextOr(s.Context), s.client, s.Location(filename))
+}
+
+// Location The key in storage.
+func (s *Storage) Location(filename string) string {
+	return s.Prefix + filename
+}
+
+// Remove Delete data from storage.
+func (s *Storage) Remove(filename string) error {
+	return del(contextOr(s.Context), s.client, s.Location(filename))
+}
+
+// Save Write data to storage, it expires after the TTL.
+func (s *Storage) Save(filename string, data []byte) error {
+	return set(contextOr(s.Context), s.client, s.Location(filename), data, s.TTL)
+}
+
+// Load The session from storage.
+func (ss *SessionStore) Load(id string) ([]byte, error) {
+	return get(contextOr(ss.Context), ss.client, ss.Prefix+id)
+}
+
+// NativeExpiry Every session is saved with a TTL and expires on the server,
+// so there is no need for a session.Reaper.
+func (ss *SessionStore) NativeExpiry() bool {
+	return true
+}
+
+// Remove A session from storage.
+func (ss *SessionStore) Remove(key string) error {
+	return del(contextOr(ss.Context), ss.client, ss.Prefix+key)
+}
+
+// Save The session, with the expiration read from the data, see SaveUntil.
+// When it cannot be read, the session lasts for the TTL, or it is not saved
+// when the TTL is zero.
+func (ss *SessionStore) Save(id string, data []byte) error {
+	exp, _ := session.ExpirationOf(data)
+
+	return ss.SaveUntil(id, data, exp)
+}
+
+// SaveUntil The session, it expires on the server at the expiration, or
+// after the TTL when the expiration is zero. A session that has already
+// expired is removed instead.
+func (ss *SessionStore) SaveUntil(id string, data []byte, expiration time.Time) error {
+	ctx := contextOr(ss.Context)
+	key := ss.Prefix + id
+
+	if expiration.IsZero() {
+		if ss.TTL <= 0 {
+			return fmt.Errorf(stderr.NoExpiration, id)
+		}
+		Log.Dbugf(stdout.DefaultTTL, id)
+		return set(ctx, ss.client, key, data, ss.TTL)
+	}
+
+	ttl := expiration.Sub(now())
+	if ttl < time.Millisecond {
+		return del(ctx, ss.client, key)
+	}
+
+	return set(ctx, ss.client, key, data, ttl)
+}
+
+// contextOr The context, or a background context when it is nil.
+func contextOr(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+
+	return ctx
+}
+
+func del(ctx context.Context, c *Client, key string) error {
+	if _, e := c.Do(ctx, "DEL", key); e != nil {
+		return fmt.Errorf(stderr.Remove, key, e.Error())
+	}
+
+	return nil
+}
+
+// escapeGlob Escape the characters that SCAN MATCH treats as a pattern.
+func escapeGlob(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+	return r.Replace(s)
+}
+
+func get(ctx context.Context, c *Client, key string) ([]byte, error) {
+	reply, e1 := c.Do(ctx, "GET", key)
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.Load, key, e1.Error())
+	}
+
+	if reply == nil {
+		return nil, &NotFoundError{key}
+	}
+
+	b, ok := reply.([]byte)
+	if !ok {
+		return nil, fmt.Errorf(stderr.Load, key, stderr.Protocol)
+	}
+
+	return b, nil
+}
+
+func set(ctx context.Context, c *Client, key string, data []byte, ttl time.Duration) error {
+	args := []string{"SET", key, string(data)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+
+	if _, e := c.Do(ctx, args...); e != nil {
+		return fmt.Errorf(stderr.Save, key, e.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,361 @@
+package redis
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kohirens/www/session"
+	"github.com/kohirens/www/session/sessiontest"
+	"github.com/kohirens/www/storage"
+	"github.com/kohirens/www/storage/storagetest"
+)
+
+// MockServer Speaks enough of the Redis protocol to test the client and
+// stores, keys are kept in memory.
+type MockServer struct {
+	Password string
+	keys     map[string][]byte
+	expires  map[string]time.Time
+	listener net.Listener
+	mutex    sync.Mutex
+}
+
+func newMockServer(t *testing.T, password string) *MockServer {
+	t.Helper()
+
+	l, e1 := net.Listen("tcp", "127.0.0.1:0")
+	if e1 != nil {
+		t.Fatal(e1)
+	}
+
+	s := &MockServer{
+		Password: password,
+		keys:     map[string][]byte{},
+		expires:  map[string]time.Time{},
+		listener: l,
+	}
+
+	go func() {
+		for {
+			conn, e := l.Accept()
+			if e != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	t.Cleanup(func() { _ = l.Close() })
+
+	return s
+}
+
+func (s *MockServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *MockServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	rd := bufio.NewReader(conn)
+	authed := s.Password == ""
+
+	for {
+		reply, e := readReply(rd)
+		if e != nil {
+			return
+		}
+
+		items, _ := reply.([]any)
+		args := make([]string, len(items))
+		for i, item := range items {
+			b, _ := item.([]byte)
+			args[i] = string(b)
+		}
+
+		var out string
+		if !authed && strings.ToUpper(args[0]) != "AUTH" {
+			out = "-NOAUTH Authentication required.\r\n"
+		} else {
+			out = s.exec(args, &authed)
+		}
+
+		if _, e := conn.Write([]byte(out)); e != nil {
+			return
+		}
+	}
+}
+
+func (s *MockServer) exec(args []string, authed *bool) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for k, exp := range s.expires {
+		if time.Now().After(exp) {
+			delete(s.keys, k)
+			delete(s.expires, k)
+		}
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "AUTH":
+		if args[len(args)-1] != s.Password {
+			return "-WRONGPASS invalid password\r\n"
+		}
+		*authed = true
+		return "+OK\r\n"
+	case "SELECT":
+		return "+OK\r\n"
+	case "GET":
+		v, ok := s.keys[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return bulk(string(v))
+	case "SET":
+		s.keys[args[1]] = []byte(args[2])
+		delete(s.expires, args[1])
+		if len(args) == 5 && strings.ToUpper(args[3]) == "PX" {
+			ms, _ := strconv.ParseInt(args[4], 10, 64)
+			s.expires[args[1]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		}
+		return "+OK\r\n"
+	case "DEL":
+		_, ok := s.keys[args[1]]
+		delete(s.keys, args[1])
+		delete(s.expires, args[1])
+		if ok {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	case "EXISTS":
+		if _, ok := s.keys[args[1]]; ok {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	case "PTTL":
+		exp, ok := s.expires[args[1]]
+		if !ok {
+			return ":-1\r\n"
+		}
+		return fmt.Sprintf(":%d\r\n", time.Until(exp).Milliseconds())
+	case "SCAN":
+		// Pages of two keys, the cursor is the index of the next page.
+		prefix := strings.ReplaceAll(strings.TrimSuffix(args[3], "*"), `\`, "")
+		keys := make([]string, 0)
+		for k := range s.keys {
+			if strings.HasPrefix(k, prefix) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		start, _ := strconv.Atoi(args[1])
+		end := min(start+2, len(keys))
+		next := strconv.Itoa(end)
+		if end == len(keys) {
+			next = "0"
+		}
+
+		out := "*2\r\n" + bulk(next) + fmt.Sprintf("*%d\r\n", end-start)
+		for _, k := range keys[start:end] {
+			out += bulk(k)
+		}
+		return out
+	}
+
+	return "-ERR unknown command '" + args[0] + "'\r\n"
+}
+
+func bulk(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+func TestClient_Do(t *testing.T) {
+	srv := newMockServer(t, "secret")
+
+	tests := []struct {
+		name     string
+		password string
+		args     []string
+		want     any
+		wantErr  bool
+	}{
+		{"simple-string", "secret", []string{"SET", "k", "v"}, "OK", false},
+		{"integer", "secret", []string{"EXISTS", "k"}, int64(1), false},
+		{"nil", "secret", []string{"GET", "missing"}, nil, false},
+		{"error-reply", "secret", []string{"NOPE"}, nil, true},
+		{"wrong-password", "wrong", []string{"GET", "k"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient(srv.Addr(), &Options{Password: tt.password, DB: 1})
+			defer c.Close()
+
+			got, err := c.Do(context.Background(), tt.args...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Do() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("Do() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStorage(t *testing.T) {
+	srv := newMockServer(t, "")
+	c := NewClient(srv.Addr(), nil)
+	defer c.Close()
+
+	s := NewStorage(context.Background(), c, "app:", time.Minute)
+
+	for _, name := range []string{"list/a.txt", "list/b.txt", "list/c.txt", "list/sub/d.txt", "other.txt"} {
+		if e := s.Save(name, []byte(name)); e != nil {
+			t.Fatalf("Save() error = %v", e)
+		}
+	}
+
+	if !s.Exist("list/a.txt") {
+		t.Errorf("Exist() = false, want true")
+	}
+
+	got, e1 := s.Load("list/b.txt")
+	if e1 != nil || string(got) != "list/b.txt" {
+		t.Errorf("Load() = %s, %v, want list/b.txt", got, e1)
+	}
+
+	names, e2 := s.List("list")
+	if e2 != nil {
+		t.Fatalf("List() error = %v", e2)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "a.txt,b.txt,c.txt" {
+		t.Errorf("List() = %v, want [a.txt b.txt c.txt]", names)
+	}
+
+	ttl, _ := c.Do(context.Background(), "PTTL", "app:list/a.txt")
+	if ms, _ := ttl.(int64); ms <= 0 || ms > time.Minute.Milliseconds() {
+		t.Errorf("Save() set a TTL of %vms, want up to a minute", ms)
+	}
+
+	if e := s.Remove("list/a.txt"); e != nil {
+		t.Fatalf("Remove() error = %v", e)
+	}
+
+	var nf *NotFoundError
+	if _, e := s.Load("list/a.txt"); !errors.As(e, &nf) {
+		t.Errorf("Load() after Remove() error = %v, want a NotFoundError", e)
+	}
+}
+
+func TestSessionStore(t *testing.T) {
+	srv := newMockServer(t, "")
+	c := NewClient(srv.Addr(), nil)
+	defer c.Close()
+
+	clock := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return clock }
+	t.Cleanup(func() { now = time.Now })
+
+	saved := func(exp time.Time) []byte {
+		b, _ := json.Marshal(&session.Data{Expiration: exp})
+		return b
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		ttl     time.Duration
+		wantTTL time.Duration
+		wantKey bool
+		wantErr bool
+	}{
+		{"expires-with-session", saved(clock.Add(time.Hour)), 0, time.Hour, true, false},
+		{"expired", saved(clock.Add(-time.Hour)), 0, 0, false, false},
+		{"unreadable-default-ttl", []byte{1, 2, 3}, time.Minute, time.Minute, true, false},
+		{"unreadable-no-ttl", []byte{1, 2, 3}, 0, 0, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ss := NewSessionStore(context.Background(), c, "sessions:")
+			ss.TTL = tt.ttl
+
+			if e := ss.Save(tt.name, tt.data); (e != nil) != tt.wantErr {
+				t.Fatalf("Save() error = %v, wantErr %v", e, tt.wantErr)
+			}
+
+			_, e1 := ss.Load(tt.name)
+			if (e1 == nil) != tt.wantKey {
+				t.Fatalf("Load() error = %v, want key %v", e1, tt.wantKey)
+			}
+
+			reply, _ := c.Do(context.Background(), "PTTL", "sessions:"+tt.name)
+			ms, _ := reply.(int64)
+			if tt.wantTTL == 0 {
+				if ms > 0 {
+					t.Errorf("Save() set a TTL of %vms, want none", ms)
+				}
+				return
+			}
+
+			// Allow for the time the mock server takes.
+			if diff := tt.wantTTL.Milliseconds() - ms; diff < 0 || diff > 1000 {
+				t.Errorf("Save() set a TTL of %vms, want %vms", ms, tt.wantTTL.Milliseconds())
+			}
+		})
+	}
+}
+
+func TestSessionStore_Encrypted(t *testing.T) {
+	srv := newMockServer(t, "")
+	c := NewClient(srv.Addr(), nil)
+	defer c.Close()
+
+	cipher, _ := session.NewAESGCM("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+	sm := session.NewManager(session.NewEncryptedStorage(NewSessionStore(context.Background(), c, "sessions:"), cipher), "", time.Hour)
+	sm.Set("k", []byte("v"))
+
+	if e := sm.Save(); e != nil {
+		t.Fatalf("Save() error = %v", e)
+	}
+
+	reply, _ := c.Do(context.Background(), "PTTL", "sessions:"+sm.ID().String()+session.Suffix)
+	if ms, _ := reply.(int64); ms <= 0 || ms > time.Hour.Milliseconds() {
+		t.Errorf("Save() set a TTL of %vms, want up to an hour", ms)
+	}
+}
+
+func TestSessionStore_Conformance(t *testing.T) {
+	srv := newMockServer(t, "")
+
+	sessiontest.Run(t, func(t *testing.T) session.Storage {
+		c := NewClient(srv.Addr(), nil)
+		t.Cleanup(func() { _ = c.Close() })
+
+		return NewSessionStore(context.Background(), c, t.Name()+":")
+	})
+}
+
+func TestStorage_Conformance(t *testing.T) {
+	srv := newMockServer(t, "")
+
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		c := NewClient(srv.Addr(), nil)
+		t.Cleanup(func() { _ = c.Close() })
+
+		return NewStorage(context.Background(), c, t.Name()+":", 0)
+	})
+}
@@ -0,0 +1,607 @@
+// Package s3test runs an S3 API in memory, so that code that uses S3, such
+// as storage.BucketStorage, can be tested offline. It has just enough of the
+// API for BucketStorage: objects, listing, multipart uploads, browser form
+// uploads and lifecycle configuration, addressed in path-style.
+//
+//	srv := s3test.NewServer()
+//	defer srv.Close()
+//
+//	s, err := storage.NewBucketStorageWithConfig(ctx, &storage.BucketConfig{
+//		Bucket:          "b",
+//		Endpoint:        srv.URL,
+//		HTTPClient:      srv.Client(),
+//		PathStyle:       true,
+//		Region:          "us-east-1",
+//		AccessKeyID:     "test",
+//		SecretAccessKey: "test",
+//	})
+//
+// Requests are not authenticated, and the body of a request may be sent in
+// aws-chunked encoding, as the AWS SDK does over TLS.
+package s3test
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"maps"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server An S3 API in memory, served over TLS. Buckets are made as objects
+// are put in them.
+type Server struct {
+	*httptest.Server
+	// Now The clock for the modification time of objects.
+	Now func() time.Time
+
+	buckets   map[string]map[string]*Object
+	lifecycle map[string][]byte
+	mutex     sync.Mutex
+	// requests The operations the clients made, such as "PutObject".
+	requests []string
+	uploads  map[string]*upload
+}
+
+// Object An object in a bucket of the Server.
+type Object struct {
+	Data []byte
+	// Header The headers the object was put with that S3 keeps, such as
+	// Content-Type, x-amz-meta-* and x-amz-checksum-*.
+	Header  http.Header
+	ModTime time.Time
+}
+
+type upload struct {
+	bucket string
+	header http.Header
+	key    string
+	parts  map[int][]byte
+}
+
+// keptHeaders The headers of a put that are kept with an object, besides
+// x-amz-meta-*. The checksum headers are only kept for a PutObject.
+var keptHeaders = []string{
+	"Cache-Control",
+	"Content-Type",
+	"X-Amz-Server-Side-Encryption",
+	"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id",
+}
+
+var checksumHeaders = []string{
+	"X-Amz-Checksum-Crc32",
+	"X-Amz-Checksum-Crc32c",
+	"X-Amz-Checksum-Crc64nvme",
+	"X-Amz-Checksum-Sha1",
+	"X-Amz-Checksum-Sha256",
+}
+
+// NewServer Start a server, which the caller must Close. Use its Client, as
+// it trusts the certificate of the server.
+func NewServer() *Server {
+	s := &Server{
+		Now:       time.Now,
+		buckets:   make(map[string]map[string]*Object),
+		lifecycle: make(map[string][]byte),
+		uploads:   make(map[string]*upload),
+	}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.serve))
+
+	return s
+}
+
+// Object A copy of an object in a bucket.
+func (s *Server) Object(bucket, key string) (*Object, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	obj, ok := s.buckets[bucket][key]
+	if !ok {
+		return nil, false
+	}
+
+	return &Object{
+		Data:    slices.Clone(obj.Data),
+		Header:  obj.Header.Clone(),
+		ModTime: obj.ModTime,
+	}, true
+}
+
+// PutObject Put an object in a bucket, as a test fixture.
+func (s *Server) PutObject(bucket, key string, data []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.put(bucket, key, &Object{Data: slices.Clone(data), Header: http.Header{}})
+}
+
+// Requests The operations the clients made, in order.
+func (s *Server) Requests() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return slices.Clone(s.requests)
+}
+
+// Uploads The number of multipart uploads that were started, but not
+// completed or aborted.
+func (s *Server) Uploads() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.uploads)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	q := r.URL.Query()
+
+	body, e1 := readBody(r)
+	if e1 != nil {
+		writeError(w, http.StatusBadRequest, "IncompleteBody", e1.Error())
+		return
+	}
+
+	switch {
+	case key == "" && q.Has("lifecycle"):
+		s.serveLifecycle(w, r, bucket, body)
+	case key == "" && r.Method == http.MethodPost:
+		s.requests = append(s.requests, "PostObject")
+		s.postObject(w, r, bucket, body)
+	case key == "" && r.Method == http.MethodGet:
+		s.requests = append(s.requests, "ListObjectsV2")
+		s.listObjects(w, bucket, q)
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		s.requests = append(s.requests, "CreateMultipartUpload")
+		id := strconv.Itoa(len(s.requests))
+		s.uploads[id] = &upload{
+			bucket: bucket,
+			header: keep(r.Header, false),
+			key:    key,
+			parts:  make(map[int][]byte),
+		}
+		writeXML(w, http.StatusOK, &initiateResult{Bucket: bucket, Key: key, UploadID: id})
+	case r.Method == http.MethodPut && q.Has("uploadId"):
+		s.requests = append(s.requests, "UploadPart")
+		u, ok := s.uploads[q.Get("uploadId")]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchUpload", "The specified upload does not exist.")
+			return
+		}
+		n, _ := strconv.Atoi(q.Get("partNumber"))
+		u.parts[n] = body
+		w.Header().Set("ETag", etag(body))
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		s.requests = append(s.requests, "CompleteMultipartUpload")
+		s.completeUpload(w, q.Get("uploadId"), body)
+	case r.Method == http.MethodDelete && q.Has("uploadId"):
+		s.requests = append(s.requests, "AbortMultipartUpload")
+		delete(s.uploads, q.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		s.requests = append(s.requests, "PutObject")
+		if e := verifyChecksum(r.Header, body); e != nil {
+			writeError(w, http.StatusBadRequest, "BadDigest", e.Error())
+			return
+		}
+		obj := &Object{Data: body, Header: keep(r.Header, true)}
+		s.put(bucket, key, obj)
+		w.Header().Set("ETag", etag(body))
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		if r.Method == http.MethodGet {
+			s.requests = append(s.requests, "GetObject")
+		} else {
+			s.requests = append(s.requests, "HeadObject")
+		}
+		obj, ok := s.buckets[bucket][key]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+			return
+		}
+		for name, values := range obj.Header {
+			if slices.Contains(checksumHeaders, name) && r.Header.Get("X-Amz-Checksum-Mode") != "ENABLED" {
+				continue
+			}
+			w.Header()[name] = values
+		}
+		w.Header().Set("ETag", etag(obj.Data))
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj.Data)))
+		w.Header().Set("Last-Modified", obj.ModTime.UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(obj.Data)
+		}
+	case r.Method == http.MethodDelete:
+		s.requests = append(s.requests, "DeleteObject")
+		delete(s.buckets[bucket], key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusNotImplemented, "NotImplemented", "s3test does not implement this operation.")
+	}
+}
+
+func (s *Server) completeUpload(w http.ResponseWriter, id string, body []byte) {
+	u, ok := s.uploads[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchUpload", "The specified upload does not exist.")
+		return
+	}
+
+	req := &completeRequest{}
+	if e := xml.Unmarshal(body, req); e != nil {
+		writeError(w, http.StatusBadRequest, "MalformedXML", e.Error())
+		return
+	}
+
+	var data []byte
+	for _, p := range req.Parts {
+		part, ok := u.parts[p.PartNumber]
+		if !ok {
+			writeError(w, http.StatusBadRequest, "InvalidPart", fmt.Sprintf("part %v was not uploaded", p.PartNumber))
+			return
+		}
+		data = append(data, part...)
+	}
+
+	s.put(u.bucket, u.key, &Object{Data: data, Header: u.header})
+	delete(s.uploads, id)
+
+	writeXML(w, http.StatusOK, &completeResult{Bucket: u.bucket, Key: u.key, ETag: etag(data)})
+}
+
+// listObjects Lists the keys in pages, the continuation token is the last
+// key or common prefix of the page before.
+func (s *Server) listObjects(w http.ResponseWriter, bucket string, q url.Values) {
+	prefix, delimiter := q.Get("prefix"), q.Get("delimiter")
+	after := max(q.Get("start-after"), q.Get("continuation-token"))
+	maxKeys := 1000
+	if n, e := strconv.Atoi(q.Get("max-keys")); e == nil {
+		maxKeys = n
+	}
+
+	result := &listResult{Name: bucket, Prefix: prefix, MaxKeys: maxKeys}
+	seen := map[string]bool{}
+	last := ""
+
+	for _, key := range slices.Sorted(maps.Keys(s.buckets[bucket])) {
+		if !strings.HasPrefix(key, prefix) || key <= after {
+			continue
+		}
+
+		// A page that ended with a common prefix covers the keys under it.
+		if delimiter != "" && strings.HasSuffix(after, delimiter) && strings.HasPrefix(key, after) {
+			continue
+		}
+
+		common := ""
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				common = key[:len(prefix)+i+len(delimiter)]
+			}
+		}
+		if common != "" && seen[common] {
+			continue
+		}
+
+		if result.KeyCount == maxKeys {
+			result.IsTruncated = true
+			result.NextContinuationToken = last
+			break
+		}
+		result.KeyCount++
+
+		if common != "" {
+			seen[common] = true
+			last = common
+			result.CommonPrefixes = append(result.CommonPrefixes, listPrefix{common})
+			continue
+		}
+
+		obj := s.buckets[bucket][key]
+		last = key
+		result.Contents = append(result.Contents, listObject{
+			ETag:         etag(obj.Data),
+			Key:          key,
+			LastModified: obj.ModTime.UTC().Format(time.RFC3339),
+			Size:         len(obj.Data),
+		})
+	}
+
+	writeXML(w, http.StatusOK, result)
+}
+
+// postObject Put an object from a browser form, checking the content type
+// and size conditions of its policy. The signature is not checked.
+func (s *Server) postObject(w http.ResponseWriter, r *http.Request, bucket string, body []byte) {
+	_, params, e1 := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if e1 != nil {
+		writeError(w, http.StatusBadRequest, "MalformedPOSTRequest", e1.Error())
+		return
+	}
+
+	form, e2 := multipart.NewReader(bytes.NewReader(body), params["boundary"]).ReadForm(32 << 20)
+	if e2 != nil {
+		writeError(w, http.StatusBadRequest, "MalformedPOSTRequest", e2.Error())
+		return
+	}
+
+	field := func(name string) string {
+		for k, v := range form.Value {
+			if strings.EqualFold(k, name) && len(v) > 0 {
+				return v[0]
+			}
+		}
+		return ""
+	}
+
+	files := form.File["file"]
+	if len(files) != 1 {
+		writeError(w, http.StatusBadRequest, "InvalidArgument", "POST requires exactly one file upload per request.")
+		return
+	}
+
+	f, _ := files[0].Open()
+	data, _ := io.ReadAll(f)
+	_ = f.Close()
+
+	if e := checkPolicy(field("policy"), field("Content-Type"), int64(len(data))); e != nil {
+		writeError(w, http.StatusForbidden, "AccessDenied", e.Error())
+		return
+	}
+
+	key := strings.ReplaceAll(field("key"), "${filename}", files[0].Filename)
+	header := http.Header{}
+	if ct := field("Content-Type"); ct != "" {
+		header.Set("Content-Type", ct)
+	}
+
+	s.put(bucket, key, &Object{Data: data, Header: header})
+
+	w.Header().Set("ETag", etag(data))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) put(bucket, key string, obj *Object) {
+	if _, ok := s.buckets[bucket]; !ok {
+		s.buckets[bucket] = make(map[string]*Object)
+	}
+
+	obj.ModTime = s.Now().Truncate(time.Second)
+	s.buckets[bucket][key] = obj
+}
+
+func (s *Server) serveLifecycle(w http.ResponseWriter, r *http.Request, bucket string, body []byte) {
+	switch r.Method {
+	case http.MethodGet:
+		s.requests = append(s.requests, "GetBucketLifecycleConfiguration")
+		config, ok := s.lifecycle[bucket]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchLifecycleConfiguration", "The lifecycle configuration does not exist.")
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(config)
+	case http.MethodPut:
+		s.requests = append(s.requests, "PutBucketLifecycleConfiguration")
+		s.lifecycle[bucket] = body
+		w.WriteHeader(http.StatusOK)
+	default:
+		writeError(w, http.StatusNotImplemented, "NotImplemented", "s3test does not implement this operation.")
+	}
+}
+
+// readBody Read the body of a request, decoding aws-chunked encoding. The
+// trailing headers of a chunked body, such as its checksum, are added to the
+// headers of the request.
+func readBody(r *http.Request) ([]byte, error) {
+	if !strings.Contains(r.Header.Get("Content-Encoding"), "aws-chunked") &&
+		!strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+
+	br := bufio.NewReader(r.Body)
+	var data bytes.Buffer
+
+	for {
+		line, e1 := br.ReadString('\n')
+		if e1 != nil {
+			return nil, fmt.Errorf("cannot read the size of a chunk: %v", e1)
+		}
+
+		// The size may be followed by ";chunk-signature=...".
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, e2 := strconv.ParseInt(sizeHex, 16, 64)
+		if e2 != nil {
+			return nil, fmt.Errorf("invalid chunk size %q", sizeHex)
+		}
+
+		if size == 0 {
+			break
+		}
+
+		if _, e := io.CopyN(&data, br, size); e != nil {
+			return nil, fmt.Errorf("cannot read a chunk: %v", e)
+		}
+
+		if crlf, _ := br.ReadString('\n'); strings.TrimSpace(crlf) != "" {
+			return nil, fmt.Errorf("a chunk is longer than its size")
+		}
+	}
+
+	for {
+		line, e1 := br.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+
+		name, value, _ := strings.Cut(line, ":")
+		if !strings.HasPrefix(name, "x-amz-trailer-signature") {
+			r.Header.Set(name, strings.TrimSpace(value))
+		}
+
+		if e1 != nil {
+			break
+		}
+	}
+
+	return data.Bytes(), nil
+}
+
+// checkPolicy Check the Content-Type and content-length-range conditions of
+// a base64 encoded POST policy.
+func checkPolicy(encoded, contentType string, size int64) error {
+	data, e1 := base64.StdEncoding.DecodeString(encoded)
+	if e1 != nil {
+		return fmt.Errorf("invalid policy: %v", e1)
+	}
+
+	policy := &struct {
+		Conditions []any  `json:"conditions"`
+		Expiration string `json:"expiration"`
+	}{}
+	if e := json.Unmarshal(data, policy); e != nil {
+		return fmt.Errorf("invalid policy: %v", e)
+	}
+
+	if expires, e := time.Parse(time.RFC3339, policy.Expiration); e != nil || time.Now().After(expires) {
+		return fmt.Errorf("policy expired")
+	}
+
+	for _, c := range policy.Conditions {
+		switch c := c.(type) {
+		case map[string]any:
+			if want, ok := c["Content-Type"]; ok && want != contentType {
+				return fmt.Errorf("policy condition failed: Content-Type %q", contentType)
+			}
+		case []any:
+			if len(c) == 3 && c[0] == "content-length-range" {
+				low, _ := c[1].(float64)
+				high, _ := c[2].(float64)
+				if size < int64(low) || size > int64(high) {
+					return fmt.Errorf("your proposed upload size %v is outside the content-length-range", size)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// keep The headers of a put that are kept with an object.
+func keep(header http.Header, checksums bool) http.Header {
+	kept := http.Header{}
+
+	for name, values := range header {
+		if slices.Contains(keptHeaders, name) ||
+			strings.HasPrefix(name, "X-Amz-Meta-") ||
+			(checksums && slices.Contains(checksumHeaders, name)) {
+			kept[name] = values
+		}
+	}
+
+	return kept
+}
+
+// verifyChecksum Verify the CRC32 checksum of a body, the checksum the AWS
+// SDK sends by default. Other checksums are not verified.
+func verifyChecksum(header http.Header, body []byte) error {
+	want := header.Get("X-Amz-Checksum-Crc32")
+	if want == "" {
+		return nil
+	}
+
+	sum := binary.BigEndian.AppendUint32(nil, crc32.ChecksumIEEE(body))
+	if got := base64.StdEncoding.EncodeToString(sum); got != want {
+		return fmt.Errorf("the CRC32 checksum %v does not match %v", got, want)
+	}
+
+	return nil
+}
+
+func etag(data []byte) string {
+	return fmt.Sprintf(`"%x"`, md5.Sum(data))
+}
+
+func writeError(w http.ResponseWriter, code int, errorCode, message string) {
+	writeXML(w, code, &errorResult{Code: errorCode, Message: message})
+}
+
+func writeXML(w http.ResponseWriter, code int, v any) {
+	data, _ := xml.Marshal(v)
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(code)
+	_, _ = w.Write(append([]byte(xml.Header), data...))
+}
+
+type completeRequest struct {
+	Parts []struct {
+		PartNumber int
+	} `xml:"Part"`
+}
+
+type completeResult struct {
+	XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+	Bucket  string
+	ETag    string
+	Key     string
+}
+
+type errorResult struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string
+	Message string
+}
+
+type initiateResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Bucket   string
+	Key      string
+	UploadID string `xml:"UploadId"`
+}
+
+type listObject struct {
+	ETag         string
+	Key          string
+	LastModified string
+	Size         int
+}
+
+type listPrefix struct {
+	Prefix string
+}
+
+type listResult struct {
+	XMLName               xml.Name `xml:"ListBucketResult"`
+	CommonPrefixes        []listPrefix
+	Contents              []listObject
+	IsTruncated           bool
+	KeyCount              int
+	MaxKeys               int
+	Name                  string
+	NextContinuationToken string `xml:",omitempty"`
+	Prefix                string
+}
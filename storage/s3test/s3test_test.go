@@ -0,0 +1,59 @@
+package s3test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		encoding    string
+		want        string
+		wantTrailer string
+		wantErr     bool
+	}{
+		{"plain", "hello", "", "hello", "", false},
+		{
+			"chunked-trailer",
+			"5\r\nhello\r\n6\r\n world\r\n0\r\nx-amz-checksum-crc32:DUoRhQ==\r\n\r\n",
+			"aws-chunked",
+			"hello world",
+			"DUoRhQ==",
+			false,
+		},
+		{
+			"chunked-signed",
+			"5;chunk-signature=abc\r\nhello\r\n0;chunk-signature=def\r\n\r\n",
+			"aws-chunked",
+			"hello",
+			"",
+			false,
+		},
+		{"chunked-short", "a\r\nhello", "aws-chunked", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/b/k", strings.NewReader(tt.body))
+			if tt.encoding != "" {
+				r.Header.Set("Content-Encoding", tt.encoding)
+			}
+
+			got, e1 := readBody(r)
+			if (e1 != nil) != tt.wantErr {
+				t.Fatalf("readBody() error = %v, wantErr %v", e1, tt.wantErr)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("readBody() = %q, want %q", got, tt.want)
+			}
+
+			if got := r.Header.Get("X-Amz-Checksum-Crc32"); got != tt.wantTrailer {
+				t.Errorf("readBody() trailer = %q, want %q", got, tt.wantTrailer)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package sqlstore
+
+import (
+	"fmt"
+	"io/fs"
+)
+
+// NotFoundError There is no row for the key.
+type NotFoundError struct {
+	key string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf(stderr.NotFound, e.key)
+}
+
+// Unwrap Makes errors.Is(e, fs.ErrNotExist) true, as for the other storage.
+func (e *NotFoundError) Unwrap() error {
+	return fs.ErrNotExist
+}
@@ -0,0 +1,25 @@
+package sqlstore
+
+var stderr = struct {
+	DeleteExpired,
+	List,
+	Load,
+	Migrate,
+	NotFound,
+	Remove,
+	Save string
+}{
+	DeleteExpired: "cannot delete expired sessions: %v",
+	List:          "cannot list rows with prefix %v: %v",
+	Load:          "cannot load %v: %v",
+	Migrate:       "cannot apply migration %v: %v",
+	NotFound:      "%v not found",
+	Remove:        "cannot remove %v: %v",
+	Save:          "cannot save %v: %v",
+}
+
+var stdout = struct {
+	Migrated string
+}{
+	Migrated: "applied migration %v",
+}
@@ -0,0 +1,97 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/kohirens/www/session"
+)
+
+// SessionStore Session storage in the www_sessions table. The expiration of
+// each session is kept in the expires_at column, so DeleteExpired can remove
+// expired sessions with one statement.
+type SessionStore struct {
+	Context context.Context
+	db      *sql.DB
+	dialect Dialect
+}
+
+var (
+	_ session.ExpirySaver = (*SessionStore)(nil)
+	_ session.Lister      = (*SessionStore)(nil)
+	_ session.Storage     = (*SessionStore)(nil)
+)
+
+// NewSessionStore Initialize session storage on a database that Migrate was
+// run on.
+func NewSessionStore(ctx context.Context, db *sql.DB, d Dialect) *SessionStore {
+	return &SessionStore{
+		Context: ctx,
+		db:      db,
+		dialect: d,
+	}
+}
+
+// DeleteExpired Remove the sessions that expired before a time, and return
+// how many were removed. It is cheaper than a session.Reaper, which loads
+// every session, for example:
+//
+//	n, err := store.DeleteExpired(time.Now().Add(-time.Hour))
+func (ss *SessionStore) DeleteExpired(before time.Time) (int64, error) {
+	q := ss.dialect.rebind("DELETE FROM " + tableSessions + " WHERE expires_at < ?")
+
+	res, e1 := ss.db.ExecContext(contextOr(ss.Context), q, before.Unix())
+	if e1 != nil {
+		return 0, fmt.Errorf(stderr.DeleteExpired, e1.Error())
+	}
+
+	n, e2 := res.RowsAffected()
+	if e2 != nil {
+		return 0, fmt.Errorf(stderr.DeleteExpired, e2.Error())
+	}
+
+	return n, nil
+}
+
+// List The sessions in a location, so that a session.Reaper can sweep them.
+func (ss *SessionStore) List(location string) ([]string, error) {
+	return list(contextOr(ss.Context), ss.db, ss.dialect, tableSessions, "id", location)
+}
+
+// Load The session from storage.
+func (ss *SessionStore) Load(id string) ([]byte, error) {
+	return load(contextOr(ss.Context), ss.db, ss.dialect, tableSessions, "id", id)
+}
+
+// Remove A session from storage.
+func (ss *SessionStore) Remove(key string) error {
+	return remove(contextOr(ss.Context), ss.db, ss.dialect, tableSessions, "id", key)
+}
+
+// Save The session, with the expiration read from the data, see SaveUntil.
+// The expires_at column is NULL when the expiration cannot be read.
+func (ss *SessionStore) Save(id string, data []byte) error {
+	exp, _ := session.ExpirationOf(data)
+
+	return ss.SaveUntil(id, data, exp)
+}
+
+// SaveUntil The session, with the expiration in the expires_at column, or
+// NULL when the expiration is zero.
+func (ss *SessionStore) SaveUntil(id string, data []byte, expiration time.Time) error {
+	var expiresAt any
+	if !expiration.IsZero() {
+		expiresAt = expiration.Unix()
+	}
+
+	q := ss.dialect.rebind("INSERT INTO " + tableSessions + " (id, data, expires_at) VALUES (?, ?, ?)" +
+		" ON CONFLICT (id) DO UPDATE SET data = excluded.data, expires_at = excluded.expires_at")
+
+	if _, e := ss.db.ExecContext(contextOr(ss.Context), q, id, data, expiresAt); e != nil {
+		return fmt.Errorf(stderr.Save, id, e.Error())
+	}
+
+	return nil
+}
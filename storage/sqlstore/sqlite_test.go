@@ -0,0 +1,36 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// openSQLite Open a new SQLite database that Migrate was run on. The tests
+// are skipped when the driver was built without cgo.
+func openSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, e1 := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if e1 != nil {
+		t.Fatal(e1)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	if e := db.Ping(); e != nil {
+		if strings.Contains(e.Error(), "CGO_ENABLED=0") {
+			t.Skip(e.Error())
+		}
+		t.Fatal(e)
+	}
+
+	if e := Migrate(context.Background(), db, SQLite); e != nil {
+		t.Fatalf("Migrate() error = %v", e)
+	}
+
+	return db
+}
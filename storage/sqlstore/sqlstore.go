@@ -0,0 +1,156 @@
+// Package sqlstore keeps data and sessions in a SQL database through
+// database/sql, such as Postgres or SQLite. Bring your own driver, then run
+// Migrate once to create the tables:
+//
+//	db, err := sql.Open("pgx", os.Getenv("DATABASE_URL"))
+//	err = sqlstore.Migrate(ctx, db, sqlstore.Postgres)
+//	store := sqlstore.NewSessionStore(ctx, db, sqlstore.Postgres)
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kohirens/stdlib/logger"
+)
+
+// Dialect The flavor of SQL the database speaks.
+type Dialect int
+
+const (
+	// SQLite Placeholders are "?" and binary data is a BLOB.
+	SQLite Dialect = iota
+	// Postgres Placeholders are "$1", "$2", ... and binary data is a BYTEA.
+	Postgres
+)
+
+// Migration A change to the schema, applied once in order of its Version.
+type Migration struct {
+	Version int
+	Up      string
+}
+
+const (
+	tableMigrations = "www_schema_migrations"
+	tableSessions   = "www_sessions"
+	tableStorage    = "www_storage"
+)
+
+var Log = &logger.Standard{}
+
+// now Allows tests to control the clock.
+var now = time.Now
+
+// Migrations The schema of the tables, in order.
+func Migrations(d Dialect) []Migration {
+	blob := "BLOB"
+	if d == Postgres {
+		blob = "BYTEA"
+	}
+
+	return []Migration{
+		{1, "CREATE TABLE IF NOT EXISTS " + tableStorage + " (name TEXT PRIMARY KEY, data " + blob + " NOT NULL, updated_at BIGINT NOT NULL)"},
+		{2, "CREATE TABLE IF NOT EXISTS " + tableSessions + " (id TEXT PRIMARY KEY, data " + blob + " NOT NULL, expires_at BIGINT)"},
+		{3, "CREATE INDEX IF NOT EXISTS " + tableSessions + "_expires_at ON " + tableSessions + " (expires_at)"},
+	}
+}
+
+// Migrate Apply the migrations that the database does not have yet, each in
+// its own transaction. It is safe to call on every start up.
+func Migrate(ctx context.Context, db *sql.DB, d Dialect) error {
+	q := "CREATE TABLE IF NOT EXISTS " + tableMigrations + " (version INTEGER PRIMARY KEY, applied_at BIGINT NOT NULL)"
+	if _, e := db.ExecContext(ctx, q); e != nil {
+		return fmt.Errorf(stderr.Migrate, 0, e.Error())
+	}
+
+	rows, e1 := db.QueryContext(ctx, "SELECT version FROM "+tableMigrations)
+	if e1 != nil {
+		return fmt.Errorf(stderr.Migrate, 0, e1.Error())
+	}
+
+	applied := map[int]bool{}
+	for rows.Next() {
+		var v int
+		if e := rows.Scan(&v); e != nil {
+			_ = rows.Close()
+			return fmt.Errorf(stderr.Migrate, 0, e.Error())
+		}
+		applied[v] = true
+	}
+	_ = rows.Close()
+
+	for _, m := range Migrations(d) {
+		if applied[m.Version] {
+			continue
+		}
+
+		if e := apply(ctx, db, d, m); e != nil {
+			return fmt.Errorf(stderr.Migrate, m.Version, e.Error())
+		}
+
+		Log.Infof(stdout.Migrated, m.Version)
+	}
+
+	return nil
+}
+
+func apply(ctx context.Context, db *sql.DB, d Dialect, m Migration) error {
+	tx, e1 := db.BeginTx(ctx, nil)
+	if e1 != nil {
+		return e1
+	}
+
+	if _, e := tx.ExecContext(ctx, m.Up); e != nil {
+		_ = tx.Rollback()
+		return e
+	}
+
+	q := d.rebind("INSERT INTO " + tableMigrations + " (version, applied_at) VALUES (?, ?)")
+	if _, e := tx.ExecContext(ctx, q, m.Version, now().Unix()); e != nil {
+		_ = tx.Rollback()
+		return e
+	}
+
+	return tx.Commit()
+}
+
+// contextOr The context, or a background context when it is nil.
+func contextOr(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+
+	return ctx
+}
+
+// escapeLike Escape the characters that LIKE treats as a pattern, with "\"
+// as the escape character.
+func escapeLike(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s)
+}
+
+// rebind Replace the "?" placeholders of a query with the ones the dialect
+// uses.
+func (d Dialect) rebind(q string) string {
+	if d != Postgres {
+		return q
+	}
+
+	sb := strings.Builder{}
+	n := 0
+	for _, r := range q {
+		if r == '?' {
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+
+	return sb.String()
+}
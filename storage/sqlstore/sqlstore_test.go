@@ -0,0 +1,187 @@
+package sqlstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kohirens/www/session"
+	"github.com/kohirens/www/session/sessiontest"
+	"github.com/kohirens/www/storage"
+	"github.com/kohirens/www/storage/storagetest"
+)
+
+func TestDialect_rebind(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		q       string
+		want    string
+	}{
+		{"sqlite", SQLite, "SELECT a FROM t WHERE b = ? AND c = ?", "SELECT a FROM t WHERE b = ? AND c = ?"},
+		{"postgres", Postgres, "SELECT a FROM t WHERE b = ? AND c = ?", "SELECT a FROM t WHERE b = $1 AND c = $2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.dialect.rebind(tt.q); got != tt.want {
+				t.Errorf("rebind() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	db := openSQLite(t)
+	ctx := context.Background()
+
+	// A second run applies nothing new.
+	if e := Migrate(ctx, db, SQLite); e != nil {
+		t.Fatalf("Migrate() error = %v", e)
+	}
+
+	var applied int
+	if e := db.QueryRow("SELECT COUNT(*) FROM " + tableMigrations).Scan(&applied); e != nil {
+		t.Fatal(e)
+	}
+	if want := len(Migrations(SQLite)); applied != want {
+		t.Errorf("Migrate() applied %v migrations, want %v", applied, want)
+	}
+
+	for _, name := range []string{tableSessions, tableStorage, tableSessions + "_expires_at"} {
+		var n int
+		_ = db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = ?", name).Scan(&n)
+		if n != 1 {
+			t.Errorf("Migrate() did not create %v", name)
+		}
+	}
+
+	for _, m := range Migrations(Postgres)[:2] {
+		if !strings.Contains(m.Up, "BYTEA") {
+			t.Errorf("Migrations() did not use the Postgres types: %v", m.Up)
+		}
+	}
+}
+
+func TestStorage(t *testing.T) {
+	s := NewStorage(context.Background(), openSQLite(t), SQLite)
+
+	for _, name := range []string{"list/a.txt", "list/b.txt", "list/sub/c.txt", "list_x/d.txt"} {
+		if e := s.Save(name, []byte(name)); e != nil {
+			t.Fatalf("Save() error = %v", e)
+		}
+	}
+
+	if !s.Exist("list/a.txt") || s.Exist("missing.txt") {
+		t.Errorf("Exist() did not match what was saved")
+	}
+
+	got, e1 := s.Load("list/b.txt")
+	if e1 != nil || string(got) != "list/b.txt" {
+		t.Errorf("Load() = %s, %v, want list/b.txt", got, e1)
+	}
+
+	names, e2 := s.List("list")
+	if e2 != nil {
+		t.Fatalf("List() error = %v", e2)
+	}
+	if strings.Join(names, ",") != "a.txt,b.txt" {
+		t.Errorf("List() = %v, want [a.txt b.txt]", names)
+	}
+
+	if e := s.Remove("list/a.txt"); e != nil {
+		t.Fatalf("Remove() error = %v", e)
+	}
+
+	var nf *NotFoundError
+	if _, e := s.Load("list/a.txt"); !errors.As(e, &nf) {
+		t.Errorf("Load() after Remove() error = %v, want a NotFoundError", e)
+	}
+}
+
+func TestSessionStore(t *testing.T) {
+	db := openSQLite(t)
+	ss := NewSessionStore(context.Background(), db, SQLite)
+
+	clock := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	saved := func(exp time.Time) []byte {
+		b, _ := json.Marshal(&session.Data{Expiration: exp})
+		return b
+	}
+
+	_ = ss.Save("sessions/old.json", saved(clock.Add(-time.Hour)))
+	_ = ss.Save("sessions/new.json", saved(clock.Add(time.Hour)))
+	_ = ss.SaveUntil("sessions/encrypted.json", []byte{1, 2, 3}, clock.Add(-time.Minute))
+	_ = ss.Save("sessions/unreadable.json", []byte{1, 2, 3})
+
+	expiresAt := func(id string) any {
+		var v any
+		_ = db.QueryRow("SELECT expires_at FROM "+tableSessions+" WHERE id = ?", id).Scan(&v)
+		return v
+	}
+
+	if got := expiresAt("sessions/new.json"); got != clock.Add(time.Hour).Unix() {
+		t.Errorf("Save() expires_at = %v, want %v", got, clock.Add(time.Hour).Unix())
+	}
+
+	if got := expiresAt("sessions/encrypted.json"); got != clock.Add(-time.Minute).Unix() {
+		t.Errorf("SaveUntil() expires_at = %v, want %v", got, clock.Add(-time.Minute).Unix())
+	}
+
+	if got := expiresAt("sessions/unreadable.json"); got != nil {
+		t.Errorf("Save() expires_at = %v, want NULL", got)
+	}
+
+	// Saving again updates the row.
+	_ = ss.Save("sessions/new.json", saved(clock.Add(2*time.Hour)))
+	if got := expiresAt("sessions/new.json"); got != clock.Add(2*time.Hour).Unix() {
+		t.Errorf("Save() expires_at = %v, want %v", got, clock.Add(2*time.Hour).Unix())
+	}
+
+	names, _ := ss.List("sessions")
+	if len(names) != 4 {
+		t.Errorf("List() = %v, want 4 sessions", names)
+	}
+
+	n, e1 := ss.DeleteExpired(clock)
+	if e1 != nil {
+		t.Fatalf("DeleteExpired() error = %v", e1)
+	}
+	if n != 2 {
+		t.Errorf("DeleteExpired() = %v, want 2", n)
+	}
+
+	for _, id := range []string{"sessions/old.json", "sessions/encrypted.json"} {
+		if _, e := ss.Load(id); e == nil {
+			t.Errorf("Load() of expired session %v after DeleteExpired() did not fail", id)
+		}
+	}
+
+	sm := session.NewManager(ss, "sessions", time.Hour)
+	sm.Set("k", []byte("v"))
+	if e := sm.Save(); e != nil {
+		t.Fatalf("Save() error = %v", e)
+	}
+
+	sm2 := session.NewManager(ss, "sessions", time.Hour)
+	if e := sm2.Restore(sm.ID().String()); e != nil {
+		t.Fatalf("Restore() error = %v", e)
+	}
+	if got := string(sm2.Get("k")); got != "v" {
+		t.Errorf("Get() = %v, want v", got)
+	}
+}
+
+func TestSessionStore_Conformance(t *testing.T) {
+	sessiontest.Run(t, func(t *testing.T) session.Storage {
+		return NewSessionStore(context.Background(), openSQLite(t), SQLite)
+	})
+}
+
+func TestStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return NewStorage(context.Background(), openSQLite(t), SQLite)
+	})
+}
@@ -0,0 +1,134 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kohirens/www/storage"
+)
+
+// Storage General purpose storage in the www_storage table.
+type Storage struct {
+	Context context.Context
+	db      *sql.DB
+	dialect Dialect
+}
+
+var _ storage.Storage = (*Storage)(nil)
+
+// NewStorage Initialize storage on a database that Migrate was run on.
+func NewStorage(ctx context.Context, db *sql.DB, d Dialect) *Storage {
+	return &Storage{
+		Context: ctx,
+		db:      db,
+		dialect: d,
+	}
+}
+
+// Exist Verify the name is in storage.
+func (s *Storage) Exist(name string) bool {
+	var one int
+	q := s.dialect.rebind("SELECT 1 FROM " + tableStorage + " WHERE name = ?")
+
+	e1 := s.db.QueryRowContext(contextOr(s.Context), q, name).Scan(&one)
+	if e1 != nil && !errors.Is(e1, sql.ErrNoRows) {
+		Log.Errf(stderr.Load, name, e1.Error())
+	}
+
+	return e1 == nil
+}
+
+// List The names in a location, relative to it. It is not recursive, names
+// in sub-locations are left out.
+func (s *Storage) List(location string) ([]string, error) {
+	return list(contextOr(s.Context), s.db, s.dialect, tableStorage, "name", location)
+}
+
+// Load Retrieve data from storage.
+func (s *Storage) Load(filename string) ([]byte, error) {
+	return load(contextOr(s.Context), s.db, s.dialect, tableStorage, "name", filename)
+}
+
+// Location The name in storage.
+func (s *Storage) Location(filename string) string {
+	return filename
+}
+
+// Remove Delete data from storage.
+func (s *Storage) Remove(filename string) error {
+	return remove(contextOr(s.Context), s.db, s.dialect, tableStorage, "name", filename)
+}
+
+// Save Write data to storage, replacing what is there.
+func (s *Storage) Save(filename string, data []byte) error {
+	q := s.dialect.rebind("INSERT INTO " + tableStorage + " (name, data, updated_at) VALUES (?, ?, ?)" +
+		" ON CONFLICT (name) DO UPDATE SET data = excluded.data, updated_at = excluded.updated_at")
+
+	if _, e := s.db.ExecContext(contextOr(s.Context), q, filename, data, now().Unix()); e != nil {
+		return fmt.Errorf(stderr.Save, filename, e.Error())
+	}
+
+	return nil
+}
+
+func list(ctx context.Context, db *sql.DB, d Dialect, table, column, location string) ([]string, error) {
+	prefix := location
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	q := d.rebind("SELECT " + column + " FROM " + table + " WHERE " + column + ` LIKE ? ESCAPE '\' ORDER BY ` + column)
+
+	rows, e1 := db.QueryContext(ctx, q, escapeLike(prefix)+"%")
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.List, prefix, e1.Error())
+	}
+	defer rows.Close()
+
+	names := make([]string, 0)
+	for rows.Next() {
+		var name string
+		if e := rows.Scan(&name); e != nil {
+			return nil, fmt.Errorf(stderr.List, prefix, e.Error())
+		}
+
+		name = strings.TrimPrefix(name, prefix)
+		if name != "" && !strings.Contains(name, "/") {
+			names = append(names, name)
+		}
+	}
+
+	if e := rows.Err(); e != nil {
+		return nil, fmt.Errorf(stderr.List, prefix, e.Error())
+	}
+
+	return names, nil
+}
+
+func load(ctx context.Context, db *sql.DB, d Dialect, table, column, key string) ([]byte, error) {
+	var data []byte
+	q := d.rebind("SELECT data FROM " + table + " WHERE " + column + " = ?")
+
+	e1 := db.QueryRowContext(ctx, q, key).Scan(&data)
+	if errors.Is(e1, sql.ErrNoRows) {
+		return nil, &NotFoundError{key}
+	}
+	if e1 != nil {
+		return nil, fmt.Errorf(stderr.Load, key, e1.Error())
+	}
+
+	return data, nil
+}
+
+func remove(ctx context.Context, db *sql.DB, d Dialect, table, column, key string) error {
+	q := d.rebind("DELETE FROM " + table + " WHERE " + column + " = ?")
+
+	if _, e := db.ExecContext(ctx, q, key); e != nil {
+		return fmt.Errorf(stderr.Remove, key, e.Error())
+	}
+
+	return nil
+}
@@ -1,25 +1,23 @@
 package storage
 
-import "github.com/kohirens/stdlib/logger"
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/kohirens/stdlib/logger"
+)
 
 // Storage Save data for long term.
 type Storage interface {
 	// Exist Verification the file is in storage.
 	Exist(name string) bool
-	// New method Storage.List will list files in a directory.
-
 	// List Files in a location in storage. It is not recursive, it only lists
-	// files in the specified directory.
-	//
-	//	There is special care that must be taken with S3. It requires a call to
-	//	ListObjectsV2, which requires parameters to be set. So there is a
-	//	prerequisite to call BucketStorage.SetRequestParams before calling
-	//	List on S3 storage. If you need to call BucketStorage.List multiple
-	//	times, care MUST be taken to call BucketStorage.SetRequestParams should
-	//	the parameters need to change. For that reason, once BucketStorage.List
-	//	is called, the request parameters are reset to nil.
+	// files in the specified directory. See PagedStorage and Names for large
+	// locations and recursive listing.
 	List(location string) ([]string, error)
-	// Load Retrieve data from storage.
+	// Load Retrieve data from storage. The error wraps fs.ErrNotExist when
+	// there is no such data.
 	Load(filename string) ([]byte, error)
 	// Location Get the location in storage. This does not check for existence.
 	Location(filename string) string
@@ -29,4 +27,77 @@ type Storage interface {
 	Remove(filename string) error
 }
 
+// ContextStorage An optional extension of Storage with methods that take a
+// context, so that a deadline, such as that of a Lambda function, or a client
+// that goes away stops the work. LocalStorage and BucketStorage implement it.
+type ContextStorage interface {
+	ExistContext(ctx context.Context, name string) bool
+	ListContext(ctx context.Context, location string) ([]string, error)
+	LoadContext(ctx context.Context, name string) ([]byte, error)
+	RemoveContext(ctx context.Context, name string) error
+	SaveContext(ctx context.Context, name string, data []byte) error
+}
+
+// PagedStorage An optional extension of Storage that lists a location a page
+// at a time. LocalStorage and BucketStorage implement it, see Names for an
+// iterator over all the pages.
+type PagedStorage interface {
+	// ListPage A page of the names in a location, opts can be nil.
+	ListPage(ctx context.Context, location string, opts *ListOptions) (*ListPage, error)
+}
+
+// StreamStorage An optional extension of Storage that reads and writes data
+// as streams, so that large objects do not have to fit in memory.
+// LocalStorage and BucketStorage implement it.
+type StreamStorage interface {
+	// Create A writer that saves data to storage. Errors are returned by
+	// Write and Close, and the data is only saved when Close returns nil. A
+	// writer closed after its context is done saves nothing.
+	Create(ctx context.Context, name string) io.WriteCloser
+	// Open A reader of data in storage, which the caller must close.
+	Open(ctx context.Context, name string) (io.ReadCloser, Info, error)
+}
+
+// MetadataStorage An optional extension of Storage that describes data
+// without loading it, and saves data with a content type, cache control and
+// user metadata. LocalStorage and BucketStorage implement it.
+type MetadataStorage interface {
+	// SaveWithOptions Save data with options, which can be nil. The
+	// metadata of data saved before is replaced, so the data saved by Save
+	// has none.
+	SaveWithOptions(ctx context.Context, name string, data []byte, opts *SaveOptions) error
+	// Stat Describe data in storage. The error wraps fs.ErrNotExist when
+	// there is no such data.
+	Stat(ctx context.Context, name string) (Info, error)
+}
+
+// Info Describes data in storage.
+type Info struct {
+	CacheControl string
+	// Checksum Of the data, the algorithm depends on the storage. It is
+	// empty when the storage does not know it.
+	Checksum    string
+	ContentType string
+	// ETag Changes when the data changes, it is quoted so that it can be
+	// used as is in an HTTP ETag header.
+	ETag string
+	// Metadata Set by the user when the data was saved.
+	Metadata map[string]string
+	// ModTime When the data was last saved.
+	ModTime time.Time
+	Name    string
+	Size    int64
+}
+
+// SaveOptions Describe data as it is saved, see MetadataStorage.
+type SaveOptions struct {
+	// CacheControl The value of an HTTP Cache-Control header, for when the
+	// data is served.
+	CacheControl string `json:"cache_control,omitempty"`
+	ContentType  string `json:"content_type,omitempty"`
+	// Metadata Pairs kept with the data. S3 limits them to 2 KB in total
+	// and changes the keys to lower case.
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
 var Log = &logger.Standard{}
@@ -0,0 +1,117 @@
+// Package storagetest checks that an implementation of storage.Storage meets
+// the contract of the interface, so that it can stand in for any other
+// storage. Call Run from a test of the implementation:
+//
+//	func TestConformance(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) storage.Storage {
+//			return NewMyStorage(t.TempDir())
+//		})
+//	}
+package storagetest
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"slices"
+	"testing"
+
+	"github.com/kohirens/www/storage"
+)
+
+// Location Where Run saves data. Storage that needs a location to be made
+// before saving to it, such as storage.LocalStorage, must have it made by
+// the function passed to Run.
+const Location = "storagetest"
+
+// Run The conformance tests, each on storage returned by newStorage. The
+// storage may hold other data, but not in Location.
+func Run(t *testing.T, newStorage func(t *testing.T) storage.Storage) {
+	t.Helper()
+
+	name := Location + "/a.txt"
+	data := []byte("storagetest\x00\xff")
+
+	t.Run("save-load", func(t *testing.T) {
+		s := newStorage(t)
+
+		if e := s.Save(name, data); e != nil {
+			t.Fatalf("Save() error = %v", e)
+		}
+
+		got, e1 := s.Load(name)
+		if e1 != nil {
+			t.Fatalf("Load() error = %v", e1)
+		}
+
+		if !bytes.Equal(got, data) {
+			t.Errorf("Load() = %q, want %q", got, data)
+		}
+
+		if !s.Exist(name) {
+			t.Errorf("Exist() = false, want true")
+		}
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		s := newStorage(t)
+
+		_ = s.Save(name, []byte("first"))
+		if e := s.Save(name, []byte("second")); e != nil {
+			t.Fatalf("Save() error = %v", e)
+		}
+
+		got, _ := s.Load(name)
+		if string(got) != "second" {
+			t.Errorf("Load() = %q, want %q", got, "second")
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		s := newStorage(t)
+		missing := Location + "/missing.txt"
+
+		if _, e := s.Load(missing); !errors.Is(e, fs.ErrNotExist) {
+			t.Errorf("Load() of missing data error = %v, want fs.ErrNotExist", e)
+		}
+
+		if s.Exist(missing) {
+			t.Errorf("Exist() of missing data = true, want false")
+		}
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		s := newStorage(t)
+
+		_ = s.Save(name, data)
+		if e := s.Remove(name); e != nil {
+			t.Fatalf("Remove() error = %v", e)
+		}
+
+		if s.Exist(name) {
+			t.Errorf("Exist() after Remove() = true, want false")
+		}
+
+		if _, e := s.Load(name); e == nil {
+			t.Errorf("Load() after Remove() did not fail")
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		s := newStorage(t)
+
+		_ = s.Save(Location+"/a.txt", data)
+		_ = s.Save(Location+"/b.txt", data)
+
+		got, e1 := s.List(Location)
+		if e1 != nil {
+			t.Fatalf("List() error = %v", e1)
+		}
+
+		for _, want := range []string{"a.txt", "b.txt"} {
+			if !slices.Contains(got, want) {
+				t.Errorf("List() = %v, want it to contain %v", got, want)
+			}
+		}
+	})
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+)
+
+// Create A writer that saves data to storage, streamed when the storage is a
+// StreamStorage. Otherwise, the data is kept in memory and saved on Close.
+func Create(ctx context.Context, s Storage, name string) io.WriteCloser {
+	if ss, ok := s.(StreamStorage); ok {
+		return ss.Create(ctx, name)
+	}
+
+	return &bufferWriter{ctx: ctx, name: name, storage: s}
+}
+
+// Open A reader of data in storage, streamed when the storage is a
+// StreamStorage. Otherwise, the data is loaded into memory.
+func Open(ctx context.Context, s Storage, name string) (io.ReadCloser, Info, error) {
+	if ss, ok := s.(StreamStorage); ok {
+		return ss.Open(ctx, name)
+	}
+
+	data, e1 := LoadContext(ctx, s, name)
+	if e1 != nil {
+		return nil, Info{}, e1
+	}
+
+	return io.NopCloser(bytes.NewReader(data)), Info{Name: name, Size: int64(len(data))}, nil
+}
+
+// LoadContext Load data with the context when the storage is a
+// ContextStorage. Otherwise, the context is only checked before loading.
+func LoadContext(ctx context.Context, s Storage, name string) ([]byte, error) {
+	if cs, ok := s.(ContextStorage); ok {
+		return cs.LoadContext(ctx, name)
+	}
+
+	if e := ctx.Err(); e != nil {
+		return nil, e
+	}
+
+	return s.Load(name)
+}
+
+// SaveContext Save data with the context when the storage is a
+// ContextStorage. Otherwise, the context is only checked before saving.
+func SaveContext(ctx context.Context, s Storage, name string, data []byte) error {
+	if cs, ok := s.(ContextStorage); ok {
+		return cs.SaveContext(ctx, name, data)
+	}
+
+	if e := ctx.Err(); e != nil {
+		return e
+	}
+
+	return s.Save(name, data)
+}
+
+// bufferWriter Keeps data in memory and saves it on Close.
+type bufferWriter struct {
+	buf     bytes.Buffer
+	closed  bool
+	ctx     context.Context
+	name    string
+	storage Storage
+}
+
+func (w *bufferWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errClosed
+	}
+
+	if e := w.ctx.Err(); e != nil {
+		return 0, e
+	}
+
+	return w.buf.Write(p)
+}
+
+func (w *bufferWriter) Close() error {
+	if w.closed {
+		return errClosed
+	}
+	w.closed = true
+
+	return SaveContext(w.ctx, w.storage, w.name, w.buf.Bytes())
+}
+
+// contextReader Stops reading once its context is done.
+type contextReader struct {
+	ctx context.Context
+	io.ReadCloser
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if e := r.ctx.Err(); e != nil {
+		return 0, e
+	}
+
+	return r.ReadCloser.Read(p)
+}
+
+// errWriter A writer that only returns an error, for a Create that failed.
+type errWriter struct {
+	err error
+}
+
+func (w *errWriter) Write(p []byte) (int, error) {
+	return 0, w.err
+}
+
+func (w *errWriter) Close() error {
+	return w.err
+}
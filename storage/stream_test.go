@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestStream(t *testing.T) {
+	local, _ := NewLocalStorage(t.TempDir())
+
+	tests := []struct {
+		name    string
+		storage Storage
+	}{
+		{"local", local},
+		{"memory", NewMemoryStorage(0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			w := Create(ctx, tt.storage, "a.txt")
+			_, _ = io.WriteString(w, "hello ")
+			_, _ = io.WriteString(w, "world")
+
+			if tt.storage.Exist("a.txt") {
+				t.Errorf("Create() saved the data before Close()")
+			}
+
+			if e := w.Close(); e != nil {
+				t.Fatalf("Close() error = %v", e)
+			}
+
+			if _, e := w.Write([]byte("x")); e == nil {
+				t.Errorf("Write() after Close() did not fail")
+			}
+
+			r, info, e1 := Open(ctx, tt.storage, "a.txt")
+			if e1 != nil {
+				t.Fatalf("Open() error = %v", e1)
+			}
+			defer func() { _ = r.Close() }()
+
+			got, _ := io.ReadAll(r)
+			if string(got) != "hello world" || info.Size != 11 {
+				t.Errorf("Open() = %q, %+v, want hello world of size 11", got, info)
+			}
+		})
+	}
+}
+
+func TestStream_Canceled(t *testing.T) {
+	local, _ := NewLocalStorage(t.TempDir())
+
+	tests := []struct {
+		name    string
+		storage Storage
+	}{
+		{"local", local},
+		{"memory", NewMemoryStorage(0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+
+			w := Create(ctx, tt.storage, "a.txt")
+			_, _ = io.WriteString(w, "hello")
+			cancel()
+
+			if e := w.Close(); !errors.Is(e, context.Canceled) {
+				t.Errorf("Close() error = %v, want context.Canceled", e)
+			}
+
+			if tt.storage.Exist("a.txt") {
+				t.Errorf("Close() after cancel saved the data")
+			}
+
+			if _, _, e := Open(ctx, tt.storage, "a.txt"); e == nil {
+				t.Errorf("Open() with a canceled context did not fail")
+			}
+		})
+	}
+}